@@ -1,6 +1,8 @@
 package gitspork
 
 import (
+	"io"
+
 	"github.com/rockholla/gitspork/v2/internal/drift"
 	"github.com/rockholla/gitspork/v2/internal/integrate"
 	"github.com/rockholla/gitspork/v2/internal/sdktypes"
@@ -60,6 +62,10 @@ type DriftReport = sdktypes.DriftReport
 // DriftedFile is a single entry in a DriftReport.
 type DriftedFile = sdktypes.DriftedFile
 
+// IgnoredDriftedFile is a drifted file accepted by a rule in the downstream's
+// .gitspork/drift-ignore.yml, listed in DriftReport.Ignored.
+type IgnoredDriftedFile = sdktypes.IgnoredDriftedFile
+
 // DownstreamState is the on-disk state stored at
 // .gitspork/downstream-state.json in the downstream repo. It records each
 // integrated upstream so subsequent runs (integrate, check-drift) can locate
@@ -69,6 +75,114 @@ type DownstreamState = sdktypes.DownstreamState
 // UpstreamState records the last integration for a single upstream.
 type UpstreamState = sdktypes.UpstreamState
 
+// MigrationRecord records a migration's last run in the downstream, in
+// DownstreamState.MigrationsComplete.
+type MigrationRecord = sdktypes.MigrationRecord
+
+// MigrationTrustDecision records whether an upstream may run migration
+// commands in the downstream, in DownstreamState.MigrationTrust.
+type MigrationTrustDecision = sdktypes.MigrationTrustDecision
+
+// MigrationStatusOptions configures a call to MigrationStatus. Leave
+// Upstreams empty to use the upstreams recorded in the downstream state.
+type MigrationStatusOptions = sdktypes.MigrationStatusOptions
+
+// MigrationStatusReport is the structural return value of MigrationStatus.
+type MigrationStatusReport = sdktypes.MigrationStatusReport
+
+// MigrationStatusEntry is a single migration instruction in a MigrationStatusReport.
+type MigrationStatusEntry = sdktypes.MigrationStatusEntry
+
+// OutdatedOptions configures a call to Outdated. Leave Upstreams empty to
+// check the upstreams recorded in the downstream state.
+type OutdatedOptions = sdktypes.OutdatedOptions
+
+// OutdatedReport is the structural return value of Outdated.
+type OutdatedReport = sdktypes.OutdatedReport
+
+// OutdatedUpstream is a single upstream in an OutdatedReport.
+type OutdatedUpstream = sdktypes.OutdatedUpstream
+
+// ScanOptions configures a call to Scan.
+type ScanOptions = sdktypes.ScanOptions
+
+// ScanReport is the structural return value of Scan.
+type ScanReport = sdktypes.ScanReport
+
+// ScanRepoResult is the drift check of a single downstream in a ScanReport.
+type ScanRepoResult = sdktypes.ScanRepoResult
+
+// Outcomes of a MigrationRecord, and the Status of a MigrationStatusEntry, which is
+// one of these or MigrationStatusPending.
+const (
+	MigrationOutcomeRan           = sdktypes.MigrationOutcomeRan
+	MigrationOutcomeNotApplicable = sdktypes.MigrationOutcomeNotApplicable
+	MigrationOutcomeSkipped       = sdktypes.MigrationOutcomeSkipped
+	MigrationOutcomeFailed        = sdktypes.MigrationOutcomeFailed
+	MigrationStatusPending        = sdktypes.MigrationStatusPending
+)
+
+// Values of OutdatedUpstream.Track.
+const (
+	OutdatedTrackBranch = sdktypes.OutdatedTrackBranch
+	OutdatedTrackTag    = sdktypes.OutdatedTrackTag
+	OutdatedTrackPinned = sdktypes.OutdatedTrackPinned
+)
+
+// Values of ScanRepoResult.Status.
+const (
+	ScanStatusClean = sdktypes.ScanStatusClean
+	ScanStatusDrift = sdktypes.ScanStatusDrift
+	ScanStatusError = sdktypes.ScanStatusError
+)
+
+// Formats WriteScanReport renders a ScanReport in.
+const (
+	ScanReportFormatTable = drift.ScanFormatTable
+	ScanReportFormatJSON  = drift.FormatJSON
+)
+
+// Values of CheckDriftOptions.Migrations.
+const (
+	DriftMigrationsSkip     = sdktypes.DriftMigrationsSkip
+	DriftMigrationsSimulate = sdktypes.DriftMigrationsSimulate
+	DriftMigrationsSandbox  = sdktypes.DriftMigrationsSandbox
+)
+
+// Values of DriftedFile.Change, describing the downstream file relative to
+// what the re-integration produces.
+const (
+	DriftChangeModified = sdktypes.DriftChangeModified
+	DriftChangeDeleted  = sdktypes.DriftChangeDeleted
+	DriftChangeAdded    = sdktypes.DriftChangeAdded
+	DriftChangeMode     = sdktypes.DriftChangeMode
+)
+
+// Values of DriftedFile.Ownership, named after the .gitspork.yml lists that
+// manage a file.
+const (
+	OwnershipUpstreamOwned              = sdktypes.OwnershipUpstreamOwned
+	OwnershipDownstreamOwned            = sdktypes.OwnershipDownstreamOwned
+	OwnershipMerged                     = sdktypes.OwnershipMerged
+	OwnershipStructuredPreferUpstream   = sdktypes.OwnershipStructuredPreferUpstream
+	OwnershipStructuredPreferDownstream = sdktypes.OwnershipStructuredPreferDownstream
+	OwnershipTemplated                  = sdktypes.OwnershipTemplated
+)
+
+// Formats WriteDriftReport renders a DriftReport in.
+const (
+	DriftReportFormatJSON     = drift.FormatJSON
+	DriftReportFormatSARIF    = drift.FormatSARIF
+	DriftReportFormatJUnit    = drift.FormatJUnit
+	DriftReportFormatMarkdown = drift.FormatMarkdown
+)
+
+// Decisions of a MigrationTrustDecision.
+const (
+	MigrationTrustAllow = sdktypes.MigrationTrustAllow
+	MigrationTrustDeny  = sdktypes.MigrationTrustDeny
+)
+
 // Logger is the small interface gitspork uses for narration/progress and
 // error messages. It is deliberately narrow so SDK consumers can wire their
 // own logging (slog, zap, log/logr) with minimal glue. A nil Logger means
@@ -92,11 +206,39 @@ var ErrGitBinaryMissing = sdktypes.ErrGitBinaryMissing
 // distinguish this from other errors via errors.Is(err, ErrSelfIntegration).
 var ErrSelfIntegration = sdktypes.ErrSelfIntegration
 
+// ErrMissingInputs is matched by the error Integrate and IntegrateLocal return
+// when NonInteractive is set and templated inputs have no value. Use
+// errors.As with *MissingInputsError to get the list of missing inputs.
+var ErrMissingInputs = sdktypes.ErrMissingInputs
+
+// MissingInputsError lists every templated input that had no value during a
+// non-interactive integrate.
+type MissingInputsError = sdktypes.MissingInputsError
+
+// MissingInput identifies a single templated input in a MissingInputsError.
+type MissingInput = sdktypes.MissingInput
+
+// Prompter collects answers for templated prompt inputs. Set it on
+// IntegrateOptions or IntegrateLocalOptions to gather answers through your
+// own UI instead of the terminal.
+type Prompter = sdktypes.Prompter
+
+// PromptRequest describes a single templated input value a Prompter is asked for.
+type PromptRequest = sdktypes.PromptRequest
+
+// PromptFunc adapts an ordinary function to the Prompter interface.
+type PromptFunc = sdktypes.PromptFunc
+
 // NoopLogger returns a Logger implementation that discards all messages.
 // SDK consumers can pass this (or nil, which is treated equivalently by the
 // coordinator entry-points) to silence gitspork output.
 func NoopLogger() Logger { return sdktypes.NoopLogger() }
 
+// TerminalPrompter returns the default Prompter, which asks on stdin. It is
+// used whenever the Prompter option is nil, and is exported so custom
+// Prompters can fall back to it.
+func TerminalPrompter() Prompter { return integrate.TerminalPrompter() }
+
 // Integrate integrates one or more upstream repos into the downstream at
 // opts.DownstreamRepoPath. See IntegrateOptions for configuration. On partial
 // failure the returned *IntegrateResult still contains the upstreams that
@@ -114,8 +256,45 @@ func IntegrateLocal(opts *IntegrateLocalOptions) (*IntegrateResult, error) {
 
 // CheckDrift re-runs each recorded upstream's integration at its pinned
 // commit hash in an isolated copy of the downstream and reports any files
-// that differ from the current downstream HEAD. Returns a populated
+// that differ from the current downstream HEAD, or from its working tree when
+// WorkingTree is set. Returns a populated
 // *DriftReport alongside ErrDriftDetected when drift is found.
 func CheckDrift(opts *CheckDriftOptions) (*DriftReport, error) {
 	return drift.CheckDrift(opts)
 }
+
+// WriteDriftReport renders report to w as JSON, SARIF 2.1.0, JUnit XML or
+// Markdown, the same output as `gitspork check-drift --format`.
+func WriteDriftReport(w io.Writer, report *DriftReport, format string) error {
+	return drift.WriteReport(w, report, format)
+}
+
+// Outdated compares each upstream recorded in the downstream at
+// opts.DownstreamRepoPath with the latest commit on its requested branch, or
+// its newest semver tag, and reports how far behind the recorded commit is.
+// With opts.Files, it also lists the managed files updating would change.
+// Nothing is integrated.
+func Outdated(opts *OutdatedOptions) (*OutdatedReport, error) {
+	return drift.Outdated(opts)
+}
+
+// Scan runs CheckDrift in each downstream opts lists, by path, path pattern
+// or manifest, up to opts.Concurrency at once, sharing the upstream mirror
+// cache. A downstream whose check fails is reported in the *ScanReport
+// rather than failing the scan.
+func Scan(opts *ScanOptions) (*ScanReport, error) {
+	return drift.Scan(opts)
+}
+
+// WriteScanReport renders report to w as a summary table or JSON, the same
+// output as `gitspork scan --format`.
+func WriteScanReport(w io.Writer, report *ScanReport, format string) error {
+	return drift.WriteScanReport(w, report, format)
+}
+
+// MigrationStatus clones each upstream, as Integrate would, and reports every
+// migration it defines alongside what the downstream at
+// opts.DownstreamRepoPath has recorded of it. Nothing is integrated.
+func MigrationStatus(opts *MigrationStatusOptions) (*MigrationStatusReport, error) {
+	return integrate.MigrationStatus(opts)
+}
@@ -4,12 +4,16 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 
+	"github.com/rockholla/gitspork/v2/internal/config"
 	"github.com/rockholla/gitspork/v2/internal/drift"
+	"github.com/rockholla/gitspork/v2/internal/logutil"
 	"github.com/rockholla/gitspork/v2/internal/sdktypes"
 )
 
@@ -17,9 +21,14 @@ const (
 	checkDriftHelpShort string = "check if a downstream repo has drifted from its last integrated upstream state"
 	checkDriftHelpLong  string = `check-drift re-runs the integration at the exact upstream commit hash used in the last
 integrate run, against an isolated copy of the downstream repo, and reports any differences.
+It checks HEAD, refusing to run on a dirty working tree, unless --working-tree is given, which
+copies uncommitted changes into the isolated copy and checks the working tree as it is on disk.
+With --fix, it restores the drifted files in the downstream working tree to what those same
+commits produce, leaving the changes to review and commit; --patch-out writes the same changes
+as a patch for 'git apply' instead. --path limits either to matching files.
 
 Exit codes:
-  0 - no drift detected
+  0 - no drift detected, or --fix restored every drifted file
   1 - error (missing state, unclean working tree, clone failure, etc.)
   2 - drift detected
   3 - self-integration blocked (upstream and downstream identify the same repo)
@@ -27,6 +36,10 @@ Exit codes:
 See https://github.com/rockholla/gitspork/docs for more info.`
 )
 
+// checkDriftFormatText is the default --format: the human-readable log lines
+// and, with --verbose, diffs.
+const checkDriftFormatText = "text"
+
 // CheckDriftSubcommand represents the subcommand and all related functionality for 'gitspork check-drift'
 type CheckDriftSubcommand struct{}
 
@@ -37,6 +50,14 @@ func (cds *CheckDriftSubcommand) GetCmd() *cobra.Command {
 	var verbose bool
 	var cacheTTL time.Duration
 	var noCache bool
+	var migrations string
+	var format string
+	var workingTree bool
+	var fix bool
+	var patchOut string
+	var fixPaths []string
+	var inputFlags []string
+	var inputsFile string
 
 	var cmd = &cobra.Command{
 		Use:   "check-drift",
@@ -48,11 +69,37 @@ func (cds *CheckDriftSubcommand) GetCmd() *cobra.Command {
 		SilenceUsage:  true,
 		SilenceErrors: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			// Machine-readable formats own stdout, so progress goes to stderr.
+			driftLogger := logger
+			if format != checkDriftFormatText {
+				if !slices.Contains(drift.Formats, format) {
+					return fmt.Errorf("invalid --format %q: expects %s or %s", format, checkDriftFormatText, strings.Join(drift.Formats, ", "))
+				}
+				driftLogger = logutil.NewStderr()
+			}
+			inputs, err := ParseInputFlags(inputsFile, inputFlags)
+			if err != nil {
+				return err
+			}
 			opts := &sdktypes.CheckDriftOptions{
-				Logger:             logger,
+				Logger:             driftLogger,
 				DownstreamRepoPath: downstreamRepoPath,
+				Inputs:             inputs,
 				CacheTTL:           cacheTTL,
 				NoCache:            noCache,
+				Migrations:         migrations,
+				WorkingTree:        workingTree,
+				Fix:                fix,
+				FixPaths:           fixPaths,
+				Context:            cmd.Context(),
+			}
+			if patchOut != "" {
+				patchFile, err := os.Create(patchOut)
+				if err != nil {
+					return fmt.Errorf("error creating patch file: %v", err)
+				}
+				defer patchFile.Close()
+				opts.PatchOut = patchFile
 			}
 			for _, f := range upstreamFlags {
 				spec, err := ParseUpstreamFlag(f)
@@ -69,6 +116,28 @@ func (cds *CheckDriftSubcommand) GetCmd() *cobra.Command {
 				}
 				return err
 			}
+			if format != checkDriftFormatText {
+				if err := drift.WriteReport(os.Stdout, report, format); err != nil {
+					return fmt.Errorf("error writing drift report: %v", err)
+				}
+				if report.HasDrift && len(report.Fixed) < len(report.Files) {
+					os.Exit(2)
+				}
+				return nil
+			}
+			if len(report.Ignored) > 0 {
+				logger.Log("ignored drift: %d file(s) accepted by .gitspork/%s", len(report.Ignored), config.DriftIgnoreFileName)
+				for _, f := range report.Ignored {
+					reason := f.Justification
+					if reason == "" {
+						reason = "matches " + f.Pattern
+					}
+					if f.Expires != "" {
+						reason = fmt.Sprintf("%s, until %s", reason, f.Expires)
+					}
+					logger.Log("  %s (%s)", f.Path, reason)
+				}
+			}
 			if !report.HasDrift {
 				logger.Log("no drift detected")
 				return nil
@@ -79,8 +148,20 @@ func (cds *CheckDriftSubcommand) GetCmd() *cobra.Command {
 				if attribution == "" {
 					attribution = "(unknown upstream)"
 				}
+				if f.Migration != "" {
+					attribution = fmt.Sprintf("%s, produced by migration %s", attribution, f.Migration)
+				}
+				if f.ExpiredIgnore != "" {
+					attribution = fmt.Sprintf("%s, ignore expired on %s", attribution, f.ExpiredIgnore)
+				}
 				logger.Log("  %s (upstream: %s)", f.Path, attribution)
 			}
+			if len(report.Fixed) > 0 {
+				logger.Log("fixed drift: %d file(s) restored, review and commit the changes", len(report.Fixed))
+				if len(report.Fixed) == len(report.Files) {
+					return nil
+				}
+			}
 			if verbose {
 				for _, f := range report.Files {
 					if f.Diff == "" {
@@ -102,6 +183,10 @@ func (cds *CheckDriftSubcommand) GetCmd() *cobra.Command {
 		"local path to the downstream repo to check, defaults to the present working directory")
 	cmd.PersistentFlags().StringArrayVar(&upstreamFlags, "upstream", nil,
 		"override upstream(s) as comma-separated key=value pairs (url, version, subpath, token); repeatable")
+	cmd.PersistentFlags().StringArrayVar(&inputFlags, "input", nil,
+		"templated input value as name=value for the re-integration, which never prompts; repeatable")
+	cmd.PersistentFlags().StringVar(&inputsFile, "inputs-file", "",
+		"YAML or JSON file mapping templated input names to values for the re-integration; --input flags take precedence")
 	cmd.PersistentFlags().BoolVarP(&verbose, "verbose", "V", false,
 		"print full git diff output when drift is detected")
 	cmd.PersistentFlags().DurationVar(&cacheTTL, "cache-ttl", 0,
@@ -109,6 +194,22 @@ func (cds *CheckDriftSubcommand) GetCmd() *cobra.Command {
 			"Zero-value means 'use GITSPORK_CACHE_TTL env if set, else 2h'. Use --no-cache to bypass entirely.")
 	cmd.PersistentFlags().BoolVar(&noCache, "no-cache", false,
 		"bypass the upstream mirror cache entirely — direct network clone on every invocation. Overrides --cache-ttl.")
+	cmd.PersistentFlags().StringVar(&migrations, "migrations", sdktypes.DriftMigrationsSkip,
+		"what to do with migrations the downstream state doesn't record as complete: skip them, simulate them "+
+			"(declarative steps apply, commands are only logged), or run them in a sandbox (restricted environment, "+
+			"network proxied to nowhere)")
+	cmd.PersistentFlags().StringVar(&format, "format", checkDriftFormatText,
+		"report format: text (log lines), or json, sarif, junit or markdown written to stdout with progress on stderr")
+	cmd.PersistentFlags().BoolVar(&workingTree, "working-tree", false,
+		"check the working tree as it is on disk, including staged, unstaged and untracked-but-not-ignored changes, "+
+			"instead of requiring a clean working tree and checking HEAD")
+	cmd.PersistentFlags().BoolVar(&fix, "fix", false,
+		"restore the drifted files in the downstream working tree to what the recorded upstream commits produce, "+
+			"leaving the changes uncommitted")
+	cmd.PersistentFlags().StringVar(&patchOut, "patch-out", "",
+		"write the changes restoring the drifted files to this file, as a patch for 'git apply'")
+	cmd.PersistentFlags().StringArrayVar(&fixPaths, "path", nil,
+		"limit --fix and --patch-out to drifted files matching this pattern (https://github.com/gobwas/glob), relative to the downstream root; repeatable")
 
 	return cmd
 }
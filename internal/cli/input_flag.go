@@ -0,0 +1,40 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+)
+
+// ParseInputFlags builds the templated input values for an integrate run from
+// an optional --inputs-file (a YAML or JSON map of input name to value) and
+// any number of --input name=value flags, which take precedence over the file.
+// Returns nil if neither was given.
+func ParseInputFlags(inputsFile string, inputFlags []string) (map[string]any, error) {
+	if inputsFile == "" && len(inputFlags) == 0 {
+		return nil, nil
+	}
+	inputs := map[string]any{}
+	if inputsFile != "" {
+		data, err := os.ReadFile(inputsFile)
+		if err != nil {
+			return nil, fmt.Errorf("--inputs-file: error reading %s: %v", inputsFile, err)
+		}
+		if err := yaml.Unmarshal(data, &inputs); err != nil {
+			return nil, fmt.Errorf("--inputs-file: error parsing %s, expects a map of input name to value: %v", inputsFile, err)
+		}
+		if inputs == nil {
+			inputs = map[string]any{}
+		}
+	}
+	for _, f := range inputFlags {
+		name, value, ok := strings.Cut(f, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("--input: invalid name=value pair %q", f)
+		}
+		inputs[name] = value
+	}
+	return inputs, nil
+}
@@ -0,0 +1,42 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ParseInputFlags(t *testing.T) {
+	t.Run("nothing given", func(t *testing.T) {
+		inputs, err := ParseInputFlags("", nil)
+		require.NoError(t, err)
+		assert.Nil(t, inputs)
+	})
+	t.Run("flags only", func(t *testing.T) {
+		inputs, err := ParseInputFlags("", []string{"name=svc", "expr=a=b"})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]any{"name": "svc", "expr": "a=b"}, inputs)
+	})
+	t.Run("file keeps typed values and flags override", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "inputs.yml")
+		require.NoError(t, os.WriteFile(path, []byte("name: from-file\nenabled: true\nregions:\n  - us\n  - eu\n"), 0644))
+		inputs, err := ParseInputFlags(path, []string{"name=from-flag"})
+		require.NoError(t, err)
+		assert.Equal(t, "from-flag", inputs["name"])
+		assert.Equal(t, true, inputs["enabled"])
+		assert.Equal(t, []any{"us", "eu"}, inputs["regions"])
+	})
+	t.Run("missing file returns error", func(t *testing.T) {
+		_, err := ParseInputFlags(filepath.Join(t.TempDir(), "nope.yml"), nil)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "--inputs-file")
+	})
+	t.Run("invalid pair returns error", func(t *testing.T) {
+		_, err := ParseInputFlags("", []string{"novalue"})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "novalue")
+	})
+}
@@ -32,6 +32,12 @@ func (isc *IntegrateSubcommand) GetCmd() *cobra.Command {
 	var upstreamFlags []string
 	var downstreamRepoPath string
 	var forceRePrompt bool
+	var inputFlags []string
+	var inputsFile string
+	var nonInteractive bool
+	var migrationsDryRun bool
+	var allowMigrations bool
+	var noMigrations bool
 	var cacheTTL time.Duration
 	var noCache bool
 
@@ -45,12 +51,22 @@ func (isc *IntegrateSubcommand) GetCmd() *cobra.Command {
 				return fmt.Errorf("cannot mix --upstream with --upstream-repo-url/version/subpath/token flags")
 			}
 
+			inputs, err := ParseInputFlags(inputsFile, inputFlags)
+			if err != nil {
+				return err
+			}
 			opts := &sdktypes.IntegrateOptions{
 				Logger:             logger,
 				DownstreamRepoPath: downstreamRepoPath,
 				ForceRePrompt:      forceRePrompt,
+				Inputs:             inputs,
+				NonInteractive:     nonInteractive,
 				CacheTTL:           cacheTTL,
 				NoCache:            noCache,
+				MigrationsDryRun:   migrationsDryRun,
+				AllowMigrations:    allowMigrations,
+				NoMigrations:       noMigrations,
+				Context:            cmd.Context(),
 			}
 			if oldFlagsSet {
 				opts.Upstreams = []sdktypes.UpstreamSpec{{
@@ -92,11 +108,24 @@ func (isc *IntegrateSubcommand) GetCmd() *cobra.Command {
 		"local path to the downstream repo clone to integrate/re-integrate, defaults to the present working directory")
 	cmd.PersistentFlags().BoolVarP(&forceRePrompt, "force-re-prompt", "f", false,
 		"If true, will disregard any previous prompt input value caches for templated instructions")
+	cmd.PersistentFlags().StringArrayVar(&inputFlags, "input", nil,
+		"templated input value as name=value, used instead of prompting; repeatable")
+	cmd.PersistentFlags().StringVar(&inputsFile, "inputs-file", "",
+		"YAML or JSON file mapping templated input names to values, used instead of prompting; --input flags take precedence")
+	cmd.PersistentFlags().BoolVar(&nonInteractive, "non-interactive", false,
+		"never prompt; fail listing every templated input without a value from --input, --inputs-file, GITSPORK_INPUT_<NAME>, .gitspork/inputs.yml or the cache")
 	cmd.PersistentFlags().DurationVar(&cacheTTL, "cache-ttl", 0,
 		"upstream mirror cache freshness threshold (e.g. 2h, 30m); if a cached upstream is younger than this, no fetch is performed. "+
 			"Zero-value means 'use GITSPORK_CACHE_TTL env if set, else 2h'. Use --no-cache to bypass entirely.")
 	cmd.PersistentFlags().BoolVar(&noCache, "no-cache", false,
 		"bypass the upstream mirror cache entirely — direct network clone on every invocation. Overrides --cache-ttl.")
+	cmd.PersistentFlags().BoolVar(&migrationsDryRun, "migrations-dry-run", false,
+		"log each step of every pending migration without running it, then exit without integrating")
+	cmd.PersistentFlags().BoolVar(&allowMigrations, "allow-migrations", false,
+		"trust the upstreams to run migration and input commands without asking, for this run only")
+	cmd.PersistentFlags().BoolVar(&noMigrations, "no-migrations", false,
+		"run no migrations, leaving them pending for a later integrate")
+	cmd.MarkFlagsMutuallyExclusive("allow-migrations", "no-migrations")
 
 	return cmd
 }
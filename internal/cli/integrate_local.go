@@ -28,17 +28,33 @@ func (ilsc *IntegrateLocalSubcommand) GetCmd() *cobra.Command {
 	var upstreamPaths []string
 	var downstreamPath string
 	var forceRePrompt bool
+	var inputFlags []string
+	var inputsFile string
+	var nonInteractive bool
+	var migrationsDryRun bool
+	var allowMigrations bool
+	var noMigrations bool
 
 	var cmd = &cobra.Command{
 		Use:   "integrate-local",
 		Short: integrateLocalHelpShort,
 		Long:  fmt.Sprintf("%s\n\n%s", integrateLocalHelpShort, integrateLocalHelpLong),
 		RunE: func(cmd *cobra.Command, args []string) error {
+			inputs, err := ParseInputFlags(inputsFile, inputFlags)
+			if err != nil {
+				return err
+			}
 			if _, err := integrate.IntegrateLocal(&sdktypes.IntegrateLocalOptions{
-				Logger:         logger,
-				UpstreamPaths:  upstreamPaths,
-				DownstreamPath: downstreamPath,
-				ForceRePrompt:  forceRePrompt,
+				Logger:           logger,
+				UpstreamPaths:    upstreamPaths,
+				DownstreamPath:   downstreamPath,
+				ForceRePrompt:    forceRePrompt,
+				Inputs:           inputs,
+				NonInteractive:   nonInteractive,
+				MigrationsDryRun: migrationsDryRun,
+				AllowMigrations:  allowMigrations,
+				NoMigrations:     noMigrations,
+				Context:          cmd.Context(),
 			}); err != nil {
 				if errors.Is(err, sdktypes.ErrSelfIntegration) {
 					logger.Log("%v", err)
@@ -56,6 +72,19 @@ func (ilsc *IntegrateLocalSubcommand) GetCmd() *cobra.Command {
 		"local path to integrate/re-integrate w/ the standards set at the upstream-path")
 	cmd.PersistentFlags().BoolVarP(&forceRePrompt, "force-re-prompt", "f", false,
 		"If true, will disregard any previous prompt input value caches for templated instructions")
+	cmd.PersistentFlags().StringArrayVar(&inputFlags, "input", nil,
+		"templated input value as name=value, used instead of prompting; repeatable")
+	cmd.PersistentFlags().StringVar(&inputsFile, "inputs-file", "",
+		"YAML or JSON file mapping templated input names to values, used instead of prompting; --input flags take precedence")
+	cmd.PersistentFlags().BoolVar(&nonInteractive, "non-interactive", false,
+		"never prompt; fail listing every templated input without a value from --input, --inputs-file, GITSPORK_INPUT_<NAME>, .gitspork/inputs.yml or the cache")
+	cmd.PersistentFlags().BoolVar(&migrationsDryRun, "migrations-dry-run", false,
+		"log each step of every pending migration without running it, then exit without integrating")
+	cmd.PersistentFlags().BoolVar(&allowMigrations, "allow-migrations", false,
+		"trust the upstreams to run migration and input commands without asking, for this run only")
+	cmd.PersistentFlags().BoolVar(&noMigrations, "no-migrations", false,
+		"run no migrations, leaving them pending for a later integrate")
+	cmd.MarkFlagsMutuallyExclusive("allow-migrations", "no-migrations")
 
 	return cmd
 }
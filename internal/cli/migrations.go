@@ -0,0 +1,208 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/rockholla/gitspork/v2/internal/integrate"
+	"github.com/rockholla/gitspork/v2/internal/sdktypes"
+)
+
+// MigrationsSubcommand represents `gitspork migrations` and its children.
+type MigrationsSubcommand struct{}
+
+// GetCmd returns the cobra command tree for `gitspork migrations`.
+func (s *MigrationsSubcommand) GetCmd() *cobra.Command {
+	root := &cobra.Command{
+		Use:   "migrations",
+		Short: "inspect and manage the upstream migrations recorded in a downstream",
+	}
+	root.AddCommand(s.listCmd())
+	root.AddCommand(s.statusCmd())
+	root.AddCommand(s.rerunCmd())
+	root.AddCommand(s.skipCmd())
+	return root
+}
+
+// downstreamPathFlag adds the -d flag shared by every migrations subcommand.
+func downstreamPathFlag(cmd *cobra.Command, downstreamRepoPath *string) {
+	cmd.Flags().StringVarP(downstreamRepoPath, "downstream-repo-path", "d", "",
+		"local path to the downstream repo, defaults to the present working directory")
+}
+
+// listCmd is `gitspork migrations list` — prints the downstream's migration records.
+func (s *MigrationsSubcommand) listCmd() *cobra.Command {
+	var downstreamRepoPath string
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "list the migrations recorded in the downstream state",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := filepath.Abs(downstreamRepoPath)
+			if err != nil {
+				return fmt.Errorf("unable to determine local downstream repo path: %v", err)
+			}
+			state, err := integrate.LoadDownstreamState(path)
+			if err != nil {
+				return fmt.Errorf("error loading downstream state: %v", err)
+			}
+			if len(state.MigrationsComplete) == 0 {
+				fmt.Fprintln(cmd.OutOrStdout(), "no migrations recorded")
+				return nil
+			}
+			for _, record := range state.MigrationsComplete {
+				printMigrationRecord(cmd.OutOrStdout(), record)
+			}
+			return nil
+		},
+	}
+	downstreamPathFlag(cmd, &downstreamRepoPath)
+	return cmd
+}
+
+// statusCmd is `gitspork migrations status` — compares the upstreams'
+// migrations with the downstream's records.
+func (s *MigrationsSubcommand) statusCmd() *cobra.Command {
+	var downstreamRepoPath string
+	var upstreamFlags []string
+	var cacheTTL time.Duration
+	var noCache bool
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "show which of the upstreams' migrations the next integrate would run",
+		Long: `status clones each upstream recorded in the downstream state, at its recorded version, or each
+--upstream given, and shows every migration it defines: pending ones the next integrate runs, and
+those the downstream has recorded as ran, skipped, not applicable or failed.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts := &sdktypes.MigrationStatusOptions{
+				Logger:             logger,
+				DownstreamRepoPath: downstreamRepoPath,
+				CacheTTL:           cacheTTL,
+				NoCache:            noCache,
+			}
+			for _, f := range upstreamFlags {
+				spec, err := ParseUpstreamFlag(f)
+				if err != nil {
+					return err
+				}
+				opts.Upstreams = append(opts.Upstreams, spec)
+			}
+			report, err := integrate.MigrationStatus(opts)
+			if err != nil {
+				return err
+			}
+			if len(report.Migrations) == 0 {
+				fmt.Fprintln(cmd.OutOrStdout(), "the upstreams define no migrations")
+				return nil
+			}
+			for _, m := range report.Migrations {
+				fmt.Fprintf(cmd.OutOrStdout(), "%-14s %s (%s, from %s)\n", m.Status, m.ID, m.Path, m.UpstreamURL)
+				if m.Reason != "" {
+					fmt.Fprintf(cmd.OutOrStdout(), "  %s\n", m.Reason)
+				}
+				if m.Record != nil {
+					fmt.Fprint(cmd.OutOrStdout(), "  ")
+					printMigrationRecord(cmd.OutOrStdout(), *m.Record)
+				}
+			}
+			return nil
+		},
+	}
+	downstreamPathFlag(cmd, &downstreamRepoPath)
+	cmd.Flags().StringArrayVar(&upstreamFlags, "upstream", nil,
+		"upstream spec as comma-separated key=value pairs (url, version, subpath, token); repeatable, defaults to the recorded upstreams")
+	cmd.Flags().DurationVar(&cacheTTL, "cache-ttl", 0,
+		"upstream mirror cache freshness threshold (e.g. 2h, 30m); zero-value means 'use GITSPORK_CACHE_TTL env if set, else 2h'")
+	cmd.Flags().BoolVar(&noCache, "no-cache", false,
+		"bypass the upstream mirror cache entirely")
+	return cmd
+}
+
+// rerunCmd is `gitspork migrations rerun` — forgets a migration's record.
+func (s *MigrationsSubcommand) rerunCmd() *cobra.Command {
+	var downstreamRepoPath string
+	cmd := &cobra.Command{
+		Use:   "rerun <migration-id>",
+		Short: "forget a recorded migration so the next integrate runs it again",
+		Long: `rerun removes the downstream's record of a migration, given as an ID like
+0001-split-services:pre_integrate, or without the phase to cover both pre_integrate and
+post_integrate. The next integrate runs it again.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := filepath.Abs(downstreamRepoPath)
+			if err != nil {
+				return fmt.Errorf("unable to determine local downstream repo path: %v", err)
+			}
+			removed, err := integrate.RerunMigration(path, args[0])
+			if err != nil {
+				return err
+			}
+			for _, id := range removed {
+				logger.Log("%s will run again on the next integrate", id)
+			}
+			return nil
+		},
+	}
+	downstreamPathFlag(cmd, &downstreamRepoPath)
+	return cmd
+}
+
+// skipCmd is `gitspork migrations skip` — records a migration as skipped.
+func (s *MigrationsSubcommand) skipCmd() *cobra.Command {
+	var downstreamRepoPath string
+	cmd := &cobra.Command{
+		Use:   "skip <migration-id>",
+		Short: "record a migration as skipped so integrate never runs it",
+		Long: `skip records a migration, given as an ID like 0001-split-services:pre_integrate, as skipped in
+the downstream state, for example when it was already applied by hand. Use rerun to undo it.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := filepath.Abs(downstreamRepoPath)
+			if err != nil {
+				return fmt.Errorf("unable to determine local downstream repo path: %v", err)
+			}
+			if err := integrate.SkipMigration(path, args[0]); err != nil {
+				return err
+			}
+			logger.Log("%s is recorded as skipped", args[0])
+			return nil
+		},
+	}
+	downstreamPathFlag(cmd, &downstreamRepoPath)
+	return cmd
+}
+
+// printMigrationRecord writes a single line describing record.
+func printMigrationRecord(w io.Writer, record sdktypes.MigrationRecord) {
+	details := []string{record.ID, record.Outcome}
+	if !record.At.IsZero() {
+		details = append(details, record.At.Local().Format(time.RFC3339))
+	}
+	if record.UpstreamCommit != "" {
+		details = append(details, "upstream "+shortCommit(record.UpstreamCommit))
+	}
+	if record.Outcome == sdktypes.MigrationOutcomeRan || record.Outcome == sdktypes.MigrationOutcomeFailed {
+		details = append(details, fmt.Sprintf("exit %d", record.ExitStatus))
+	}
+	if record.DurationMillis > 0 {
+		details = append(details, (time.Duration(record.DurationMillis) * time.Millisecond).String())
+	}
+	fmt.Fprintln(w, strings.Join(details, "  "))
+	if record.Error != "" {
+		fmt.Fprintf(w, "    %s\n", record.Error)
+	}
+}
+
+// shortCommit abbreviates a commit hash the way git does.
+func shortCommit(hash string) string {
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+	return hash
+}
@@ -0,0 +1,125 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/rockholla/gitspork/v2/internal/drift"
+	"github.com/rockholla/gitspork/v2/internal/sdktypes"
+)
+
+const (
+	outdatedHelpShort string = "check if a downstream repo is behind the latest versions of its upstreams"
+	outdatedHelpLong  string = `outdated compares the upstream commit recorded for each upstream in the downstream state with
+the latest version of what was requested at integration: the latest commit on the requested branch
+(the default branch when no version was requested), or the newest semver tag when a semver tag was
+requested. A commit hash or other tag never moves. With --files, it also previews updating each
+outdated upstream against an isolated copy of the downstream, listing the managed files that would change.
+
+Exit codes:
+  0 - every upstream is up to date
+  1 - error
+  2 - at least one upstream is outdated`
+)
+
+// OutdatedSubcommand represents the subcommand and all related functionality for 'gitspork outdated'
+type OutdatedSubcommand struct{}
+
+// GetCmd will return the native cobra command for the outdated subcommand
+func (s *OutdatedSubcommand) GetCmd() *cobra.Command {
+	var downstreamRepoPath string
+	var upstreamFlags []string
+	var files bool
+	var cacheTTL time.Duration
+	var noCache bool
+	var inputFlags []string
+	var inputsFile string
+
+	var cmd = &cobra.Command{
+		Use:           "outdated",
+		Short:         outdatedHelpShort,
+		Long:          fmt.Sprintf("%s\n\n%s", outdatedHelpShort, outdatedHelpLong),
+		Args:          cobra.NoArgs,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			inputs, err := ParseInputFlags(inputsFile, inputFlags)
+			if err != nil {
+				return err
+			}
+			opts := &sdktypes.OutdatedOptions{
+				Logger:             logger,
+				DownstreamRepoPath: downstreamRepoPath,
+				Files:              files,
+				Inputs:             inputs,
+				CacheTTL:           cacheTTL,
+				NoCache:            noCache,
+			}
+			for _, f := range upstreamFlags {
+				spec, err := ParseUpstreamFlag(f)
+				if err != nil {
+					return err
+				}
+				opts.Upstreams = append(opts.Upstreams, spec)
+			}
+			report, err := drift.Outdated(opts)
+			if err != nil {
+				return err
+			}
+			for _, upstream := range report.Upstreams {
+				printOutdatedUpstream(cmd.OutOrStdout(), upstream)
+			}
+			if report.HasOutdated {
+				os.Exit(2)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&downstreamRepoPath, "downstream-repo-path", "d", "",
+		"local path to the downstream repo to check, defaults to the present working directory")
+	cmd.Flags().StringArrayVar(&upstreamFlags, "upstream", nil,
+		"override upstream(s) as comma-separated key=value pairs (url, version, subpath, token); repeatable")
+	cmd.Flags().BoolVar(&files, "files", false,
+		"also list the managed files updating each outdated upstream would change")
+	cmd.Flags().StringArrayVar(&inputFlags, "input", nil,
+		"templated input value as name=value for the --files preview, which never prompts; repeatable")
+	cmd.Flags().StringVar(&inputsFile, "inputs-file", "",
+		"YAML or JSON file mapping templated input names to values for the --files preview; --input flags take precedence")
+	cmd.Flags().DurationVar(&cacheTTL, "cache-ttl", 0,
+		"upstream mirror cache freshness threshold (e.g. 2h, 30m); zero-value means 'use GITSPORK_CACHE_TTL env if set, else 2h'")
+	cmd.Flags().BoolVar(&noCache, "no-cache", false,
+		"bypass the upstream mirror cache entirely")
+
+	return cmd
+}
+
+// printOutdatedUpstream writes a line describing upstream, followed by the
+// files updating it would change.
+func printOutdatedUpstream(w io.Writer, upstream sdktypes.OutdatedUpstream) {
+	name := upstream.URL
+	if upstream.Subpath != "" {
+		name = fmt.Sprintf("%s (subpath %s)", name, upstream.Subpath)
+	}
+	switch {
+	case upstream.Track == sdktypes.OutdatedTrackPinned:
+		fmt.Fprintf(w, "%-9s %s at %s\n", "pinned", name, upstream.Version)
+	case !upstream.Outdated:
+		fmt.Fprintf(w, "%-9s %s at %s %s\n", "current", name, upstream.LatestVersion, shortCommit(upstream.CommitHash))
+	default:
+		details := fmt.Sprintf("%d commit(s) behind", upstream.CommitsBehind)
+		if len(upstream.TagsBehind) > 0 {
+			details = fmt.Sprintf("%s, tags %s", details, strings.Join(upstream.TagsBehind, ", "))
+		}
+		fmt.Fprintf(w, "%-9s %s at %s, latest %s %s (%s)\n", "outdated", name, shortCommit(upstream.CommitHash),
+			upstream.LatestVersion, shortCommit(upstream.LatestCommitHash), details)
+	}
+	for _, f := range upstream.ChangedFiles {
+		fmt.Fprintf(w, "  would change %s\n", f)
+	}
+}
@@ -1,7 +1,12 @@
 package cli
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/rockholla/gitspork/v2/internal/logutil"
@@ -14,6 +19,10 @@ const (
 purpose this tool serves and how to use it.`
 )
 
+// interruptGracePeriod is how long an interrupted command has to stop a
+// running migration and return before the process exits anyway.
+const interruptGracePeriod = 10 * time.Second
+
 var (
 	version string
 	logger  *logutil.Logger
@@ -29,12 +38,30 @@ var rootCmd = &cobra.Command{
 func Execute(ver string) {
 	version = ver
 	rootCmd.Version = version
-	err := rootCmd.Execute()
+	err := rootCmd.ExecuteContext(interruptContext())
 	if err != nil {
 		logger.Fatal("%s", err.Error())
 	}
 }
 
+// interruptContext is cancelled by the first SIGINT or SIGTERM, so a running
+// migration's commands are killed and the command fails. The process exits
+// with 130 if it hasn't returned after interruptGracePeriod, and a second
+// signal terminates it at once.
+func interruptContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		signal.Stop(signals)
+		cancel()
+		time.Sleep(interruptGracePeriod)
+		os.Exit(130)
+	}()
+	return ctx
+}
+
 func init() {
 	var forceColor bool
 	rootCmd.PersistentFlags().BoolVar(&forceColor, "color", false, "force color output even when stdout is not a TTY (useful in Docker)")
@@ -60,8 +87,11 @@ func init() {
 	rootCmd.AddCommand(integrateLocalSubcommand.GetCmd())
 	rootCmd.AddCommand(InitSubcommand.GetCmd())
 	rootCmd.AddCommand(checkDriftSubcommand.GetCmd())
+	rootCmd.AddCommand((&OutdatedSubcommand{}).GetCmd())
+	rootCmd.AddCommand((&ScanSubcommand{}).GetCmd())
 	rootCmd.AddCommand(mvSubcommand.GetCmd())
 	rootCmd.AddCommand(rmSubcommand.GetCmd())
 	rootCmd.AddCommand(schemaSubcommand.GetCmd())
 	rootCmd.AddCommand((&CacheSubcommand{}).GetCmd())
+	rootCmd.AddCommand((&MigrationsSubcommand{}).GetCmd())
 }
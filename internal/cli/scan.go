@@ -0,0 +1,114 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/rockholla/gitspork/v2/internal/drift"
+	"github.com/rockholla/gitspork/v2/internal/logutil"
+	"github.com/rockholla/gitspork/v2/internal/sdktypes"
+)
+
+const (
+	scanHelpShort string = "check drift across many downstream repo checkouts at once"
+	scanHelpLong  string = `scan runs check-drift in each downstream repo checkout given as an argument, as a path pattern
+like 'checkouts/*', or in a --manifest, several at a time, sharing the upstream mirror cache
+between them. It ends with a summary table, or with --format json, a report of every repo's
+status, drifted file counts, error and recorded upstream versions on stdout.
+
+A manifest is a YAML file listing the downstreams, relative to the manifest:
+
+  downstreams:
+  - checkouts/*
+  - /srv/service-a
+
+Exit codes:
+  0 - no drift detected in any repo
+  1 - error, or drift couldn't be checked in at least one repo
+  2 - drift detected in at least one repo`
+)
+
+// ScanSubcommand represents the subcommand and all related functionality for 'gitspork scan'
+type ScanSubcommand struct{}
+
+// GetCmd will return the native cobra command for the scan subcommand
+func (s *ScanSubcommand) GetCmd() *cobra.Command {
+	var manifest string
+	var concurrency int
+	var cacheTTL time.Duration
+	var noCache bool
+	var migrations string
+	var format string
+	var inputFlags []string
+	var inputsFile string
+
+	var cmd = &cobra.Command{
+		Use:           "scan [path or pattern...]",
+		Short:         scanHelpShort,
+		Long:          fmt.Sprintf("%s\n\n%s", scanHelpShort, scanHelpLong),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !slices.Contains(drift.ScanFormats, format) {
+				return fmt.Errorf("invalid --format %q: expects %s", format, strings.Join(drift.ScanFormats, " or "))
+			}
+			// JSON owns stdout, so progress goes to stderr.
+			scanLogger := logger
+			if format == drift.FormatJSON {
+				scanLogger = logutil.NewStderr()
+			}
+			inputs, err := ParseInputFlags(inputsFile, inputFlags)
+			if err != nil {
+				return err
+			}
+			report, err := drift.Scan(&sdktypes.ScanOptions{
+				Logger:              scanLogger,
+				DownstreamRepoPaths: args,
+				Manifest:            manifest,
+				Concurrency:         concurrency,
+				CacheTTL:            cacheTTL,
+				NoCache:             noCache,
+				Migrations:          migrations,
+				Inputs:              inputs,
+				Context:             cmd.Context(),
+			})
+			if err != nil {
+				return err
+			}
+			if err := drift.WriteScanReport(cmd.OutOrStdout(), report, format); err != nil {
+				return fmt.Errorf("error writing scan report: %v", err)
+			}
+			switch {
+			case report.HasErrors:
+				os.Exit(1)
+			case report.HasDrift:
+				os.Exit(2)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&manifest, "manifest", "",
+		"YAML file listing the downstream repo paths or path patterns to scan under 'downstreams', relative to the file")
+	cmd.Flags().IntVar(&concurrency, "concurrency", 0,
+		"how many repos to check at once; zero-value means the number of CPUs")
+	cmd.Flags().DurationVar(&cacheTTL, "cache-ttl", 0,
+		"upstream mirror cache freshness threshold (e.g. 2h, 30m); zero-value means 'use GITSPORK_CACHE_TTL env if set, else 2h'")
+	cmd.Flags().BoolVar(&noCache, "no-cache", false,
+		"bypass the upstream mirror cache entirely")
+	cmd.Flags().StringVar(&migrations, "migrations", sdktypes.DriftMigrationsSkip,
+		"what each check does with migrations the downstream state doesn't record as complete: skip, simulate or sandbox, as for check-drift")
+	cmd.Flags().StringArrayVar(&inputFlags, "input", nil,
+		"templated input value as name=value for every check, which never prompts; repeatable")
+	cmd.Flags().StringVar(&inputsFile, "inputs-file", "",
+		"YAML or JSON file mapping templated input names to values for every check; --input flags take precedence")
+	cmd.Flags().StringVar(&format, "format", drift.ScanFormatTable,
+		"report format: table (a summary table), or json")
+
+	return cmd
+}
@@ -2,15 +2,18 @@ package cli
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/rockholla/gitspork/v2/internal/config"
+	"github.com/rockholla/gitspork/v2/internal/integrate"
 	"github.com/rockholla/gitspork/v2/internal/logutil"
 	"github.com/spf13/cobra"
 )
 
 const (
 	schemaHelpShort string = "print the .gitspork.yml and migration YAML schemas"
-	schemaHelpLong  string = `Prints the annotated schema for both .gitspork.yml and the migration YAML format.
+	schemaHelpLong  string = `Prints the annotated schema for both .gitspork.yml and the migration YAML format, followed by
+the built-in functions available to templated instructions.
 
 Output is syntax-highlighted when writing to a terminal.`
 )
@@ -29,11 +32,26 @@ func (s *SchemaSubcommand) GetCmd() *cobra.Command {
 			if err != nil {
 				return err
 			}
-			fmt.Printf("Main .gitspork.yml schema:\n---------------------------------------------\n%s\n\nMigration YAML schema:\n---------------------------------------------\n%s\n",
+			fmt.Printf("Main .gitspork.yml schema:\n---------------------------------------------\n%s\n\nMigration YAML schema:\n---------------------------------------------\n%s\n\nTemplate functions:\n---------------------------------------------\n%s",
 				logutil.ColorizeYAML(configSchema),
 				logutil.ColorizeYAML(migrationsSchema),
+				renderTemplateFuncDocs(integrate.TemplateFuncDocs()),
 			)
 			return nil
 		},
 	}
 }
+
+// renderTemplateFuncDocs formats the template function library as an aligned
+// two-column listing: usage signature, then description.
+func renderTemplateFuncDocs(docs []integrate.TemplateFuncDoc) string {
+	width := 0
+	for _, d := range docs {
+		width = max(width, len(d.Signature))
+	}
+	var b strings.Builder
+	for _, d := range docs {
+		fmt.Fprintf(&b, "%-*s  # %s\n", width, d.Signature, d.Description)
+	}
+	return b.String()
+}
@@ -2,9 +2,17 @@ package config
 
 import (
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
 
+	"github.com/gobwas/glob"
 	"github.com/goccy/go-yaml"
 	"github.com/rockholla/go-lib/marshal"
 )
@@ -15,24 +23,49 @@ const (
 	GitSporkConfigFileName    string = ".gitspork.yml"
 	GitSporkConfigFileNameAlt string = ".gitspork.yaml"
 	GitSporkMarkerSeparator   string = "::"
+	// GitSporkDirName is the downstream directory of gitspork's own state and
+	// policy files, which no upstream instruction may write.
+	GitSporkDirName string = ".gitspork"
 
 	// TemplatedMergeStructuredPreferUpstream / TemplatedMergeStructuredPreferDownstream
 	// are the valid values for GitSporkConfigTemplatedMerged.Structured.
 	TemplatedMergeStructuredPreferUpstream   = "prefer-upstream"
 	TemplatedMergeStructuredPreferDownstream = "prefer-downstream"
+
+	// TemplatedInputType* are the valid values for GitSporkConfigTemplatedInput.Type.
+	// An empty Type is treated as TemplatedInputTypeString.
+	TemplatedInputTypeString      = "string"
+	TemplatedInputTypeBool        = "bool"
+	TemplatedInputTypeInt         = "int"
+	TemplatedInputTypeSelect      = "select"
+	TemplatedInputTypeMultiSelect = "multi-select"
+
+	// TemplatedRender* are the valid values for GitSporkConfigTemplated.Render.
+	// An empty Render is treated as TemplatedRenderAlways.
+	TemplatedRenderAlways = "always"
+	TemplatedRenderOnce   = "once"
 )
 
 var (
 	GitSporkCommentMarker string = fmt.Sprintf("%s%s%s", GitSporkMarkerSeparator, GitSpork, GitSporkMarkerSeparator)
 )
 
+// InGitSporkDir reports whether the slash-separated downstream path, or glob
+// pattern, p is the .gitspork directory or under it. The name is compared
+// case-insensitively, as it resolves on case-insensitive filesystems.
+func InGitSporkDir(p string) bool {
+	first, _, _ := strings.Cut(strings.TrimPrefix(filepath.ToSlash(filepath.Clean(filepath.FromSlash(p))), "/"), "/")
+	return strings.EqualFold(first, GitSporkDirName)
+}
+
 // GitSporkConfig represents the config an upstream repo defines in .gitspork.yml
 type GitSporkConfig struct {
-	UpstreamOwned   []OwnedEntry                  `yaml:"upstream_owned" comment:"file patterns (https://github.com/gobwas/glob) fully owned by the upstream; an entry may instead be a {from, to} map to rename a file as it syncs to the downstream"`
-	DownstreamOwned []OwnedEntry                  `yaml:"downstream_owned" comment:"file patterns (https://github.com/gobwas/glob) fully owned by the downstream once initially integrated; an entry may instead be a {from, to} map to seed a file at a different downstream path"`
-	SharedOwnership GitSporkConfigSharedOwnership `yaml:"shared_ownership" comment:"file patterns (https://github.com/gobwas/glob) that will be owned by both the upstream and downstream repos in some managed way"`
-	Templated       []GitSporkConfigTemplated     `yaml:"templated" comment:"list of instruction for templated source files in the upstream that should be rendered in some way to a location in the downstream"`
-	Migrations      []string                      `yaml:"migrations" comment:"list of YAML file paths in the upstream repo, relative to the upstream repo root or subpath if specified, containing downstream repo migration instructions"`
+	UpstreamOwned    []OwnedEntry                  `yaml:"upstream_owned" comment:"file patterns (https://github.com/gobwas/glob) fully owned by the upstream; an entry may instead be a {from, to} map to rename a file as it syncs to the downstream"`
+	DownstreamOwned  []OwnedEntry                  `yaml:"downstream_owned" comment:"file patterns (https://github.com/gobwas/glob) fully owned by the downstream once initially integrated; an entry may instead be a {from, to} map to seed a file at a different downstream path"`
+	SharedOwnership  GitSporkConfigSharedOwnership `yaml:"shared_ownership" comment:"file patterns (https://github.com/gobwas/glob) that will be owned by both the upstream and downstream repos in some managed way"`
+	Templated        []GitSporkConfigTemplated     `yaml:"templated" comment:"list of instruction for templated source files in the upstream that should be rendered in some way to a location in the downstream"`
+	TemplatePartials []string                      `yaml:"template_partials,omitempty" comment:"(optional) file patterns (https://github.com/gobwas/glob) of upstream files whose {{ define }} blocks are available to every templated instruction; matching files are never rendered themselves"`
+	Migrations       []string                      `yaml:"migrations" comment:"list of YAML file paths in the upstream repo, relative to the upstream repo root or subpath if specified, containing downstream repo migration instructions"`
 
 	// comments holds user-written YAML comments captured on parse, re-injected on write.
 	comments yaml.CommentMap `yaml:"-"`
@@ -52,30 +85,421 @@ type GitSporkConfigSharedOwnershipStructured struct {
 
 // GitSporkConfigMigration represents config for a single downstream repo migration
 type GitSporkConfigMigration struct {
+	ID            string                               `yaml:"id,omitempty" comment:"(optional) stable ID downstreams record the migration under once it has run, so the file can move without the migration running again; defaults to the file's path"`
+	Aliases       []string                             `yaml:"aliases,omitempty" comment:"(optional) earlier IDs or paths of this migration; a downstream that completed it under any of them, or under its path when id is set, doesn't run it again"`
+	AppliesIf     *GitSporkConfigMigrationAppliesIf    `yaml:"applies_if,omitempty" comment:"(optional) conditions, all of which must hold, for the migration to run against a downstream; a migration that doesn't apply is recorded as complete without running"`
 	PreIntegrate  *GitSporkConfigMigrationInstructions `yaml:"pre_integrate,omitempty"`
 	PostIntegrate *GitSporkConfigMigrationInstructions `yaml:"post_integrate,omitempty"`
 }
 
+const (
+	MigrationIntegrationFresh   string = "fresh"
+	MigrationIntegrationUpgrade string = "upgrade"
+)
+
+// Migration phases, which suffix a migration's ID to identify each of its
+// instructions, e.g. "0001-split-services:pre_integrate".
+const (
+	MigrationPhasePreIntegrate  string = "pre_integrate"
+	MigrationPhasePostIntegrate string = "post_integrate"
+)
+
+// SetIDs assigns the ID of each of the migration's instructions, based on its
+// id or, without one, migrationConfigPath, the path it was parsed from. The
+// instructions' aliases come from the migration's aliases, plus
+// migrationConfigPath when id is set.
+func (m *GitSporkConfigMigration) SetIDs(migrationConfigPath string) {
+	baseID, aliases := migrationConfigPath, m.Aliases
+	if m.ID != "" {
+		baseID, aliases = m.ID, append([]string{migrationConfigPath}, m.Aliases...)
+	}
+	for phase, instructions := range map[string]*GitSporkConfigMigrationInstructions{
+		MigrationPhasePreIntegrate:  m.PreIntegrate,
+		MigrationPhasePostIntegrate: m.PostIntegrate,
+	} {
+		if instructions == nil {
+			continue
+		}
+		instructions.ID = fmt.Sprintf("%s:%s", baseID, phase)
+		instructions.Aliases = nil
+		for _, alias := range aliases {
+			instructions.Aliases = append(instructions.Aliases, fmt.Sprintf("%s:%s", alias, phase))
+		}
+	}
+}
+
+// BaseMigrationID strips the phase from a migration instruction ID, returning
+// the migration's ID and the phase, which is empty if migrationID has none.
+func BaseMigrationID(migrationID string) (string, string) {
+	for _, phase := range []string{MigrationPhasePreIntegrate, MigrationPhasePostIntegrate} {
+		if base, ok := strings.CutSuffix(migrationID, ":"+phase); ok {
+			return base, phase
+		}
+	}
+	return migrationID, ""
+}
+
+// validateMigrationID rejects IDs that are empty, or that contain whitespace
+// or the ':' separating an ID from its phase.
+func validateMigrationID(id string) error {
+	if id == "" || strings.ContainsFunc(id, func(r rune) bool { return r == ':' || unicode.IsSpace(r) }) {
+		return fmt.Errorf("invalid migration id %q: expects a non-empty ID without whitespace or ':'", id)
+	}
+	return nil
+}
+
+// GitSporkConfigMigrationAppliesIf limits a migration to the downstreams it's
+// meant for, judged by the upstream commit each last integrated.
+type GitSporkConfigMigrationAppliesIf struct {
+	Integration        string `yaml:"integration,omitempty" comment:"(optional) 'fresh' applies only to a downstream's first integration of the upstream, 'upgrade' only to later ones"`
+	PreviousAncestorOf string `yaml:"previous_ancestor_of,omitempty" comment:"(optional) upstream tag, branch or commit; applies only to upgrades from this ref or an ancestor of it"`
+	PreviousBefore     string `yaml:"previous_before,omitempty" comment:"(optional) upstream tag, branch or commit; applies only to upgrades from a strict ancestor of this ref, i.e. from a version older than it"`
+}
+
+// Validate reports a configuration error if the integration kind is unknown,
+// or a previous-commit condition is combined with fresh integrations, which
+// have no previous commit.
+func (a GitSporkConfigMigrationAppliesIf) Validate() error {
+	switch a.Integration {
+	case "", MigrationIntegrationFresh, MigrationIntegrationUpgrade:
+	default:
+		return fmt.Errorf("invalid integration %q: expects %s or %s", a.Integration, MigrationIntegrationFresh, MigrationIntegrationUpgrade)
+	}
+	if a.Integration == MigrationIntegrationFresh && (a.PreviousAncestorOf != "" || a.PreviousBefore != "") {
+		return fmt.Errorf("previous_ancestor_of and previous_before only apply to upgrades, not %s integrations", MigrationIntegrationFresh)
+	}
+	return nil
+}
+
 // GitSporkConfigMigrationInstruction provides specific instructions for a migration operation/set of operations
 type GitSporkConfigMigrationInstructions struct {
-	ID   string `yaml:"-"`
-	Exec string `yaml:"exec" comment:"command, or path to a script relative to the upstream repo root or subpath if specified, to execute in the downstream repo as a migration-related operation"`
+	ID         string                        `yaml:"-"`
+	Aliases    []string                      `yaml:"-"`
+	Exec       string                        `yaml:"exec,omitempty" comment:"(one-of required) command, or path to a script relative to the upstream repo root or subpath if specified, to execute in the downstream repo as a migration-related operation"`
+	Steps      []GitSporkConfigMigrationStep `yaml:"steps,omitempty" comment:"(one-of required) declarative operations run in order against the downstream repo; paths are relative to the downstream root"`
+	Timeout    string                        `yaml:"timeout,omitempty" comment:"(optional) how long the whole migration may run, e.g. '5m'; commands still running are killed along with everything they started"`
+	Env        map[string]string             `yaml:"env,omitempty" comment:"(optional) environment variables added for exec and shell commands, alongside the GITSPORK_* variables gitspork provides"`
+	WorkingDir string                        `yaml:"working_dir,omitempty" comment:"(optional) directory, relative to the downstream root, exec and shell commands run in; defaults to the downstream root"`
+}
+
+// TimeoutDuration is the parsed Timeout, or zero for no timeout.
+func (m GitSporkConfigMigrationInstructions) TimeoutDuration() time.Duration {
+	d, _ := time.ParseDuration(m.Timeout)
+	return d
+}
+
+// GitSporkConfigMigrationStep is a single declarative migration operation. Exactly
+// one of its operation fields is set.
+type GitSporkConfigMigrationStep struct {
+	Name       string                             `yaml:"name,omitempty" comment:"(optional) description of the step for the integrate log"`
+	Move       *GitSporkConfigMigrationMove       `yaml:"move,omitempty" comment:"move or rename a file or directory"`
+	Delete     []string                           `yaml:"delete,omitempty" comment:"file patterns (https://github.com/gobwas/glob) of files to delete"`
+	Replace    *GitSporkConfigMigrationReplace    `yaml:"replace,omitempty" comment:"regular expression replacement in files"`
+	Patch      *GitSporkConfigMigrationPatch      `yaml:"patch,omitempty" comment:"set or delete key paths in a JSON or YAML file"`
+	EnsureLine *GitSporkConfigMigrationEnsureLine `yaml:"ensure_line,omitempty" comment:"append a line to a file unless the file already has it"`
+	Append     *GitSporkConfigMigrationAppend     `yaml:"append,omitempty" comment:"append content to a file"`
+	Exec       string                             `yaml:"exec,omitempty" comment:"command, or path to a script in the upstream, to execute in the downstream repo, like the migration-level exec"`
+	Shell      string                             `yaml:"shell,omitempty" comment:"script run through 'sh -c' in the downstream repo"`
+	Env        map[string]string                  `yaml:"env,omitempty" comment:"(optional, exec/shell only) environment variables added to the command's environment"`
+}
+
+// GitSporkConfigMigrationMove moves From to To, both relative to the downstream root.
+type GitSporkConfigMigrationMove struct {
+	From string `yaml:"from" comment:"existing path; a missing path is skipped"`
+	To   string `yaml:"to" comment:"new path, which must not exist yet"`
+}
+
+// GitSporkConfigMigrationReplace replaces matches of Pattern in the files matching Files.
+type GitSporkConfigMigrationReplace struct {
+	Files   []string `yaml:"files" comment:"file patterns (https://github.com/gobwas/glob) of the files to edit"`
+	Pattern string   `yaml:"pattern" comment:"regular expression (https://pkg.go.dev/regexp/syntax) to replace"`
+	With    string   `yaml:"with" comment:"replacement, which may reference capture groups like '${1}'"`
+}
+
+// GitSporkConfigMigrationPatch edits a structured data file in place.
+type GitSporkConfigMigrationPatch struct {
+	File   string         `yaml:"file" comment:"JSON or YAML file to edit; a missing file is created by set"`
+	Set    map[string]any `yaml:"set,omitempty" comment:"key paths, like '$.scripts.lint' or 'jobs.build.steps[0].uses', mapped to the value to set, applied in key path order; missing mappings along the path are created"`
+	Delete []string       `yaml:"delete,omitempty" comment:"key paths to remove, applied after set; a missing key is skipped"`
+}
+
+// GitSporkConfigMigrationEnsureLine appends Line to File unless an identical line exists.
+type GitSporkConfigMigrationEnsureLine struct {
+	File string `yaml:"file" comment:"file to edit, created if missing"`
+	Line string `yaml:"line" comment:"line that should be present"`
+}
+
+// GitSporkConfigMigrationAppend appends Content to File.
+type GitSporkConfigMigrationAppend struct {
+	File    string `yaml:"file" comment:"file to edit, created if missing"`
+	Content string `yaml:"content" comment:"content to append"`
+}
+
+// Validate reports a configuration error if the instructions set both exec and
+// steps, have an invalid timeout or working_dir, or a step is malformed.
+func (m GitSporkConfigMigrationInstructions) Validate() error {
+	if m.Exec != "" && len(m.Steps) > 0 {
+		return fmt.Errorf("only one of exec or steps may be set")
+	}
+	if m.Timeout != "" {
+		if d, err := time.ParseDuration(m.Timeout); err != nil || d <= 0 {
+			return fmt.Errorf("invalid timeout %q: expects a positive duration like '90s' or '5m'", m.Timeout)
+		}
+	}
+	if m.WorkingDir != "" && !filepath.IsLocal(filepath.FromSlash(m.WorkingDir)) {
+		return fmt.Errorf("working_dir %q must be relative to and inside the downstream", m.WorkingDir)
+	}
+	for idx, step := range m.Steps {
+		if err := step.Validate(); err != nil {
+			return fmt.Errorf("step %d: %v", idx+1, err)
+		}
+	}
+	return nil
+}
+
+// Validate reports a configuration error if the step doesn't set exactly one
+// operation, or an operation has a missing field, an invalid pattern, or a
+// path outside the downstream or under .gitspork.
+func (s GitSporkConfigMigrationStep) Validate() error {
+	operations := 0
+	for _, set := range []bool{s.Move != nil, len(s.Delete) > 0, s.Replace != nil, s.Patch != nil, s.EnsureLine != nil, s.Append != nil, s.Exec != "", s.Shell != ""} {
+		if set {
+			operations++
+		}
+	}
+	if operations != 1 {
+		return fmt.Errorf("exactly one of move, delete, replace, patch, ensure_line, append, exec or shell must be set")
+	}
+	if len(s.Env) > 0 && s.Exec == "" && s.Shell == "" {
+		return fmt.Errorf("env only applies to exec and shell")
+	}
+	var paths, patterns []string
+	switch {
+	case s.Move != nil:
+		if s.Move.From == "" || s.Move.To == "" {
+			return fmt.Errorf("move requires from and to")
+		}
+		paths = []string{s.Move.From, s.Move.To}
+	case len(s.Delete) > 0:
+		patterns = s.Delete
+	case s.Replace != nil:
+		if len(s.Replace.Files) == 0 || s.Replace.Pattern == "" {
+			return fmt.Errorf("replace requires files and pattern")
+		}
+		if _, err := regexp.Compile(s.Replace.Pattern); err != nil {
+			return fmt.Errorf("invalid replace pattern %q: %v", s.Replace.Pattern, err)
+		}
+		patterns = s.Replace.Files
+	case s.Patch != nil:
+		if s.Patch.File == "" || (len(s.Patch.Set) == 0 && len(s.Patch.Delete) == 0) {
+			return fmt.Errorf("patch requires file and at least one of set or delete")
+		}
+		for _, keyPath := range append(slices.Collect(maps.Keys(s.Patch.Set)), s.Patch.Delete...) {
+			if _, err := ParseKeyPath(keyPath); err != nil {
+				return err
+			}
+		}
+		paths = []string{s.Patch.File}
+	case s.EnsureLine != nil:
+		if s.EnsureLine.File == "" || s.EnsureLine.Line == "" || strings.Contains(s.EnsureLine.Line, "\n") {
+			return fmt.Errorf("ensure_line requires file and a single line")
+		}
+		paths = []string{s.EnsureLine.File}
+	case s.Append != nil:
+		if s.Append.File == "" {
+			return fmt.Errorf("append requires file")
+		}
+		paths = []string{s.Append.File}
+	}
+	for _, p := range paths {
+		if !filepath.IsLocal(filepath.FromSlash(p)) {
+			return fmt.Errorf("path %q must be relative to and inside the downstream", p)
+		}
+	}
+	for _, p := range patterns {
+		if _, err := glob.Compile(p); err != nil {
+			return fmt.Errorf("invalid pattern %q: %v", p, err)
+		}
+	}
+	for _, p := range append(paths, patterns...) {
+		if InGitSporkDir(p) {
+			return fmt.Errorf("path %q is under %s, which only gitspork writes", p, GitSporkDirName)
+		}
+	}
+	return nil
 }
 
-// GitSporkConfigTemplated is a single templated/render template instruction from upstream -> downstream
+// GitSporkConfigTemplated is a single templated/render template instruction from upstream -> downstream.
+// It is either a single file (Template + Destination) or a directory tree (TemplateDir + DestinationDir).
 type GitSporkConfigTemplated struct {
-	Template    string                         `yaml:"template" comment:"source path of the Go template file to use in the upstream"`
-	Destination string                         `yaml:"destination" comment:"destination path and file name in the dowstream where the template will be rendered"`
-	Inputs      []GitSporkConfigTemplatedInput `yaml:"inputs" comment:"list of inputs to provide to the template, and how to determine them"`
-	Merged      *GitSporkConfigTemplatedMerged `yaml:"merged,omitempty" comment:"optional instruction for merging with pre-existing file in the destination, if present, post-render"`
+	Template       string                         `yaml:"template,omitempty" comment:"(one-of required) source path of the Go template file to use in the upstream"`
+	Destination    string                         `yaml:"destination,omitempty" comment:"destination path and file name in the dowstream where the template will be rendered"`
+	TemplateDir    string                         `yaml:"template_dir,omitempty" comment:"(one-of required) source directory in the upstream whose files are all rendered as Go templates; file and directory names may contain template expressions too, and a file whose rendered path has an empty segment is skipped"`
+	DestinationDir string                         `yaml:"destination_dir,omitempty" comment:"destination directory in the downstream where the template_dir tree is rendered"`
+	Include        []string                       `yaml:"include,omitempty" comment:"(optional, template_dir only) file patterns (https://github.com/gobwas/glob), relative to template_dir, to render; defaults to all files"`
+	Exclude        []string                       `yaml:"exclude,omitempty" comment:"(optional, template_dir only) file patterns (https://github.com/gobwas/glob), relative to template_dir, to leave out"`
+	Raw            []string                       `yaml:"raw,omitempty" comment:"(optional, template_dir only) file patterns (https://github.com/gobwas/glob), relative to template_dir, whose contents are copied verbatim rather than rendered, e.g. binaries; their paths are still rendered"`
+	Inputs         []GitSporkConfigTemplatedInput `yaml:"inputs" comment:"list of inputs to provide to the template, and how to determine them"`
+	Merged         *GitSporkConfigTemplatedMerged `yaml:"merged,omitempty" comment:"optional instruction for merging with pre-existing file in the destination, if present, post-render (single-file templates only)"`
+	Render         string                         `yaml:"render,omitempty" comment:"(optional) 'always' (default) re-renders on every integrate; 'once' renders only if the destination doesn't exist yet, after which the downstream owns it"`
+	When           string                         `yaml:"when,omitempty" comment:"(optional) template pipeline, without braces, evaluated like '{{ if ... }}' against the instruction's inputs, e.g. '.Inputs.uses_docker'; renders only when true, and a destination rendered earlier is removed when it turns false"`
+}
+
+// IsDir reports whether the instruction renders a directory tree (template_dir)
+// rather than a single template file.
+func (t GitSporkConfigTemplated) IsDir() bool {
+	return t.TemplateDir != ""
+}
+
+// Source returns the upstream template file or directory of the instruction.
+// It is the name previous_input.template refers to.
+func (t GitSporkConfigTemplated) Source() string {
+	if t.IsDir() {
+		return t.TemplateDir
+	}
+	return t.Template
+}
+
+// RenderOnce reports whether the instruction only seeds its destination.
+func (t GitSporkConfigTemplated) RenderOnce() bool {
+	return t.Render == TemplatedRenderOnce
+}
+
+// Target returns the downstream destination file or directory of the instruction.
+func (t GitSporkConfigTemplated) Target() string {
+	if t.IsDir() {
+		return t.DestinationDir
+	}
+	return t.Destination
+}
+
+// Validate reports a configuration error if the instruction mixes the
+// single-file and directory forms, is missing its destination or has it under
+// .gitspork, has an invalid render mode or glob, or has an invalid input
+// definition.
+func (t GitSporkConfigTemplated) Validate() error {
+	switch {
+	case t.Template != "" && t.TemplateDir != "":
+		return fmt.Errorf("only one of template or template_dir may be set")
+	case t.Template == "" && t.TemplateDir == "":
+		return fmt.Errorf("one of template or template_dir is required")
+	case t.IsDir() && (t.Destination != "" || t.DestinationDir == ""):
+		return fmt.Errorf("template_dir %s requires destination_dir (and not destination)", t.TemplateDir)
+	case !t.IsDir() && (t.DestinationDir != "" || t.Destination == ""):
+		return fmt.Errorf("template %s requires destination (and not destination_dir)", t.Template)
+	case !t.IsDir() && (len(t.Include) > 0 || len(t.Exclude) > 0 || len(t.Raw) > 0):
+		return fmt.Errorf("template %s: include, exclude and raw are only valid with template_dir", t.Template)
+	case t.IsDir() && t.Merged != nil:
+		return fmt.Errorf("template_dir %s: merged is only supported for single-file templates", t.TemplateDir)
+	case t.Render != "" && t.Render != TemplatedRenderAlways && t.Render != TemplatedRenderOnce:
+		return fmt.Errorf("invalid render %q, expects one of: %s, %s", t.Render, TemplatedRenderAlways, TemplatedRenderOnce)
+	case t.Merged != nil && t.Merged.Structured != "" && t.Merged.Blocks:
+		return fmt.Errorf("template %s: merged takes only one of structured or blocks", t.Template)
+	case t.RenderOnce() && t.Merged != nil:
+		return fmt.Errorf("merged has no effect with render: %s, which never renders over an existing destination", TemplatedRenderOnce)
+	case InGitSporkDir(t.Target()):
+		return fmt.Errorf("destination %s is under %s, which only gitspork writes", t.Target(), GitSporkDirName)
+	}
+	for _, patterns := range [][]string{t.Include, t.Exclude, t.Raw} {
+		for _, p := range patterns {
+			if _, err := glob.Compile(p); err != nil {
+				return fmt.Errorf("invalid glob pattern %q: %v", p, err)
+			}
+		}
+	}
+	for _, in := range t.Inputs {
+		if err := in.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // GitSporkConfigTemplatedInput
 type GitSporkConfigTemplatedInput struct {
-	Name          string                                `yaml:"name" comment:"name of the input as defined in the template like 'index .Inputs \"[name]\"'"`
-	Prompt        string                                `yaml:"prompt,omitempty" comment:"(optional, one-of required) prompt to present to the user in order to gather the input value"`
-	JSONDataPath  string                                `yaml:"json_data_path,omitempty" comment:"(optional, one-of required) JSON data file path (relative to the downstream path) containing the input value at the root property equal to the 'name'. Contract is that downstream is responsible for maintaining this path."`
-	PreviousInput *GitSporkConfigTemplatedInputPrevious `yaml:"previous_input,omitempty" comment:"(optional, one-of-required) reference to an input already known from this template or another template defined before this one"`
+	Name            string                                `yaml:"name" comment:"name of the input as defined in the template like 'index .Inputs \"[name]\"'"`
+	Prompt          string                                `yaml:"prompt,omitempty" comment:"(optional, one-of required) prompt to present to the user in order to gather the input value"`
+	JSONDataPath    string                                `yaml:"json_data_path,omitempty" comment:"(optional, one-of required) JSON data file path (relative to the downstream path) containing the input value at the root property equal to the 'name'. Contract is that downstream is responsible for maintaining this path."`
+	YAMLDataPath    string                                `yaml:"yaml_data_path,omitempty" comment:"(optional, one-of required) like json_data_path, for a YAML data file"`
+	DataKey         string                                `yaml:"data_key,omitempty" comment:"(optional, json_data_path/yaml_data_path only) key path selecting a nested value in the data file rather than the root property named like the input, e.g. '$.service.owner' or '$.contributors[0].name'"`
+	Env             string                                `yaml:"env,omitempty" comment:"(optional, one-of required) env var holding the value when integrating"`
+	Exec            string                                `yaml:"exec,omitempty" comment:"(optional, one-of required) command run in the downstream repo whose trimmed stdout is the value, e.g. 'go list -m'; split on whitespace with no quoting, and killed after 2 minutes"`
+	PreviousInput   *GitSporkConfigTemplatedInputPrevious `yaml:"previous_input,omitempty" comment:"(optional, one-of-required) reference to an input already known from this template or another template defined before this one"`
+	Type            string                                `yaml:"type,omitempty" comment:"(optional) type of the value: 'string' (default), 'bool', 'int', 'select' or 'multi-select'; the template receives a correspondingly typed value (multi-select is a list of strings)"`
+	Options         []string                              `yaml:"options,omitempty" comment:"(required for select/multi-select) the allowed values"`
+	Default         string                                `yaml:"default,omitempty" comment:"(optional) value used when a prompt is answered empty or a data source lacks the input; comma-separated for multi-select"`
+	Required        bool                                  `yaml:"required,omitempty" comment:"(optional) if true, an empty value is rejected (and re-prompted for prompt inputs)"`
+	ValidateRegex   string                                `yaml:"validate,omitempty" comment:"(optional) regular expression every value must match; applied to each selection of a multi-select"`
+	ValidateMessage string                                `yaml:"validate_message,omitempty" comment:"(optional) message shown when the value does not match 'validate'"`
+	Help            string                                `yaml:"help,omitempty" comment:"(optional) additional help text shown above the prompt"`
+	Secret          bool                                  `yaml:"secret,omitempty" comment:"(optional) if true, the value is never written to the inputs cache: it's taken from supplied inputs, GITSPORK_INPUT_<NAME>, secret_env or secret_command, or else prompted for with masked entry, on every integrate"`
+	SecretEnv       string                                `yaml:"secret_env,omitempty" comment:"(optional, secret only) env var holding the value, e.g. one a CI system already provides"`
+	SecretCommand   string                                `yaml:"secret_command,omitempty" comment:"(optional, secret only) command run in the downstream repo whose trimmed stdout is the value, e.g. 'op read op://vault/item/field'; split on whitespace with no quoting, and killed after 2 minutes"`
+	Global          bool                                  `yaml:"global,omitempty" comment:"(optional) if true, the value is shared by name with every template, from any upstream, that also declares it global: it's kept in the downstream's hand-editable .gitspork/inputs.yml rather than per destination, so it's only asked for once"`
+}
+
+// InputType returns the input's declared type, defaulting to TemplatedInputTypeString.
+func (i GitSporkConfigTemplatedInput) InputType() string {
+	if i.Type == "" {
+		return TemplatedInputTypeString
+	}
+	return i.Type
+}
+
+// Validate reports a configuration error if the input definition is malformed:
+// an unknown type, a select/multi-select without options, options on a
+// non-select type, a validate expression or data_key that does not compile,
+// conflicting data files, or a secret or global input with another source. The
+// default value itself is checked at integrate time alongside every other
+// value, so a bad default surfaces with the same message a bad answer would.
+func (i GitSporkConfigTemplatedInput) Validate() error {
+	if i.Name == "" {
+		return fmt.Errorf("templated input is missing a name")
+	}
+	switch i.InputType() {
+	case TemplatedInputTypeString, TemplatedInputTypeBool, TemplatedInputTypeInt:
+		if len(i.Options) > 0 {
+			return fmt.Errorf("templated input %s: options are only valid for %s/%s types", i.Name, TemplatedInputTypeSelect, TemplatedInputTypeMultiSelect)
+		}
+	case TemplatedInputTypeSelect, TemplatedInputTypeMultiSelect:
+		if len(i.Options) == 0 {
+			return fmt.Errorf("templated input %s: type %s requires a non-empty options list", i.Name, i.Type)
+		}
+	default:
+		return fmt.Errorf("templated input %s: invalid type %q, expects one of: %s, %s, %s, %s, %s", i.Name, i.Type,
+			TemplatedInputTypeString, TemplatedInputTypeBool, TemplatedInputTypeInt, TemplatedInputTypeSelect, TemplatedInputTypeMultiSelect)
+	}
+	if i.ValidateRegex != "" {
+		if _, err := regexp.Compile(i.ValidateRegex); err != nil {
+			return fmt.Errorf("templated input %s: invalid validate expression %q: %v", i.Name, i.ValidateRegex, err)
+		}
+	}
+	switch {
+	case i.JSONDataPath != "" && i.YAMLDataPath != "":
+		return fmt.Errorf("templated input %s: only one of json_data_path or yaml_data_path may be set", i.Name)
+	case i.DataKey != "" && i.JSONDataPath == "" && i.YAMLDataPath == "":
+		return fmt.Errorf("templated input %s: data_key requires json_data_path or yaml_data_path", i.Name)
+	case !i.Secret && (i.SecretEnv != "" || i.SecretCommand != ""):
+		return fmt.Errorf("templated input %s: secret_env and secret_command require secret: true", i.Name)
+	case i.Secret && i.InputType() != TemplatedInputTypeString:
+		return fmt.Errorf("templated input %s: secret inputs must be of type %s", i.Name, TemplatedInputTypeString)
+	case i.Secret && i.hasNonPromptSource():
+		return fmt.Errorf("templated input %s: secret inputs can't use a data file, env, exec or previous_input; use secret_env or secret_command", i.Name)
+	case i.Global && (i.Secret || i.hasNonPromptSource()):
+		return fmt.Errorf("templated input %s: global inputs can't be secret or use a data file, env, exec or previous_input", i.Name)
+	}
+	if i.DataKey != "" {
+		if _, err := yaml.PathString(i.DataKey); err != nil {
+			return fmt.Errorf("templated input %s: invalid data_key %q: %v", i.Name, i.DataKey, err)
+		}
+	}
+	return nil
+}
+
+// hasNonPromptSource reports whether the input takes its value from a data
+// file, env var, command or previous input rather than a prompt.
+func (i GitSporkConfigTemplatedInput) hasNonPromptSource() bool {
+	return i.JSONDataPath != "" || i.YAMLDataPath != "" || i.Env != "" || i.Exec != "" || i.PreviousInput != nil
 }
 
 type GitSporkConfigTemplatedInputPrevious struct {
@@ -85,7 +509,8 @@ type GitSporkConfigTemplatedInputPrevious struct {
 
 // GitSporkConfigTemplatedMerged
 type GitSporkConfigTemplatedMerged struct {
-	Structured string `yaml:"structured" comment:"instruction for a structured merged post-render, either 'prefer-upstream' or 'prefer-downstream'"`
+	Structured string `yaml:"structured,omitempty" comment:"instruction for a structured merged post-render, either 'prefer-upstream' or 'prefer-downstream'"`
+	Blocks     bool   `yaml:"blocks,omitempty" comment:"merge like shared_ownership.merged: only the rendered upstream-owned blocks (::gitspork::begin-upstream-owned-block ... ::gitspork::end-upstream-owned-block) replace those in the existing file, and the rest of it stays downstream-owned"`
 }
 
 // ParseGitSporkConfig will parse a .gitspork.yml config file at the provided path
@@ -104,15 +529,78 @@ func ParseGitSporkConfig(gitSporkConfigFilePath string) (*GitSporkConfig, error)
 		if err := e.Validate(); err != nil {
 			return config, fmt.Errorf("invalid upstream_owned entry in %s: %v", gitSporkConfigFilePath, err)
 		}
+		if dest := e.DestPattern(); InGitSporkDir(dest) {
+			return config, fmt.Errorf("invalid upstream_owned entry in %s: %s is under %s, which only gitspork writes", gitSporkConfigFilePath, dest, GitSporkDirName)
+		}
 	}
 	for _, e := range config.DownstreamOwned {
 		if err := e.Validate(); err != nil {
 			return config, fmt.Errorf("invalid downstream_owned entry in %s: %v", gitSporkConfigFilePath, err)
 		}
 	}
+	for _, t := range config.Templated {
+		if err := t.Validate(); err != nil {
+			return config, fmt.Errorf("invalid templated entry for %s in %s: %v", t.Source(), gitSporkConfigFilePath, err)
+		}
+	}
+	for _, p := range config.TemplatePartials {
+		if _, err := glob.Compile(p); err != nil {
+			return config, fmt.Errorf("invalid template_partials pattern %q in %s: %v", p, gitSporkConfigFilePath, err)
+		}
+	}
 	return config, nil
 }
 
+// KeyPathSegment is one step of a key path: a mapping key, or a sequence index
+// when Index is 0 or more.
+type KeyPathSegment struct {
+	Key   string
+	Index int
+}
+
+// ParseKeyPath splits a key path like '$.jobs.build.steps[0].uses' into its
+// segments. The leading '$' and the dot after it are optional, and keys can't
+// contain '.' or '['.
+func ParseKeyPath(keyPath string) ([]KeyPathSegment, error) {
+	rest := strings.TrimPrefix(keyPath, "$")
+	rest = strings.TrimPrefix(rest, ".")
+	var segments []KeyPathSegment
+	for rest != "" {
+		if strings.HasPrefix(rest, "[") {
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("invalid key path %q: unclosed '['", keyPath)
+			}
+			idx, err := strconv.Atoi(rest[1:end])
+			if err != nil || idx < 0 {
+				return nil, fmt.Errorf("invalid key path %q: index %q isn't a non-negative integer", keyPath, rest[1:end])
+			}
+			segments = append(segments, KeyPathSegment{Index: idx})
+			rest = strings.TrimPrefix(rest[end+1:], ".")
+			continue
+		}
+		end := strings.IndexAny(rest, ".[")
+		if end < 0 {
+			end = len(rest)
+		}
+		if end == 0 {
+			return nil, fmt.Errorf("invalid key path %q: empty key", keyPath)
+		}
+		segments = append(segments, KeyPathSegment{Key: rest[:end], Index: -1})
+		rest = rest[end:]
+		if strings.HasPrefix(rest, ".") {
+			rest = rest[1:]
+			if rest == "" {
+				return nil, fmt.Errorf("invalid key path %q: empty key", keyPath)
+			}
+		}
+	}
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("invalid key path %q: no keys", keyPath)
+	}
+	return segments, nil
+}
+
 // ParseMigrationConfig will read a migration config YAML file, parse its instruction and return the parsed data
 func ParseMigrationConfig(migrationConfigPath string) (*GitSporkConfigMigration, error) {
 	migration := &GitSporkConfigMigration{}
@@ -124,6 +612,31 @@ func ParseMigrationConfig(migrationConfigPath string) (*GitSporkConfigMigration,
 	if err != nil {
 		return migration, fmt.Errorf("error parsing gitspork migration config file %s: %v", migrationConfigPath, err)
 	}
+	if migration.ID != "" {
+		if err := validateMigrationID(migration.ID); err != nil {
+			return migration, fmt.Errorf("invalid id in gitspork migration config file %s: %v", migrationConfigPath, err)
+		}
+	}
+	for _, alias := range migration.Aliases {
+		if err := validateMigrationID(alias); err != nil {
+			return migration, fmt.Errorf("invalid aliases in gitspork migration config file %s: %v", migrationConfigPath, err)
+		}
+	}
+	if migration.AppliesIf != nil {
+		if err := migration.AppliesIf.Validate(); err != nil {
+			return migration, fmt.Errorf("invalid applies_if in gitspork migration config file %s: %v", migrationConfigPath, err)
+		}
+	}
+	if migration.PreIntegrate != nil {
+		if err := migration.PreIntegrate.Validate(); err != nil {
+			return migration, fmt.Errorf("invalid pre_integrate in gitspork migration config file %s: %v", migrationConfigPath, err)
+		}
+	}
+	if migration.PostIntegrate != nil {
+		if err := migration.PostIntegrate.Validate(); err != nil {
+			return migration, fmt.Errorf("invalid post_integrate in gitspork migration config file %s: %v", migrationConfigPath, err)
+		}
+	}
 	return migration, nil
 }
 
@@ -161,6 +674,19 @@ func GetGitSporkConfigSchema() (string, string, error) {
 						Name:         "input_two",
 						JSONDataPath: "./.json/data.json",
 					},
+					{
+						Name:         "owner",
+						YAMLDataPath: "service.yml",
+						DataKey:      "$.service.owner",
+					},
+					{
+						Name: "registry",
+						Env:  "CONTAINER_REGISTRY",
+					},
+					{
+						Name: "module_path",
+						Exec: "go list -m",
+					},
 					{
 						Name: "input_three",
 						PreviousInput: &GitSporkConfigTemplatedInputPrevious{
@@ -168,17 +694,96 @@ func GetGitSporkConfigSchema() (string, string, error) {
 							Name:     "input_one",
 						},
 					},
+					{
+						Name:            "input_four",
+						Prompt:          "Which environment does this service deploy to?",
+						Type:            TemplatedInputTypeSelect,
+						Options:         []string{"dev", "staging", "prod"},
+						Default:         "dev",
+						Required:        true,
+						ValidateRegex:   "^[a-z]+$",
+						ValidateMessage: "must be lower-case letters only",
+						Help:            "Pick the first environment this service is deployed to.",
+					},
+				},
+			},
+			{
+				Template:    "Dockerfile.go.tmpl",
+				Destination: "Dockerfile",
+				When:        ".Inputs.uses_docker",
+				Inputs: []GitSporkConfigTemplatedInput{
+					{
+						Name:   "uses_docker",
+						Prompt: "Is this service built as a container image?",
+						Type:   TemplatedInputTypeBool,
+					},
+				},
+			},
+			{
+				Template:    "CHANGELOG.md.go.tmpl",
+				Destination: "CHANGELOG.md",
+				Render:      TemplatedRenderOnce,
+			},
+			{
+				Template:    "Makefile.go.tmpl",
+				Destination: "Makefile",
+				Merged: &GitSporkConfigTemplatedMerged{
+					Blocks: true,
+				},
+			},
+			{
+				TemplateDir:    "templates/service",
+				DestinationDir: "services",
+				Include:        []string{"*"},
+				Exclude:        []string{"*.md"},
+				Raw:            []string{"*.png"},
+				Inputs: []GitSporkConfigTemplatedInput{
+					{
+						Name:   "service_name",
+						Prompt: "What is the name of the service?",
+					},
+					{
+						Name:   "team",
+						Prompt: "Which team owns this repo?",
+						Global: true,
+					},
+					{
+						Name:          "registry_token",
+						Prompt:        "Token for pushing to the container registry?",
+						Secret:        true,
+						SecretEnv:     "REGISTRY_TOKEN",
+						SecretCommand: "op read op://platform/registry/token",
+					},
 				},
 			},
 		},
-		Migrations: []string{".gitspork/migrations/0001/migration.yml"},
+		TemplatePartials: []string{"templates/partials/*.tmpl"},
+		Migrations:       []string{".gitspork/migrations/0001/migration.yml"},
 	}
 	migrationExampleConfig := &GitSporkConfigMigration{
+		ID:        "0001-split-services",
+		Aliases:   []string{".gitspork/migrations/split-services.yml"},
+		AppliesIf: &GitSporkConfigMigrationAppliesIf{Integration: MigrationIntegrationUpgrade, PreviousAncestorOf: "main", PreviousBefore: "v2.0.0"},
 		PreIntegrate: &GitSporkConfigMigrationInstructions{
-			Exec: "./.gitspork/migrations/0001/pre-integrate.sh",
+			Exec:       "./.gitspork/migrations/0001/pre-integrate.sh",
+			Timeout:    "5m",
+			Env:        map[string]string{"LOG_LEVEL": "debug"},
+			WorkingDir: "services",
 		},
 		PostIntegrate: &GitSporkConfigMigrationInstructions{
-			Exec: "./.gitspork/migrations/0001/post-integrate.sh",
+			Steps: []GitSporkConfigMigrationStep{
+				{Name: "move the ci config", Move: &GitSporkConfigMigrationMove{From: ".ci/config.yml", To: ".github/ci.yml"}},
+				{Delete: []string{"scripts/legacy-*.sh"}},
+				{Replace: &GitSporkConfigMigrationReplace{Files: []string{"**/*.go"}, Pattern: "example.com/old/([a-z]+)", With: "example.com/new/${1}"}},
+				{Patch: &GitSporkConfigMigrationPatch{
+					File:   "package.json",
+					Set:    map[string]any{"$.scripts.lint": "eslint ."},
+					Delete: []string{"$.scripts.legacy"},
+				}},
+				{EnsureLine: &GitSporkConfigMigrationEnsureLine{File: ".gitignore", Line: "/dist"}},
+				{Append: &GitSporkConfigMigrationAppend{File: "CHANGELOG.md", Content: "- migrated to the v2 layout\n"}},
+				{Shell: "go mod tidy && go generate ./...", Env: map[string]string{"GOFLAGS": "-mod=mod"}},
+			},
 		},
 	}
 	renderedMain, err := marshal.YAMLWithComments(gitSporkExampleConfig, 0)
@@ -0,0 +1,121 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGitSporkConfigTemplatedInput_Validate(t *testing.T) {
+	cases := []struct {
+		name    string
+		input   GitSporkConfigTemplatedInput
+		wantErr string
+	}{
+		{"plain string", GitSporkConfigTemplatedInput{Name: "a", Prompt: "a?"}, ""},
+		{"explicit types", GitSporkConfigTemplatedInput{Name: "a", Type: TemplatedInputTypeInt}, ""},
+		{"select with options", GitSporkConfigTemplatedInput{Name: "a", Type: TemplatedInputTypeSelect, Options: []string{"x"}}, ""},
+		{"missing name", GitSporkConfigTemplatedInput{Prompt: "a?"}, "missing a name"},
+		{"unknown type", GitSporkConfigTemplatedInput{Name: "a", Type: "float"}, `invalid type "float"`},
+		{"select without options", GitSporkConfigTemplatedInput{Name: "a", Type: TemplatedInputTypeMultiSelect}, "requires a non-empty options list"},
+		{"options on string", GitSporkConfigTemplatedInput{Name: "a", Options: []string{"x"}}, "options are only valid"},
+		{"bad regex", GitSporkConfigTemplatedInput{Name: "a", ValidateRegex: "("}, "invalid validate expression"},
+		{"secret", GitSporkConfigTemplatedInput{Name: "a", Prompt: "a?", Secret: true, SecretEnv: "A", SecretCommand: "get-a"}, ""},
+		{"secret source without secret", GitSporkConfigTemplatedInput{Name: "a", Prompt: "a?", SecretEnv: "A"}, "require secret: true"},
+		{"non-string secret", GitSporkConfigTemplatedInput{Name: "a", Secret: true, Type: TemplatedInputTypeBool}, "secret inputs must be of type string"},
+		{"global", GitSporkConfigTemplatedInput{Name: "a", Prompt: "a?", Global: true}, ""},
+		{"global secret", GitSporkConfigTemplatedInput{Name: "a", Prompt: "a?", Global: true, Secret: true}, "global inputs can't be secret"},
+		{"nested yaml data", GitSporkConfigTemplatedInput{Name: "a", YAMLDataPath: "a.yml", DataKey: "$.a.b[0]"}, ""},
+		{"two data files", GitSporkConfigTemplatedInput{Name: "a", JSONDataPath: "a.json", YAMLDataPath: "a.yml"}, "only one of json_data_path or yaml_data_path"},
+		{"data_key without a data file", GitSporkConfigTemplatedInput{Name: "a", Prompt: "a?", DataKey: "$.a"}, "data_key requires"},
+		{"bad data_key", GitSporkConfigTemplatedInput{Name: "a", JSONDataPath: "a.json", DataKey: "a.b"}, "invalid data_key"},
+		{"secret from env", GitSporkConfigTemplatedInput{Name: "a", Secret: true, Env: "A"}, "use secret_env or secret_command"},
+		{"secret from a data file", GitSporkConfigTemplatedInput{Name: "a", Secret: true, JSONDataPath: "a.json"}, "secret inputs can't use a data file"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.input.Validate()
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.wantErr)
+		})
+	}
+}
+
+func TestParseGitSporkConfig_rejectsInvalidTemplatedInput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".gitspork.yml")
+	require.NoError(t, os.WriteFile(path, []byte(`templated:
+  - template: a.tmpl
+    destination: a.txt
+    inputs:
+      - name: env
+        prompt: env?
+        type: select
+`), 0644))
+	_, err := ParseGitSporkConfig(path)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "a.tmpl")
+	assert.Contains(t, err.Error(), "requires a non-empty options list")
+}
+
+func TestGitSporkConfigTemplated_Validate(t *testing.T) {
+	cases := []struct {
+		name     string
+		template GitSporkConfigTemplated
+		wantErr  string
+	}{
+		{"single file", GitSporkConfigTemplated{Template: "a.tmpl", Destination: "a.txt"}, ""},
+		{"directory", GitSporkConfigTemplated{TemplateDir: "tmpl", DestinationDir: "out", Include: []string{"**"}, Raw: []string{"*.png"}}, ""},
+		{"neither", GitSporkConfigTemplated{Destination: "a.txt"}, "one of template or template_dir is required"},
+		{"both", GitSporkConfigTemplated{Template: "a.tmpl", TemplateDir: "tmpl", DestinationDir: "out"}, "only one of template or template_dir"},
+		{"dir with destination", GitSporkConfigTemplated{TemplateDir: "tmpl", Destination: "out"}, "requires destination_dir"},
+		{"file with destination_dir", GitSporkConfigTemplated{Template: "a.tmpl", DestinationDir: "out"}, "requires destination"},
+		{"globs on file", GitSporkConfigTemplated{Template: "a.tmpl", Destination: "a.txt", Raw: []string{"*"}}, "only valid with template_dir"},
+		{"merged on dir", GitSporkConfigTemplated{TemplateDir: "tmpl", DestinationDir: "out", Merged: &GitSporkConfigTemplatedMerged{}}, "merged is only supported"},
+		{"bad glob", GitSporkConfigTemplated{TemplateDir: "tmpl", DestinationDir: "out", Exclude: []string{"["}}, "invalid glob pattern"},
+		{"render once with when", GitSporkConfigTemplated{Template: "a.tmpl", Destination: "a.txt", Render: TemplatedRenderOnce, When: ".Inputs.a"}, ""},
+		{"bad render", GitSporkConfigTemplated{Template: "a.tmpl", Destination: "a.txt", Render: "sometimes"}, `invalid render "sometimes"`},
+		{"blocks merge", GitSporkConfigTemplated{Template: "a.tmpl", Destination: "Makefile", Merged: &GitSporkConfigTemplatedMerged{Blocks: true}}, ""},
+		{"structured and blocks merge", GitSporkConfigTemplated{Template: "a.tmpl", Destination: "a.yml", Merged: &GitSporkConfigTemplatedMerged{Structured: TemplatedMergeStructuredPreferUpstream, Blocks: true}}, "only one of structured or blocks"},
+		{"merged with render once", GitSporkConfigTemplated{Template: "a.tmpl", Destination: "a.txt", Render: TemplatedRenderOnce, Merged: &GitSporkConfigTemplatedMerged{}}, "merged has no effect"},
+		{"destination under .gitspork", GitSporkConfigTemplated{Template: "a.tmpl", Destination: "./.gitspork/trust.yml"}, "is under .gitspork"},
+		{"destination_dir .gitspork", GitSporkConfigTemplated{TemplateDir: "tmpl", DestinationDir: ".gitspork"}, "is under .gitspork"},
+		{"destination beside .gitspork", GitSporkConfigTemplated{Template: "a.tmpl", Destination: ".gitspork.d/a.txt"}, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.template.Validate()
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.wantErr)
+		})
+	}
+}
+
+func TestParseGitSporkConfig_rejectsUpstreamOwnedUnderGitSporkDir(t *testing.T) {
+	for _, entry := range []string{"- .gitspork/trust.yml", "- .gitspork/**", "- {from: state.json, to: .gitspork/downstream-state.json}"} {
+		path := filepath.Join(t.TempDir(), ".gitspork.yml")
+		require.NoError(t, os.WriteFile(path, []byte("upstream_owned:\n  "+entry+"\n"), 0644))
+		_, err := ParseGitSporkConfig(path)
+		require.ErrorContains(t, err, "which only gitspork writes", entry)
+	}
+}
+
+func TestParseGitSporkConfig_rejectsInvalidTemplatePartials(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".gitspork.yml")
+	require.NoError(t, os.WriteFile(path, []byte(`template_partials:
+  - "partials/[.tmpl"
+`), 0644))
+	_, err := ParseGitSporkConfig(path)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid template_partials pattern "partials/[.tmpl"`)
+}
@@ -0,0 +1,96 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gobwas/glob"
+	"github.com/goccy/go-yaml"
+)
+
+// DriftIgnoreFileName is the downstream file, under .gitspork, listing drift
+// the downstream accepts.
+const DriftIgnoreFileName string = "drift-ignore.yml"
+
+// DriftIgnore is the downstream's .gitspork/drift-ignore.yml. It is written by
+// hand in the downstream, never by an upstream.
+type DriftIgnore struct {
+	Ignore []DriftIgnoreRule `yaml:"ignore"`
+}
+
+// DriftIgnoreRule accepts drift in the files matching Paths.
+type DriftIgnoreRule struct {
+	Paths         []string `yaml:"paths" comment:"file patterns (https://github.com/gobwas/glob), relative to the downstream root, whose drift is accepted"`
+	Justification string   `yaml:"justification,omitempty" comment:"(optional) why the drift is accepted, shown alongside the ignored files"`
+	Expires       string   `yaml:"expires,omitempty" comment:"(optional) date, as YYYY-MM-DD, through which the rule applies; after it the drift is reported again"`
+	Upstream      string   `yaml:"upstream,omitempty" comment:"(optional) upstream URL pattern (https://github.com/gobwas/glob), like 'github.com/my-org/*', limiting the rule to files attributed to matching upstreams; matched against the URL lowercased, without http(s):// or .git suffix, and with git@host:org/repo as host/org/repo"`
+}
+
+// Expired reports whether the rule's expiry date has passed at now.
+func (r DriftIgnoreRule) Expired(now time.Time) bool {
+	if r.Expires == "" {
+		return false
+	}
+	expires, err := time.Parse(time.DateOnly, r.Expires)
+	return err == nil && !now.Before(expires.AddDate(0, 0, 1))
+}
+
+// Match returns the first of the rule's Paths matching path, a slash-separated
+// path relative to the downstream root, that drifted from the upstream at
+// normalizedURL, or "" when the rule doesn't cover it.
+func (r DriftIgnoreRule) Match(path string, normalizedURL string) string {
+	if r.Upstream != "" {
+		g, err := glob.Compile(strings.ToLower(r.Upstream))
+		if err != nil || !g.Match(normalizedURL) {
+			return ""
+		}
+	}
+	for _, pattern := range r.Paths {
+		g, err := glob.Compile(pattern)
+		if err == nil && g.Match(path) {
+			return pattern
+		}
+	}
+	return ""
+}
+
+// ParseDriftIgnore reads the drift ignore file at driftIgnorePath. A missing
+// file ignores nothing.
+func ParseDriftIgnore(driftIgnorePath string) (*DriftIgnore, error) {
+	driftIgnore := &DriftIgnore{}
+	f, err := os.ReadFile(driftIgnorePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return driftIgnore, nil
+	}
+	if err != nil {
+		return driftIgnore, fmt.Errorf("error reading gitspork drift ignore file %s: %v", driftIgnorePath, err)
+	}
+	if err := yaml.Unmarshal(f, driftIgnore); err != nil {
+		return driftIgnore, fmt.Errorf("error parsing gitspork drift ignore file %s: %v", driftIgnorePath, err)
+	}
+	for idx, rule := range driftIgnore.Ignore {
+		if len(rule.Paths) == 0 {
+			return driftIgnore, fmt.Errorf("invalid ignore[%d] in %s: paths needs at least one pattern", idx, driftIgnorePath)
+		}
+		for _, pattern := range rule.Paths {
+			if _, err := glob.Compile(pattern); err != nil {
+				return driftIgnore, fmt.Errorf("invalid ignore[%d].paths pattern %q in %s: %v", idx, pattern, driftIgnorePath, err)
+			}
+		}
+		if rule.Expires != "" {
+			if _, err := time.Parse(time.DateOnly, rule.Expires); err != nil {
+				return driftIgnore, fmt.Errorf("invalid ignore[%d].expires %q in %s: expects a YYYY-MM-DD date", idx, rule.Expires, driftIgnorePath)
+			}
+		}
+		if rule.Upstream != "" {
+			if _, err := glob.Compile(strings.ToLower(rule.Upstream)); err != nil {
+				return driftIgnore, fmt.Errorf("invalid ignore[%d].upstream pattern %q in %s: %v", idx, rule.Upstream, driftIgnorePath, err)
+			}
+		}
+	}
+	return driftIgnore, nil
+}
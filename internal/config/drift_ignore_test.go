@@ -0,0 +1,67 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ParseDriftIgnore(t *testing.T) {
+	write := func(t *testing.T, content string) string {
+		t.Helper()
+		path := filepath.Join(t.TempDir(), DriftIgnoreFileName)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		return path
+	}
+
+	t.Run("missing file ignores nothing", func(t *testing.T) {
+		driftIgnore, err := ParseDriftIgnore(filepath.Join(t.TempDir(), DriftIgnoreFileName))
+		require.NoError(t, err)
+		assert.Empty(t, driftIgnore.Ignore)
+	})
+
+	t.Run("rules match paths within their upstream scope", func(t *testing.T) {
+		driftIgnore, err := ParseDriftIgnore(write(t, `ignore:
+- paths: [.github/workflows/*.yml]
+  justification: waiting on the upstream fix
+  expires: 2026-03-31
+  upstream: GitHub.com/Acme/*
+- paths: [docs/**]
+`))
+		require.NoError(t, err)
+		require.Len(t, driftIgnore.Ignore, 2)
+		scoped, unscoped := driftIgnore.Ignore[0], driftIgnore.Ignore[1]
+		assert.Equal(t, ".github/workflows/*.yml", scoped.Match(".github/workflows/ci.yml", "github.com/acme/platform"))
+		assert.Empty(t, scoped.Match(".github/workflows/ci.yml", "github.com/other/platform"))
+		assert.Empty(t, scoped.Match("Makefile", "github.com/acme/platform"))
+		assert.Equal(t, "docs/**", unscoped.Match("docs/guide/intro.md", ""))
+	})
+
+	t.Run("rules apply through their expiry date", func(t *testing.T) {
+		rule := DriftIgnoreRule{Paths: []string{"*"}, Expires: "2026-03-31"}
+		assert.False(t, rule.Expired(time.Date(2026, 3, 31, 23, 59, 0, 0, time.UTC)))
+		assert.True(t, rule.Expired(time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)))
+		assert.False(t, DriftIgnoreRule{Paths: []string{"*"}}.Expired(time.Now()))
+	})
+
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{name: "rule without paths", content: "ignore:\n- justification: nothing\n", wantErr: "paths needs at least one pattern"},
+		{name: "invalid path pattern", content: "ignore:\n- paths: ['ci/[x']\n", wantErr: "invalid ignore[0].paths pattern"},
+		{name: "invalid expiry", content: "ignore:\n- paths: [ci.yml]\n  expires: next week\n", wantErr: "expects a YYYY-MM-DD date"},
+		{name: "invalid upstream pattern", content: "ignore:\n- paths: [ci.yml]\n  upstream: 'github.com/[acme'\n", wantErr: "invalid ignore[0].upstream pattern"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseDriftIgnore(write(t, tt.content))
+			require.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
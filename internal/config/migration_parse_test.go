@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -59,6 +60,84 @@ func Test_ParseMigrationConfig(t *testing.T) {
 		assert.Contains(t, err.Error(), "error reading gitspork migration config file")
 	})
 
+	t.Run("steps", func(t *testing.T) {
+		p := writeYAML(t, `post_integrate:
+  steps:
+  - name: relocate ci
+    move: {from: .ci/config.yml, to: .github/ci.yml}
+  - patch:
+      file: package.json
+      set:
+        $.scripts.lint: eslint .
+  - shell: make tidy
+    env: {GOFLAGS: -mod=mod}
+`)
+		m, err := ParseMigrationConfig(p)
+		require.NoError(t, err)
+		require.Len(t, m.PostIntegrate.Steps, 3)
+		assert.Equal(t, "relocate ci", m.PostIntegrate.Steps[0].Name)
+		assert.Equal(t, ".github/ci.yml", m.PostIntegrate.Steps[0].Move.To)
+		assert.Equal(t, map[string]any{"$.scripts.lint": "eslint ."}, m.PostIntegrate.Steps[1].Patch.Set)
+		assert.Equal(t, map[string]string{"GOFLAGS": "-mod=mod"}, m.PostIntegrate.Steps[2].Env)
+	})
+
+	t.Run("timeout, env and working_dir", func(t *testing.T) {
+		p := writeYAML(t, "pre_integrate:\n  exec: ./a.sh\n  timeout: 90s\n  env: {LOG_LEVEL: debug}\n  working_dir: services/api\n")
+		m, err := ParseMigrationConfig(p)
+		require.NoError(t, err)
+		assert.Equal(t, 90*time.Second, m.PreIntegrate.TimeoutDuration())
+		assert.Equal(t, map[string]string{"LOG_LEVEL": "debug"}, m.PreIntegrate.Env)
+		assert.Equal(t, "services/api", m.PreIntegrate.WorkingDir)
+	})
+
+	t.Run("id and aliases", func(t *testing.T) {
+		p := writeYAML(t, "id: split-services\naliases: [split]\npre_integrate:\n  exec: ./a.sh\npost_integrate:\n  exec: ./b.sh\n")
+		m, err := ParseMigrationConfig(p)
+		require.NoError(t, err)
+		m.SetIDs("m/0001.yml")
+		assert.Equal(t, "split-services:pre_integrate", m.PreIntegrate.ID)
+		assert.Equal(t, []string{"m/0001.yml:pre_integrate", "split:pre_integrate"}, m.PreIntegrate.Aliases)
+		assert.Equal(t, "split-services:post_integrate", m.PostIntegrate.ID)
+	})
+
+	t.Run("applies_if", func(t *testing.T) {
+		p := writeYAML(t, "applies_if:\n  integration: upgrade\n  previous_before: v2.0.0\npre_integrate:\n  exec: ./a.sh\n")
+		m, err := ParseMigrationConfig(p)
+		require.NoError(t, err)
+		require.NotNil(t, m.AppliesIf)
+		assert.Equal(t, MigrationIntegrationUpgrade, m.AppliesIf.Integration)
+		assert.Equal(t, "v2.0.0", m.AppliesIf.PreviousBefore)
+	})
+
+	t.Run("invalid steps", func(t *testing.T) {
+		for name, tc := range map[string]struct{ yaml, wantErr string }{
+			"exec and steps":      {"pre_integrate:\n  exec: a.sh\n  steps:\n  - shell: b\n", "only one of exec or steps"},
+			"two operations":      {"pre_integrate:\n  steps:\n  - shell: a\n    exec: b\n", "step 1: exactly one of"},
+			"no operation":        {"pre_integrate:\n  steps:\n  - name: nothing\n", "step 1: exactly one of"},
+			"env on a file op":    {"pre_integrate:\n  steps:\n  - delete: [a]\n    env: {A: b}\n", "env only applies to exec and shell"},
+			"path outside":        {"post_integrate:\n  steps:\n  - append: {file: ../x, content: y}\n", `path "../x" must be relative to and inside the downstream`},
+			"into .gitspork":      {"post_integrate:\n  steps:\n  - ensure_line: {file: .gitspork/trust.yml, line: 'x'}\n", `path ".gitspork/trust.yml" is under .gitspork`},
+			"glob in .gitspork":   {"pre_integrate:\n  steps:\n  - replace: {files: ['.GitSpork/*.json'], pattern: a, with: b}\n", `path ".GitSpork/*.json" is under .gitspork`},
+			"bad regexp":          {"pre_integrate:\n  steps:\n  - replace: {files: [a], pattern: '(', with: b}\n", "invalid replace pattern"},
+			"bad glob":            {"pre_integrate:\n  steps:\n  - delete: ['[a']\n", `invalid pattern "[a"`},
+			"bad key path":        {"pre_integrate:\n  steps:\n  - patch: {file: a.json, delete: ['a..b']}\n", "empty key"},
+			"patch without edits": {"pre_integrate:\n  steps:\n  - patch: {file: a.json}\n", "patch requires file and at least one of set or delete"},
+			"bad timeout":         {"pre_integrate:\n  exec: a.sh\n  timeout: soon\n", `invalid timeout "soon"`},
+			"negative timeout":    {"pre_integrate:\n  exec: a.sh\n  timeout: -1m\n", `invalid timeout "-1m"`},
+			"working_dir outside": {"pre_integrate:\n  exec: a.sh\n  working_dir: /etc\n", `working_dir "/etc" must be relative to and inside the downstream`},
+			"id with a colon":     {"id: a:b\npre_integrate:\n  exec: a.sh\n", `invalid migration id "a:b"`},
+			"alias with a space":  {"aliases: ['a b']\npre_integrate:\n  exec: a.sh\n", `invalid migration id "a b"`},
+			"unknown integration": {"applies_if: {integration: sometimes}\npre_integrate:\n  exec: a.sh\n", `invalid integration "sometimes"`},
+			"fresh with previous": {"applies_if: {integration: fresh, previous_before: v2}\npre_integrate:\n  exec: a.sh\n", "only apply to upgrades"},
+		} {
+			t.Run(name, func(t *testing.T) {
+				_, err := ParseMigrationConfig(writeYAML(t, tc.yaml))
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.wantErr)
+			})
+		}
+	})
+
 	t.Run("malformed YAML returns wrapped parse error", func(t *testing.T) {
 		p := writeYAML(t, "pre_integrate: [invalid: mapping\n  under: sequence]\n")
 		_, err := ParseMigrationConfig(p)
@@ -66,3 +145,31 @@ func Test_ParseMigrationConfig(t *testing.T) {
 		assert.Contains(t, err.Error(), "error parsing gitspork migration config file")
 	})
 }
+
+func Test_BaseMigrationID(t *testing.T) {
+	for id, want := range map[string][2]string{
+		"0001:pre_integrate":        {"0001", MigrationPhasePreIntegrate},
+		"m/0001.yml:post_integrate": {"m/0001.yml", MigrationPhasePostIntegrate},
+		"0001":                      {"0001", ""},
+	} {
+		base, phase := BaseMigrationID(id)
+		assert.Equal(t, want, [2]string{base, phase}, id)
+	}
+}
+
+func Test_ParseKeyPath(t *testing.T) {
+	for keyPath, want := range map[string][]KeyPathSegment{
+		"$.scripts.lint":           {{Key: "scripts", Index: -1}, {Key: "lint", Index: -1}},
+		"jobs.build.steps[0].uses": {{Key: "jobs", Index: -1}, {Key: "build", Index: -1}, {Key: "steps", Index: -1}, {Index: 0}, {Key: "uses", Index: -1}},
+		"$[1]":                     {{Index: 1}},
+		"name":                     {{Key: "name", Index: -1}},
+	} {
+		got, err := ParseKeyPath(keyPath)
+		require.NoError(t, err, keyPath)
+		assert.Equal(t, want, got, keyPath)
+	}
+	for _, keyPath := range []string{"", "$", "a.", "a..b", "a[x]", "a[-1]", "a[0"} {
+		_, err := ParseKeyPath(keyPath)
+		assert.Error(t, err, keyPath)
+	}
+}
@@ -34,6 +34,15 @@ func (e OwnedEntry) SourcePattern() string {
 	return e.Pattern
 }
 
+// DestPattern returns the pattern of the downstream paths the entry writes:
+// To for a rename entry, otherwise Pattern.
+func (e OwnedEntry) DestPattern() string {
+	if e.IsRename() {
+		return e.To
+	}
+	return e.Pattern
+}
+
 // ResolveDest returns the downstream destination path for an upstream file that
 // matched this entry's SourcePattern. Plain entries map to the same path; rename
 // entries swap the source pattern's non-wildcard prefix for the destination's,
@@ -47,6 +56,20 @@ func (e OwnedEntry) ResolveDest(matchedFile string) string {
 	return dstPrefix + strings.TrimPrefix(matchedFile, srcPrefix)
 }
 
+// ResolveSource is the inverse of ResolveDest: it returns the upstream path
+// that would be written to destFile, or "" when a rename entry's destination
+// can't produce destFile.
+func (e OwnedEntry) ResolveSource(destFile string) string {
+	if !e.IsRename() {
+		return destFile
+	}
+	rest, ok := strings.CutPrefix(destFile, globNonWildcardPrefix(e.To))
+	if !ok {
+		return ""
+	}
+	return globNonWildcardPrefix(e.From) + rest
+}
+
 // Validate reports a configuration error if the entry is malformed: a rename
 // must set both From and To, and the two sides must agree on whether they are
 // globs (both contain a wildcard or neither does). An asymmetric rename — a glob
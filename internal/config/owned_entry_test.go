@@ -93,6 +93,14 @@ func TestOwnedEntry_ResolveDest(t *testing.T) {
 	assert.Equal(t, ".configs/x/y/z.txt", e.ResolveDest("configs/x/y/z.txt"))
 }
 
+func TestOwnedEntry_ResolveSource(t *testing.T) {
+	assert.Equal(t, "x/y.txt", OwnedEntry{Pattern: "x/**"}.ResolveSource("x/y.txt"))
+	assert.Equal(t, "a.txt", OwnedEntry{From: "a.txt", To: "b.txt"}.ResolveSource("b.txt"))
+	e := OwnedEntry{From: "configs/**", To: ".configs/**"}
+	assert.Equal(t, "configs/app/db.yml", e.ResolveSource(".configs/app/db.yml"))
+	assert.Equal(t, "", e.ResolveSource("other/db.yml"))
+}
+
 func TestCollapsePlainOwnedEntries_bothBlocks(t *testing.T) {
 	cfg := &ownedEntryYAML{
 		UpstreamOwned: []OwnedEntry{
@@ -0,0 +1,40 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/goccy/go-yaml"
+)
+
+// ScanManifest lists the downstream checkouts a fleet-wide drift scan checks.
+// It's written by whoever runs the scan, outside any downstream.
+type ScanManifest struct {
+	Downstreams []string `yaml:"downstreams" comment:"downstream checkout paths, or path patterns (as in Go's filepath.Match, like 'checkouts/*') matching several, relative to the manifest"`
+}
+
+// ParseScanManifest reads the scan manifest at manifestPath, resolving each
+// entry in Downstreams against the manifest's directory.
+func ParseScanManifest(manifestPath string) (*ScanManifest, error) {
+	manifest := &ScanManifest{}
+	f, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return manifest, fmt.Errorf("error reading gitspork scan manifest %s: %v", manifestPath, err)
+	}
+	if err := yaml.Unmarshal(f, manifest); err != nil {
+		return manifest, fmt.Errorf("error parsing gitspork scan manifest %s: %v", manifestPath, err)
+	}
+	for idx, downstream := range manifest.Downstreams {
+		if downstream == "" {
+			return manifest, fmt.Errorf("invalid downstreams[%d] in %s: expects a path or path pattern", idx, manifestPath)
+		}
+		if _, err := filepath.Match(downstream, ""); err != nil {
+			return manifest, fmt.Errorf("invalid downstreams[%d] pattern %q in %s: %v", idx, downstream, manifestPath, err)
+		}
+		if !filepath.IsAbs(downstream) {
+			manifest.Downstreams[idx] = filepath.Join(filepath.Dir(manifestPath), downstream)
+		}
+	}
+	return manifest, nil
+}
@@ -0,0 +1,45 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ParseScanManifest(t *testing.T) {
+	write := func(t *testing.T, content string) string {
+		t.Helper()
+		path := filepath.Join(t.TempDir(), "scan.yml")
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		return path
+	}
+
+	t.Run("entries resolve against the manifest directory", func(t *testing.T) {
+		path := write(t, "downstreams:\n- checkouts/*\n- /srv/service-a\n")
+		manifest, err := ParseScanManifest(path)
+		require.NoError(t, err)
+		assert.Equal(t, []string{filepath.Join(filepath.Dir(path), "checkouts", "*"), "/srv/service-a"}, manifest.Downstreams)
+	})
+
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{name: "empty entry", content: "downstreams:\n- ''\n", wantErr: "invalid downstreams[0]"},
+		{name: "invalid pattern", content: "downstreams:\n- 'checkouts/[x'\n", wantErr: "invalid downstreams[0] pattern"},
+		{name: "invalid yaml", content: "downstreams: {", wantErr: "error parsing gitspork scan manifest"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseScanManifest(write(t, tt.content))
+			require.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+
+	_, err := ParseScanManifest(filepath.Join(t.TempDir(), "missing.yml"))
+	require.ErrorContains(t, err, "error reading gitspork scan manifest")
+}
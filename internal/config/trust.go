@@ -0,0 +1,75 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gobwas/glob"
+	"github.com/goccy/go-yaml"
+)
+
+// TrustPolicyFileName is the downstream file, under .gitspork, that decides
+// which upstreams may run migration commands in the downstream.
+const TrustPolicyFileName string = "trust.yml"
+
+// TrustPolicy is the downstream's .gitspork/trust.yml. It is written by hand in
+// the downstream, never by an upstream.
+type TrustPolicy struct {
+	Migrations TrustPolicyMigrations `yaml:"migrations"`
+}
+
+// TrustPolicyMigrations controls which upstreams may run migrations that
+// execute commands (exec, or exec and shell steps). Declarative steps always run.
+type TrustPolicyMigrations struct {
+	Allow                []string `yaml:"allow,omitempty" comment:"upstream URL patterns (https://github.com/gobwas/glob), like 'github.com/my-org/*', whose migrations may run commands without asking; matched against the URL without scheme, user or .git suffix, lowercased"`
+	RequireSignedCommits bool     `yaml:"require_signed_commits,omitempty" comment:"(optional) only run migration commands from an upstream commit signed by one of trusted_keys"`
+	TrustedKeys          []string `yaml:"trusted_keys,omitempty" comment:"(required with require_signed_commits) paths, relative to the downstream root and under .gitspork, of armored PGP public keys"`
+}
+
+// AllowsUpstream reports whether the allow list matches normalizedURL, an
+// upstream URL as normalized for the downstream state.
+func (p TrustPolicyMigrations) AllowsUpstream(normalizedURL string) bool {
+	for _, pattern := range p.Allow {
+		g, err := glob.Compile(strings.ToLower(pattern))
+		if err == nil && g.Match(normalizedURL) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseTrustPolicy reads the trust policy at trustPolicyPath. A missing file
+// is an empty policy, which trusts no upstream.
+func ParseTrustPolicy(trustPolicyPath string) (*TrustPolicy, error) {
+	policy := &TrustPolicy{}
+	f, err := os.ReadFile(trustPolicyPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return policy, nil
+	}
+	if err != nil {
+		return policy, fmt.Errorf("error reading gitspork trust policy %s: %v", trustPolicyPath, err)
+	}
+	if err := yaml.Unmarshal(f, policy); err != nil {
+		return policy, fmt.Errorf("error parsing gitspork trust policy %s: %v", trustPolicyPath, err)
+	}
+	for _, pattern := range policy.Migrations.Allow {
+		if _, err := glob.Compile(strings.ToLower(pattern)); err != nil {
+			return policy, fmt.Errorf("invalid migrations.allow pattern %q in %s: %v", pattern, trustPolicyPath, err)
+		}
+	}
+	if policy.Migrations.RequireSignedCommits && len(policy.Migrations.TrustedKeys) == 0 {
+		return policy, fmt.Errorf("invalid migrations in %s: require_signed_commits needs at least one trusted_keys entry", trustPolicyPath)
+	}
+	// Keys live under .gitspork, which no upstream writes, so an upstream
+	// can't swap in its own key to pass require_signed_commits.
+	for _, key := range policy.Migrations.TrustedKeys {
+		if !filepath.IsLocal(filepath.FromSlash(key)) || !InGitSporkDir(key) {
+			return policy, fmt.Errorf("invalid migrations.trusted_keys entry %q in %s: must be relative to the downstream and under %s", key, trustPolicyPath, GitSporkDirName)
+		}
+	}
+	return policy, nil
+}
@@ -0,0 +1,56 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ParseTrustPolicy(t *testing.T) {
+	write := func(t *testing.T, content string) string {
+		t.Helper()
+		path := filepath.Join(t.TempDir(), TrustPolicyFileName)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		return path
+	}
+
+	t.Run("missing file trusts nothing", func(t *testing.T) {
+		policy, err := ParseTrustPolicy(filepath.Join(t.TempDir(), TrustPolicyFileName))
+		require.NoError(t, err)
+		assert.Empty(t, policy.Migrations.Allow)
+		assert.False(t, policy.Migrations.AllowsUpstream("github.com/acme/platform"))
+	})
+
+	t.Run("allow patterns match normalized upstream URLs", func(t *testing.T) {
+		policy, err := ParseTrustPolicy(write(t, "migrations:\n  allow:\n    - GitHub.com/Acme/*\n    - gitlab.com/infra/templates\n"))
+		require.NoError(t, err)
+		assert.True(t, policy.Migrations.AllowsUpstream("github.com/acme/platform"))
+		assert.True(t, policy.Migrations.AllowsUpstream("gitlab.com/infra/templates"))
+		assert.False(t, policy.Migrations.AllowsUpstream("github.com/other/platform"))
+	})
+
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{name: "invalid pattern", content: "migrations:\n  allow: ['github.com/[acme']\n", wantErr: "invalid migrations.allow pattern"},
+		{name: "signed commits without keys", content: "migrations:\n  require_signed_commits: true\n", wantErr: "needs at least one trusted_keys entry"},
+		{name: "key outside the downstream", content: "migrations:\n  require_signed_commits: true\n  trusted_keys: [../key.asc]\n", wantErr: "must be relative to the downstream and under .gitspork"},
+		{name: "key outside .gitspork", content: "migrations:\n  require_signed_commits: true\n  trusted_keys: [keys/upstream.asc]\n", wantErr: "must be relative to the downstream and under .gitspork"},
+		{name: "signed commits with keys", content: "migrations:\n  require_signed_commits: true\n  trusted_keys: [.gitspork/keys/upstream.asc]\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseTrustPolicy(write(t, tt.content))
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
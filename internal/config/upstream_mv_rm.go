@@ -94,6 +94,7 @@ func ComputeUpstreamMvFromConfig(config *GitSporkConfig, oldPath, newPath string
 	config.SharedOwnership.Merged = rewritePatterns(config.SharedOwnership.Merged)
 	config.SharedOwnership.Structured.PreferUpstream = rewritePatterns(config.SharedOwnership.Structured.PreferUpstream)
 	config.SharedOwnership.Structured.PreferDownstream = rewritePatterns(config.SharedOwnership.Structured.PreferDownstream)
+	config.TemplatePartials = rewritePatterns(config.TemplatePartials)
 
 	rewritePath := func(p string) string {
 		if p == oldPath {
@@ -107,6 +108,8 @@ func ComputeUpstreamMvFromConfig(config *GitSporkConfig, oldPath, newPath string
 	for i, t := range config.Templated {
 		config.Templated[i].Template = rewritePath(t.Template)
 		config.Templated[i].Destination = rewritePath(t.Destination)
+		config.Templated[i].TemplateDir = rewritePath(t.TemplateDir)
+		config.Templated[i].DestinationDir = rewritePath(t.DestinationDir)
 	}
 
 	return config, warnings, nil
@@ -191,13 +194,14 @@ func ComputeUpstreamRmFromConfig(config *GitSporkConfig, path string, recursive
 	config.SharedOwnership.Merged = filterPatterns(config.SharedOwnership.Merged)
 	config.SharedOwnership.Structured.PreferUpstream = filterPatterns(config.SharedOwnership.Structured.PreferUpstream)
 	config.SharedOwnership.Structured.PreferDownstream = filterPatterns(config.SharedOwnership.Structured.PreferDownstream)
+	config.TemplatePartials = filterPatterns(config.TemplatePartials)
 
 	var templated []GitSporkConfigTemplated
 	for _, t := range config.Templated {
-		if t.Template == path || t.Destination == path {
+		if t.Source() == path || t.Target() == path {
 			continue
 		}
-		if recursive && (strings.HasPrefix(t.Template, path+"/") || strings.HasPrefix(t.Destination, path+"/")) {
+		if recursive && (strings.HasPrefix(t.Source(), path+"/") || strings.HasPrefix(t.Target(), path+"/")) {
 			continue
 		}
 		templated = append(templated, t)
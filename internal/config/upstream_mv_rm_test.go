@@ -221,6 +221,17 @@ func Test_UpstreamMv(t *testing.T) {
 		result := loadConfigFile(t, cfg)
 		assert.Equal(t, []OwnedEntry{{Pattern: "docs/cloud/**"}}, result.UpstreamOwned)
 	})
+
+	t.Run("template_partials glob is rewritten", func(t *testing.T) {
+		cfg := makeConfigFile(t, &GitSporkConfig{
+			TemplatePartials: []string{"templates/partials/*.tmpl"},
+		})
+		warnings, err := UpstreamMv(cfg, "templates/partials", "templates/shared")
+		require.NoError(t, err)
+		assert.Empty(t, warnings)
+		result := loadConfigFile(t, cfg)
+		assert.Equal(t, []string{"templates/shared/*.tmpl"}, result.TemplatePartials)
+	})
 }
 
 func Test_FindGitSporkConfig(t *testing.T) {
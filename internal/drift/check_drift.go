@@ -2,9 +2,9 @@ package drift
 
 import (
 	"bytes"
-	"crypto/sha256"
 	"errors"
 	"fmt"
+	"maps"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -51,44 +51,30 @@ func CheckDrift(opts *sdktypes.CheckDriftOptions) (*sdktypes.DriftReport, error)
 		}
 	}
 
+	switch opts.Migrations {
+	case "":
+		opts.Migrations = sdktypes.DriftMigrationsSkip
+	case sdktypes.DriftMigrationsSkip, sdktypes.DriftMigrationsSimulate, sdktypes.DriftMigrationsSandbox:
+	default:
+		return report, fmt.Errorf("invalid migrations mode %q: expects %s, %s or %s", opts.Migrations, sdktypes.DriftMigrationsSkip, sdktypes.DriftMigrationsSimulate, sdktypes.DriftMigrationsSandbox)
+	}
+	fixPaths, err := compileFixPaths(opts.FixPaths)
+	if err != nil {
+		return report, err
+	}
+
 	state, err := integrate.LoadDownstreamState(opts.DownstreamRepoPath)
 	if err != nil {
 		return report, fmt.Errorf("error loading downstream state: %v", err)
 	}
-
-	// Resolve which upstreams to check and their recorded commit hashes.
-	type upstreamCheckEntry struct {
-		spec       sdktypes.UpstreamSpec
-		commitHash string
+	driftIgnore, err := integrate.LoadDriftIgnore(opts.DownstreamRepoPath)
+	if err != nil {
+		return report, err
 	}
-	var entries []upstreamCheckEntry
 
-	if len(opts.Upstreams) > 0 {
-		// Override mode: match each override to its state entry for the commit hash.
-		for _, override := range opts.Upstreams {
-			key := integrate.NormalizeUpstreamURL(override.URL, override.Subpath)
-			found := false
-			for _, su := range state.Upstreams {
-				if integrate.NormalizeUpstreamURL(su.URL, su.Subpath) == key {
-					entries = append(entries, upstreamCheckEntry{spec: override, commitHash: su.CommitHash})
-					found = true
-					break
-				}
-			}
-			if !found {
-				return report, fmt.Errorf("--upstream override %q has no matching state entry — run 'gitspork integrate' first", override.URL)
-			}
-		}
-	} else {
-		if len(state.Upstreams) == 0 {
-			return report, fmt.Errorf("no previous integration found in downstream state — run 'gitspork integrate' first")
-		}
-		for _, su := range state.Upstreams {
-			entries = append(entries, upstreamCheckEntry{
-				spec:       sdktypes.UpstreamSpec{URL: su.URL, Subpath: su.Subpath},
-				commitHash: su.CommitHash,
-			})
-		}
+	entries, err := recordedUpstreams(state, opts.Upstreams)
+	if err != nil {
+		return report, err
 	}
 
 	// Self-integration guard runs against the caller, not the scratch clone.
@@ -102,8 +88,10 @@ func CheckDrift(opts *sdktypes.CheckDriftOptions) (*sdktypes.DriftReport, error)
 		}
 	}
 
-	if err := checkCleanWorkingTree(opts.DownstreamRepoPath); err != nil {
-		return report, err
+	if !opts.WorkingTree {
+		if err := checkCleanWorkingTree(opts.DownstreamRepoPath); err != nil {
+			return report, err
+		}
 	}
 
 	opts.Logger.Log("provisioning scratch clone of %s for drift-check", opts.DownstreamRepoPath)
@@ -138,35 +126,52 @@ func CheckDrift(opts *sdktypes.CheckDriftOptions) (*sdktypes.DriftReport, error)
 	if err := wt.Checkout(&gogit.CheckoutOptions{Branch: driftBranchRef}); err != nil {
 		return report, fmt.Errorf("error checking out drift-check branch in scratch: %v", err)
 	}
+	if opts.WorkingTree {
+		report.WorkingTree = true
+		if err := commitWorkingTreeSnapshot(opts, scratchPath, wt); err != nil {
+			return report, err
+		}
+	}
 
 	// Re-integrate each upstream against the scratch clone; track which files
 	// each one last touched. fileOwner maps relative file path -> upstream URL
 	// that last wrote it.
 	fileOwner := map[string]string{}
+	// migrationFile maps relative file path -> ID of the migration that last
+	// changed it during re-integration.
+	migrationFile := map[string]string{}
+	// results holds each upstream's re-integration result, keyed by URL, to
+	// find the .gitspork.yml entry managing a file.
+	results := map[string]*integrate.DriftCheckResult{}
 
 	for _, entry := range entries {
 		opts.Logger.Log("re-integrating upstream %s at commit %s", entry.spec.URL, entry.commitHash)
 
-		beforeFiles, err := listWorktreeFiles(scratchPath)
+		beforeFiles, err := integrate.ListWorktreeFiles(scratchPath)
 		if err != nil {
 			return report, fmt.Errorf("error listing worktree files before integrate: %v", err)
 		}
 
-		if err := integrate.IntegrateForDriftCheck(&integrate.DriftCheckRequest{
-			Logger:             opts.Logger,
-			DownstreamRepoPath: scratchPath,
-			UpstreamURL:        entry.spec.URL,
-			UpstreamSubpath:    entry.spec.Subpath,
-			UpstreamToken:      entry.spec.Token,
-			UpstreamCommit:     entry.commitHash,
-			CacheTTL:           opts.CacheTTL,
-			NoCache:            opts.NoCache,
-			Progress:           opts.Progress,
-		}); err != nil {
+		result, err := integrate.IntegrateForDriftCheck(&integrate.DriftCheckRequest{
+			Logger:                 opts.Logger,
+			DownstreamRepoPath:     scratchPath,
+			UpstreamURL:            entry.spec.URL,
+			UpstreamSubpath:        entry.spec.Subpath,
+			UpstreamToken:          entry.spec.Token,
+			UpstreamCommit:         entry.commitHash,
+			CacheTTL:               opts.CacheTTL,
+			NoCache:                opts.NoCache,
+			Progress:               opts.Progress,
+			DownstreamMetadataPath: opts.DownstreamRepoPath,
+			Migrations:             opts.Migrations,
+			Inputs:                 opts.Inputs,
+			Context:                opts.Context,
+		})
+		if err != nil {
 			return report, fmt.Errorf("error running integration for drift check: %w", err)
 		}
 
-		afterFiles, err := listWorktreeFiles(scratchPath)
+		afterFiles, err := integrate.ListWorktreeFiles(scratchPath)
 		if err != nil {
 			return report, fmt.Errorf("error listing worktree files after integrate: %v", err)
 		}
@@ -181,6 +186,13 @@ func CheckDrift(opts *sdktypes.CheckDriftOptions) (*sdktypes.DriftReport, error)
 				fileOwner[f] = entry.spec.URL
 			}
 		}
+		for f := range migrationFile {
+			if fileOwner[filepath.FromSlash(f)] == entry.spec.URL {
+				delete(migrationFile, f)
+			}
+		}
+		maps.Copy(migrationFile, result.MigrationFiles)
+		results[entry.spec.URL] = result
 	}
 
 	patch, err := diffWorktreeAgainstHEAD(repo, wt)
@@ -191,15 +203,20 @@ func CheckDrift(opts *sdktypes.CheckDriftOptions) (*sdktypes.DriftReport, error)
 		return report, nil
 	}
 
-	report.HasDrift = true
 	for _, fp := range patch.FilePatches() {
 		from, to := fp.Files()
-		var name string
+		// The patch runs from the re-integrated state to HEAD, so from is
+		// the expected file and to is the downstream's.
+		var name, change string
 		switch {
+		case from != nil && to != nil && from.Mode() != to.Mode():
+			name, change = to.Path(), sdktypes.DriftChangeMode
+		case from != nil && to != nil:
+			name, change = to.Path(), sdktypes.DriftChangeModified
 		case to != nil:
-			name = to.Path()
+			name, change = to.Path(), sdktypes.DriftChangeAdded
 		case from != nil:
-			name = from.Path()
+			name, change = from.Path(), sdktypes.DriftChangeDeleted
 		default:
 			continue
 		}
@@ -207,17 +224,141 @@ func CheckDrift(opts *sdktypes.CheckDriftOptions) (*sdktypes.DriftReport, error)
 		if err != nil {
 			return report, fmt.Errorf("error encoding per-file diff for %s: %v", name, err)
 		}
+		var ownership, configEntry string
+		if result := results[fileOwner[name]]; result != nil {
+			ownership, configEntry = result.FileSource(name)
+		}
 		report.Files = append(report.Files, sdktypes.DriftedFile{
 			Path:          name,
 			AttributedURL: fileOwner[name], // empty string means unattributed
+			Change:        change,
+			Ownership:     ownership,
+			ConfigEntry:   configEntry,
 			Diff:          diffText,
 			ColorizedDiff: logutil.ColorizeUnifiedDiff(diffText),
+			Migration:     migrationFile[name],
 		})
 	}
 
+	applyDriftIgnore(report, driftIgnore, time.Now(), opts.Logger)
+	if !report.HasDrift {
+		return report, nil
+	}
+	if opts.Fix || opts.PatchOut != nil {
+		if err := remediate(opts, scratchPath, report, fixPaths); err != nil {
+			return report, err
+		}
+	}
 	return report, sdktypes.ErrDriftDetected
 }
 
+// recordedUpstream is an upstream to check, with the commit and version the
+// downstream state records for it.
+type recordedUpstream struct {
+	spec       sdktypes.UpstreamSpec
+	commitHash string
+	version    string
+}
+
+// recordedUpstreams lists the upstreams recorded in state or, when overrides
+// are given, each override matched to its state entry by normalized URL and
+// subpath.
+func recordedUpstreams(state *sdktypes.DownstreamState, overrides []sdktypes.UpstreamSpec) ([]recordedUpstream, error) {
+	var entries []recordedUpstream
+	if len(overrides) > 0 {
+		for _, override := range overrides {
+			key := integrate.NormalizeUpstreamURL(override.URL, override.Subpath)
+			found := false
+			for _, su := range state.Upstreams {
+				if integrate.NormalizeUpstreamURL(su.URL, su.Subpath) == key {
+					entries = append(entries, recordedUpstream{spec: override, commitHash: su.CommitHash, version: su.Version})
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("--upstream override %q has no matching state entry — run 'gitspork integrate' first", override.URL)
+			}
+		}
+		return entries, nil
+	}
+	if len(state.Upstreams) == 0 {
+		return nil, fmt.Errorf("no previous integration found in downstream state — run 'gitspork integrate' first")
+	}
+	for _, su := range state.Upstreams {
+		entries = append(entries, recordedUpstream{
+			spec:       sdktypes.UpstreamSpec{URL: su.URL, Subpath: su.Subpath},
+			commitHash: su.CommitHash,
+			version:    su.Version,
+		})
+	}
+	return entries, nil
+}
+
+// applyDriftIgnore moves the drifted files an unexpired rule of driftIgnore
+// matches from report.Files to report.Ignored, marks those only expired rules
+// match, and sets report.HasDrift from what's left.
+func applyDriftIgnore(report *sdktypes.DriftReport, driftIgnore *config.DriftIgnore, now time.Time, logger sdktypes.Logger) {
+	var files []sdktypes.DriftedFile
+	for _, f := range report.Files {
+		normalizedURL := ""
+		if f.AttributedURL != "" {
+			normalizedURL = integrate.NormalizeUpstreamURL(f.AttributedURL, "")
+		}
+		ignored := false
+		for _, rule := range driftIgnore.Ignore {
+			pattern := rule.Match(f.Path, normalizedURL)
+			if pattern == "" {
+				continue
+			}
+			if rule.Expired(now) {
+				if f.ExpiredIgnore == "" {
+					f.ExpiredIgnore = rule.Expires
+					logger.Log("drift ignore rule %q for %s expired on %s", pattern, f.Path, rule.Expires)
+				}
+				continue
+			}
+			report.Ignored = append(report.Ignored, sdktypes.IgnoredDriftedFile{
+				DriftedFile:   f,
+				Pattern:       pattern,
+				Justification: rule.Justification,
+				Expires:       rule.Expires,
+			})
+			ignored = true
+			break
+		}
+		if !ignored {
+			files = append(files, f)
+		}
+	}
+	report.Files = files
+	report.HasDrift = len(files) > 0
+}
+
+// commitWorkingTreeSnapshot commits the uncommitted state of the caller's
+// working tree on the scratch clone's drift-check branch, so the drift-check
+// compares the re-integration with what's on disk rather than HEAD.
+func commitWorkingTreeSnapshot(opts *sdktypes.CheckDriftOptions, scratchPath string, wt *gogit.Worktree) error {
+	changed, err := snapshotWorkingTree(opts.DownstreamRepoPath, scratchPath)
+	if err != nil {
+		return fmt.Errorf("error snapshotting the working tree into scratch: %w", err)
+	}
+	if len(changed) == 0 {
+		opts.Logger.Log("working tree is clean, checking drift against HEAD")
+		return nil
+	}
+	opts.Logger.Log("checking drift against the working tree, including %d uncommitted file(s)", len(changed))
+	if err := wt.AddWithOptions(&gogit.AddOptions{All: true}); err != nil {
+		return fmt.Errorf("error staging the working tree snapshot in scratch: %v", err)
+	}
+	sig := &object.Signature{Name: config.GitSpork, Email: config.GitSpork + "@localhost", When: time.Now()}
+	_, err = wt.Commit("working-tree snapshot", &gogit.CommitOptions{Author: sig})
+	if err != nil && !errors.Is(err, gogit.ErrEmptyCommit) {
+		return fmt.Errorf("error committing the working tree snapshot in scratch: %v", err)
+	}
+	return nil
+}
+
 // diffWorktreeAgainstHEAD stages all changes and compares against HEAD.
 // Returns nil patch when there are no changes (no drift).
 func diffWorktreeAgainstHEAD(repo *gogit.Repository, wt *gogit.Worktree) (*object.Patch, error) {
@@ -284,50 +425,10 @@ func checkCleanWorkingTree(repoPath string) error {
 		return fmt.Errorf("error checking working tree status: %v", err)
 	}
 	if status := strings.TrimSpace(string(out)); status != "" {
-		return fmt.Errorf("working tree is not clean — commit or stash changes before running check-drift:\n%s\n\n"+
+		return fmt.Errorf("working tree is not clean — commit or stash changes before running check-drift, or check the working tree as it is with --working-tree:\n%s\n\n"+
 			"note: this may be running in a container with different global gitignore rules than your local git environment, "+
 			"which can explain differences you see versus a local `git status`. "+
 			"Commit needed gitignore changes to your repo's .gitignore in these cases to ensure the repo ignores what you need it to regardless of global rules.", status)
 	}
 	return nil
 }
-
-// listWorktreeFiles returns a map of relative path -> hex content hash for all
-// non-.git files under dir. Used to detect which files an integrate pass touched.
-func listWorktreeFiles(dir string) (map[string]string, error) {
-	result := map[string]string{}
-	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if info.IsDir() {
-			if info.Name() == ".git" {
-				return filepath.SkipDir
-			}
-			return nil
-		}
-		rel, err := filepath.Rel(dir, path)
-		if err != nil {
-			return err
-		}
-		// Symlinks: hash the target string (mirrors git's mode-120000 blob
-		// semantics). filepath.Walk lstats without following, so a symlink to
-		// a directory would otherwise fall through to os.ReadFile below and
-		// error with EISDIR; a broken symlink would error with ENOENT.
-		if info.Mode()&os.ModeSymlink != 0 {
-			target, err := os.Readlink(path)
-			if err != nil {
-				return err
-			}
-			result[rel] = fmt.Sprintf("%x", sha256.Sum256([]byte(target)))
-			return nil
-		}
-		b, err := os.ReadFile(path)
-		if err != nil {
-			return err
-		}
-		result[rel] = fmt.Sprintf("%x", sha256.Sum256(b))
-		return nil
-	})
-	return result, err
-}
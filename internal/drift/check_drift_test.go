@@ -4,6 +4,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -153,53 +154,6 @@ func Test_diffWorktreeAgainstHEAD(t *testing.T) {
 	})
 }
 
-func Test_listWorktreeFiles(t *testing.T) {
-	t.Run("symlink pointing at a directory does not crash the walker", func(t *testing.T) {
-		dir, err := os.MkdirTemp("", "gitspork-test-listwt")
-		require.NoError(t, err)
-		defer os.RemoveAll(dir)
-
-		require.NoError(t, os.WriteFile(filepath.Join(dir, "file.txt"), []byte("hello"), 0644))
-		require.NoError(t, os.Mkdir(filepath.Join(dir, "realdir"), 0755))
-		require.NoError(t, os.WriteFile(filepath.Join(dir, "realdir", "inner.txt"), []byte("inner"), 0644))
-		require.NoError(t, os.Symlink("realdir", filepath.Join(dir, "link-to-dir")))
-
-		got, err := listWorktreeFiles(dir)
-		require.NoError(t, err, "walker must not choke on a symlink pointing at a directory")
-		assert.Contains(t, got, "file.txt")
-		assert.Contains(t, got, filepath.Join("realdir", "inner.txt"))
-		assert.Contains(t, got, "link-to-dir",
-			"symlink itself should be recorded as an entry, not silently skipped")
-	})
-
-	t.Run("symlink to a regular file hashes the link target, not the target's contents", func(t *testing.T) {
-		dir, err := os.MkdirTemp("", "gitspork-test-listwt")
-		require.NoError(t, err)
-		defer os.RemoveAll(dir)
-
-		require.NoError(t, os.WriteFile(filepath.Join(dir, "real.txt"), []byte("real content"), 0644))
-		require.NoError(t, os.Symlink("real.txt", filepath.Join(dir, "link.txt")))
-
-		got, err := listWorktreeFiles(dir)
-		require.NoError(t, err)
-		assert.NotEqual(t, got["real.txt"], got["link.txt"],
-			"symlink hash should be derived from the link target string (git mode-120000 semantics), "+
-				"not from the file the link resolves to")
-	})
-
-	t.Run("broken symlink does not crash the walker", func(t *testing.T) {
-		dir, err := os.MkdirTemp("", "gitspork-test-listwt")
-		require.NoError(t, err)
-		defer os.RemoveAll(dir)
-
-		require.NoError(t, os.Symlink("does-not-exist", filepath.Join(dir, "broken")))
-
-		got, err := listWorktreeFiles(dir)
-		require.NoError(t, err, "walker must tolerate broken symlinks — git allows them and we mustn't crash")
-		assert.Contains(t, got, "broken")
-	})
-}
-
 // makeBaselineRepo initialises a git repo with one committed file and returns the Worktree.
 func makeBaselineRepo(t *testing.T, dir string) *gogit.Worktree {
 	t.Helper()
@@ -248,6 +202,271 @@ func TestCheckDrift_returns_report_with_drifted_file_and_attribution(t *testing.
 	require.Len(t, report.Files, 1)
 	assert.Equal(t, "upstream-owned/file.txt", report.Files[0].Path)
 	assert.Equal(t, "file://"+upstreamDir, report.Files[0].AttributedURL)
+	assert.Equal(t, sdktypes.DriftChangeModified, report.Files[0].Change)
+	assert.Equal(t, sdktypes.OwnershipUpstreamOwned, report.Files[0].Ownership)
+	assert.Equal(t, "upstream-owned/**", report.Files[0].ConfigEntry)
+}
+
+func TestCheckDrift_reports_change_kind_of_mode_change(t *testing.T) {
+	upstreamDir, _ := testharness.MinimalUpstream(t)
+	downstreamDir := testharness.EmptyDownstream(t)
+	testIntegrateAndCommitBaseline(t, upstreamDir, downstreamDir)
+	require.NoError(t, os.Chmod(filepath.Join(downstreamDir, "upstream-owned/file.txt"), 0755))
+	repo, err := gogit.PlainOpen(downstreamDir)
+	require.NoError(t, err)
+	testharness.CommitAllWithMessage(t, repo, "drift mode")
+
+	report, err := CheckDrift(&sdktypes.CheckDriftOptions{
+		Logger:             logutil.New(),
+		DownstreamRepoPath: downstreamDir,
+	})
+	require.ErrorIs(t, err, sdktypes.ErrDriftDetected)
+	require.Len(t, report.Files, 1)
+	assert.Equal(t, sdktypes.DriftChangeMode, report.Files[0].Change)
+	assert.Equal(t, "file://"+upstreamDir, report.Files[0].AttributedURL)
+	assert.Equal(t, sdktypes.OwnershipUpstreamOwned, report.Files[0].Ownership)
+}
+
+func TestCheckDrift_reports_change_kind_of_deleted_file(t *testing.T) {
+	upstreamDir, _ := testharness.MinimalUpstream(t)
+	downstreamDir := testharness.EmptyDownstream(t)
+	testIntegrateAndCommitBaseline(t, upstreamDir, downstreamDir)
+	require.NoError(t, os.Remove(filepath.Join(downstreamDir, "upstream-owned/file.txt")))
+	repo, err := gogit.PlainOpen(downstreamDir)
+	require.NoError(t, err)
+	testharness.CommitAllWithMessage(t, repo, "drift delete")
+
+	report, err := CheckDrift(&sdktypes.CheckDriftOptions{
+		Logger:             logutil.New(),
+		DownstreamRepoPath: downstreamDir,
+	})
+	require.ErrorIs(t, err, sdktypes.ErrDriftDetected)
+	require.Len(t, report.Files, 1)
+	assert.Equal(t, "upstream-owned/file.txt", report.Files[0].Path)
+	assert.Equal(t, sdktypes.DriftChangeDeleted, report.Files[0].Change)
+}
+
+func TestCheckDrift_drift_ignore_rules(t *testing.T) {
+	upstreamDir, _ := testharness.MinimalUpstream(t)
+	downstreamDir := testharness.EmptyDownstream(t)
+	testIntegrateAndCommitBaseline(t, upstreamDir, downstreamDir)
+	testWriteAndCommitInDownstream(t, downstreamDir, "upstream-owned/file.txt", "drifted\n")
+
+	t.Run("unexpired rule moves the drift to ignored", func(t *testing.T) {
+		testWriteAndCommitInDownstream(t, downstreamDir, ".gitspork/"+config.DriftIgnoreFileName, `ignore:
+- paths: [upstream-owned/*.txt]
+  justification: local tweak until the upstream catches up
+  expires: 2999-12-31
+`)
+		report, err := CheckDrift(&sdktypes.CheckDriftOptions{
+			Logger:             logutil.New(),
+			DownstreamRepoPath: downstreamDir,
+		})
+		require.NoError(t, err)
+		assert.False(t, report.HasDrift)
+		assert.Empty(t, report.Files)
+		require.Len(t, report.Ignored, 1)
+		assert.Equal(t, "upstream-owned/file.txt", report.Ignored[0].Path)
+		assert.Equal(t, "upstream-owned/*.txt", report.Ignored[0].Pattern)
+		assert.Equal(t, "local tweak until the upstream catches up", report.Ignored[0].Justification)
+		assert.Equal(t, "2999-12-31", report.Ignored[0].Expires)
+		assert.Equal(t, sdktypes.OwnershipUpstreamOwned, report.Ignored[0].Ownership)
+	})
+
+	t.Run("expired rule reports the drift again", func(t *testing.T) {
+		testWriteAndCommitInDownstream(t, downstreamDir, ".gitspork/"+config.DriftIgnoreFileName, `ignore:
+- paths: [upstream-owned/*.txt]
+  expires: 2000-01-31
+`)
+		report, err := CheckDrift(&sdktypes.CheckDriftOptions{
+			Logger:             logutil.New(),
+			DownstreamRepoPath: downstreamDir,
+		})
+		require.ErrorIs(t, err, sdktypes.ErrDriftDetected)
+		assert.Empty(t, report.Ignored)
+		require.Len(t, report.Files, 1)
+		assert.Equal(t, "2000-01-31", report.Files[0].ExpiredIgnore)
+	})
+
+	t.Run("invalid ignore file fails the check", func(t *testing.T) {
+		testWriteAndCommitInDownstream(t, downstreamDir, ".gitspork/"+config.DriftIgnoreFileName, "ignore:\n- justification: no paths\n")
+		_, err := CheckDrift(&sdktypes.CheckDriftOptions{
+			Logger:             logutil.New(),
+			DownstreamRepoPath: downstreamDir,
+		})
+		require.ErrorContains(t, err, "paths needs at least one pattern")
+	})
+}
+
+func TestCheckDrift_fix_and_patch_out(t *testing.T) {
+	upstreamDir, _ := testharness.MinimalUpstream(t)
+	testharness.WriteFiles(t, upstreamDir, map[string]string{"upstream-owned/other.txt": "other\n"})
+	upstreamRepo, err := gogit.PlainOpen(upstreamDir)
+	require.NoError(t, err)
+	testharness.CommitAllWithMessage(t, upstreamRepo, "more files")
+	downstreamDir := testharness.EmptyDownstream(t)
+	testIntegrateAndCommitBaseline(t, upstreamDir, downstreamDir)
+	stateBefore := testharness.ReadFile(t, downstreamDir, ".gitspork/downstream-state.json")
+	testWriteAndCommitInDownstream(t, downstreamDir, "upstream-owned/file.txt", "drifted\n")
+	require.NoError(t, os.Remove(filepath.Join(downstreamDir, "upstream-owned", "other.txt")))
+	downstreamRepo, err := gogit.PlainOpen(downstreamDir)
+	require.NoError(t, err)
+	testharness.CommitAllWithMessage(t, downstreamRepo, "drift delete")
+
+	t.Run("patch out limited to a path", func(t *testing.T) {
+		var patch strings.Builder
+		report, err := CheckDrift(&sdktypes.CheckDriftOptions{
+			Logger:             logutil.New(),
+			DownstreamRepoPath: downstreamDir,
+			PatchOut:           &patch,
+			FixPaths:           []string{"upstream-owned/file.*"},
+		})
+		require.ErrorIs(t, err, sdktypes.ErrDriftDetected)
+		assert.Len(t, report.Files, 2)
+		assert.Empty(t, report.Fixed)
+		assert.Contains(t, patch.String(), "upstream-owned/file.txt")
+		assert.NotContains(t, patch.String(), "upstream-owned/other.txt")
+		assert.Equal(t, "drifted\n", testharness.ReadFile(t, downstreamDir, "upstream-owned/file.txt"))
+
+		cmd := exec.Command("git", "-C", downstreamDir, "apply", "-")
+		cmd.Stdin = strings.NewReader(patch.String())
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+		assert.Equal(t, "upstream content\n", testharness.ReadFile(t, downstreamDir, "upstream-owned/file.txt"))
+		out, err = exec.Command("git", "-C", downstreamDir, "checkout", "--", ".").CombinedOutput()
+		require.NoError(t, err, string(out))
+	})
+
+	t.Run("fix restores every drifted file", func(t *testing.T) {
+		report, err := CheckDrift(&sdktypes.CheckDriftOptions{
+			Logger:             logutil.New(),
+			DownstreamRepoPath: downstreamDir,
+			Fix:                true,
+		})
+		require.ErrorIs(t, err, sdktypes.ErrDriftDetected)
+		assert.Equal(t, []string{"upstream-owned/file.txt", "upstream-owned/other.txt"}, report.Fixed)
+		assert.Equal(t, "upstream content\n", testharness.ReadFile(t, downstreamDir, "upstream-owned/file.txt"))
+		assert.Equal(t, "other\n", testharness.ReadFile(t, downstreamDir, "upstream-owned/other.txt"))
+		assert.Equal(t, stateBefore, testharness.ReadFile(t, downstreamDir, ".gitspork/downstream-state.json"))
+	})
+
+	_, err = CheckDrift(&sdktypes.CheckDriftOptions{DownstreamRepoPath: downstreamDir, FixPaths: []string{"["}})
+	require.ErrorContains(t, err, `invalid fix path pattern "["`)
+}
+
+func TestCheckDrift_working_tree(t *testing.T) {
+	upstreamDir, _ := testharness.MinimalUpstream(t)
+	downstreamDir := testharness.EmptyDownstream(t)
+	testIntegrateAndCommitBaseline(t, upstreamDir, downstreamDir)
+	check := func(workingTree bool) (*sdktypes.DriftReport, error) {
+		return CheckDrift(&sdktypes.CheckDriftOptions{
+			Logger:             logutil.New(),
+			DownstreamRepoPath: downstreamDir,
+			WorkingTree:        workingTree,
+		})
+	}
+
+	testharness.WriteFiles(t, downstreamDir, map[string]string{"upstream-owned/file.txt": "uncommitted\n"})
+	_, err := check(false)
+	require.ErrorContains(t, err, "working tree is not clean")
+
+	report, err := check(true)
+	require.ErrorIs(t, err, sdktypes.ErrDriftDetected)
+	assert.True(t, report.WorkingTree)
+	require.Len(t, report.Files, 1)
+	assert.Equal(t, "upstream-owned/file.txt", report.Files[0].Path)
+	assert.Contains(t, report.Files[0].Diff, "+uncommitted")
+	assert.Equal(t, "uncommitted\n", testharness.ReadFile(t, downstreamDir, "upstream-owned/file.txt"))
+
+	// Drift committed at HEAD but undone on disk isn't drift in the working tree.
+	testWriteAndCommitInDownstream(t, downstreamDir, "upstream-owned/file.txt", "drifted\n")
+	testharness.WriteFiles(t, downstreamDir, map[string]string{"upstream-owned/file.txt": "upstream content\n"})
+	report, err = check(true)
+	require.NoError(t, err)
+	assert.False(t, report.HasDrift)
+	assert.True(t, report.WorkingTree)
+
+	require.NoError(t, os.Remove(filepath.Join(downstreamDir, "upstream-owned", "file.txt")))
+	report, err = check(true)
+	require.ErrorIs(t, err, sdktypes.ErrDriftDetected)
+	require.Len(t, report.Files, 1)
+	assert.Equal(t, sdktypes.DriftChangeDeleted, report.Files[0].Change)
+}
+
+// TestCheckDrift_never_prompts covers a secret input in the re-integration:
+// it never prompts or runs secret_command, and fails naming the input unless
+// a value is supplied.
+func TestCheckDrift_never_prompts(t *testing.T) {
+	upstreamDir := testharness.NewUpstreamRepo(t, map[string]string{
+		"token.txt.go.tmpl": "token={{ .Inputs.token }}\n",
+	}, `templated:
+- template: token.txt.go.tmpl
+  destination: token.txt
+  inputs:
+  - name: token
+    prompt: token?
+    secret: true
+    secret_command: echo s3cret
+`)
+	downstreamDir := testharness.EmptyDownstream(t)
+	_, err := integrate.Integrate(&sdktypes.IntegrateOptions{
+		Logger:             logutil.New(),
+		Upstreams:          []sdktypes.UpstreamSpec{{URL: "file://" + upstreamDir, Version: "main"}},
+		DownstreamRepoPath: downstreamDir,
+		Inputs:             map[string]any{"token": "s3cret"},
+	})
+	require.NoError(t, err)
+	testharness.CommitAllWithMessage(t, testharness.OpenRepo(t, downstreamDir), "post-integrate baseline")
+	check := func(inputs map[string]any) (*sdktypes.DriftReport, error) {
+		return CheckDrift(&sdktypes.CheckDriftOptions{
+			Logger:             logutil.New(),
+			DownstreamRepoPath: downstreamDir,
+			Inputs:             inputs,
+		})
+	}
+
+	_, err = check(nil)
+	var missingErr *sdktypes.MissingInputsError
+	require.ErrorAs(t, err, &missingErr)
+	require.Len(t, missingErr.Inputs, 1)
+	assert.Equal(t, "token", missingErr.Inputs[0].Name)
+
+	report, err := check(map[string]any{"token": "s3cret"})
+	require.NoError(t, err)
+	assert.False(t, report.HasDrift)
+
+	t.Setenv("GITSPORK_INPUT_TOKEN", "s3cret")
+	report, err = check(nil)
+	require.NoError(t, err)
+	assert.False(t, report.HasDrift)
+}
+
+func Test_applyDriftIgnore(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	driftIgnore := &config.DriftIgnore{Ignore: []config.DriftIgnoreRule{
+		{Paths: []string{"ci.yml"}, Upstream: "github.com/acme/*"},
+		{Paths: []string{"docs/**"}, Expires: "2026-05-31"},
+		{Paths: []string{"docs/keep.md"}, Expires: "2026-06-01"},
+	}}
+	report := &sdktypes.DriftReport{Files: []sdktypes.DriftedFile{
+		{Path: "ci.yml", AttributedURL: "git@GitHub.com:acme/platform.git"},
+		{Path: "ci.yml", AttributedURL: "https://github.com/other/platform.git"},
+		{Path: "docs/intro.md"},
+		{Path: "docs/keep.md"},
+	}}
+	applyDriftIgnore(report, driftIgnore, now, logutil.New())
+
+	assert.True(t, report.HasDrift)
+	require.Len(t, report.Files, 2)
+	assert.Equal(t, "https://github.com/other/platform.git", report.Files[0].AttributedURL)
+	assert.Empty(t, report.Files[0].ExpiredIgnore)
+	assert.Equal(t, "docs/intro.md", report.Files[1].Path)
+	assert.Equal(t, "2026-05-31", report.Files[1].ExpiredIgnore)
+	require.Len(t, report.Ignored, 2)
+	assert.Equal(t, "ci.yml", report.Ignored[0].Pattern)
+	assert.Equal(t, "docs/keep.md", report.Ignored[1].Path)
+	assert.Equal(t, "2026-06-01", report.Ignored[1].Expires)
+	assert.Equal(t, "2026-05-31", report.Ignored[1].ExpiredIgnore)
 }
 
 // testIntegrateAndCommitBaseline integrates upstreamDir into downstreamDir and
@@ -487,15 +706,15 @@ func TestCheckDrift_selfIntegrationBlockedByOriginURL(t *testing.T) {
 		"CheckDrift must catch by-origin self-integration against the caller repo, before provisioning scratch clone")
 }
 
-// snapshotWorktree wraps listWorktreeFiles (the production walker used by
-// CheckDrift for file attribution) so the invariant test measures the caller's
-// worktree the same way production code does. If listWorktreeFiles ever
+// snapshotWorktree wraps integrate.ListWorktreeFiles (the production walker used
+// by CheckDrift for file attribution) so the invariant test measures the
+// caller's worktree the same way production code does. If it ever
 // changes (symlink handling, hash algorithm, path normalization), the
 // invariant test stays consistent by construction rather than drifting
 // silently.
 func snapshotWorktree(t *testing.T, dir string) map[string]string {
 	t.Helper()
-	m, err := listWorktreeFiles(dir)
+	m, err := integrate.ListWorktreeFiles(dir)
 	require.NoError(t, err)
 	return m
 }
@@ -0,0 +1,153 @@
+package drift
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/rockholla/gitspork/v2/internal/gitbin"
+	"github.com/rockholla/gitspork/v2/internal/integrate"
+	"github.com/rockholla/gitspork/v2/internal/sdktypes"
+)
+
+// Outdated compares each upstream recorded in the downstream state with the
+// latest version of what was requested at integration.
+func Outdated(opts *sdktypes.OutdatedOptions) (*sdktypes.OutdatedReport, error) {
+	report := &sdktypes.OutdatedReport{}
+	var err error
+
+	if opts.Logger == nil {
+		opts.Logger = sdktypes.NoopLogger()
+	}
+	if opts.DownstreamRepoPath == "" {
+		opts.DownstreamRepoPath, err = os.Getwd()
+		if err != nil {
+			return report, fmt.Errorf("unable to get the present working directory: %v", err)
+		}
+	} else {
+		opts.DownstreamRepoPath, err = filepath.Abs(opts.DownstreamRepoPath)
+		if err != nil {
+			return report, fmt.Errorf("unable to determine local downstream repo path: %v", err)
+		}
+	}
+
+	state, err := integrate.LoadDownstreamState(opts.DownstreamRepoPath)
+	if err != nil {
+		return report, fmt.Errorf("error loading downstream state: %v", err)
+	}
+	entries, err := recordedUpstreams(state, opts.Upstreams)
+	if err != nil {
+		return report, err
+	}
+
+	for _, entry := range entries {
+		spec := entry.spec
+		spec.Version = cmp.Or(spec.Version, entry.version)
+		opts.Logger.Log("resolving the latest version of upstream %s", spec.URL)
+		upstream, err := integrate.ResolveLatestUpstream(&integrate.LatestUpstreamRequest{
+			Logger:     opts.Logger,
+			Upstream:   spec,
+			CommitHash: entry.commitHash,
+			CacheTTL:   opts.CacheTTL,
+			NoCache:    opts.NoCache,
+			Progress:   opts.Progress,
+		})
+		if err != nil {
+			return report, err
+		}
+		report.Upstreams = append(report.Upstreams, upstream)
+		report.HasOutdated = report.HasOutdated || upstream.Outdated
+	}
+
+	if opts.Files && report.HasOutdated {
+		if err := outdatedChangedFiles(opts, entries, report); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// outdatedChangedFiles sets ChangedFiles on each outdated upstream in report.
+// In a scratch clone of the downstream, it reproduces the recorded
+// integration of every upstream, then updates each outdated one in turn,
+// noting the files each update changes. Local drift is left out that way, and
+// migrations don't run.
+func outdatedChangedFiles(opts *sdktypes.OutdatedOptions, entries []recordedUpstream, report *sdktypes.OutdatedReport) error {
+	if err := gitbin.Require(); err != nil {
+		return err
+	}
+	opts.Logger.Log("provisioning scratch clone of %s to preview updates", opts.DownstreamRepoPath)
+	scratchPath, cleanup, err := provisionScratchClone(opts.DownstreamRepoPath)
+	if err != nil {
+		return fmt.Errorf("error provisioning scratch clone to preview updates: %w", err)
+	}
+	defer cleanup()
+	if err := ensureStateFilePresent(opts.DownstreamRepoPath, scratchPath); err != nil {
+		return fmt.Errorf("error preparing scratch clone: %w", err)
+	}
+
+	driftCheckRequest := func(entry recordedUpstream) *integrate.DriftCheckRequest {
+		return &integrate.DriftCheckRequest{
+			Logger:                 opts.Logger,
+			DownstreamRepoPath:     scratchPath,
+			UpstreamURL:            entry.spec.URL,
+			UpstreamSubpath:        entry.spec.Subpath,
+			UpstreamToken:          entry.spec.Token,
+			UpstreamCommit:         entry.commitHash,
+			CacheTTL:               opts.CacheTTL,
+			NoCache:                opts.NoCache,
+			Progress:               opts.Progress,
+			DownstreamMetadataPath: opts.DownstreamRepoPath,
+			Inputs:                 opts.Inputs,
+		}
+	}
+	for _, entry := range entries {
+		opts.Logger.Log("re-integrating upstream %s at commit %s", entry.spec.URL, entry.commitHash)
+		if _, err := integrate.IntegrateForDriftCheck(driftCheckRequest(entry)); err != nil {
+			return fmt.Errorf("error re-integrating upstream %s: %w", entry.spec.URL, err)
+		}
+	}
+
+	for i, entry := range entries {
+		upstream := &report.Upstreams[i]
+		if !upstream.Outdated {
+			continue
+		}
+		beforeFiles, err := integrate.ListWorktreeFiles(scratchPath)
+		if err != nil {
+			return fmt.Errorf("error listing worktree files before update: %v", err)
+		}
+		opts.Logger.Log("previewing update of upstream %s to %s at commit %s", entry.spec.URL, upstream.LatestVersion, upstream.LatestCommitHash)
+		req := driftCheckRequest(entry)
+		req.PrevUpstreamCommit, req.UpstreamCommit = entry.commitHash, upstream.LatestCommitHash
+		if upstream.Track == sdktypes.OutdatedTrackTag {
+			req.UpstreamVersion = upstream.LatestVersion
+		}
+		if _, err := integrate.IntegrateForDriftCheck(req); err != nil {
+			return fmt.Errorf("error previewing update of upstream %s: %w", entry.spec.URL, err)
+		}
+		afterFiles, err := integrate.ListWorktreeFiles(scratchPath)
+		if err != nil {
+			return fmt.Errorf("error listing worktree files after update: %v", err)
+		}
+		for f, hash := range afterFiles {
+			if beforeFiles[f] != hash {
+				upstream.ChangedFiles = append(upstream.ChangedFiles, filepath.ToSlash(f))
+			}
+		}
+		for f := range beforeFiles {
+			if _, stillPresent := afterFiles[f]; !stillPresent {
+				upstream.ChangedFiles = append(upstream.ChangedFiles, filepath.ToSlash(f))
+			}
+		}
+		// gitspork's own bookkeeping isn't a managed file.
+		upstream.ChangedFiles = slices.DeleteFunc(upstream.ChangedFiles, func(f string) bool {
+			return strings.HasPrefix(f, ".gitspork/")
+		})
+		slices.Sort(upstream.ChangedFiles)
+	}
+	return nil
+}
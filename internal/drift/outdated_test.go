@@ -0,0 +1,68 @@
+package drift
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	gogit "github.com/go-git/go-git/v6"
+	"github.com/rockholla/gitspork/v2/internal/logutil"
+	"github.com/rockholla/gitspork/v2/internal/sdktypes"
+	"github.com/rockholla/gitspork/v2/test/testharness"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutdated(t *testing.T) {
+	upstreamDir, _ := testharness.MinimalUpstream(t)
+	testharness.WriteFiles(t, upstreamDir, map[string]string{
+		"upstream-owned/other.txt": "other\n",
+		"upstream-owned/gone.txt":  "gone\n",
+	})
+	upstreamRepo, err := gogit.PlainOpen(upstreamDir)
+	require.NoError(t, err)
+	first := testharness.CommitAllWithMessage(t, upstreamRepo, "more files")
+	downstreamDir := testharness.EmptyDownstream(t)
+	testIntegrateAndCommitBaseline(t, upstreamDir, downstreamDir)
+	// Local drift in a file the update leaves alone isn't a change the update makes.
+	testWriteAndCommitInDownstream(t, downstreamDir, "upstream-owned/other.txt", "drifted\n")
+
+	opts := func(files bool) *sdktypes.OutdatedOptions {
+		return &sdktypes.OutdatedOptions{
+			Logger:             logutil.New(),
+			DownstreamRepoPath: downstreamDir,
+			Files:              files,
+			CacheTTL:           time.Nanosecond,
+		}
+	}
+
+	report, err := Outdated(opts(true))
+	require.NoError(t, err)
+	assert.False(t, report.HasOutdated)
+	require.Len(t, report.Upstreams, 1)
+	assert.Equal(t, sdktypes.OutdatedTrackBranch, report.Upstreams[0].Track)
+	assert.Equal(t, first.String(), report.Upstreams[0].LatestCommitHash)
+	assert.Empty(t, report.Upstreams[0].ChangedFiles)
+
+	testharness.WriteFiles(t, upstreamDir, map[string]string{"upstream-owned/file.txt": "updated\n"})
+	require.NoError(t, os.Remove(filepath.Join(upstreamDir, "upstream-owned", "gone.txt")))
+	latest := testharness.CommitAllWithMessage(t, upstreamRepo, "update")
+
+	report, err = Outdated(opts(false))
+	require.NoError(t, err)
+	assert.True(t, report.HasOutdated)
+	upstream := report.Upstreams[0]
+	assert.Equal(t, "file://"+upstreamDir, upstream.URL)
+	assert.Equal(t, "main", upstream.Version)
+	assert.Equal(t, first.String(), upstream.CommitHash)
+	assert.Equal(t, latest.String(), upstream.LatestCommitHash)
+	assert.Equal(t, 1, upstream.CommitsBehind)
+	assert.Empty(t, upstream.ChangedFiles)
+
+	report, err = Outdated(opts(true))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"upstream-owned/file.txt", "upstream-owned/gone.txt"}, report.Upstreams[0].ChangedFiles)
+	assert.Equal(t, "drifted\n", testharness.ReadFile(t, downstreamDir, "upstream-owned/other.txt"))
+	assert.Equal(t, "upstream content\n", testharness.ReadFile(t, downstreamDir, "upstream-owned/file.txt"))
+}
@@ -0,0 +1,105 @@
+package drift
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/gobwas/glob"
+	"github.com/rockholla/gitspork/v2/internal/sdktypes"
+)
+
+// compileFixPaths compiles the patterns limiting a fix or corrective patch,
+// failing on the first invalid one.
+func compileFixPaths(patterns []string) ([]glob.Glob, error) {
+	var globs []glob.Glob
+	for _, pattern := range patterns {
+		g, err := glob.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid fix path pattern %q: %v", pattern, err)
+		}
+		globs = append(globs, g)
+	}
+	return globs, nil
+}
+
+// remediationPaths returns the drifted files in report matching any of globs,
+// or all of them when there are no globs. Ignored drift is never included.
+func remediationPaths(report *sdktypes.DriftReport, globs []glob.Glob) []string {
+	var paths []string
+	for _, f := range report.Files {
+		matched := len(globs) == 0
+		for _, g := range globs {
+			if g.Match(f.Path) {
+				matched = true
+				break
+			}
+		}
+		if matched {
+			paths = append(paths, f.Path)
+		}
+	}
+	return paths
+}
+
+// correctivePatch returns a `git apply`-able patch, binary content included,
+// that turns paths in the checked downstream, its HEAD or working tree
+// snapshot, into what the re-integration produced. scratchPath must have the
+// re-integration committed on top of it, as diffWorktreeAgainstHEAD leaves it.
+func correctivePatch(scratchPath string, paths []string) ([]byte, error) {
+	args := []string{"-c", "safe.directory=*", "-C", scratchPath,
+		"diff", "--binary", "--no-color", "--no-ext-diff", "--no-textconv", "--no-renames", "HEAD~1", "HEAD", "--"}
+	for _, p := range paths {
+		args = append(args, ":(literal)"+p)
+	}
+	cmd := exec.Command("git", args...)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("error computing the corrective patch: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// applyCorrectivePatch applies patch to the working tree of the downstream at
+// repoPath, leaving the changes uncommitted.
+func applyCorrectivePatch(repoPath string, patch []byte) error {
+	cmd := exec.Command("git", "-c", "safe.directory=*", "-C", repoPath, "apply", "--whitespace=nowarn", "-")
+	cmd.Stdin = bytes.NewReader(patch)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("error applying the corrective patch to %s: %v: %s", repoPath, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// remediate writes the corrective patch for the drifted files matching globs
+// to opts.PatchOut and, with opts.Fix, applies it to the downstream, listing
+// what it fixed in report.Fixed.
+func remediate(opts *sdktypes.CheckDriftOptions, scratchPath string, report *sdktypes.DriftReport, globs []glob.Glob) error {
+	paths := remediationPaths(report, globs)
+	if len(paths) == 0 {
+		opts.Logger.Log("no drifted files match the fix paths, nothing to remediate")
+		return nil
+	}
+	patch, err := correctivePatch(scratchPath, paths)
+	if err != nil {
+		return err
+	}
+	if opts.PatchOut != nil {
+		if _, err := opts.PatchOut.Write(patch); err != nil {
+			return fmt.Errorf("error writing the corrective patch: %v", err)
+		}
+	}
+	if opts.Fix {
+		if err := applyCorrectivePatch(opts.DownstreamRepoPath, patch); err != nil {
+			return err
+		}
+		opts.Logger.Log("restored %d drifted file(s) in %s to the recorded upstream state, review and commit the changes", len(paths), opts.DownstreamRepoPath)
+		report.Fixed = paths
+	}
+	return nil
+}
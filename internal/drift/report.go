@@ -0,0 +1,378 @@
+package drift
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/rockholla/gitspork/v2/internal/config"
+	"github.com/rockholla/gitspork/v2/internal/sdktypes"
+)
+
+// Formats WriteReport renders a DriftReport in.
+const (
+	FormatJSON     = "json"
+	FormatSARIF    = "sarif"
+	FormatJUnit    = "junit"
+	FormatMarkdown = "markdown"
+)
+
+// Formats lists every format WriteReport accepts.
+var Formats = []string{FormatJSON, FormatSARIF, FormatJUnit, FormatMarkdown}
+
+// sarifRuleDescriptions are the SARIF rules, one per change kind, that drift
+// results refer to.
+var sarifRuleDescriptions = map[string]string{
+	sdktypes.DriftChangeModified: "A file differs from what its upstream integration produces",
+	sdktypes.DriftChangeDeleted:  "A file its upstream integration produces is missing from the downstream",
+	sdktypes.DriftChangeAdded:    "A file is in the downstream but its upstream integration doesn't produce it",
+	sdktypes.DriftChangeMode:     "A file's mode differs from what its upstream integration produces",
+}
+
+// WriteReport renders report to w in format, one of Formats.
+func WriteReport(w io.Writer, report *sdktypes.DriftReport, format string) error {
+	switch format {
+	case FormatJSON:
+		return writeJSONReport(w, report)
+	case FormatSARIF:
+		return writeSARIFReport(w, report)
+	case FormatJUnit:
+		return writeJUnitReport(w, report)
+	case FormatMarkdown:
+		return writeMarkdownReport(w, report)
+	}
+	return fmt.Errorf("invalid drift report format %q: expects one of %s", format, strings.Join(Formats, ", "))
+}
+
+// describeDriftedFile is a one-line explanation of f, used as the SARIF
+// message and the JUnit failure message.
+func describeDriftedFile(f sdktypes.DriftedFile) string {
+	upstream := "its upstream"
+	if f.AttributedURL != "" {
+		upstream = "upstream " + f.AttributedURL
+	}
+	var msg string
+	switch f.Change {
+	case sdktypes.DriftChangeDeleted:
+		msg = fmt.Sprintf("%s is produced by %s but missing from the downstream", f.Path, upstream)
+	case sdktypes.DriftChangeAdded:
+		msg = fmt.Sprintf("%s is in the downstream but %s doesn't produce it", f.Path, upstream)
+	case sdktypes.DriftChangeMode:
+		msg = fmt.Sprintf("%s has a different mode than %s produces", f.Path, upstream)
+	default:
+		msg = fmt.Sprintf("%s differs from what %s produces", f.Path, upstream)
+	}
+	if f.Ownership != "" {
+		msg += fmt.Sprintf(" (managed by %s entry %s)", f.Ownership, f.ConfigEntry)
+	}
+	if f.Migration != "" {
+		msg += fmt.Sprintf(" (produced by migration %s)", f.Migration)
+	}
+	if f.ExpiredIgnore != "" {
+		msg += fmt.Sprintf(" (ignore expired on %s)", f.ExpiredIgnore)
+	}
+	return msg
+}
+
+// describeIgnoredDriftedFile explains why f's drift is ignored.
+func describeIgnoredDriftedFile(f sdktypes.IgnoredDriftedFile) string {
+	msg := fmt.Sprintf("drift ignored by %s", f.Pattern)
+	if f.Justification != "" {
+		msg += ": " + f.Justification
+	}
+	if f.Expires != "" {
+		msg += fmt.Sprintf(" (until %s)", f.Expires)
+	}
+	return msg
+}
+
+func writeJSONReport(w io.Writer, report *sdktypes.DriftReport) error {
+	out := *report
+	if out.Files == nil {
+		out.Files = []sdktypes.DriftedFile{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool       sarifTool      `json:"tool"`
+	Results    []sarifResult  `json:"results"`
+	Properties map[string]any `json:"properties,omitempty"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID       string             `json:"ruleId"`
+	Level        string             `json:"level"`
+	Message      sarifMessage       `json:"message"`
+	Locations    []sarifLocation    `json:"locations"`
+	Properties   map[string]any     `json:"properties"`
+	Suppressions []sarifSuppression `json:"suppressions,omitempty"`
+}
+
+type sarifSuppression struct {
+	Kind          string `json:"kind"`
+	Justification string `json:"justification,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+// sarifRuleID is the SARIF rule for a change kind.
+func sarifRuleID(change string) string {
+	return "drift/" + change
+}
+
+func writeSARIFReport(w io.Writer, report *sdktypes.DriftReport) error {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           config.GitSpork,
+			InformationURI: "https://github.com/rockholla/gitspork",
+		}},
+		Results: []sarifResult{},
+	}
+	if report.WorkingTree {
+		run.Properties = map[string]any{"workingTree": true}
+	}
+	for _, change := range []string{sdktypes.DriftChangeModified, sdktypes.DriftChangeDeleted, sdktypes.DriftChangeAdded, sdktypes.DriftChangeMode} {
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{
+			ID:               sarifRuleID(change),
+			ShortDescription: sarifMessage{Text: sarifRuleDescriptions[change]},
+		})
+	}
+	for _, f := range report.Files {
+		run.Results = append(run.Results, sarifDriftResult(f))
+	}
+	// Ignored drift stays in the log as suppressed results, which code
+	// scanning shows as dismissed.
+	for _, f := range report.Ignored {
+		result := sarifDriftResult(f.DriftedFile)
+		result.Properties["ignorePattern"] = f.Pattern
+		if f.Expires != "" {
+			result.Properties["ignoreExpires"] = f.Expires
+		}
+		result.Suppressions = []sarifSuppression{{Kind: "external", Justification: f.Justification}}
+		run.Results = append(run.Results, result)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	})
+}
+
+// sarifDriftResult is the SARIF result for a drifted file.
+func sarifDriftResult(f sdktypes.DriftedFile) sarifResult {
+	properties := map[string]any{"change": f.Change, "diff": f.Diff}
+	if f.AttributedURL != "" {
+		properties["attributedUrl"] = f.AttributedURL
+	}
+	if f.Ownership != "" {
+		properties["ownership"] = f.Ownership
+		properties["configEntry"] = f.ConfigEntry
+	}
+	if f.Migration != "" {
+		properties["migration"] = f.Migration
+	}
+	if f.ExpiredIgnore != "" {
+		properties["expiredIgnore"] = f.ExpiredIgnore
+	}
+	return sarifResult{
+		RuleID:     sarifRuleID(f.Change),
+		Level:      "error",
+		Message:    sarifMessage{Text: describeDriftedFile(f)},
+		Locations:  []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: f.Path}}}},
+		Properties: properties,
+	}
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr,omitempty"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr,omitempty"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Body    string `xml:",chardata"`
+}
+
+// junitSuiteName names the JUnit suite, and the passing test case reported
+// when there's no drift.
+const junitSuiteName = "gitspork check-drift"
+
+func writeJUnitReport(w io.Writer, report *sdktypes.DriftReport) error {
+	suite := junitTestSuite{Name: junitSuiteName}
+	className := func(f sdktypes.DriftedFile) string {
+		if f.AttributedURL == "" {
+			return "unattributed"
+		}
+		return f.AttributedURL
+	}
+	for _, f := range report.Files {
+		suite.TestCases = append(suite.TestCases, junitTestCase{
+			ClassName: className(f),
+			Name:      f.Path,
+			Failure:   &junitFailure{Message: describeDriftedFile(f), Type: f.Change, Body: f.Diff},
+		})
+	}
+	for _, f := range report.Ignored {
+		suite.TestCases = append(suite.TestCases, junitTestCase{
+			ClassName: className(f.DriftedFile),
+			Name:      f.Path,
+			Skipped:   &junitSkipped{Message: describeIgnoredDriftedFile(f)},
+		})
+	}
+	if len(suite.TestCases) == 0 {
+		suite.TestCases = []junitTestCase{{ClassName: config.GitSpork, Name: "no drift"}}
+	}
+	suite.Tests, suite.Failures, suite.Skipped = len(suite.TestCases), len(report.Files), len(report.Ignored)
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(junitTestSuites{Name: junitSuiteName, Tests: suite.Tests, Failures: suite.Failures, Skipped: suite.Skipped, Suites: []junitTestSuite{suite}}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func writeMarkdownReport(w io.Writer, report *sdktypes.DriftReport) error {
+	var b strings.Builder
+	b.WriteString("## gitspork drift\n\n")
+	if report.WorkingTree {
+		b.WriteString("Checked against the working tree, including uncommitted changes.\n\n")
+	}
+	if len(report.Files) == 0 {
+		b.WriteString("No drift detected.\n")
+		writeMarkdownIgnored(&b, report.Ignored)
+		_, err := io.WriteString(w, b.String())
+		return err
+	}
+	fmt.Fprintf(&b, "%d file(s) drifted from their upstreams.\n\n", len(report.Files))
+	b.WriteString("| File | Change | Upstream | Managed by | Migration |\n|---|---|---|---|---|\n")
+	for _, f := range report.Files {
+		var managedBy string
+		if f.Ownership != "" {
+			managedBy = fmt.Sprintf("%s `%s`", f.Ownership, f.ConfigEntry)
+		}
+		fmt.Fprintf(&b, "| `%s` | %s | %s | %s | %s |\n", markdownCell(f.Path), f.Change, markdownCell(f.AttributedURL), markdownCell(managedBy), markdownCell(f.Migration))
+	}
+	for _, f := range report.Files {
+		if f.Diff == "" {
+			continue
+		}
+		fence := markdownFence(f.Diff)
+		fmt.Fprintf(&b, "\n<details>\n<summary><code>%s</code></summary>\n\n%sdiff\n%s", xmlEscape(f.Path), fence, f.Diff)
+		if !strings.HasSuffix(f.Diff, "\n") {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "%s\n\n</details>\n", fence)
+	}
+	writeMarkdownIgnored(&b, report.Ignored)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeMarkdownIgnored adds a table of the ignored drift, if any, to a
+// Markdown report.
+func writeMarkdownIgnored(b *strings.Builder, ignored []sdktypes.IgnoredDriftedFile) {
+	if len(ignored) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "\n### Ignored\n\n%d file(s) drifted, but are accepted by `.gitspork/%s`.\n\n", len(ignored), config.DriftIgnoreFileName)
+	b.WriteString("| File | Change | Pattern | Justification | Expires |\n|---|---|---|---|---|\n")
+	for _, f := range ignored {
+		fmt.Fprintf(b, "| `%s` | %s | `%s` | %s | %s |\n", markdownCell(f.Path), f.Change, markdownCell(f.Pattern), markdownCell(f.Justification), f.Expires)
+	}
+}
+
+// markdownCell escapes s for a Markdown table cell.
+func markdownCell(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+}
+
+// markdownFence is a code fence longer than any run of backticks in s.
+func markdownFence(s string) string {
+	longest, run := 0, 0
+	for _, r := range s {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	return strings.Repeat("`", max(3, longest+1))
+}
+
+// xmlEscape escapes s for HTML in a Markdown report.
+func xmlEscape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
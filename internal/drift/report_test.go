@@ -0,0 +1,183 @@
+package drift
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"testing"
+
+	"github.com/rockholla/gitspork/v2/internal/sdktypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteReport(t *testing.T) {
+	report := &sdktypes.DriftReport{
+		HasDrift: true,
+		Files: []sdktypes.DriftedFile{
+			{
+				Path:          "upstream-owned/file.txt",
+				AttributedURL: "https://github.com/acme/platform.git",
+				Change:        sdktypes.DriftChangeModified,
+				Ownership:     sdktypes.OwnershipUpstreamOwned,
+				ConfigEntry:   "upstream-owned/**",
+				Diff:          "diff --git a/upstream-owned/file.txt b/upstream-owned/file.txt\n-upstream\n+drifted\n",
+				ColorizedDiff: "\x1b[1mcolored\x1b[0m",
+			},
+			{
+				Path:      "CHANGELOG.md",
+				Change:    sdktypes.DriftChangeDeleted,
+				Migration: "migrations/0001.yml:post_integrate",
+			},
+		},
+	}
+	write := func(t *testing.T, report *sdktypes.DriftReport, format string) string {
+		t.Helper()
+		var buf bytes.Buffer
+		require.NoError(t, WriteReport(&buf, report, format))
+		return buf.String()
+	}
+
+	t.Run("json", func(t *testing.T) {
+		var got sdktypes.DriftReport
+		require.NoError(t, json.Unmarshal([]byte(write(t, report, FormatJSON)), &got))
+		report := *report
+		report.Files = []sdktypes.DriftedFile{report.Files[0], report.Files[1]}
+		report.Files[0].ColorizedDiff = ""
+		assert.Equal(t, report, got)
+
+		assert.JSONEq(t, `{"has_drift": false, "files": []}`, write(t, &sdktypes.DriftReport{}, FormatJSON))
+	})
+
+	t.Run("sarif", func(t *testing.T) {
+		var got struct {
+			Version string `json:"version"`
+			Runs    []struct {
+				Results []struct {
+					RuleID    string `json:"ruleId"`
+					Message   struct{ Text string }
+					Locations []struct {
+						PhysicalLocation struct {
+							ArtifactLocation struct{ URI string }
+						}
+					}
+					Properties map[string]any
+				}
+			}
+		}
+		require.NoError(t, json.Unmarshal([]byte(write(t, report, FormatSARIF)), &got))
+		assert.Equal(t, "2.1.0", got.Version)
+		require.Len(t, got.Runs, 1)
+		require.Len(t, got.Runs[0].Results, 2)
+		modified, deleted := got.Runs[0].Results[0], got.Runs[0].Results[1]
+		assert.Equal(t, "drift/modified", modified.RuleID)
+		assert.Equal(t, "upstream-owned/file.txt", modified.Locations[0].PhysicalLocation.ArtifactLocation.URI)
+		assert.Equal(t, "https://github.com/acme/platform.git", modified.Properties["attributedUrl"])
+		assert.Contains(t, modified.Properties["diff"], "+drifted")
+		assert.Equal(t, "upstream_owned", modified.Properties["ownership"])
+		assert.Equal(t, "upstream-owned/file.txt differs from what upstream https://github.com/acme/platform.git produces (managed by upstream_owned entry upstream-owned/**)", modified.Message.Text)
+		assert.Equal(t, "drift/deleted", deleted.RuleID)
+		assert.Equal(t, "CHANGELOG.md is produced by its upstream but missing from the downstream (produced by migration migrations/0001.yml:post_integrate)", deleted.Message.Text)
+	})
+
+	t.Run("junit", func(t *testing.T) {
+		var got junitTestSuites
+		require.NoError(t, xml.Unmarshal([]byte(write(t, report, FormatJUnit)), &got))
+		assert.Equal(t, 2, got.Failures)
+		require.Len(t, got.Suites, 1)
+		require.Len(t, got.Suites[0].TestCases, 2)
+		tc := got.Suites[0].TestCases[0]
+		assert.Equal(t, "https://github.com/acme/platform.git", tc.ClassName)
+		assert.Equal(t, "upstream-owned/file.txt", tc.Name)
+		require.NotNil(t, tc.Failure)
+		assert.Equal(t, sdktypes.DriftChangeModified, tc.Failure.Type)
+		assert.Contains(t, tc.Failure.Body, "+drifted")
+		assert.Equal(t, "unattributed", got.Suites[0].TestCases[1].ClassName)
+
+		require.NoError(t, xml.Unmarshal([]byte(write(t, &sdktypes.DriftReport{}, FormatJUnit)), &got))
+		assert.Equal(t, 0, got.Failures)
+		assert.Equal(t, 1, got.Tests)
+	})
+
+	t.Run("markdown", func(t *testing.T) {
+		got := write(t, report, FormatMarkdown)
+		assert.Contains(t, got, "2 file(s) drifted")
+		assert.Contains(t, got, "| `upstream-owned/file.txt` | modified | https://github.com/acme/platform.git | upstream_owned `upstream-owned/**` |  |\n")
+		assert.Contains(t, got, "| `CHANGELOG.md` | deleted |  |  | migrations/0001.yml:post_integrate |\n")
+		assert.Contains(t, got, "```diff\ndiff --git a/upstream-owned/file.txt")
+		assert.NotContains(t, got, "\x1b[")
+
+		assert.Contains(t, write(t, &sdktypes.DriftReport{}, FormatMarkdown), "No drift detected.")
+	})
+
+	t.Run("ignored drift", func(t *testing.T) {
+		ignoredReport := &sdktypes.DriftReport{
+			HasDrift: true,
+			Files:    []sdktypes.DriftedFile{{Path: "Makefile", Change: sdktypes.DriftChangeModified, ExpiredIgnore: "2026-01-31"}},
+			Ignored: []sdktypes.IgnoredDriftedFile{{
+				DriftedFile:   sdktypes.DriftedFile{Path: ".github/workflows/ci.yml", Change: sdktypes.DriftChangeModified},
+				Pattern:       ".github/workflows/*.yml",
+				Justification: "waiting on the upstream fix",
+				Expires:       "2026-12-31",
+			}},
+		}
+
+		var sarif struct {
+			Runs []struct {
+				Results []struct {
+					Message      struct{ Text string }
+					Properties   map[string]any
+					Suppressions []struct{ Kind, Justification string }
+				}
+			}
+		}
+		require.NoError(t, json.Unmarshal([]byte(write(t, ignoredReport, FormatSARIF)), &sarif))
+		require.Len(t, sarif.Runs[0].Results, 2)
+		drifted, ignored := sarif.Runs[0].Results[0], sarif.Runs[0].Results[1]
+		assert.Empty(t, drifted.Suppressions)
+		assert.Contains(t, drifted.Message.Text, "(ignore expired on 2026-01-31)")
+		require.Len(t, ignored.Suppressions, 1)
+		assert.Equal(t, "external", ignored.Suppressions[0].Kind)
+		assert.Equal(t, "waiting on the upstream fix", ignored.Suppressions[0].Justification)
+		assert.Equal(t, ".github/workflows/*.yml", ignored.Properties["ignorePattern"])
+		assert.Equal(t, "2026-12-31", ignored.Properties["ignoreExpires"])
+
+		var junit junitTestSuites
+		require.NoError(t, xml.Unmarshal([]byte(write(t, ignoredReport, FormatJUnit)), &junit))
+		assert.Equal(t, 2, junit.Tests)
+		assert.Equal(t, 1, junit.Failures)
+		assert.Equal(t, 1, junit.Skipped)
+		skipped := junit.Suites[0].TestCases[1]
+		assert.Nil(t, skipped.Failure)
+		require.NotNil(t, skipped.Skipped)
+		assert.Equal(t, "drift ignored by .github/workflows/*.yml: waiting on the upstream fix (until 2026-12-31)", skipped.Skipped.Message)
+
+		markdown := write(t, ignoredReport, FormatMarkdown)
+		assert.Contains(t, markdown, "1 file(s) drifted, but are accepted by `.gitspork/drift-ignore.yml`.")
+		assert.Contains(t, markdown, "| `.github/workflows/ci.yml` | modified | `.github/workflows/*.yml` | waiting on the upstream fix | 2026-12-31 |\n")
+
+		ignoredReport.HasDrift, ignoredReport.Files = false, nil
+		markdown = write(t, ignoredReport, FormatMarkdown)
+		assert.Contains(t, markdown, "No drift detected.")
+		assert.Contains(t, markdown, "### Ignored")
+		require.NoError(t, xml.Unmarshal([]byte(write(t, ignoredReport, FormatJUnit)), &junit))
+		assert.Equal(t, 1, junit.Tests)
+		assert.Equal(t, 0, junit.Failures)
+	})
+
+	t.Run("working tree", func(t *testing.T) {
+		workingTreeReport := &sdktypes.DriftReport{WorkingTree: true}
+		assert.JSONEq(t, `{"has_drift": false, "working_tree": true, "files": []}`, write(t, workingTreeReport, FormatJSON))
+		assert.Contains(t, write(t, workingTreeReport, FormatSARIF), "\"properties\": {\n        \"workingTree\": true\n      }")
+		assert.Contains(t, write(t, workingTreeReport, FormatMarkdown), "Checked against the working tree, including uncommitted changes.")
+	})
+
+	t.Run("markdown fences outlast backticks in the diff", func(t *testing.T) {
+		assert.Equal(t, "```", markdownFence("no backticks"))
+		assert.Equal(t, "`````", markdownFence("+````go"))
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		require.ErrorContains(t, WriteReport(&bytes.Buffer{}, report, "yaml"), `invalid drift report format "yaml"`)
+	})
+}
@@ -0,0 +1,203 @@
+package drift
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/rockholla/gitspork/v2/internal/config"
+	"github.com/rockholla/gitspork/v2/internal/integrate"
+	"github.com/rockholla/gitspork/v2/internal/sdktypes"
+)
+
+// ScanFormatTable is the summary table WriteScanReport renders, alongside
+// FormatJSON.
+const ScanFormatTable = "table"
+
+// ScanFormats lists every format WriteScanReport accepts.
+var ScanFormats = []string{ScanFormatTable, FormatJSON}
+
+// Scan checks drift in each downstream opts lists, running up to
+// opts.Concurrency checks at once. A downstream whose check fails is reported
+// with ScanStatusError rather than failing the scan; the returned error is
+// only for a scan that can't start, like an invalid manifest.
+func Scan(opts *sdktypes.ScanOptions) (*sdktypes.ScanReport, error) {
+	report := &sdktypes.ScanReport{}
+	if opts.Logger == nil {
+		opts.Logger = sdktypes.NoopLogger()
+	}
+	if opts.Concurrency < 0 {
+		return report, fmt.Errorf("invalid concurrency %d: expects a positive number, or zero for the number of CPUs", opts.Concurrency)
+	}
+	paths, err := scanPaths(opts)
+	if err != nil {
+		return report, err
+	}
+	if len(paths) == 0 {
+		return report, fmt.Errorf("no downstream repos to scan: give their paths, path patterns or a manifest")
+	}
+	concurrency := cmp.Or(opts.Concurrency, runtime.NumCPU())
+	opts.Logger.Log("scanning %d downstream repo(s) for drift, %d at a time", len(paths), concurrency)
+
+	report.Repos = make([]sdktypes.ScanRepoResult, len(paths))
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, path := range paths {
+		slots <- struct{}{}
+		wg.Go(func() {
+			defer func() { <-slots }()
+			report.Repos[i] = scanRepo(opts, path)
+		})
+	}
+	wg.Wait()
+
+	for _, repo := range report.Repos {
+		report.HasDrift = report.HasDrift || repo.Status == sdktypes.ScanStatusDrift
+		report.HasErrors = report.HasErrors || repo.Status == sdktypes.ScanStatusError
+	}
+	return report, nil
+}
+
+// scanPaths resolves the downstream paths and path patterns in opts, and in
+// its manifest, to absolute paths, in the order given and without duplicates.
+// A pattern must match at least one directory.
+func scanPaths(opts *sdktypes.ScanOptions) ([]string, error) {
+	entries := slices.Clone(opts.DownstreamRepoPaths)
+	if opts.Manifest != "" {
+		manifest, err := config.ParseScanManifest(opts.Manifest)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, manifest.Downstreams...)
+	}
+
+	var paths []string
+	for _, entry := range entries {
+		matches := []string{entry}
+		if strings.ContainsAny(entry, `*?[\`) {
+			globbed, err := filepath.Glob(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid downstream path pattern %q: %v", entry, err)
+			}
+			matches = slices.DeleteFunc(globbed, func(match string) bool {
+				info, err := os.Stat(match)
+				return err != nil || !info.IsDir()
+			})
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no downstream repos match %q", entry)
+			}
+		}
+		for _, match := range matches {
+			path, err := filepath.Abs(match)
+			if err != nil {
+				return nil, fmt.Errorf("unable to determine local downstream repo path %s: %v", match, err)
+			}
+			if !slices.Contains(paths, path) {
+				paths = append(paths, path)
+			}
+		}
+	}
+	return paths, nil
+}
+
+// scanRepo checks drift in the downstream at path. Its own progress isn't
+// logged, since checks run concurrently, only the outcome is.
+func scanRepo(opts *sdktypes.ScanOptions, path string) sdktypes.ScanRepoResult {
+	result := sdktypes.ScanRepoResult{Path: path}
+	if state, err := integrate.LoadDownstreamState(path); err == nil {
+		result.Upstreams = state.Upstreams
+	}
+
+	driftReport, err := CheckDrift(&sdktypes.CheckDriftOptions{
+		DownstreamRepoPath: path,
+		CacheTTL:           opts.CacheTTL,
+		NoCache:            opts.NoCache,
+		Progress:           opts.Progress,
+		Migrations:         opts.Migrations,
+		Inputs:             opts.Inputs,
+		Context:            opts.Context,
+	})
+	result.IgnoredFiles = len(driftReport.Ignored)
+	switch {
+	case err != nil && !errors.Is(err, sdktypes.ErrDriftDetected):
+		result.Status, result.Error = sdktypes.ScanStatusError, err.Error()
+		opts.Logger.Log("error checking drift in %s: %v", path, err)
+	case driftReport.HasDrift:
+		result.Status, result.DriftedFiles = sdktypes.ScanStatusDrift, len(driftReport.Files)
+		for _, f := range driftReport.Files {
+			result.Files = append(result.Files, f.Path)
+		}
+		opts.Logger.Log("drift detected in %s: %d file(s) changed", path, result.DriftedFiles)
+	default:
+		result.Status = sdktypes.ScanStatusClean
+		opts.Logger.Log("no drift detected in %s", path)
+	}
+	return result
+}
+
+// WriteScanReport renders report to w in format, one of ScanFormats.
+func WriteScanReport(w io.Writer, report *sdktypes.ScanReport, format string) error {
+	switch format {
+	case FormatJSON:
+		out := *report
+		if out.Repos == nil {
+			out.Repos = []sdktypes.ScanRepoResult{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(out)
+	case ScanFormatTable:
+		return writeScanTable(w, report)
+	}
+	return fmt.Errorf("invalid scan report format %q: expects one of %s", format, strings.Join(ScanFormats, ", "))
+}
+
+// writeScanTable writes a row per downstream, then the errors of those that
+// failed and a count of each status.
+func writeScanTable(w io.Writer, report *sdktypes.ScanReport) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "REPO\tSTATUS\tDRIFTED\tIGNORED\tUPSTREAMS")
+	counts := map[string]int{}
+	for _, repo := range report.Repos {
+		counts[repo.Status]++
+		var upstreams []string
+		for _, upstream := range repo.Upstreams {
+			name := upstream.URL
+			if upstream.Subpath != "" {
+				name += "//" + upstream.Subpath
+			}
+			commit := upstream.CommitHash
+			if len(commit) > 7 {
+				commit = commit[:7]
+			}
+			if upstream.Version != "" {
+				commit = upstream.Version + " " + commit
+			}
+			upstreams = append(upstreams, fmt.Sprintf("%s (%s)", name, commit))
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%s\n", repo.Path, repo.Status, repo.DriftedFiles, repo.IgnoredFiles, strings.Join(upstreams, ", "))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if report.HasErrors {
+		fmt.Fprintln(w, "\nerrors:")
+		for _, repo := range report.Repos {
+			if repo.Status == sdktypes.ScanStatusError {
+				fmt.Fprintf(w, "  %s: %s\n", repo.Path, repo.Error)
+			}
+		}
+	}
+	_, err := fmt.Fprintf(w, "\n%d repo(s): %d clean, %d drifted, %d failed\n", len(report.Repos),
+		counts[sdktypes.ScanStatusClean], counts[sdktypes.ScanStatusDrift], counts[sdktypes.ScanStatusError])
+	return err
+}
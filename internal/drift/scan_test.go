@@ -0,0 +1,92 @@
+package drift
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rockholla/gitspork/v2/internal/logutil"
+	"github.com/rockholla/gitspork/v2/internal/sdktypes"
+	"github.com/rockholla/gitspork/v2/test/testharness"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScan(t *testing.T) {
+	upstreamDir, upstreamHead := testharness.MinimalUpstream(t)
+	clean := testharness.EmptyDownstream(t)
+	testIntegrateAndCommitBaseline(t, upstreamDir, clean)
+	drifted := testharness.EmptyDownstream(t)
+	testIntegrateAndCommitBaseline(t, upstreamDir, drifted)
+	testWriteAndCommitInDownstream(t, drifted, "upstream-owned/file.txt", "drifted\n")
+	notIntegrated := testharness.EmptyDownstream(t)
+
+	manifest := filepath.Join(t.TempDir(), "scan.yml")
+	require.NoError(t, os.WriteFile(manifest, []byte("downstreams:\n- "+notIntegrated+"\n- "+clean+"\n"), 0644))
+	report, err := Scan(&sdktypes.ScanOptions{
+		Logger:              logutil.New(),
+		DownstreamRepoPaths: []string{clean, drifted},
+		Manifest:            manifest,
+		Concurrency:         2,
+		CacheTTL:            time.Hour,
+	})
+	require.NoError(t, err)
+	assert.True(t, report.HasDrift)
+	assert.True(t, report.HasErrors)
+	require.Len(t, report.Repos, 3, "paths listed twice are checked once")
+
+	assert.Equal(t, clean, report.Repos[0].Path)
+	assert.Equal(t, sdktypes.ScanStatusClean, report.Repos[0].Status)
+	require.Len(t, report.Repos[0].Upstreams, 1)
+	assert.Equal(t, "file://"+upstreamDir, report.Repos[0].Upstreams[0].URL)
+	assert.Equal(t, "main", report.Repos[0].Upstreams[0].Version)
+	assert.Equal(t, upstreamHead.String(), report.Repos[0].Upstreams[0].CommitHash)
+
+	assert.Equal(t, sdktypes.ScanStatusDrift, report.Repos[1].Status)
+	assert.Equal(t, 1, report.Repos[1].DriftedFiles)
+	assert.Equal(t, []string{"upstream-owned/file.txt"}, report.Repos[1].Files)
+
+	assert.Equal(t, notIntegrated, report.Repos[2].Path)
+	assert.Equal(t, sdktypes.ScanStatusError, report.Repos[2].Status)
+	assert.NotEmpty(t, report.Repos[2].Error)
+
+	var table bytes.Buffer
+	require.NoError(t, WriteScanReport(&table, report, ScanFormatTable))
+	assert.Contains(t, table.String(), "REPO")
+	assert.Contains(t, table.String(), "file://"+upstreamDir+" (main "+upstreamHead.String()[:7]+")")
+	assert.Contains(t, table.String(), "errors:\n  "+notIntegrated+": ")
+	assert.Contains(t, table.String(), "3 repo(s): 1 clean, 1 drifted, 1 failed\n")
+
+	var got sdktypes.ScanReport
+	var out bytes.Buffer
+	require.NoError(t, WriteScanReport(&out, report, FormatJSON))
+	require.NoError(t, json.Unmarshal(out.Bytes(), &got))
+	assert.Equal(t, *report, got)
+	require.ErrorContains(t, WriteScanReport(&out, report, "sarif"), `invalid scan report format "sarif"`)
+}
+
+func Test_scanPaths(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"b", "a"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(root, "checkouts", dir), 0755))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(root, "checkouts", "notes.txt"), nil, 0644))
+
+	paths, err := scanPaths(&sdktypes.ScanOptions{DownstreamRepoPaths: []string{
+		filepath.Join(root, "checkouts", "b"),
+		filepath.Join(root, "checkouts", "*"),
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(root, "checkouts", "b"), filepath.Join(root, "checkouts", "a")}, paths)
+
+	_, err = scanPaths(&sdktypes.ScanOptions{DownstreamRepoPaths: []string{filepath.Join(root, "missing", "*")}})
+	require.ErrorContains(t, err, "no downstream repos match")
+
+	_, err = Scan(&sdktypes.ScanOptions{})
+	require.ErrorContains(t, err, "no downstream repos to scan")
+	_, err = Scan(&sdktypes.ScanOptions{DownstreamRepoPaths: []string{root}, Concurrency: -1})
+	require.ErrorContains(t, err, "invalid concurrency -1")
+}
@@ -83,6 +83,72 @@ func ensureStateFilePresent(callerPath, scratchPath string) error {
 	return nil
 }
 
+// snapshotWorkingTree copies the uncommitted state of callerPath's working
+// tree into scratchPath: staged, unstaged and untracked-but-not-ignored files
+// are copied as they are on disk, and files deleted on disk are removed. It
+// returns the paths it changed, relative to the repo root with forward slashes.
+//
+// Only paths `git status` reports are touched, so the scratch clone must be
+// checked out at the caller's HEAD, as provisionScratchClone leaves it.
+func snapshotWorkingTree(callerPath, scratchPath string) ([]string, error) {
+	out, err := exec.Command("git", "-c", "safe.directory=*", "-C", callerPath,
+		"status", "--porcelain", "-z", "--untracked-files=all", "--no-renames").Output()
+	if err != nil {
+		return nil, fmt.Errorf("error reading working tree status: %v", err)
+	}
+	var changed []string
+	for _, entry := range strings.Split(string(out), "\x00") {
+		// Each entry is "XY path"; --no-renames means no second path follows.
+		if len(entry) < 4 {
+			continue
+		}
+		rel := entry[3:]
+		src := filepath.Join(callerPath, filepath.FromSlash(rel))
+		dst := filepath.Join(scratchPath, filepath.FromSlash(rel))
+		info, err := os.Lstat(src)
+		switch {
+		case os.IsNotExist(err):
+			if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+				return nil, fmt.Errorf("error removing %s from scratch: %v", rel, err)
+			}
+		case err != nil:
+			return nil, fmt.Errorf("error reading %s: %v", src, err)
+		case info.IsDir():
+			// A submodule or nested repo: its content isn't the downstream's.
+			continue
+		default:
+			if err := copySnapshotFile(src, dst, info); err != nil {
+				return nil, fmt.Errorf("error copying %s to scratch: %v", rel, err)
+			}
+		}
+		changed = append(changed, rel)
+	}
+	return changed, nil
+}
+
+// copySnapshotFile replaces dst with a copy of the file or symlink at src,
+// keeping its permissions.
+func copySnapshotFile(src, dst string, info os.FileInfo) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(target, dst)
+	}
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, data, info.Mode().Perm())
+}
+
 // shellGitOutput runs `git <args...>` (with -c safe.directory=* prepended) and
 // returns trimmed stdout, wrapping stderr into the error on failure so callers
 // see the real git message.
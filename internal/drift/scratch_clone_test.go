@@ -128,3 +128,36 @@ func Test_ensureStateFilePresent_noopWhenAbsentInBoth(t *testing.T) {
 	_, err := os.Stat(filepath.Join(scratch, ".gitspork/downstream-state.json"))
 	assert.True(t, os.IsNotExist(err))
 }
+
+func Test_snapshotWorkingTree(t *testing.T) {
+	src := t.TempDir()
+	makeBaselineRepo(t, src)
+	require.NoError(t, os.WriteFile(filepath.Join(src, "gone.txt"), []byte("gone"), 0644))
+	require.NoError(t, exec.Command("git", "-c", "safe.directory=*", "-C", src, "add", "gone.txt").Run())
+	require.NoError(t, exec.Command("git", "-c", "safe.directory=*", "-C", src,
+		"-c", "user.name=test", "-c", "user.email=test@localhost", "commit", "-q", "-m", "gone").Run())
+
+	scratch, cleanup, err := provisionScratchClone(src)
+	require.NoError(t, err)
+	t.Cleanup(cleanup)
+
+	require.NoError(t, os.WriteFile(filepath.Join(src, "file.txt"), []byte("unstaged"), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(src, "new"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "new", "run.sh"), []byte("#!/bin/sh\n"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "ignored.log"), []byte("ignored"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(src, ".gitignore"), []byte("*.log\n"), 0644))
+	require.NoError(t, os.Remove(filepath.Join(src, "gone.txt")))
+
+	changed, err := snapshotWorkingTree(src, scratch)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{".gitignore", "file.txt", "gone.txt", "new/run.sh"}, changed)
+
+	got, err := os.ReadFile(filepath.Join(scratch, "file.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "unstaged", string(got))
+	info, err := os.Stat(filepath.Join(scratch, "new", "run.sh"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+	assert.NoFileExists(t, filepath.Join(scratch, "gone.txt"))
+	assert.NoFileExists(t, filepath.Join(scratch, "ignored.log"))
+}
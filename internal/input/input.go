@@ -9,19 +9,23 @@ import (
 	"io"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"syscall"
 
 	"github.com/fatih/color"
+	"golang.org/x/term"
 )
 
 // RequestInputType is an enum type representing a limited set of input request types, e.g. single value, selection etc.
 type RequestInputType int
 
 const (
-	SingleValue RequestInputType = iota // 0
-	Selection                           // 1
-	YesNo                               // 2
+	SingleValue    RequestInputType = iota // 0
+	Selection                              // 1
+	YesNo                                  // 2
+	MultiSelection                         // 3
+	Masked                                 // 4
 )
 
 // RequestInputOptions are options/args to pass to RequestInput
@@ -29,12 +33,19 @@ type RequestInputOptions struct {
 	Type          RequestInputType
 	Prompt        string
 	SelectOptions []string
+	// Help, if set, is printed on its own line before the prompt.
+	Help string
+	// Default, if set, is shown alongside the prompt. Applying it to an empty
+	// answer is left to the caller, which knows how to interpret it.
+	Default string
 }
 
 // RequestInputResult is an object representing the result of a RequestInput run
 type RequestInputResult struct {
 	StringValue string
 	BoolValue   bool
+	// StringValues holds the chosen options for a MultiSelection request.
+	StringValues []string
 }
 
 // RequestInput is the main entrypoint for the user of this package, designating the type of prompt,
@@ -50,16 +61,24 @@ func RequestInput(opts *RequestInputOptions) (*RequestInputResult, error) {
 		os.Exit(0)
 	}()
 
+	if opts.Help != "" {
+		fmt.Printf("ℹ️  %s\n", opts.Help)
+	}
+	prompt := opts.Prompt
+	if opts.Default != "" {
+		prompt = fmt.Sprintf("%s [%s]", prompt, opts.Default)
+	}
+
 	switch opts.Type {
 	case SingleValue:
-		value, err := readLine(opts.Prompt)
+		value, err := readLine(prompt)
 		if err != nil {
 			return result, err
 		}
 		result.StringValue = value
 		return result, nil
 	case Selection:
-		menu := NewMenu(fmt.Sprintf("➡️ %s", promptColor.Sprint(opts.Prompt)))
+		menu := NewMenu(fmt.Sprintf("➡️ %s", promptColor.Sprint(prompt)))
 		for _, selectOption := range opts.SelectOptions {
 			menu.AddItem(selectOption, selectOption)
 		}
@@ -71,16 +90,73 @@ func RequestInput(opts *RequestInputOptions) (*RequestInputResult, error) {
 		return result, err
 	case YesNo:
 		stdinReader := bufio.NewReader(os.Stdin)
-		fmt.Printf("➡️ %s (y/n) ", promptColor.Sprint(opts.Prompt))
+		fmt.Printf("➡️ %s (y/n) ", promptColor.Sprint(prompt))
 		yesNoResult, err := stdinReader.ReadString('\n')
 		if err != nil && err != io.EOF {
 			return result, err
 		}
 		yesNoResult = strings.TrimSpace(strings.ToLower(yesNoResult))
+		// StringValue carries the raw answer so callers can tell an explicit
+		// "no" apart from an empty answer that should fall back to a default.
+		result.StringValue = yesNoResult
 		if yesNoResult == "y" || yesNoResult == "yes" || yesNoResult == "1" {
 			result.BoolValue = true
 		}
 		return result, nil
+	case Masked:
+		value, err := readMasked(fmt.Sprintf("➡️ %s ", promptColor.Sprint(prompt)))
+		if err != nil {
+			return result, err
+		}
+		result.StringValue = value
+		return result, nil
+	case MultiSelection:
+		for i, selectOption := range opts.SelectOptions {
+			fmt.Printf("  %d: %s\n", i+1, selectOption)
+		}
+		value, err := readLine(fmt.Sprintf("%s (comma-separated numbers or values)", prompt))
+		if err != nil {
+			return result, err
+		}
+		result.StringValue = value
+		result.StringValues = ParseMultiSelection(value, opts.SelectOptions)
+		return result, nil
 	}
 	return result, nil
 }
+
+// ParseMultiSelection splits a comma-separated multi-selection answer into its
+// chosen values. Each entry may be a 1-based option number or the option text
+// itself; anything else is returned verbatim so the caller can reject it with
+// a meaningful message. Empty entries are dropped.
+func ParseMultiSelection(answer string, options []string) []string {
+	values := []string{}
+	for _, part := range strings.Split(answer, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if n, err := strconv.Atoi(part); err == nil && n >= 1 && n <= len(options) {
+			part = options[n-1]
+		}
+		values = append(values, part)
+	}
+	return values
+}
+
+// readMasked prints prompt and reads a line from /dev/tty without echoing it,
+// for secret values.
+func readMasked(prompt string) (string, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return "", err
+	}
+	defer tty.Close()
+	fmt.Fprint(tty, prompt)
+	value, err := term.ReadPassword(int(tty.Fd()))
+	fmt.Fprint(tty, "\n")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(value)), nil
+}
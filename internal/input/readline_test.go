@@ -201,3 +201,11 @@ func TestPathCompleter_noMatchesReturnsEmpty(t *testing.T) {
 	got := pathCompleter("nonexistent-prefix-")
 	assert.Empty(t, got)
 }
+
+func TestParseMultiSelection(t *testing.T) {
+	options := []string{"us", "eu", "ap"}
+	assert.Equal(t, []string{"us", "ap"}, ParseMultiSelection("1, 3", options))
+	assert.Equal(t, []string{"eu", "us"}, ParseMultiSelection("eu,1", options))
+	assert.Equal(t, []string{"9", "mars"}, ParseMultiSelection("9,mars", options), "unknown entries are returned verbatim for the caller to reject")
+	assert.Equal(t, []string{}, ParseMultiSelection(" , ", options))
+}
@@ -250,11 +250,11 @@ func ensureUpstreamCache(cfg cacheConfig, url string, auth authInfo, logger sdkt
 	key := cacheKey(url)
 	dir, tsFile, lockFile := cacheEntryPaths(cfg.Root, key)
 
-	fl := getOrCreateFlock(lockFile)
-	if err := fl.Lock(); err != nil {
+	unlock, err := lockCacheEntry(lockFile)
+	if err != nil {
 		return "", fmt.Errorf("acquiring upstream cache lock at %s: %w", lockFile, err)
 	}
-	defer func() { _ = fl.Unlock() }()
+	defer unlock()
 
 	// First attempt.
 	if err := runCacheOp(dir, tsFile, url, cfg.TTL, auth, logger, progress); err != nil {
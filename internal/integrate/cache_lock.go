@@ -11,13 +11,16 @@ import (
 // goroutines in the same process each calling flock.New(path).Lock() would
 // obtain separate fds and could BOTH claim the lock simultaneously. Routing
 // every in-process caller through the same *flock.Flock instance for a given
-// path resolves that — flock's own mutex serialises the shared instance.
+// path resolves that. A shared instance that's already locked returns from
+// Lock immediately, though, so a per-path mutex, held alongside the flock,
+// keeps a second goroutine waiting until the first unlocks.
 //
 // Cross-process callers each construct their own map entry in their own
 // address space; the OS flock coordinates them via the kernel.
 var (
 	flocksMu sync.Mutex
 	flocks   = map[string]*flock.Flock{}
+	flockMus = map[string]*sync.Mutex{}
 )
 
 func getOrCreateFlock(path string) *flock.Flock {
@@ -28,5 +31,25 @@ func getOrCreateFlock(path string) *flock.Flock {
 	}
 	f := flock.New(path)
 	flocks[path] = f
+	flockMus[path] = &sync.Mutex{}
 	return f
 }
+
+// lockCacheEntry takes the lock at path for this goroutine, against other
+// goroutines and processes alike, returning the func that releases it.
+func lockCacheEntry(path string) (func(), error) {
+	fl := getOrCreateFlock(path)
+	flocksMu.Lock()
+	mu := flockMus[path]
+	flocksMu.Unlock()
+
+	mu.Lock()
+	if err := fl.Lock(); err != nil {
+		mu.Unlock()
+		return nil, err
+	}
+	return func() {
+		_ = fl.Unlock()
+		mu.Unlock()
+	}, nil
+}
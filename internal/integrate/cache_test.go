@@ -182,6 +182,33 @@ func Test_getOrCreateFlock_returnsSameInstancePerPath(t *testing.T) {
 		"different paths must yield distinct *flock.Flock instances")
 }
 
+func Test_lockCacheEntry_excludesOtherGoroutines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "entry.lock")
+	unlock, err := lockCacheEntry(path)
+	require.NoError(t, err)
+
+	acquired := make(chan struct{})
+	go func() {
+		unlockSecond, err := lockCacheEntry(path)
+		assert.NoError(t, err)
+		close(acquired)
+		if unlockSecond != nil {
+			unlockSecond()
+		}
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("a second goroutine acquired the lock while it was held")
+	case <-time.After(100 * time.Millisecond):
+	}
+	unlock()
+	select {
+	case <-acquired:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the second goroutine never acquired the released lock")
+	}
+}
+
 func Test_populateCache_localFileURL(t *testing.T) {
 	upstreamDir, upstreamHash := testharness.MinimalUpstream(t)
 	cacheDir := filepath.Join(t.TempDir(), "cache-entry")
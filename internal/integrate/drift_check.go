@@ -1,10 +1,18 @@
 package integrate
 
 import (
+	"context"
+	"crypto/sha256"
 	"fmt"
 	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/gobwas/glob"
+	"github.com/rockholla/gitspork/v2/internal/config"
 	"github.com/rockholla/gitspork/v2/internal/sdktypes"
 )
 
@@ -18,38 +26,220 @@ type DriftCheckRequest struct {
 	UpstreamSubpath    string
 	UpstreamToken      string
 	UpstreamCommit     string
-	CacheTTL           time.Duration
-	NoCache            bool
+	// PrevUpstreamCommit, when set, previews updating from it to
+	// UpstreamCommit: the upstream's deletions and renames between the two are
+	// applied first, as integrate does.
+	PrevUpstreamCommit string
+	// UpstreamVersion is the version templates render; empty means the one
+	// recorded in the downstream state.
+	UpstreamVersion string
+	CacheTTL        time.Duration
+	NoCache         bool
 	// Progress, when non-nil, is threaded into go-git as the Progress writer
 	// for upstream mirror cache clone/fetch operations during drift-check
 	// re-integration.
 	Progress io.Writer
+	// DownstreamMetadataPath is the caller's repo, read for the .Downstream
+	// template metadata: the scratch clone's origin points at the caller's
+	// local path and its HEAD at the drift-check branch.
+	DownstreamMetadataPath string
+	// Migrations is what happens to migrations the downstream state doesn't
+	// record as complete, one of the sdktypes.DriftMigrations* values;
+	// empty means sdktypes.DriftMigrationsSkip.
+	Migrations string
+	// Inputs supplies templated input values. The re-integration is
+	// non-interactive, so an input without a supplied, env, cached or default
+	// value fails it with a *sdktypes.MissingInputsError.
+	Inputs map[string]any
+	// Context, if non-nil, cancels a migration the re-integration runs.
+	Context context.Context
+}
+
+// DriftCheckResult is what IntegrateForDriftCheck reports besides the
+// re-integrated downstream itself.
+type DriftCheckResult struct {
+	// MigrationFiles maps each file a migration changed, relative to the
+	// downstream root with forward slashes, to that migration's ID.
+	MigrationFiles map[string]string
+	// sources are in integration order, so the last one matching a file is
+	// the entry that wrote it.
+	sources []driftFileSource
+}
+
+// driftFileSource attributes the downstream files match accepts to an
+// ownership category, one of the sdktypes.Ownership* values, and the
+// .gitspork.yml entry within it.
+type driftFileSource struct {
+	ownership string
+	entry     string
+	match     func(path string) bool
+}
+
+// FileSource returns the ownership category, one of the sdktypes.Ownership*
+// values, and the .gitspork.yml entry that manage file, relative to the
+// downstream root with forward slashes. Both are empty when no entry does.
+func (r *DriftCheckResult) FileSource(file string) (string, string) {
+	for i := len(r.sources) - 1; i >= 0; i-- {
+		if r.sources[i].match(file) {
+			return r.sources[i].ownership, r.sources[i].entry
+		}
+	}
+	return "", ""
+}
+
+// driftFileSources lists an upstream's .gitspork.yml entries in the order
+// integrate applies them, each matching the downstream paths it writes.
+func driftFileSources(gitSporkConfig *config.GitSporkConfig) []driftFileSource {
+	var sources []driftFileSource
+	for _, owned := range []struct {
+		ownership string
+		entries   []config.OwnedEntry
+	}{
+		{sdktypes.OwnershipUpstreamOwned, gitSporkConfig.UpstreamOwned},
+		{sdktypes.OwnershipDownstreamOwned, gitSporkConfig.DownstreamOwned},
+	} {
+		for _, entry := range owned.entries {
+			g, err := glob.Compile(entry.SourcePattern())
+			if err != nil {
+				continue
+			}
+			entryDescription := entry.Pattern
+			if entry.IsRename() {
+				entryDescription = fmt.Sprintf("{from: %s, to: %s}", entry.From, entry.To)
+			}
+			sources = append(sources, driftFileSource{
+				ownership: owned.ownership,
+				entry:     entryDescription,
+				match: func(file string) bool {
+					return g.Match(entry.ResolveSource(filepath.FromSlash(file)))
+				},
+			})
+		}
+	}
+	for _, shared := range []struct {
+		ownership string
+		patterns  []string
+	}{
+		{sdktypes.OwnershipMerged, gitSporkConfig.SharedOwnership.Merged},
+		{sdktypes.OwnershipStructuredPreferUpstream, gitSporkConfig.SharedOwnership.Structured.PreferUpstream},
+		{sdktypes.OwnershipStructuredPreferDownstream, gitSporkConfig.SharedOwnership.Structured.PreferDownstream},
+	} {
+		for _, pattern := range shared.patterns {
+			g, err := glob.Compile(pattern)
+			if err != nil {
+				continue
+			}
+			sources = append(sources, driftFileSource{
+				ownership: shared.ownership,
+				entry:     pattern,
+				match:     func(file string) bool { return g.Match(filepath.FromSlash(file)) },
+			})
+		}
+	}
+	for _, templated := range gitSporkConfig.Templated {
+		target := path.Clean(filepath.ToSlash(templated.Target()))
+		source := driftFileSource{
+			ownership: sdktypes.OwnershipTemplated,
+			entry:     fmt.Sprintf("{template: %s, destination: %s}", templated.Template, templated.Destination),
+			match:     func(file string) bool { return file == target },
+		}
+		if templated.IsDir() {
+			source.entry = fmt.Sprintf("{template_dir: %s, destination_dir: %s}", templated.TemplateDir, templated.DestinationDir)
+			source.match = func(file string) bool { return target == "." || strings.HasPrefix(file, target+"/") }
+		}
+		sources = append(sources, source)
+	}
+	return sources
 }
 
 // IntegrateForDriftCheck runs a single-upstream integrate pinned to a specific
 // commit hash and skips the state write. It's used by internal/drift to
 // reconstruct the downstream at each recorded upstream's last-integrated
 // commit and then diff against HEAD.
-func IntegrateForDriftCheck(req *DriftCheckRequest) error {
+func IntegrateForDriftCheck(req *DriftCheckRequest) (*DriftCheckResult, error) {
+	result := &DriftCheckResult{MigrationFiles: map[string]string{}}
 	if req.Logger == nil {
 		req.Logger = sdktypes.NoopLogger()
 	}
+	if req.Migrations == "" {
+		req.Migrations = sdktypes.DriftMigrationsSkip
+	}
 	upstream := sdktypes.UpstreamSpec{
 		URL:     req.UpstreamURL,
 		Subpath: req.UpstreamSubpath,
 		Token:   req.UpstreamToken,
 	}
 	internalReq := &internalRequest{
-		Logger:             req.Logger,
-		DownstreamRepoPath: req.DownstreamRepoPath,
-		forDriftCheck:      true,
-		upstreamCommit:     req.UpstreamCommit,
-		cacheTTL:           req.CacheTTL,
-		noCache:            req.NoCache,
-		progress:           req.Progress,
+		Logger:                 req.Logger,
+		DownstreamRepoPath:     req.DownstreamRepoPath,
+		Inputs:                 req.Inputs,
+		NonInteractive:         true,
+		forDriftCheck:          true,
+		upstreamCommit:         req.UpstreamCommit,
+		prevUpstreamCommitHash: req.PrevUpstreamCommit,
+		upstreamVersion:        req.UpstreamVersion,
+		cacheTTL:               req.CacheTTL,
+		noCache:                req.NoCache,
+		progress:               req.Progress,
+		downstreamMetadataPath: req.DownstreamMetadataPath,
+		driftMigrations:        req.Migrations,
+		driftResult:            result,
+		ctx:                    req.Context,
 	}
 	if _, err := integrateOneInternal(internalReq, upstream); err != nil {
-		return fmt.Errorf("drift-check re-integration failed: %w", err)
+		return result, fmt.Errorf("drift-check re-integration failed: %w", err)
 	}
-	return nil
+	return result, nil
+}
+
+// LoadDriftIgnore reads the downstream's .gitspork/drift-ignore.yml, which
+// ignores nothing when it doesn't exist.
+func LoadDriftIgnore(downstreamRepoPath string) (*config.DriftIgnore, error) {
+	return config.ParseDriftIgnore(filepath.Join(downstreamRepoPath, gitSporkMetaDirName, config.DriftIgnoreFileName))
+}
+
+// ListWorktreeFiles returns a map of relative path -> hex hash of content and
+// executable bit for all non-.git files under dir. Used to detect which files
+// an integrate pass, or a migration within it, touched.
+func ListWorktreeFiles(dir string) (map[string]string, error) {
+	result := map[string]string{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		// Symlinks: hash the target string (mirrors git's mode-120000 blob
+		// semantics). filepath.Walk lstats without following, so a symlink to
+		// a directory would otherwise fall through to os.ReadFile below and
+		// error with EISDIR; a broken symlink would error with ENOENT.
+		if info.Mode()&os.ModeSymlink != 0 {
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			result[rel] = fmt.Sprintf("%x", sha256.Sum256([]byte(target)))
+			return nil
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		// Fold in the executable bit, the only mode git tracks for regular
+		// files, so a mode-only change shows up too.
+		if info.Mode()&0111 != 0 {
+			b = append(b, 'x')
+		}
+		result[rel] = fmt.Sprintf("%x", sha256.Sum256(b))
+		return nil
+	})
+	return result, err
 }
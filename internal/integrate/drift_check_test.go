@@ -0,0 +1,168 @@
+package integrate
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/rockholla/gitspork/v2/internal/config"
+	"github.com/rockholla/gitspork/v2/internal/sdktypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ListWorktreeFiles(t *testing.T) {
+	t.Run("symlink pointing at a directory does not crash the walker", func(t *testing.T) {
+		dir, err := os.MkdirTemp("", "gitspork-test-listwt")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "file.txt"), []byte("hello"), 0644))
+		require.NoError(t, os.Mkdir(filepath.Join(dir, "realdir"), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "realdir", "inner.txt"), []byte("inner"), 0644))
+		require.NoError(t, os.Symlink("realdir", filepath.Join(dir, "link-to-dir")))
+
+		got, err := ListWorktreeFiles(dir)
+		require.NoError(t, err, "walker must not choke on a symlink pointing at a directory")
+		assert.Contains(t, got, "file.txt")
+		assert.Contains(t, got, filepath.Join("realdir", "inner.txt"))
+		assert.Contains(t, got, "link-to-dir",
+			"symlink itself should be recorded as an entry, not silently skipped")
+	})
+
+	t.Run("symlink to a regular file hashes the link target, not the target's contents", func(t *testing.T) {
+		dir, err := os.MkdirTemp("", "gitspork-test-listwt")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "real.txt"), []byte("real content"), 0644))
+		require.NoError(t, os.Symlink("real.txt", filepath.Join(dir, "link.txt")))
+
+		got, err := ListWorktreeFiles(dir)
+		require.NoError(t, err)
+		assert.NotEqual(t, got["real.txt"], got["link.txt"],
+			"symlink hash should be derived from the link target string (git mode-120000 semantics), "+
+				"not from the file the link resolves to")
+	})
+
+	t.Run("broken symlink does not crash the walker", func(t *testing.T) {
+		dir, err := os.MkdirTemp("", "gitspork-test-listwt")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		require.NoError(t, os.Symlink("does-not-exist", filepath.Join(dir, "broken")))
+
+		got, err := ListWorktreeFiles(dir)
+		require.NoError(t, err, "walker must tolerate broken symlinks — git allows them and we mustn't crash")
+		assert.Contains(t, got, "broken")
+	})
+}
+
+func Test_runMigrationForDriftCheck(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses POSIX sh")
+	}
+	instructions := &config.GitSporkConfigMigrationInstructions{
+		ID: "migrations/0001.yml:post_integrate",
+		Steps: []config.GitSporkConfigMigrationStep{
+			{Move: &config.GitSporkConfigMigrationMove{From: "old.txt", To: "new.txt"}},
+			{Shell: `env | grep -E '^(HOME|TMPDIR|GITSPORK_SANDBOX|HTTPS_PROXY|SECRET_TOKEN|KEEP_ME)=' | sort > env.txt`},
+		},
+		Env: map[string]string{"KEEP_ME": "yes"},
+	}
+	setup := func(t *testing.T) string {
+		t.Helper()
+		downstreamDir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(downstreamDir, "old.txt"), []byte("content\n"), 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(downstreamDir, "untouched.txt"), []byte("same\n"), 0644))
+		return downstreamDir
+	}
+
+	t.Run("simulate applies declarative steps and only logs commands", func(t *testing.T) {
+		downstreamDir := setup(t)
+		logger := &recordingLogger{}
+		opts := migrationOptions{forDriftCheck: true, driftMigrations: sdktypes.DriftMigrationsSimulate, producedFiles: map[string]string{}}
+		require.NoError(t, runMigrationForDriftCheck(instructions, t.TempDir(), downstreamDir, opts, logger))
+
+		assert.FileExists(t, filepath.Join(downstreamDir, "new.txt"))
+		assert.NoFileExists(t, filepath.Join(downstreamDir, "env.txt"))
+		assert.Contains(t, strings.Join(logger.lines, "\n"), "(simulated) not running")
+		assert.Equal(t, map[string]string{
+			"old.txt": instructions.ID,
+			"new.txt": instructions.ID,
+		}, opts.producedFiles)
+	})
+
+	t.Run("sandbox runs commands with a restricted environment", func(t *testing.T) {
+		t.Setenv("SECRET_TOKEN", "do-not-leak")
+		downstreamDir := setup(t)
+		opts := migrationOptions{forDriftCheck: true, driftMigrations: sdktypes.DriftMigrationsSandbox, producedFiles: map[string]string{}}
+		require.NoError(t, runMigrationForDriftCheck(instructions, t.TempDir(), downstreamDir, opts, sdktypes.NoopLogger()))
+
+		env := readFileString(t, filepath.Join(downstreamDir, "env.txt"))
+		assert.NotContains(t, env, "SECRET_TOKEN")
+		assert.Contains(t, env, "KEEP_ME=yes\n")
+		assert.Contains(t, env, "GITSPORK_SANDBOX=true\n")
+		assert.Contains(t, env, "HTTPS_PROXY="+migrationSandboxProxy+"\n")
+		assert.NotContains(t, env, "HOME="+os.Getenv("HOME")+"\n")
+		assert.Equal(t, instructions.ID, opts.producedFiles["env.txt"])
+		assert.NotContains(t, opts.producedFiles, "untouched.txt")
+	})
+
+	t.Run("sandbox refuses a working directory outside the downstream", func(t *testing.T) {
+		downstreamDir := setup(t)
+		require.NoError(t, os.Symlink(t.TempDir(), filepath.Join(downstreamDir, "escape")))
+		escaping := &config.GitSporkConfigMigrationInstructions{ID: "m:post_integrate", WorkingDir: "escape", Steps: []config.GitSporkConfigMigrationStep{{Shell: "true"}}}
+		opts := migrationOptions{forDriftCheck: true, driftMigrations: sdktypes.DriftMigrationsSandbox, producedFiles: map[string]string{}}
+		err := runMigrationForDriftCheck(escaping, t.TempDir(), downstreamDir, opts, sdktypes.NoopLogger())
+		require.ErrorContains(t, err, "resolves outside the downstream")
+	})
+}
+
+func Test_DriftCheckResult_FileSource(t *testing.T) {
+	gitSporkConfig := &config.GitSporkConfig{
+		UpstreamOwned: []config.OwnedEntry{
+			{Pattern: "ci/**"},
+			{From: "configs/**", To: ".configs/**"},
+		},
+		DownstreamOwned: []config.OwnedEntry{{Pattern: "README.md"}},
+		SharedOwnership: config.GitSporkConfigSharedOwnership{
+			Merged: []string{"Makefile"},
+			Structured: config.GitSporkConfigSharedOwnershipStructured{
+				PreferUpstream:   []string{"*.json"},
+				PreferDownstream: []string{"values.yml"},
+			},
+		},
+		Templated: []config.GitSporkConfigTemplated{
+			{Template: "templates/app.yml.tmpl", Destination: "ci/app.yml"},
+			{TemplateDir: "templates/service", DestinationDir: "service"},
+		},
+	}
+	result := &DriftCheckResult{sources: driftFileSources(gitSporkConfig)}
+
+	tests := []struct {
+		path      string
+		ownership string
+		entry     string
+	}{
+		{path: "ci/build.yml", ownership: sdktypes.OwnershipUpstreamOwned, entry: "ci/**"},
+		{path: ".configs/app/db.yml", ownership: sdktypes.OwnershipUpstreamOwned, entry: "{from: configs/**, to: .configs/**}"},
+		{path: "configs/app/db.yml"},
+		{path: "README.md", ownership: sdktypes.OwnershipDownstreamOwned, entry: "README.md"},
+		{path: "Makefile", ownership: sdktypes.OwnershipMerged, entry: "Makefile"},
+		{path: "package.json", ownership: sdktypes.OwnershipStructuredPreferUpstream, entry: "*.json"},
+		{path: "values.yml", ownership: sdktypes.OwnershipStructuredPreferDownstream, entry: "values.yml"},
+		{path: "ci/app.yml", ownership: sdktypes.OwnershipTemplated, entry: "{template: templates/app.yml.tmpl, destination: ci/app.yml}"},
+		{path: "service/main.go", ownership: sdktypes.OwnershipTemplated, entry: "{template_dir: templates/service, destination_dir: service}"},
+		{path: "unmanaged.txt"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			ownership, entry := result.FileSource(tt.path)
+			assert.Equal(t, tt.ownership, ownership)
+			assert.Equal(t, tt.entry, entry)
+		})
+	}
+}
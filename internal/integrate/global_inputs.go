@@ -0,0 +1,146 @@
+package integrate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/goccy/go-yaml"
+	"github.com/rockholla/gitspork/v2/internal/config"
+	"github.com/rockholla/gitspork/v2/internal/sdktypes"
+)
+
+const globalInputsFileName = "inputs.yml"
+
+// globalInputs is the downstream's shared namespace for templated inputs
+// declared `global`, kept in <downstream>/.gitspork/inputs.yml as a map of
+// input name to value. Unlike the templated-inputs cache it's meant to be
+// edited by hand, so it's YAML, keeps its key order, and is only rewritten
+// when a value actually changes.
+type globalInputs struct {
+	values yaml.MapSlice
+	// answered holds the names given a value during this run, so a forced
+	// re-prompt asks once per run rather than once per template.
+	answered map[string]bool
+	changed  bool
+}
+
+// loadGlobalInputs reads <downstream>/.gitspork/inputs.yml. A missing file is
+// an empty namespace.
+func loadGlobalInputs(downstreamPath string) (*globalInputs, error) {
+	g := &globalInputs{answered: map[string]bool{}}
+	path := filepath.Join(downstreamPath, gitSporkMetaDirName, globalInputsFileName)
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return g, nil
+		}
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	var raw any
+	if err := yaml.UnmarshalWithOptions(b, &raw, yaml.UseOrderedMap()); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	switch val := raw.(type) {
+	case nil:
+	case yaml.MapSlice:
+		g.values = val
+	default:
+		return nil, fmt.Errorf("parsing %s: expects a map of input name to value", path)
+	}
+	return g, nil
+}
+
+// get returns the value recorded for name.
+func (g *globalInputs) get(name string) (any, bool) {
+	for _, item := range g.values {
+		if fmt.Sprint(item.Key) == name {
+			return item.Value, true
+		}
+	}
+	return nil, false
+}
+
+// set records value for name, appending names not yet in the file.
+func (g *globalInputs) set(name string, value any) {
+	g.answered[name] = true
+	for idx, item := range g.values {
+		if fmt.Sprint(item.Key) != name {
+			continue
+		}
+		// Compare as text: values read back from YAML are differently typed
+		// (uint64, []any) than the resolved ones (int, []string).
+		if fmt.Sprint(item.Value) != fmt.Sprint(value) {
+			g.values[idx].Value = value
+			g.changed = true
+		}
+		return
+	}
+	g.values = append(g.values, yaml.MapItem{Key: name, Value: value})
+	g.changed = true
+}
+
+// save writes the namespace back if any value changed during the run.
+func (g *globalInputs) save(downstreamPath string) error {
+	if !g.changed {
+		return nil
+	}
+	dir := filepath.Join(downstreamPath, gitSporkMetaDirName)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("ensuring %s: %w", dir, err)
+	}
+	b, err := yaml.Marshal(g.values)
+	if err != nil {
+		return fmt.Errorf("marshaling global inputs: %w", err)
+	}
+	return os.WriteFile(filepath.Join(dir, globalInputsFileName), b, 0644)
+}
+
+// knownGlobalInputValue determines a global input's value without prompting,
+// in precedence order: a supplied value or GITSPORK_INPUT_<NAME>, a value
+// already given this run, inputs.yml (unless forceRePrompt), then a value
+// cached for this destination before the input became global, and in a
+// non-interactive run its default. ok is false when the user has to be asked.
+func (i *IntegratorTemplated) knownGlobalInputValue(input config.GitSporkConfigTemplatedInput, template string, globals *globalInputs, cached any, isCached bool, forceRePrompt bool) (value any, ok bool, err error) {
+	if raw, source, supplied := i.suppliedInputValue(input.Name); supplied {
+		value, err := resolveTemplatedInputValue(input, raw)
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid value from %s under template %s: %v", source, template, err)
+		}
+		return value, true, nil
+	}
+	if raw, found := globals.get(input.Name); found && (!forceRePrompt || globals.answered[input.Name]) {
+		value, err := resolveTemplatedInputValue(input, raw)
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid value for global input %s in %s/%s under template %s: %v", input.Name, gitSporkMetaDirName, globalInputsFileName, template, err)
+		}
+		return value, true, nil
+	}
+	if !forceRePrompt && isCached && !templatedInputIsEmpty(cached) {
+		if value, err := resolveTemplatedInputValue(input, cached); err == nil {
+			return value, true, nil
+		}
+	}
+	return i.nonInteractiveDefault(input, template)
+}
+
+// resolveGlobalInput determines a global input's value, prompting if it isn't
+// known, and records it in globals for every later template and upstream.
+func (i *IntegratorTemplated) resolveGlobalInput(templatedInstruction config.GitSporkConfigTemplated, input config.GitSporkConfigTemplatedInput, globals *globalInputs, cached any, isCached bool, forceRePrompt bool, logger sdktypes.Logger) (any, error) {
+	value, ok, err := i.knownGlobalInputValue(input, templatedInstruction.Source(), globals, cached, isCached, forceRePrompt)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		if input.Prompt == "" {
+			return nil, fmt.Errorf("no value for global input %s under template %s, add it to %s/%s or set %s",
+				input.Name, templatedInstruction.Source(), gitSporkMetaDirName, globalInputsFileName, templatedInputEnvVar(input.Name))
+		}
+		value, err = i.promptTemplatedInput(templatedInstruction, input, logger)
+		if err != nil {
+			return nil, err
+		}
+	}
+	globals.set(input.Name, value)
+	return value, nil
+}
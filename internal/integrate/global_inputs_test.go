@@ -0,0 +1,106 @@
+package integrate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rockholla/gitspork/v2/internal/config"
+	"github.com/rockholla/gitspork/v2/internal/sdktypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIntegratorTemplated_globalInputs(t *testing.T) {
+	team := config.GitSporkConfigTemplatedInput{Name: "team", Prompt: "team?", Global: true}
+	upstreamA, downstreamDir := setupTemplatedDirFixture(t, map[string]string{
+		"owners.tmpl": "owner={{ .Inputs.team }}",
+		"readme.tmpl": "team {{ .Inputs.team }}, {{ .Inputs.name }}",
+	})
+	upstreamB, _ := setupTemplatedDirFixture(t, map[string]string{"ci.tmpl": "notify={{ .Inputs.team }}"})
+	instructionsA := []config.GitSporkConfigTemplated{
+		{Template: "owners.tmpl", Destination: "OWNERS", Inputs: []config.GitSporkConfigTemplatedInput{team}},
+		{Template: "readme.tmpl", Destination: "README.md", Inputs: []config.GitSporkConfigTemplatedInput{team, {Name: "name", Prompt: "name?"}}},
+	}
+	instructionsB := []config.GitSporkConfigTemplated{
+		{Template: "ci.tmpl", Destination: "ci.txt", Inputs: []config.GitSporkConfigTemplatedInput{{Name: "team", Prompt: "owning team?", Global: true}}},
+	}
+	globalsPath := filepath.Join(downstreamDir, gitSporkMetaDirName, globalInputsFileName)
+	integrate := func(t *testing.T, integrator *IntegratorTemplated, upstreamDir string, instructions []config.GitSporkConfigTemplated, forceRePrompt bool) {
+		t.Helper()
+		require.NoError(t, integrator.Integrate(instructions, upstreamDir, downstreamDir, forceRePrompt, sdktypes.NoopLogger()))
+	}
+
+	t.Run("asked once across templates and upstreams", func(t *testing.T) {
+		prompter := newSequencePrompter("payments", "billing")
+		integrate(t, &IntegratorTemplated{Prompter: prompter}, upstreamA, instructionsA, false)
+		cache, err := loadTemplatedInputs(downstreamDir)
+		require.NoError(t, err)
+		assert.NotContains(t, cache["README.md"], "team", "global values aren't cached per destination")
+		assert.Equal(t, "billing", cache["README.md"]["name"])
+
+		integrate(t, &IntegratorTemplated{Prompter: prompter}, upstreamB, instructionsB, false)
+		require.Len(t, prompter.requests, 2)
+		assert.Equal(t, "team?", prompter.requests[0].Prompt)
+		assert.Equal(t, "name?", prompter.requests[1].Prompt)
+
+		assert.Equal(t, "owner=payments", readFileString(t, filepath.Join(downstreamDir, "OWNERS")))
+		assert.Equal(t, "team payments, billing", readFileString(t, filepath.Join(downstreamDir, "README.md")))
+		assert.Equal(t, "notify=payments", readFileString(t, filepath.Join(downstreamDir, "ci.txt")))
+		assert.Equal(t, "team: payments\n", readFileString(t, globalsPath))
+	})
+
+	t.Run("hand edits apply everywhere", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(globalsPath, []byte("# owning team\nteam: ledger\nunused: x\n"), 0644))
+		integrate(t, &IntegratorTemplated{Prompter: newSequencePrompter("SHOULD-NEVER-BE-USED")}, upstreamA, instructionsA, false)
+		assert.Equal(t, "owner=ledger", readFileString(t, filepath.Join(downstreamDir, "OWNERS")))
+		assert.Equal(t, "# owning team\nteam: ledger\nunused: x\n", readFileString(t, globalsPath), "unchanged values don't rewrite the file")
+	})
+
+	t.Run("supplied values win and are recorded", func(t *testing.T) {
+		integrate(t, &IntegratorTemplated{Inputs: map[string]any{"team": "treasury"}}, upstreamB, instructionsB, false)
+		assert.Equal(t, "notify=treasury", readFileString(t, filepath.Join(downstreamDir, "ci.txt")))
+		assert.Equal(t, "team: treasury\nunused: x\n", readFileString(t, globalsPath))
+	})
+
+	t.Run("forced re-prompt asks once per run", func(t *testing.T) {
+		prompter := newSequencePrompter("risk", "billing")
+		integrate(t, &IntegratorTemplated{Prompter: prompter}, upstreamA, instructionsA, true)
+		assert.Len(t, prompter.requests, 2)
+		assert.Equal(t, "team: risk\nunused: x\n", readFileString(t, globalsPath))
+	})
+
+	t.Run("invalid value names inputs.yml", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(globalsPath, []byte("team: [a, b]\n"), 0644))
+		err := (&IntegratorTemplated{}).Integrate(instructionsB, upstreamB, downstreamDir, false, sdktypes.NoopLogger())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid value for global input team in .gitspork/inputs.yml")
+	})
+}
+
+func TestIntegratorTemplated_globalInputs_promotesCachedValue(t *testing.T) {
+	upstreamDir, downstreamDir := setupTemplatedFixture(t, `{{ .Inputs.team }}`, "")
+	require.NoError(t, saveTemplatedInputs(downstreamDir, map[string]map[string]any{"rendered.txt": {"team": "cached"}}))
+	instructions := []config.GitSporkConfigTemplated{{
+		Template:    "template.txt",
+		Destination: "rendered.txt",
+		Inputs:      []config.GitSporkConfigTemplatedInput{{Name: "team", Prompt: "team?", Global: true}},
+	}}
+	require.NoError(t, (&IntegratorTemplated{NonInteractive: true}).Integrate(instructions, upstreamDir, downstreamDir, false, sdktypes.NoopLogger()))
+	assert.Equal(t, "team: cached\n", readFileString(t, filepath.Join(downstreamDir, gitSporkMetaDirName, globalInputsFileName)))
+}
+
+func TestIntegratorTemplated_globalInputs_nonInteractiveListsOnce(t *testing.T) {
+	upstreamDir, downstreamDir := setupTemplatedFixture(t, `{{ .Inputs.team }}`, "")
+	team := config.GitSporkConfigTemplatedInput{Name: "team", Prompt: "team?", Global: true}
+	instructions := []config.GitSporkConfigTemplated{
+		{Template: "template.txt", Destination: "a.txt", Inputs: []config.GitSporkConfigTemplatedInput{team}},
+		{Template: "template.txt", Destination: "b.txt", Inputs: []config.GitSporkConfigTemplatedInput{team}},
+	}
+	err := (&IntegratorTemplated{NonInteractive: true}).Integrate(instructions, upstreamDir, downstreamDir, false, sdktypes.NoopLogger())
+	var missingErr *sdktypes.MissingInputsError
+	require.ErrorAs(t, err, &missingErr)
+	assert.Equal(t, []sdktypes.MissingInput{
+		{Template: "template.txt", Destination: "a.txt", Name: "team", Prompt: "team?", EnvVar: "GITSPORK_INPUT_TEAM"},
+	}, missingErr.Inputs)
+}
@@ -1,6 +1,7 @@
 package integrate
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -9,6 +10,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
 	"time"
 
@@ -27,12 +29,10 @@ import (
 )
 
 const (
-	structuredDataTypeYAML   string = "yaml"
-	structuredDataTypeJSON   string = "json"
-	preIntegrateMigrationID  string = "pre_integrate"
-	postIntegrateMigrationID string = "post_integrate"
-	gitSporkMetaDirName      string = ".gitspork"
-	downstreamStateFileName  string = "downstream-state.json"
+	structuredDataTypeYAML  string = "yaml"
+	structuredDataTypeJSON  string = "json"
+	gitSporkMetaDirName     string = config.GitSporkDirName
+	downstreamStateFileName string = "downstream-state.json"
 )
 
 var (
@@ -61,9 +61,13 @@ type internalRequest struct {
 	Logger                 sdktypes.Logger
 	DownstreamRepoPath     string
 	ForceRePrompt          bool
-	forDriftCheck          bool   // true = skip state write, skip delta
+	Inputs                 map[string]any
+	NonInteractive         bool
+	Prompter               sdktypes.Prompter
+	forDriftCheck          bool   // true = skip state write, skip delta unless prevUpstreamCommitHash is set
 	upstreamCommit         string // when forDriftCheck: the pinned commit
-	prevUpstreamCommitHash string // set by integrateOne between calls
+	prevUpstreamCommitHash string // set by integrateOne between calls, or by IntegrateForDriftCheck to preview an update
+	upstreamVersion        string // when forDriftCheck: the version templates render, empty for the recorded one
 
 	// Cache controls, propagated from IntegrateOptions / CheckDriftOptions.
 	cacheTTL time.Duration
@@ -71,6 +75,22 @@ type internalRequest struct {
 	// progress, when non-nil, is passed through to go-git as the Progress
 	// writer for upstream mirror cache clone/fetch operations.
 	progress io.Writer
+	// downstreamMetadataPath, when set, is the repo the .Downstream template
+	// metadata is read from instead of DownstreamRepoPath.
+	downstreamMetadataPath string
+	// migrationsDryRun only logs the pending migrations: no delta, integration
+	// or state write happens.
+	migrationsDryRun bool
+	// ctx, when non-nil, cancels running migrations.
+	ctx context.Context
+	// allowMigrations / noMigrations carry --allow-migrations and
+	// --no-migrations through to migrationOptions.
+	allowMigrations bool
+	noMigrations    bool
+	// driftMigrations is what a drift check does with pending migrations, and
+	// driftResult collects what IntegrateForDriftCheck reports.
+	driftMigrations string
+	driftResult     *DriftCheckResult
 }
 
 // Integrator is implemented by the ownership integrators that process a
@@ -157,6 +177,9 @@ func Integrate(opts *sdktypes.IntegrateOptions) (*sdktypes.IntegrateResult, erro
 	if len(opts.Upstreams) == 0 {
 		return result, fmt.Errorf("no upstream specified: set Upstreams on IntegrateOptions")
 	}
+	if opts.AllowMigrations && opts.NoMigrations {
+		return result, fmt.Errorf("only one of AllowMigrations or NoMigrations may be set")
+	}
 
 	for _, upstream := range opts.Upstreams {
 		integrated, err := integrateOne(opts, upstream)
@@ -175,9 +198,16 @@ func integrateOne(opts *sdktypes.IntegrateOptions, upstream sdktypes.UpstreamSpe
 		Logger:             opts.Logger,
 		DownstreamRepoPath: opts.DownstreamRepoPath,
 		ForceRePrompt:      opts.ForceRePrompt,
+		Inputs:             opts.Inputs,
+		NonInteractive:     opts.NonInteractive,
+		Prompter:           opts.Prompter,
 		cacheTTL:           opts.CacheTTL,
 		noCache:            opts.NoCache,
 		progress:           opts.Progress,
+		migrationsDryRun:   opts.MigrationsDryRun,
+		ctx:                opts.Context,
+		allowMigrations:    opts.AllowMigrations,
+		noMigrations:       opts.NoMigrations,
 		// forDriftCheck / upstreamCommit / prevUpstreamCommitHash stay zero-value:
 		// public Integrate never runs drift-check semantics.
 	}
@@ -200,20 +230,22 @@ func integrateOneInternal(req *internalRequest, upstream sdktypes.UpstreamSpec)
 		return sdktypes.IntegratedUpstream{}, err
 	}
 
-	prevHash := ""
-	if !req.forDriftCheck {
-		existingState, err := LoadDownstreamState(req.DownstreamRepoPath)
-		if err != nil {
-			return sdktypes.IntegratedUpstream{}, fmt.Errorf("error loading downstream state for delta check: %v", err)
-		}
-		key := NormalizeUpstreamURL(upstream.URL, upstream.Subpath)
-		for _, u := range existingState.Upstreams {
-			if NormalizeUpstreamURL(u.URL, u.Subpath) == key {
-				prevHash = u.CommitHash
-				break
-			}
+	existingState, err := LoadDownstreamState(req.DownstreamRepoPath)
+	if err != nil {
+		return sdktypes.IntegratedUpstream{}, fmt.Errorf("error loading downstream state for delta check: %v", err)
+	}
+	var prevState sdktypes.UpstreamState
+	key := NormalizeUpstreamURL(upstream.URL, upstream.Subpath)
+	for _, u := range existingState.Upstreams {
+		if NormalizeUpstreamURL(u.URL, u.Subpath) == key {
+			prevState = u
+			break
 		}
 	}
+	prevHash := req.prevUpstreamCommitHash
+	if !req.forDriftCheck {
+		prevHash = prevState.CommitHash
+	}
 
 	cloneDir, err := os.MkdirTemp("", config.GitSpork)
 	if err != nil {
@@ -225,12 +257,22 @@ func integrateOneInternal(req *internalRequest, upstream sdktypes.UpstreamSpec)
 		Logger:                 req.Logger,
 		DownstreamRepoPath:     req.DownstreamRepoPath,
 		ForceRePrompt:          req.ForceRePrompt,
+		Inputs:                 req.Inputs,
+		NonInteractive:         req.NonInteractive,
+		Prompter:               req.Prompter,
 		forDriftCheck:          req.forDriftCheck,
 		upstreamCommit:         req.upstreamCommit,
 		prevUpstreamCommitHash: prevHash,
 		cacheTTL:               req.cacheTTL,
 		noCache:                req.noCache,
 		progress:               req.progress,
+		downstreamMetadataPath: req.downstreamMetadataPath,
+		migrationsDryRun:       req.migrationsDryRun,
+		ctx:                    req.ctx,
+		allowMigrations:        req.allowMigrations,
+		noMigrations:           req.noMigrations,
+		driftMigrations:        req.driftMigrations,
+		driftResult:            req.driftResult,
 	}
 
 	originalUpstreamURL := upstream.URL
@@ -247,7 +289,7 @@ func integrateOneInternal(req *internalRequest, upstream sdktypes.UpstreamSpec)
 		return sdktypes.IntegratedUpstream{}, err
 	}
 
-	if !req.forDriftCheck && prevHash != "" {
+	if !req.migrationsDryRun && prevHash != "" {
 		upstreamRepo, err := git.PlainOpen(cloneDir)
 		if err != nil {
 			return sdktypes.IntegratedUpstream{}, fmt.Errorf("error opening upstream clone for delta computation: %v", err)
@@ -261,19 +303,70 @@ func integrateOneInternal(req *internalRequest, upstream sdktypes.UpstreamSpec)
 		}
 	}
 
-	if err := integrate(gitSporkConfig, upstreamRootPath, req.DownstreamRepoPath, req.ForceRePrompt, req.forDriftCheck, req.Logger); err != nil {
+	// Drift-check reproduces the recorded integration, so it renders the
+	// version and time from state rather than from this run.
+	version, integratedAt := upstream.Version, time.Now()
+	if req.forDriftCheck {
+		version = cmp.Or(req.upstreamVersion, prevState.Version)
+	}
+	if !prevState.IntegratedAt.IsZero() && (req.forDriftCheck || prevState.CommitHash == commitHash) {
+		integratedAt = prevState.IntegratedAt
+	} else if req.forDriftCheck {
+		// State written before integrated_at was recorded has no time to
+		// reproduce: the recorded commit's time stands in for it, where
+		// today's date would drift every day.
+		integratedAt, err = upstreamCommitTime(cloneDir, cmp.Or(prevState.CommitHash, commitHash))
+		if err != nil {
+			return sdktypes.IntegratedUpstream{}, fmt.Errorf("error determining the integration time to render: %v", err)
+		}
+	}
+	downstreamMetadataPath := req.DownstreamRepoPath
+	if req.downstreamMetadataPath != "" {
+		downstreamMetadataPath = req.downstreamMetadataPath
+	}
+	templatedIntegrator := &IntegratorTemplated{
+		Inputs:         req.Inputs,
+		NonInteractive: req.NonInteractive,
+		Prompter:       req.Prompter,
+		Metadata: TemplatedMetadata{
+			Gitspork:   newTemplatedGitsporkData(integratedAt),
+			Downstream: downstreamTemplatedData(downstreamMetadataPath),
+			Upstream:   newTemplatedUpstreamData(originalUpstreamURL, version, upstream.Subpath, commitHash),
+		},
+	}
+	migrations := migrationOptions{
+		dryRun:          req.migrationsDryRun,
+		ctx:             req.ctx,
+		upstreamURL:     originalUpstreamURL,
+		prevCommit:      prevHash,
+		newCommit:       commitHash,
+		allowMigrations: req.allowMigrations,
+		noMigrations:    req.noMigrations,
+		nonInteractive:  req.NonInteractive,
+		prompter:        req.Prompter,
+		driftMigrations: req.driftMigrations,
+	}
+	if req.driftResult != nil {
+		migrations.producedFiles = req.driftResult.MigrationFiles
+	}
+	if err := integrate(gitSporkConfig, upstreamRootPath, req.DownstreamRepoPath, req.ForceRePrompt, req.forDriftCheck, templatedIntegrator, migrations, req.Logger); err != nil {
 		return sdktypes.IntegratedUpstream{}, err
 	}
+	if req.driftResult != nil {
+		req.driftResult.sources = driftFileSources(gitSporkConfig)
+	}
 
-	if !req.forDriftCheck {
+	if !req.forDriftCheck && !req.migrationsDryRun {
 		state, err := LoadDownstreamState(req.DownstreamRepoPath)
 		if err != nil {
 			return sdktypes.IntegratedUpstream{}, fmt.Errorf("error loading downstream state to save upstream metadata: %v", err)
 		}
 		UpsertUpstreamState(state, sdktypes.UpstreamState{
-			URL:        originalUpstreamURL,
-			Subpath:    upstream.Subpath,
-			CommitHash: commitHash,
+			URL:          originalUpstreamURL,
+			Subpath:      upstream.Subpath,
+			CommitHash:   commitHash,
+			Version:      upstream.Version,
+			IntegratedAt: templatedIntegrator.Metadata.Gitspork.Time,
 		})
 		if err := SaveDownstreamState(req.DownstreamRepoPath, state); err != nil {
 			return sdktypes.IntegratedUpstream{}, fmt.Errorf("error saving upstream metadata to downstream state: %v", err)
@@ -287,35 +380,55 @@ func integrateOneInternal(req *internalRequest, upstream sdktypes.UpstreamSpec)
 	}, nil
 }
 
-func integrate(gitSporkConfig *config.GitSporkConfig, upstreamPath string, downstreamPath string, forceRePrompt bool, forDriftCheck bool, logger sdktypes.Logger) error {
+func integrate(gitSporkConfig *config.GitSporkConfig, upstreamPath string, downstreamPath string, forceRePrompt bool, forDriftCheck bool, templatedIntegrator *IntegratorTemplated, migrations migrationOptions, logger sdktypes.Logger) error {
 	greenBold := color.New(color.FgHiGreen, color.Bold)
+	migrations.forDriftCheck = forDriftCheck
 
 	preIntegrateMigrations := []*config.GitSporkConfigMigrationInstructions{}
 	postIntegrateMigrations := []*config.GitSporkConfigMigrationInstructions{}
 	queueMigrationIfNotCompleted := func(instructions *config.GitSporkConfigMigrationInstructions, queue []*config.GitSporkConfigMigrationInstructions) ([]*config.GitSporkConfigMigrationInstructions, error) {
-		migrationCompleted, err := migrationCompletedInDownstream(instructions.ID, downstreamPath)
+		migrationCompleted, err := migrationCompletedInDownstream(instructions.ID, downstreamPath, instructions.Aliases...)
 		if err != nil {
 			return queue, fmt.Errorf("error determining if migration %s was already run in downstream: %v", instructions.ID, err)
 		}
 		if !migrationCompleted {
 			queue = append(queue, instructions)
+		} else if !forDriftCheck && !migrations.dryRun {
+			if err := adoptMigrationAlias(instructions, downstreamPath); err != nil {
+				return queue, fmt.Errorf("error recording migration %s under its current ID: %v", instructions.ID, err)
+			}
 		}
 		return queue, nil
 	}
+	migrationConfigs, err := loadMigrationConfigs(gitSporkConfig, upstreamPath)
+	if err != nil {
+		return err
+	}
 	for _, migrationConfigPath := range gitSporkConfig.Migrations {
-		migrationConfig, err := config.ParseMigrationConfig(filepath.Join(upstreamPath, migrationConfigPath))
+		migrationConfig := migrationConfigs[migrationConfigPath]
+		pending, err := migrationPending(migrationConfig, downstreamPath)
 		if err != nil {
-			return fmt.Errorf("error parsing migration config: %v", err)
+			return err
+		}
+		if migrationConfig.AppliesIf != nil && pending {
+			applies, reason, err := migrationApplies(migrationConfig.AppliesIf, upstreamPath, migrations.prevCommit, logger)
+			if err != nil {
+				return fmt.Errorf("error evaluating applies_if of migration %s: %v", migrationConfigPath, err)
+			}
+			if !applies {
+				if err := skipInapplicableMigration(migrationConfig, reason, downstreamPath, migrations, logger); err != nil {
+					return err
+				}
+				continue
+			}
 		}
 		if migrationConfig.PreIntegrate != nil {
-			migrationConfig.PreIntegrate.ID = fmt.Sprintf("%s:%s", migrationConfigPath, preIntegrateMigrationID)
 			preIntegrateMigrations, err = queueMigrationIfNotCompleted(migrationConfig.PreIntegrate, preIntegrateMigrations)
 			if err != nil {
 				return fmt.Errorf("error queuing post-integrate migrations: %v", err)
 			}
 		}
 		if migrationConfig.PostIntegrate != nil {
-			migrationConfig.PostIntegrate.ID = fmt.Sprintf("%s:%s", migrationConfigPath, postIntegrateMigrationID)
 			postIntegrateMigrations, err = queueMigrationIfNotCompleted(migrationConfig.PostIntegrate, postIntegrateMigrations)
 			if err != nil {
 				return fmt.Errorf("error queuing post-integrate migrations: %v", err)
@@ -323,16 +436,51 @@ func integrate(gitSporkConfig *config.GitSporkConfig, upstreamPath string, downs
 		}
 	}
 
+	if pending := len(preIntegrateMigrations) + len(postIntegrateMigrations); pending > 0 {
+		switch {
+		case migrations.noMigrations:
+			logger.Log("⏭️  not running %d pending migration(s): migrations are disabled", pending)
+			preIntegrateMigrations, postIntegrateMigrations = nil, nil
+		case forDriftCheck && migrations.driftMigrations == sdktypes.DriftMigrationsSkip:
+			logger.Log("⏭️  not running %d pending migration(s) in the drift check", pending)
+			preIntegrateMigrations, postIntegrateMigrations = nil, nil
+		}
+	}
+
+	if migrations.dryRun {
+		for _, preIntegrateMigration := range preIntegrateMigrations {
+			logger.Log("%s", greenBold.Sprintf("(dry run) pre-integrate migration defined in upstream: %s", preIntegrateMigration.ID))
+			if err := runMigration(preIntegrateMigration, upstreamPath, downstreamPath, migrations, logger); err != nil {
+				return fmt.Errorf("error in pre-integrate migration %s: %v", preIntegrateMigration.ID, err)
+			}
+		}
+		for _, postIntegrateMigration := range postIntegrateMigrations {
+			logger.Log("%s", greenBold.Sprintf("(dry run) post-integrate migration defined in upstream: %s", postIntegrateMigration.ID))
+			if err := runMigration(postIntegrateMigration, upstreamPath, downstreamPath, migrations, logger); err != nil {
+				return fmt.Errorf("error in post-integrate migration %s: %v", postIntegrateMigration.ID, err)
+			}
+		}
+		logger.Log("(dry run) %d pending migration(s), nothing integrated", len(preIntegrateMigrations)+len(postIntegrateMigrations))
+		return nil
+	}
+
+	if !migrations.simulateCommands() {
+		if err := authorizeMigrationCommands(slices.Concat(preIntegrateMigrations, postIntegrateMigrations), upstreamPath, downstreamPath, migrations, logger); err != nil {
+			return err
+		}
+	}
+	// Input commands are authorized before anything is integrated too, so
+	// nothing the upstream writes can bear on the decision.
+	templatedIntegrator.commandTrust = migrations
+	if err := templatedIntegrator.authorizeInputCommands(gitSporkConfig.Templated, upstreamPath, downstreamPath, logger); err != nil {
+		return err
+	}
+
 	for _, preIntegrateMigration := range preIntegrateMigrations {
 		logger.Log("%s", greenBold.Sprintf("running pre-integrate migration defined in upstream against the downstream: %s", preIntegrateMigration.ID))
-		if err := runMigration(preIntegrateMigration, upstreamPath, downstreamPath, logger); err != nil {
+		if err := runAndRecordMigration(preIntegrateMigration, upstreamPath, downstreamPath, migrations, logger); err != nil {
 			return fmt.Errorf("error running pre-integrate migration against the downstream: %v", err)
 		}
-		if !forDriftCheck {
-			if err := recordCompleteMigration(preIntegrateMigration.ID, downstreamPath); err != nil {
-				return fmt.Errorf("error recording successful migration result: %v", err)
-			}
-		}
 	}
 
 	logger.Log("%s", greenBold.Sprint("integrating configured upstream-owned resources from upstream to downstream"))
@@ -361,20 +509,17 @@ func integrate(gitSporkConfig *config.GitSporkConfig, upstreamPath string, downs
 	}
 
 	logger.Log("%s", greenBold.Sprint("integrating configured templated resources from upstream to downstream"))
-	if err := (&IntegratorTemplated{}).Integrate(gitSporkConfig.Templated, upstreamPath, downstreamPath, forceRePrompt, logger); err != nil {
-		return fmt.Errorf("error integrating templated: %v", err)
+	templatedIntegrator.Partials = gitSporkConfig.TemplatePartials
+	if err := templatedIntegrator.Integrate(gitSporkConfig.Templated, upstreamPath, downstreamPath, forceRePrompt, logger); err != nil {
+		// %w so callers can detect sdktypes.ErrMissingInputs via errors.Is.
+		return fmt.Errorf("error integrating templated: %w", err)
 	}
 
 	for _, postIntegrateMigration := range postIntegrateMigrations {
 		logger.Log("%s", greenBold.Sprintf("running post-integrate migration defined in upstream against the downstream: %s", postIntegrateMigration.ID))
-		if err := runMigration(postIntegrateMigration, upstreamPath, downstreamPath, logger); err != nil {
+		if err := runAndRecordMigration(postIntegrateMigration, upstreamPath, downstreamPath, migrations, logger); err != nil {
 			return fmt.Errorf("error running post-integrate migration against the downstream: %v", err)
 		}
-		if !forDriftCheck {
-			if err := recordCompleteMigration(postIntegrateMigration.ID, downstreamPath); err != nil {
-				return fmt.Errorf("error recording successful migration result: %v", err)
-			}
-		}
 	}
 
 	return nil
@@ -420,30 +565,39 @@ func resolveUpstreamURL(url string, token string) string {
 	return url
 }
 
-func cloneUpstreamForIntegrate(cloneDir string, req *internalRequest, upstream sdktypes.UpstreamSpec) (string, error) {
-	upstreamURL := resolveUpstreamURL(upstream.URL, upstream.Token)
-	var err error
-	var auth authInfo
+// upstreamAuth sets up the git auth for upstreamURL, as resolved by
+// resolveUpstreamURL: token over HTTPS, the ssh-agent over SSH.
+func upstreamAuth(upstreamURL string, token string) (authInfo, error) {
 	isHTTPsUpstreamURL, _ := regexp.MatchString("^https://.*$", upstreamURL)
 	isSSHUpstreamURL, _ := regexp.MatchString("^git@", upstreamURL)
-	if isHTTPsUpstreamURL && upstream.Token != "" {
+	if isHTTPsUpstreamURL && token != "" {
 		basicAuth := &http.BasicAuth{
 			Username: config.GitSpork,
-			Password: upstream.Token,
+			Password: token,
 		}
-		auth = authInfo{
-			token:         upstream.Token,
+		return authInfo{
+			token:         token,
 			clientOptions: []client.Option{client.WithHTTPAuth(basicAuth)},
-		}
-	} else if isSSHUpstreamURL {
+		}, nil
+	}
+	if isSSHUpstreamURL {
 		agentAuth, err := ssh.NewSSHAgentAuth(config.GitSSHUsername)
 		if err != nil {
-			return "", fmt.Errorf("error setting up SSH auth method for git: %v", err)
+			return authInfo{}, fmt.Errorf("error setting up SSH auth method for git: %v", err)
 		}
 		if err := applySSHKnownHosts(agentAuth); err != nil {
-			return "", err
+			return authInfo{}, err
 		}
-		auth = authInfo{clientOptions: []client.Option{client.WithSSHAuth(agentAuth)}}
+		return authInfo{clientOptions: []client.Option{client.WithSSHAuth(agentAuth)}}, nil
+	}
+	return authInfo{}, nil
+}
+
+func cloneUpstreamForIntegrate(cloneDir string, req *internalRequest, upstream sdktypes.UpstreamSpec) (string, error) {
+	upstreamURL := resolveUpstreamURL(upstream.URL, upstream.Token)
+	auth, err := upstreamAuth(upstreamURL, upstream.Token)
+	if err != nil {
+		return "", err
 	}
 	// Resolve cache configuration (merging CLI/env/defaults). If enabled and
 	// healthy, we clone from the machine-scoped bare mirror rather than the
@@ -898,54 +1052,3 @@ func SaveDownstreamState(downstreamRepoPath string, state *sdktypes.DownstreamSt
 	}
 	return nil
 }
-
-func migrationCompletedInDownstream(migrationID string, downstreamRepoPath string) (bool, error) {
-	state, err := LoadDownstreamState(downstreamRepoPath)
-	if err != nil {
-		return false, err
-	}
-	for _, migrationComplete := range state.MigrationsComplete {
-		if migrationComplete == migrationID {
-			return true, nil
-		}
-	}
-	return false, nil
-}
-
-func runMigration(migrationInstructions *config.GitSporkConfigMigrationInstructions, upstreamRepoRootPath string, downstreamRepoPath string, logger sdktypes.Logger) error {
-	if migrationInstructions.Exec != "" {
-		// strings.Fields splits on any run of whitespace (spaces, tabs, newlines),
-		// so double-spaced or tab-separated commands tokenize correctly. Users
-		// needing arguments that contain literal spaces should invoke a shell
-		// explicitly: `sh -c 'my-script "arg with spaces"'`.
-		execParts := strings.Fields(migrationInstructions.Exec)
-		if len(execParts) == 0 {
-			return fmt.Errorf("migration exec %q resolved to zero tokens", migrationInstructions.Exec)
-		}
-		if _, err := os.Stat(filepath.Join(upstreamRepoRootPath, execParts[0])); err == nil {
-			// this is a case where the exec is calling a script that exists in the upstream, so call from that absolute path
-			execParts[0] = filepath.Join(upstreamRepoRootPath, execParts[0])
-		}
-		cmd := exec.Command(execParts[0], execParts[1:]...)
-		cmd.Stdout = &logutil.LoggerWriter{L: logger}
-		cmd.Stderr = &logutil.LoggerWriter{L: logger}
-		cmd.Dir = downstreamRepoPath
-		return cmd.Run()
-	}
-	return nil
-}
-
-func recordCompleteMigration(migrationID string, downstreamRepoPath string) error {
-	state, err := LoadDownstreamState(downstreamRepoPath)
-	if err != nil {
-		return err
-	}
-	migrationCompleted, err := migrationCompletedInDownstream(migrationID, downstreamRepoPath)
-	if err != nil {
-		return err
-	}
-	if !migrationCompleted {
-		state.MigrationsComplete = append(state.MigrationsComplete, migrationID)
-	}
-	return SaveDownstreamState(downstreamRepoPath, state)
-}
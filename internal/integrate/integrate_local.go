@@ -3,6 +3,7 @@ package integrate
 import (
 	"fmt"
 	"path/filepath"
+	"time"
 
 	"github.com/rockholla/gitspork/v2/internal/config"
 	"github.com/rockholla/gitspork/v2/internal/sdktypes"
@@ -19,6 +20,9 @@ func IntegrateLocal(opts *sdktypes.IntegrateLocalOptions) (*sdktypes.IntegrateRe
 	if len(opts.UpstreamPaths) == 0 {
 		return result, fmt.Errorf("no upstream path specified: set UpstreamPaths on IntegrateLocalOptions")
 	}
+	if opts.AllowMigrations && opts.NoMigrations {
+		return result, fmt.Errorf("only one of AllowMigrations or NoMigrations may be set")
+	}
 
 	for _, upstreamPath := range opts.UpstreamPaths {
 		if err := EnsureNotSelfIntegration(opts.DownstreamPath, "", upstreamPath); err != nil {
@@ -31,7 +35,28 @@ func IntegrateLocal(opts *sdktypes.IntegrateLocalOptions) (*sdktypes.IntegrateRe
 		if err != nil {
 			return result, err
 		}
-		if err := integrate(gitSporkConfig, upstreamPath, opts.DownstreamPath, opts.ForceRePrompt, false, opts.Logger); err != nil {
+		commit := localUpstreamCommit(upstreamPath)
+		templatedIntegrator := &IntegratorTemplated{
+			Inputs:         opts.Inputs,
+			NonInteractive: opts.NonInteractive,
+			Prompter:       opts.Prompter,
+			Metadata: TemplatedMetadata{
+				Gitspork:   newTemplatedGitsporkData(time.Now()),
+				Downstream: downstreamTemplatedData(opts.DownstreamPath),
+				Upstream:   newTemplatedUpstreamData(upstreamPath, "", "", commit),
+			},
+		}
+		migrations := migrationOptions{
+			dryRun:          opts.MigrationsDryRun,
+			ctx:             opts.Context,
+			upstreamURL:     upstreamPath,
+			newCommit:       commit,
+			allowMigrations: opts.AllowMigrations,
+			noMigrations:    opts.NoMigrations,
+			nonInteractive:  opts.NonInteractive,
+			prompter:        opts.Prompter,
+		}
+		if err := integrate(gitSporkConfig, upstreamPath, opts.DownstreamPath, opts.ForceRePrompt, false, templatedIntegrator, migrations, opts.Logger); err != nil {
 			return result, err
 		}
 		result.Upstreams = append(result.Upstreams, sdktypes.IntegratedUpstream{
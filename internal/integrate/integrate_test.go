@@ -252,7 +252,7 @@ func TestIntegrateForDriftCheck_honors_UpstreamCommit(t *testing.T) {
 	require.NoError(t, err)
 
 	logger := logutil.New()
-	err = IntegrateForDriftCheck(&DriftCheckRequest{
+	_, err = IntegrateForDriftCheck(&DriftCheckRequest{
 		Logger:             logger,
 		DownstreamRepoPath: downstreamDir,
 		UpstreamURL:        "file://" + upstreamDir,
@@ -266,6 +266,41 @@ func TestIntegrateForDriftCheck_honors_UpstreamCommit(t *testing.T) {
 		"IntegrateForDriftCheck with UpstreamCommit set to v1 should produce v1 content, not HEAD (v2)")
 }
 
+// TestIntegrateForDriftCheck_dateWithoutIntegratedAt covers state recorded
+// before integrated_at: the drift check renders the recorded commit's time, not
+// today's date, so .Gitspork.Date doesn't drift from one day to the next.
+func TestIntegrateForDriftCheck_dateWithoutIntegratedAt(t *testing.T) {
+	upstreamDir := t.TempDir()
+	upstreamRepo, err := gogit.PlainInit(upstreamDir, false,
+		gogit.WithDefaultBranch(plumbing.NewBranchReferenceName("main")),
+	)
+	require.NoError(t, err)
+	testharness.WriteFiles(t, upstreamDir, map[string]string{
+		"date.txt.go.tmpl": "{{ .Gitspork.Date }}",
+		".gitspork.yml":    "templated:\n- template: date.txt.go.tmpl\n  destination: date.txt\n",
+	})
+	wt, err := upstreamRepo.Worktree()
+	require.NoError(t, err)
+	require.NoError(t, wt.AddWithOptions(&gogit.AddOptions{All: true}))
+	sig := &object.Signature{Name: "gitspork-test", Email: "gitspork-test@localhost", When: time.Date(2024, 5, 6, 12, 0, 0, 0, time.UTC)}
+	commit, err := wt.Commit("v1", &gogit.CommitOptions{Author: sig, Committer: sig})
+	require.NoError(t, err)
+
+	downstreamDir := testharness.EmptyDownstream(t)
+	upstreamURL := "file://" + upstreamDir
+	require.NoError(t, SaveDownstreamState(downstreamDir, &sdktypes.DownstreamState{
+		Upstreams: []sdktypes.UpstreamState{{URL: upstreamURL, CommitHash: commit.String()}},
+	}))
+
+	_, err = IntegrateForDriftCheck(&DriftCheckRequest{
+		DownstreamRepoPath: downstreamDir,
+		UpstreamURL:        upstreamURL,
+		UpstreamCommit:     commit.String(),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "2024-05-06", testharness.ReadFile(t, downstreamDir, "date.txt"))
+}
+
 // upstreamWithTag builds on testharness.MinimalUpstream by creating a
 // lightweight tag pointing at HEAD. Returns the repo dir and the commit hash.
 func upstreamWithTag(t *testing.T, tagName string) (string, plumbing.Hash) {
@@ -3,6 +3,7 @@ package integrate
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -58,7 +59,25 @@ func mergeOneSharedOwnershipFile(upstreamPath, downstreamPath, integrateFile str
 	}
 	defer upstreamFile.Close()
 
-	upstreamScanner := bufio.NewScanner(upstreamFile)
+	upstreamOwnedBlocks, err := parseUpstreamOwnedBlocks(upstreamFile)
+	if err != nil {
+		return fmt.Errorf("error scanning/buffering upstream file %s: %v", integrateFile, err)
+	}
+
+	if _, err := os.Stat(filepath.Join(downstreamPath, integrateFile)); os.IsNotExist(err) {
+		if err := syncFile(filepath.Join(upstreamPath, integrateFile), filepath.Join(downstreamPath, integrateFile)); err != nil {
+			return fmt.Errorf("error copying upstream %s to downstream", integrateFile)
+		}
+	}
+
+	logger.Log("🔧 merging upstream file owned blocks from %s into downstream ", integrateFile)
+	return mergeUpstreamOwnedBlocksIntoFile(upstreamOwnedBlocks, downstreamPath, integrateFile, logger)
+}
+
+// parseUpstreamOwnedBlocks collects the upstream-owned blocks, markers
+// included, in the order they appear in r.
+func parseUpstreamOwnedBlocks(r io.Reader) ([]*upstreamOwnedBlock, error) {
+	upstreamScanner := bufio.NewScanner(r)
 	upstreamScanner.Buffer(make([]byte, 0, 64*1024), sharedOwnershipMergedMaxLineSize)
 
 	var currentUpstreamOwnedBlock *upstreamOwnedBlock
@@ -87,18 +106,16 @@ func mergeOneSharedOwnershipFile(upstreamPath, downstreamPath, integrateFile str
 			currentUpstreamOwnedBlock.content = fmt.Sprintf("%s%s\n", currentUpstreamOwnedBlock.content, line)
 		}
 	}
-
 	if err := upstreamScanner.Err(); err != nil {
-		return fmt.Errorf("error scanning/buffering upstream file %s: %v", integrateFile, err)
-	}
-
-	if _, err := os.Stat(filepath.Join(downstreamPath, integrateFile)); os.IsNotExist(err) {
-		if err := syncFile(filepath.Join(upstreamPath, integrateFile), filepath.Join(downstreamPath, integrateFile)); err != nil {
-			return fmt.Errorf("error copying upstream %s to downstream", integrateFile)
-		}
+		return nil, err
 	}
+	return upstreamOwnedBlocks, nil
+}
 
-	logger.Log("🔧 merging upstream file owned blocks from %s into downstream ", integrateFile)
+// mergeUpstreamOwnedBlocksIntoFile replaces each upstream-owned block of the
+// existing downstream file integrateFile, in order, with the next of
+// upstreamOwnedBlocks, appending any left over, and keeps every other line.
+func mergeUpstreamOwnedBlocksIntoFile(upstreamOwnedBlocks []*upstreamOwnedBlock, downstreamPath, integrateFile string, logger sdktypes.Logger) error {
 	mergedContent := ""
 	downstreamFile, err := os.Open(filepath.Join(downstreamPath, integrateFile))
 	if err != nil {
@@ -2,33 +2,51 @@ package integrate
 
 import (
 	"bytes"
-	"encoding/json"
 	"fmt"
 	"maps"
 	"os"
 	"path/filepath"
-	"text/template"
+	"text/template/parse"
 
 	"github.com/rockholla/gitspork/v2/internal/config"
-	inputpkg "github.com/rockholla/gitspork/v2/internal/input"
 	"github.com/rockholla/gitspork/v2/internal/sdktypes"
 )
 
-// requestInputFn is the templated integrator's indirection to the interactive
-// prompt package. In production it points at inputpkg.RequestInput; tests can
-// swap it (with a t.Cleanup restore) to observe or drive prompt behaviour
-// without a real terminal. Package-private and unexported — SDK consumers
-// cannot see it, so it isn't part of the public surface.
-var requestInputFn = inputpkg.RequestInput
-
 // IntegratorTemplated will process a list of instructions on how to render Go templates in the upstream to downstream rendered files
-type IntegratorTemplated struct{}
+type IntegratorTemplated struct {
+	// Inputs supplies prompt input values by name, taking precedence over
+	// GITSPORK_INPUT_<NAME> env vars and cached answers.
+	Inputs map[string]any
+	// NonInteractive fails with a *sdktypes.MissingInputsError instead of
+	// prompting when any prompt input has no value.
+	NonInteractive bool
+	// Prompter collects prompt input values; nil means TerminalPrompter.
+	Prompter sdktypes.Prompter
+	// Metadata is exposed to every template as .Gitspork, .Downstream and .Upstream.
+	Metadata TemplatedMetadata
+	// Partials are the upstream's template_partials patterns.
+	Partials []string
+	// commandTrust decides, as it does for migration commands, whether the
+	// upstream may run the commands inputs take their values from; integrate
+	// sets it. In a drift check they don't run.
+	commandTrust migrationOptions
+	// authorizedCommands are the input commands already authorized, so
+	// Integrate only asks about those that turn up after integrate did.
+	authorizedCommands map[inputCommand]bool
+}
 
 var _ TemplatedIntegrator = (*IntegratorTemplated)(nil)
 
 // IntegratorTemplateData is the common data interface passed into templates
 type IntegratorTemplatedData struct {
-	Inputs map[string]string `json:"inputs"`
+	// Inputs holds each input's typed value: string for string/select inputs,
+	// bool, int, or []string for multi-select (see config.TemplatedInputType*).
+	Inputs map[string]any `json:"inputs"`
+	// Gitspork, Downstream and Upstream carry the built-in integration
+	// metadata (see TemplatedMetadata).
+	Gitspork   TemplatedGitsporkData   `json:"gitspork"`
+	Downstream TemplatedDownstreamData `json:"downstream"`
+	Upstream   TemplatedUpstreamData   `json:"upstream"`
 }
 
 // Integrate will process the gitspork files list to ensure integration b/w upstream -> downstream
@@ -40,6 +58,14 @@ func (i *IntegratorTemplated) Integrate(templatedInstructions []config.GitSporkC
 	if err != nil {
 		return fmt.Errorf("error loading templated inputs cache: %v", err)
 	}
+	renderedFiles, err := loadTemplatedRendered(downstreamPath)
+	if err != nil {
+		return fmt.Errorf("error loading templated rendered files record: %v", err)
+	}
+	globals, err := loadGlobalInputs(downstreamPath)
+	if err != nil {
+		return fmt.Errorf("error loading global inputs: %v", err)
+	}
 	// Nothing to do at all — no templated instructions and no lingering cache. Skip
 	// creating an empty cache file and .gitattributes on downstreams that don't use
 	// templated integration.
@@ -47,6 +73,24 @@ func (i *IntegratorTemplated) Integrate(templatedInstructions []config.GitSporkC
 		return nil
 	}
 
+	partials, err := loadTemplatedPartials(upstreamPath, i.Partials)
+	if err != nil {
+		return fmt.Errorf("error loading template_partials: %v", err)
+	}
+
+	if i.NonInteractive {
+		missing, err := i.missingTemplatedInputs(templatedInstructions, downstreamPath, existingCache, globals, forceRePrompt)
+		if err != nil {
+			return err
+		}
+		if len(missing) > 0 {
+			return &sdktypes.MissingInputsError{Inputs: missing}
+		}
+	}
+	if err := i.authorizeInputCommands(templatedInstructions, upstreamPath, downstreamPath, logger); err != nil {
+		return err
+	}
+
 	// captured input values will support the input 'previous_input' type via this structure:
 	/*
 		capturedInputValues = {
@@ -56,58 +100,141 @@ func (i *IntegratorTemplated) Integrate(templatedInstructions []config.GitSporkC
 			}
 		}
 	*/
-	capturedInputValues := map[string]map[string]string{}
+	capturedInputValues := map[string]map[string]any{}
+	// capturedSecrets marks, per template, the inputs holding a secret value
+	// (directly or via previous_input), which are kept out of nextCache.
+	capturedSecrets := map[string]map[string]bool{}
+	// globalNames marks, per template, its global inputs, which are kept in
+	// globals rather than nextCache.
+	globalNames := map[string]map[string]bool{}
 	// nextCache is built up as we process each instruction and written at the end.
 	// Destinations no longer present in templatedInstructions are pruned by construction.
-	nextCache := map[string]map[string]string{}
+	nextCache := map[string]map[string]any{}
 
 	for _, templatedInstruction := range templatedInstructions {
-		logger.Log("📄 executing templated instruction for rendering upstream template %s to downstream location %s", templatedInstruction.Template, templatedInstruction.Destination)
+		source, target := templatedInstruction.Source(), templatedInstruction.Target()
+		capturedInputValues[source] = map[string]any{}
+		capturedSecrets[source] = map[string]bool{}
+		globalNames[source] = map[string]bool{}
+		if renderedOnce(templatedInstruction, downstreamPath) {
+			// The downstream owns the destination from here on: don't prompt
+			// or render, but keep any cached answers for previous_input.
+			logger.Log("⏭️  skipping templated instruction for %s, render: %s and it already exists downstream", target, config.TemplatedRenderOnce)
+			if cached, ok := existingCache[target]; ok {
+				maps.Copy(capturedInputValues[source], cached)
+				nextCache[target] = cached
+			}
+			delete(renderedFiles, target)
+			continue
+		}
+		logger.Log("📄 executing templated instruction for rendering upstream template %s to downstream location %s", source, target)
 
-		capturedInputValues[templatedInstruction.Template] = map[string]string{}
 		templateData := IntegratorTemplatedData{
-			Inputs: map[string]string{},
+			Inputs:     map[string]any{},
+			Gitspork:   i.Metadata.Gitspork,
+			Downstream: i.Metadata.Downstream,
+			Upstream:   i.Metadata.Upstream,
 		}
-		if cached, ok := existingCache[templatedInstruction.Destination]; ok {
+		if cached, ok := existingCache[target]; ok {
 			// seed template inputs from consolidated cache so users aren't re-prompted
 			maps.Copy(templateData.Inputs, cached)
-			maps.Copy(capturedInputValues[templatedInstruction.Template], templateData.Inputs)
+			maps.Copy(capturedInputValues[source], templateData.Inputs)
 		}
 		// we'll begin by gathering inputs to start
 		for _, input := range templatedInstruction.Inputs {
-			if input.JSONDataPath != "" {
-				jsonDataPath := filepath.Join(downstreamPath, input.JSONDataPath)
-				jsonData, err := os.ReadFile(jsonDataPath)
+			if input.Secret {
+				value, err := i.resolveSecretInput(templatedInstruction, input, downstreamPath, logger)
+				if err != nil {
+					return err
+				}
+				templateData.Inputs[input.Name] = value
+				capturedInputValues[source][input.Name] = value
+				capturedSecrets[source][input.Name] = true
+			} else if input.Global {
+				cached, isCached := templateData.Inputs[input.Name]
+				value, err := i.resolveGlobalInput(templatedInstruction, input, globals, cached, isCached, forceRePrompt, logger)
 				if err != nil {
-					return fmt.Errorf("error reading json_data_path at %s: %v", jsonDataPath, err)
+					return err
 				}
-				if err := json.Unmarshal(jsonData, &templateData.Inputs); err != nil {
-					return fmt.Errorf("error parsing json_data_path file %s into inputs: %v", jsonDataPath, err)
+				templateData.Inputs[input.Name] = value
+				capturedInputValues[source][input.Name] = value
+				globalNames[source][input.Name] = true
+			} else if input.JSONDataPath != "" || input.YAMLDataPath != "" {
+				field, dataPath := templatedDataInputFile(input, downstreamPath)
+				raw, err := readTemplatedDataInput(input, field, dataPath, templateData.Inputs)
+				if err != nil {
+					return err
+				}
+				value, err := resolveTemplatedInputValue(input, raw)
+				if err != nil {
+					return fmt.Errorf("invalid value in %s file %s under template %s: %v", field, dataPath, source, err)
 				}
+				templateData.Inputs[input.Name] = value
 				// Only propagate inputs to capturedInputValues after a successful
 				// unmarshal — otherwise a JSON parse error would leak partially-
 				// populated data into the previous_input chain for subsequent
 				// templated instructions in this run.
-				maps.Copy(capturedInputValues[templatedInstruction.Template], templateData.Inputs)
+				maps.Copy(capturedInputValues[source], templateData.Inputs)
+			} else if input.Env != "" {
+				raw, _ := os.LookupEnv(input.Env)
+				value, err := resolveTemplatedInputValue(input, raw)
+				if err != nil {
+					return fmt.Errorf("invalid value from env var %s under template %s: %v", input.Env, source, err)
+				}
+				templateData.Inputs[input.Name] = value
+				capturedInputValues[source][input.Name] = value
+			} else if input.Exec != "" && i.commandTrust.forDriftCheck {
+				// A drift check reproduces the recorded value rather than
+				// running upstream code.
+				cached, isCached := templateData.Inputs[input.Name]
+				value, ok, err := i.recordedExecInputValue(input, source, cached, isCached)
+				if err != nil {
+					return err
+				}
+				if !ok {
+					return fmt.Errorf("no recorded value for exec input %s under template %s, which a drift check doesn't run: supply one with --input or %s", input.Name, source, templatedInputEnvVar(input.Name))
+				}
+				templateData.Inputs[input.Name] = value
+				capturedInputValues[source][input.Name] = value
+			} else if input.Exec != "" {
+				out, err := i.runInputCommand(input.Exec, downstreamPath, logger)
+				if err != nil {
+					return fmt.Errorf("error running exec for input %s under template %s: %v", input.Name, source, err)
+				}
+				value, err := resolveTemplatedInputValue(input, out)
+				if err != nil {
+					return fmt.Errorf("invalid value from exec %q under template %s: %v", input.Exec, source, err)
+				}
+				templateData.Inputs[input.Name] = value
+				capturedInputValues[source][input.Name] = value
 			} else if input.Prompt != "" {
-				if templateData.Inputs[input.Name] == "" || forceRePrompt {
-					requestInputOpts := &inputpkg.RequestInputOptions{
-						Type:   inputpkg.SingleValue,
-						Prompt: input.Prompt,
-					}
-					requestInputResult, err := requestInputFn(requestInputOpts)
+				cached, isCached := templateData.Inputs[input.Name]
+				value, ok, err := i.resolvePromptInput(input, source, cached, isCached, forceRePrompt, logger)
+				if err != nil {
+					return err
+				}
+				if !ok {
+					value, err = i.promptTemplatedInput(templatedInstruction, input, logger)
 					if err != nil {
-						return fmt.Errorf("error setting up prompt input: %v", err)
+						return err
 					}
-					templateData.Inputs[input.Name] = requestInputResult.StringValue
-					capturedInputValues[templatedInstruction.Template][input.Name] = requestInputResult.StringValue
 				}
+				templateData.Inputs[input.Name] = value
+				capturedInputValues[source][input.Name] = value
 			} else if input.PreviousInput != nil {
 				var previousInputErr error
 				if _, ok := capturedInputValues[input.PreviousInput.Template]; ok {
-					if value, ok := capturedInputValues[input.PreviousInput.Template][input.PreviousInput.Name]; ok {
-						templateData.Inputs[input.Name] = value
-						capturedInputValues[templatedInstruction.Template][input.Name] = value
+					if previousValue, ok := capturedInputValues[input.PreviousInput.Template][input.PreviousInput.Name]; ok {
+						value, err := resolveTemplatedInputValue(input, previousValue)
+						if err != nil {
+							previousInputErr = err
+						} else {
+							templateData.Inputs[input.Name] = value
+							capturedInputValues[source][input.Name] = value
+							if capturedSecrets[input.PreviousInput.Template][input.PreviousInput.Name] {
+								capturedSecrets[source][input.Name] = true
+							}
+						}
 					} else {
 						previousInputErr = fmt.Errorf("previous input name %s not found in template %s", input.PreviousInput.Name, input.PreviousInput.Template)
 					}
@@ -115,90 +242,211 @@ func (i *IntegratorTemplated) Integrate(templatedInstructions []config.GitSporkC
 					previousInputErr = fmt.Errorf("previous template not found: %s", input.PreviousInput.Template)
 				}
 				if previousInputErr != nil {
-					return fmt.Errorf("error in previous_input configuration under template %s: %v", templatedInstruction.Template, previousInputErr)
+					return fmt.Errorf("error in previous_input configuration under template %s: %v", source, previousInputErr)
 				}
 			} else {
-				return fmt.Errorf("templated definition %s requires at least one of 'prompt', 'json_data_path', or 'previous_input' to be defined", input.Name)
+				return fmt.Errorf("templated definition %s requires at least one of 'prompt', 'json_data_path', 'yaml_data_path', 'env', 'exec' or 'previous_input' to be defined", input.Name)
 			}
 		}
 
-		// now that we have our template data populated we can actually render the template from upstream to the downstream destination
-		templateFileBytes, err := os.ReadFile(filepath.Join(upstreamPath, templatedInstruction.Template))
-		if err != nil {
-			return fmt.Errorf("error reading upstream template %s: %v", templatedInstruction.Template, err)
+		// Secret values never reach the committed cache, and global ones
+		// live in inputs.yml instead.
+		nextCache[target] = maps.Clone(templateData.Inputs)
+		for name := range capturedSecrets[source] {
+			delete(nextCache[target], name)
 		}
-		t, err := template.New("").Parse(string(templateFileBytes))
-		if err != nil {
-			return fmt.Errorf("error parsing related template in upstream %s: %v", templatedInstruction.Template, err)
-		}
-		fullDestinationPath := filepath.Join(downstreamPath, templatedInstruction.Destination)
-		fullDestinationDir := filepath.Dir(fullDestinationPath)
-
-		if err := os.MkdirAll(fullDestinationDir, 0755); err != nil {
-			return fmt.Errorf("error ensuring %s exists: %v", fullDestinationDir, err)
-		}
-		performPostMergeStructured := ""
-		if templatedInstruction.Merged != nil && templatedInstruction.Merged.Structured != "" {
-			if _, err := os.Stat(fullDestinationPath); err == nil {
-				// if we have merge instruction present, and there's a file at the destination path already
-				performPostMergeStructured = templatedInstruction.Merged.Structured
-				if performPostMergeStructured != config.TemplatedMergeStructuredPreferUpstream && performPostMergeStructured != config.TemplatedMergeStructuredPreferDownstream {
-					return fmt.Errorf("invalid templated merged.structured value %s, expects one of: %s, %s", performPostMergeStructured,
-						config.TemplatedMergeStructuredPreferUpstream, config.TemplatedMergeStructuredPreferDownstream)
+		for name := range globalNames[source] {
+			delete(nextCache[target], name)
+		}
+
+		if templatedInstruction.When != "" {
+			render, err := evaluateTemplatedWhen(templatedInstruction, templateData, partials)
+			if err != nil {
+				return err
+			}
+			if !render {
+				logger.Log("⏭️  not rendering %s, when condition %q is false", target, templatedInstruction.When)
+				if err := removeStaleRenderedFiles(downstreamPath, target, renderedFiles[target], nil, logger); err != nil {
+					return fmt.Errorf("error removing files rendered from %s: %v", source, err)
 				}
+				delete(renderedFiles, target)
+				continue
 			}
 		}
-		var renderedBytes bytes.Buffer
-		err = t.Execute(&renderedBytes, templateData)
-		if err != nil {
-			return fmt.Errorf("error rendering template data: %v", err)
-		}
-		if performPostMergeStructured != "" {
-			// Wrapped in a closure so the tmpDir RemoveAll defer scopes to a
-			// single templated instruction — otherwise every render in the
-			// outer loop accumulated another defer that only ran at Integrate
-			// return, holding onto multiple temp directories for the whole run.
-			if err := func() error {
-				tmpDir, err := os.MkdirTemp("", config.GitSpork)
-				if err != nil {
-					return fmt.Errorf("error creating temp directory: %v", err)
-				}
-				defer os.RemoveAll(tmpDir)
-				tmpFilePath := filepath.Join(tmpDir, filepath.Base(fullDestinationPath))
-				if err := os.WriteFile(tmpFilePath, renderedBytes.Bytes(), 0644); err != nil {
-					return fmt.Errorf("error writing rendered template to temporary location: %v", err)
-				}
-				newData, existingData, structuredDataType, err := getStructuredData(tmpFilePath, fullDestinationPath)
-				if err != nil {
-					return fmt.Errorf("error loading structured data from existing/new template render process in %s: %v", templatedInstruction.Template, err)
-				}
-				var merged *node
-				if performPostMergeStructured == config.TemplatedMergeStructuredPreferDownstream {
-					merged = mergeNodes(newData, existingData, true)
-				} else {
-					merged = mergeNodes(existingData, newData, true)
-				}
-				if err := writeStructuredData(merged, structuredDataType, fullDestinationPath); err != nil {
-					return fmt.Errorf("error writing merged structured data in templated instruction from %s: %v", templatedInstruction.Template, err)
-				}
-				return nil
-			}(); err != nil {
+
+		// now that we have our template data populated we can actually render the template from upstream to the downstream destination
+		if templatedInstruction.IsDir() {
+			rendered, err := renderTemplatedDir(templatedInstruction, upstreamPath, downstreamPath, templateData, partials)
+			if err != nil {
 				return err
 			}
+			if err := removeStaleRenderedFiles(downstreamPath, target, renderedFiles[target], rendered, logger); err != nil {
+				return fmt.Errorf("error removing stale files rendered from template_dir %s: %v", source, err)
+			}
+			renderedFiles[target] = rendered
 		} else {
-			if err := os.WriteFile(fullDestinationPath, renderedBytes.Bytes(), 0644); err != nil {
-				return fmt.Errorf("error writing rendered templated file from instruction %s: %v", templatedInstruction.Destination, err)
+			if err := renderTemplatedFile(templatedInstruction, upstreamPath, downstreamPath, templateData, partials, logger); err != nil {
+				return err
+			}
+			// Only a conditional destination may later need removing once its
+			// when turns false; record it as the destination itself.
+			if templatedInstruction.When != "" {
+				renderedFiles[target] = []string{"."}
+			} else {
+				delete(renderedFiles, target)
 			}
 		}
-
-		nextCache[templatedInstruction.Destination] = templateData.Inputs
+		if templatedInstruction.RenderOnce() {
+			// Seeded files belong to the downstream, so they're never removed.
+			delete(renderedFiles, target)
+		}
 	}
 
+	if err := globals.save(downstreamPath); err != nil {
+		return fmt.Errorf("error writing global inputs: %v", err)
+	}
 	if err := saveTemplatedInputs(downstreamPath, nextCache); err != nil {
 		return fmt.Errorf("error writing templated inputs cache: %v", err)
 	}
+	// Unlike the inputs cache, destinations not configured in this run are
+	// kept: another upstream may own them, and removing a template_dir entry
+	// is handled by the upstream delta, which deletes its recorded files.
+	if err := saveTemplatedRendered(downstreamPath, renderedFiles); err != nil {
+		return fmt.Errorf("error writing templated rendered files record: %v", err)
+	}
 	if err := ensureGitsporkAttributes(downstreamPath); err != nil {
 		return fmt.Errorf("error ensuring .gitattributes entry for templated cache: %v", err)
 	}
 	return nil
 }
+
+// renderedOnce reports whether a render: once instruction's destination
+// already exists downstream, so it must not be rendered again.
+func renderedOnce(templatedInstruction config.GitSporkConfigTemplated, downstreamPath string) bool {
+	if !templatedInstruction.RenderOnce() {
+		return false
+	}
+	_, err := os.Lstat(filepath.Join(downstreamPath, templatedInstruction.Target()))
+	return err == nil
+}
+
+// evaluateTemplatedWhen evaluates the instruction's when pipeline as the
+// condition of an {{ if }} against templateData.
+func evaluateTemplatedWhen(templatedInstruction config.GitSporkConfigTemplated, templateData IntegratorTemplatedData, partials *templatedPartials) (bool, error) {
+	out, err := func() (string, error) {
+		t, err := partials.parse(templatedInstruction.Source()+" when", "{{ if "+templatedInstruction.When+" }}true{{ end }}", templateData)
+		if err != nil {
+			return "", err
+		}
+		// Reject a when that closes the wrapping action, e.g. "x }}{{ else",
+		// which would otherwise parse into a different template.
+		if !isWhenTemplate(t.Tree.Root) {
+			return "", fmt.Errorf("must be a single pipeline")
+		}
+		b, err := partials.execute(t, templateData)
+		return string(b), err
+	}()
+	if err != nil {
+		return false, fmt.Errorf("error evaluating when %q for %s: %v", templatedInstruction.When, templatedInstruction.Source(), err)
+	}
+	return out == "true", nil
+}
+
+// isWhenTemplate reports whether root is exactly the {{ if }}true{{ end }}
+// evaluateTemplatedWhen wraps a when pipeline in.
+func isWhenTemplate(root *parse.ListNode) bool {
+	if len(root.Nodes) != 1 {
+		return false
+	}
+	ifNode, ok := root.Nodes[0].(*parse.IfNode)
+	if !ok || ifNode.ElseList != nil || len(ifNode.List.Nodes) != 1 {
+		return false
+	}
+	text, ok := ifNode.List.Nodes[0].(*parse.TextNode)
+	return ok && string(text.Text) == "true"
+}
+
+// renderTemplatedFile renders a single-file templated instruction to its
+// destination, applying the optional structured or blocks merge with an
+// existing file.
+func renderTemplatedFile(templatedInstruction config.GitSporkConfigTemplated, upstreamPath string, downstreamPath string, templateData IntegratorTemplatedData, partials *templatedPartials, logger sdktypes.Logger) error {
+	templateFileBytes, err := os.ReadFile(filepath.Join(upstreamPath, templatedInstruction.Template))
+	if err != nil {
+		return fmt.Errorf("error reading upstream template %s: %v", templatedInstruction.Template, err)
+	}
+	t, err := partials.parse(templatedInstruction.Template, string(templateFileBytes), templateData)
+	if err != nil {
+		return fmt.Errorf("error parsing related template in upstream %s: %v", templatedInstruction.Template, err)
+	}
+	fullDestinationPath := filepath.Join(downstreamPath, templatedInstruction.Destination)
+	fullDestinationDir := filepath.Dir(fullDestinationPath)
+
+	if err := os.MkdirAll(fullDestinationDir, 0755); err != nil {
+		return fmt.Errorf("error ensuring %s exists: %v", fullDestinationDir, err)
+	}
+	performPostMergeStructured := ""
+	if templatedInstruction.Merged != nil && templatedInstruction.Merged.Structured != "" {
+		if _, err := os.Stat(fullDestinationPath); err == nil {
+			// if we have merge instruction present, and there's a file at the destination path already
+			performPostMergeStructured = templatedInstruction.Merged.Structured
+			if performPostMergeStructured != config.TemplatedMergeStructuredPreferUpstream && performPostMergeStructured != config.TemplatedMergeStructuredPreferDownstream {
+				return fmt.Errorf("invalid templated merged.structured value %s, expects one of: %s, %s", performPostMergeStructured,
+					config.TemplatedMergeStructuredPreferUpstream, config.TemplatedMergeStructuredPreferDownstream)
+			}
+		}
+	}
+	renderedBytes, err := partials.execute(t, templateData)
+	if err != nil {
+		return fmt.Errorf("error rendering template data for %s: %v", templatedInstruction.Template, err)
+	}
+	if templatedInstruction.Merged != nil && templatedInstruction.Merged.Blocks {
+		if _, err := os.Stat(fullDestinationPath); err == nil {
+			// Only the rendered upstream-owned blocks are merged into the
+			// existing file; everything else in it is downstream-owned.
+			logger.Log("🔧 merging upstream owned blocks rendered from %s into downstream %s", templatedInstruction.Template, templatedInstruction.Destination)
+			blocks, err := parseUpstreamOwnedBlocks(bytes.NewReader(renderedBytes))
+			if err != nil {
+				return fmt.Errorf("error parsing upstream-owned blocks rendered from %s: %v", templatedInstruction.Template, err)
+			}
+			return mergeUpstreamOwnedBlocksIntoFile(blocks, downstreamPath, templatedInstruction.Destination, logger)
+		}
+	}
+	if performPostMergeStructured != "" {
+		// Wrapped in a closure so the tmpDir RemoveAll defer scopes to a
+		// single templated instruction — otherwise every render in the
+		// outer loop accumulated another defer that only ran at Integrate
+		// return, holding onto multiple temp directories for the whole run.
+		if err := func() error {
+			tmpDir, err := os.MkdirTemp("", config.GitSpork)
+			if err != nil {
+				return fmt.Errorf("error creating temp directory: %v", err)
+			}
+			defer os.RemoveAll(tmpDir)
+			tmpFilePath := filepath.Join(tmpDir, filepath.Base(fullDestinationPath))
+			if err := os.WriteFile(tmpFilePath, renderedBytes, 0644); err != nil {
+				return fmt.Errorf("error writing rendered template to temporary location: %v", err)
+			}
+			newData, existingData, structuredDataType, err := getStructuredData(tmpFilePath, fullDestinationPath)
+			if err != nil {
+				return fmt.Errorf("error loading structured data from existing/new template render process in %s: %v", templatedInstruction.Template, err)
+			}
+			var merged *node
+			if performPostMergeStructured == config.TemplatedMergeStructuredPreferDownstream {
+				merged = mergeNodes(newData, existingData, true)
+			} else {
+				merged = mergeNodes(existingData, newData, true)
+			}
+			if err := writeStructuredData(merged, structuredDataType, fullDestinationPath); err != nil {
+				return fmt.Errorf("error writing merged structured data in templated instruction from %s: %v", templatedInstruction.Template, err)
+			}
+			return nil
+		}(); err != nil {
+			return err
+		}
+	} else {
+		if err := os.WriteFile(fullDestinationPath, renderedBytes, 0644); err != nil {
+			return fmt.Errorf("error writing rendered templated file from instruction %s: %v", templatedInstruction.Destination, err)
+		}
+	}
+	return nil
+}
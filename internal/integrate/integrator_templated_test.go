@@ -3,10 +3,10 @@ package integrate
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/rockholla/gitspork/v2/internal/config"
-	inputpkg "github.com/rockholla/gitspork/v2/internal/input"
 	"github.com/rockholla/gitspork/v2/internal/sdktypes"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -105,7 +105,7 @@ func TestIntegratorTemplated_prunesStaleDestinationsFromCache(t *testing.T) {
 		`{"name":"world"}`,
 	)
 	// seed the consolidated cache with a destination that ISN'T in this run's instructions
-	require.NoError(t, saveTemplatedInputs(downstreamDir, map[string]map[string]string{
+	require.NoError(t, saveTemplatedInputs(downstreamDir, map[string]map[string]any{
 		"stale.txt":    {"old": "value"},
 		"rendered.txt": {"name": "previous-run-value"},
 	}))
@@ -138,35 +138,27 @@ func TestIntegratorTemplated_noopWithEmptyInstructionsAndNoCache(t *testing.T) {
 	assert.True(t, os.IsNotExist(err), "must not create .gitattributes on a downstream with no templated integration")
 }
 
-// stubRequestInput replaces requestInputFn with a counting stub for the
-// duration of a test. Returns a pointer to the call count and the resolved
-// values captured per call so tests can assert both invocation count and
-// argument shape. Restores the original via t.Cleanup.
-func stubRequestInput(t *testing.T, returnValue string) *stubCounter {
-	t.Helper()
-	orig := requestInputFn
-	sc := &stubCounter{returnValue: returnValue}
-	requestInputFn = func(opts *inputpkg.RequestInputOptions) (*inputpkg.RequestInputResult, error) {
-		sc.calls++
-		sc.prompts = append(sc.prompts, opts.Prompt)
-		return &inputpkg.RequestInputResult{StringValue: sc.returnValue}, nil
-	}
-	t.Cleanup(func() { requestInputFn = orig })
-	return sc
-}
-
-type stubCounter struct {
+// stubPrompter is a counting Prompter returning a fixed answer, recording
+// each request's prompt text so tests can assert both invocation count and
+// argument shape.
+type stubPrompter struct {
 	calls       int
 	prompts     []string
 	returnValue string
 }
 
+func (sp *stubPrompter) Prompt(req sdktypes.PromptRequest) (any, error) {
+	sp.calls++
+	sp.prompts = append(sp.prompts, req.Prompt)
+	return sp.returnValue, nil
+}
+
 // TestIntegratorTemplated_forceRePrompt covers the four cells of the
 // (cached-value present) × (forceRePrompt true|false) matrix on a prompt
-// input. The seam swap on requestInputFn is what makes this testable — a
-// production regression that stopped honouring forceRePrompt would either
-// keep the stub un-called (if the seam were bypassed) or call it in a case
-// where cached content should have won.
+// input. Injecting a Prompter is what makes this testable — a production
+// regression that stopped honouring forceRePrompt would either keep the stub
+// un-called (if the Prompter were bypassed) or call it in a case where cached
+// content should have won.
 func TestIntegratorTemplated_forceRePrompt(t *testing.T) {
 	setupPromptFixture := func(t *testing.T, seedCache bool, cachedValue string) (string, string) {
 		t.Helper()
@@ -175,7 +167,7 @@ func TestIntegratorTemplated_forceRePrompt(t *testing.T) {
 		require.NoError(t, os.WriteFile(filepath.Join(upstreamDir, "template.txt"),
 			[]byte(`Hello, {{ index .Inputs "name" }}!`), 0644))
 		if seedCache {
-			require.NoError(t, saveTemplatedInputs(downstreamDir, map[string]map[string]string{
+			require.NoError(t, saveTemplatedInputs(downstreamDir, map[string]map[string]any{
 				"rendered.txt": {"name": cachedValue},
 			}))
 		}
@@ -192,8 +184,8 @@ func TestIntegratorTemplated_forceRePrompt(t *testing.T) {
 		// Baseline: without a cached value, the prompt runs regardless of
 		// forceRePrompt.
 		upstream, downstream := setupPromptFixture(t, false, "")
-		stub := stubRequestInput(t, "fresh-value")
-		require.NoError(t, (&IntegratorTemplated{}).Integrate(instructions, upstream, downstream, false, sdktypes.NoopLogger()))
+		stub := &stubPrompter{returnValue: "fresh-value"}
+		require.NoError(t, (&IntegratorTemplated{Prompter: stub}).Integrate(instructions, upstream, downstream, false, sdktypes.NoopLogger()))
 		assert.Equal(t, 1, stub.calls, "empty cache must trigger the prompt exactly once")
 		got, err := os.ReadFile(filepath.Join(downstream, "rendered.txt"))
 		require.NoError(t, err)
@@ -205,8 +197,8 @@ func TestIntegratorTemplated_forceRePrompt(t *testing.T) {
 		// the user for values they've already given. A stub that gets called
 		// here indicates a broken cache path.
 		upstream, downstream := setupPromptFixture(t, true, "cached-value")
-		stub := stubRequestInput(t, "SHOULD-NEVER-BE-USED")
-		require.NoError(t, (&IntegratorTemplated{}).Integrate(instructions, upstream, downstream, false, sdktypes.NoopLogger()))
+		stub := &stubPrompter{returnValue: "SHOULD-NEVER-BE-USED"}
+		require.NoError(t, (&IntegratorTemplated{Prompter: stub}).Integrate(instructions, upstream, downstream, false, sdktypes.NoopLogger()))
 		assert.Equal(t, 0, stub.calls,
 			"cached value must satisfy the prompt input — a call here indicates a broken cache lookup or accidental re-prompt")
 		got, err := os.ReadFile(filepath.Join(downstream, "rendered.txt"))
@@ -221,8 +213,8 @@ func TestIntegratorTemplated_forceRePrompt(t *testing.T) {
 		// The prompt MUST run, and the returned value MUST replace the cache
 		// entry for subsequent runs.
 		upstream, downstream := setupPromptFixture(t, true, "cached-value")
-		stub := stubRequestInput(t, "re-prompted-value")
-		require.NoError(t, (&IntegratorTemplated{}).Integrate(instructions, upstream, downstream, true, sdktypes.NoopLogger()))
+		stub := &stubPrompter{returnValue: "re-prompted-value"}
+		require.NoError(t, (&IntegratorTemplated{Prompter: stub}).Integrate(instructions, upstream, downstream, true, sdktypes.NoopLogger()))
 
 		assert.Equal(t, 1, stub.calls,
 			"forceRePrompt=true must trigger the prompt exactly once even when a cached value exists")
@@ -249,8 +241,8 @@ func TestIntegratorTemplated_forceRePrompt(t *testing.T) {
 		// invariant that the flag isn't accidentally short-circuiting away
 		// the prompt for fresh installs.
 		upstream, downstream := setupPromptFixture(t, false, "")
-		stub := stubRequestInput(t, "value")
-		require.NoError(t, (&IntegratorTemplated{}).Integrate(instructions, upstream, downstream, true, sdktypes.NoopLogger()))
+		stub := &stubPrompter{returnValue: "value"}
+		require.NoError(t, (&IntegratorTemplated{Prompter: stub}).Integrate(instructions, upstream, downstream, true, sdktypes.NoopLogger()))
 		assert.Equal(t, 1, stub.calls)
 	})
 }
@@ -609,6 +601,53 @@ func TestIntegratorTemplated_structuredMerge_invalidMode(t *testing.T) {
 	assert.Contains(t, err.Error(), config.TemplatedMergeStructuredPreferDownstream)
 }
 
+func TestIntegratorTemplated_blocksMerge(t *testing.T) {
+	upstreamTemplate := "# upstream preamble\n" +
+		"# ::gitspork::begin-upstream-owned-block\n" +
+		"lint:\n\tgolangci-lint run --timeout {{ .Inputs.timeout }}\n" +
+		"# ::gitspork::end-upstream-owned-block\n" +
+		"# ::gitspork::begin-upstream-owned-block\n" +
+		"test:\n\tgo test ./...\n" +
+		"# ::gitspork::end-upstream-owned-block\n"
+	instructions := []config.GitSporkConfigTemplated{{
+		Template:    "Makefile.go.tmpl",
+		Destination: "Makefile",
+		Merged:      &config.GitSporkConfigTemplatedMerged{Blocks: true},
+		Inputs:      []config.GitSporkConfigTemplatedInput{{Name: "timeout", Prompt: "timeout?"}},
+	}}
+	integrator := &IntegratorTemplated{Inputs: map[string]any{"timeout": "5m"}}
+
+	t.Run("rendered blocks replace downstream blocks and the rest is kept", func(t *testing.T) {
+		existingDownstream := "build:\n\tgo build ./...\n" +
+			"# ::gitspork::begin-upstream-owned-block\n" +
+			"lint:\n\tstale\n" +
+			"# ::gitspork::end-upstream-owned-block\n" +
+			"release:\n\t./release.sh\n"
+		upstreamDir, downstreamDir := setupStructuredMergeFixture(t, "Makefile.go.tmpl", upstreamTemplate, "Makefile", existingDownstream)
+		require.NoError(t, integrator.Integrate(instructions, upstreamDir, downstreamDir, false, sdktypes.NoopLogger()))
+
+		merged, err := os.ReadFile(filepath.Join(downstreamDir, "Makefile"))
+		require.NoError(t, err)
+		assert.Equal(t, "build:\n\tgo build ./...\n"+
+			"# ::gitspork::begin-upstream-owned-block\n"+
+			"lint:\n\tgolangci-lint run --timeout 5m\n"+
+			"# ::gitspork::end-upstream-owned-block\n"+
+			"release:\n\t./release.sh\n"+
+			"# ::gitspork::begin-upstream-owned-block\n"+
+			"test:\n\tgo test ./...\n"+
+			"# ::gitspork::end-upstream-owned-block\n", string(merged))
+	})
+
+	t.Run("absent destination gets the full render", func(t *testing.T) {
+		upstreamDir, downstreamDir := setupStructuredMergeFixture(t, "Makefile.go.tmpl", upstreamTemplate, "Makefile", "")
+		require.NoError(t, integrator.Integrate(instructions, upstreamDir, downstreamDir, false, sdktypes.NoopLogger()))
+
+		rendered, err := os.ReadFile(filepath.Join(downstreamDir, "Makefile"))
+		require.NoError(t, err)
+		assert.Equal(t, strings.Replace(upstreamTemplate, "{{ .Inputs.timeout }}", "5m", 1), string(rendered))
+	})
+}
+
 // TestIntegratorTemplated_structuredMerge_noTmpDirLeak asserts the fix from
 // PR #66 (defer scoping) holds: rendering many templated instructions in one
 // Integrate call must not leave temp directories behind at the end of the run.
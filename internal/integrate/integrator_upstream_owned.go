@@ -23,6 +23,9 @@ func (i *IntegratorUpstreamOwned) Integrate(entries []config.OwnedEntry, upstrea
 		}
 		for _, integrateFile := range integrateFiles {
 			dest := entry.ResolveDest(integrateFile)
+			if config.InGitSporkDir(dest) {
+				return fmt.Errorf("upstream_owned %q matches %s, which would be written under %s, and only gitspork writes there", entry.SourcePattern(), integrateFile, config.GitSporkDirName)
+			}
 			if dest == integrateFile {
 				logger.Log("➡️ copying/overwriting %s to downstream", integrateFile)
 			} else {
@@ -0,0 +1,27 @@
+package integrate
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/rockholla/gitspork/v2/internal/config"
+	"github.com/rockholla/gitspork/v2/internal/sdktypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIntegratorUpstreamOwned_neverWritesGitSporkDir(t *testing.T) {
+	upstreamDir, downstreamDir := setupDownstreamOwnedFixture(t, map[string]string{
+		"conf/ci.yml":         "ci: true\n",
+		".gitspork/trust.yml": "migrations:\n  allow: ['*']\n",
+	})
+	writeDownstreamFile(t, downstreamDir, ".gitspork/trust.yml", "migrations:\n  deny: ['*']\n")
+
+	err := (&IntegratorUpstreamOwned{}).Integrate([]config.OwnedEntry{{Pattern: "**.yml"}}, upstreamDir, downstreamDir, sdktypes.NoopLogger())
+	require.ErrorContains(t, err, "would be written under .gitspork")
+	assert.Equal(t, "migrations:\n  deny: ['*']\n", readFileString(t, filepath.Join(downstreamDir, ".gitspork/trust.yml")))
+
+	err = (&IntegratorUpstreamOwned{}).Integrate([]config.OwnedEntry{{From: "conf/*.yml", To: ".gitspork/*.yml"}}, upstreamDir, downstreamDir, sdktypes.NoopLogger())
+	require.ErrorContains(t, err, "would be written under .gitspork")
+	assert.NoFileExists(t, filepath.Join(downstreamDir, ".gitspork/ci.yml"))
+}
@@ -0,0 +1,900 @@
+package integrate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	gogit "github.com/go-git/go-git/v6"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/gobwas/glob"
+	"github.com/rockholla/gitspork/v2/internal/config"
+	"github.com/rockholla/gitspork/v2/internal/logutil"
+	"github.com/rockholla/gitspork/v2/internal/sdktypes"
+)
+
+// migrationCommandWaitDelay bounds how long a killed migration command's
+// output is drained before Wait gives up on it.
+const migrationCommandWaitDelay = 5 * time.Second
+
+// migrationOptions carries the per-run settings for executing migrations, and
+// the integration context exported to their commands as GITSPORK_* variables.
+type migrationOptions struct {
+	// dryRun logs what each pending migration would do without changing the
+	// downstream, running commands, or recording the migration complete.
+	dryRun bool
+	// ctx, when non-nil, cancels a running migration.
+	ctx           context.Context
+	upstreamURL   string
+	prevCommit    string
+	newCommit     string
+	forDriftCheck bool
+	// allowMigrations trusts the upstream to run migration commands, and
+	// noMigrations runs no migrations at all.
+	allowMigrations bool
+	noMigrations    bool
+	// nonInteractive and prompter decide how an untrusted upstream's
+	// migration commands are confirmed.
+	nonInteractive bool
+	prompter       sdktypes.Prompter
+	// driftMigrations is what a drift check does with pending migrations, one
+	// of the sdktypes.DriftMigrations* values, and producedFiles collects the
+	// files they change, keyed by slash-separated path.
+	driftMigrations string
+	producedFiles   map[string]string
+}
+
+// simulateCommands reports whether migration commands are only logged, as in
+// a drift check that simulates migrations.
+func (o migrationOptions) simulateCommands() bool {
+	return o.forDriftCheck && o.driftMigrations == sdktypes.DriftMigrationsSimulate
+}
+
+// sandboxed reports whether migration commands run in a drift-check sandbox.
+func (o migrationOptions) sandboxed() bool {
+	return o.forDriftCheck && o.driftMigrations == sdktypes.DriftMigrationsSandbox
+}
+
+// migrationRun is a single migration in progress.
+type migrationRun struct {
+	ctx            context.Context
+	upstreamPath   string
+	downstreamPath string
+	// dir is where exec and shell commands run.
+	dir    string
+	env    []string
+	dryRun bool
+	// simulate logs exec and shell commands instead of running them, while
+	// declarative steps still apply.
+	simulate bool
+	logger   sdktypes.Logger
+}
+
+func runMigration(migrationInstructions *config.GitSporkConfigMigrationInstructions, upstreamRepoRootPath string, downstreamRepoPath string, opts migrationOptions, logger sdktypes.Logger) error {
+	if opts.dryRun {
+		if migrationInstructions.Exec != "" {
+			logger.Log("(dry run) would exec %s", migrationInstructions.Exec)
+			return nil
+		}
+		run := &migrationRun{ctx: context.Background(), upstreamPath: upstreamRepoRootPath, downstreamPath: downstreamRepoPath, dryRun: true, logger: logger}
+		return run.steps(migrationInstructions.Steps)
+	}
+
+	ctx := opts.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	timeout := migrationInstructions.TimeoutDuration()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	// Commands run in their own process group so the whole tree can be killed
+	// when ctx is done, which also keeps a terminal's Ctrl-C from reaching
+	// them: the caller forwards it by cancelling ctx, as the CLI does.
+
+	env, err := migrationEnviron(migrationInstructions, upstreamRepoRootPath, downstreamRepoPath, opts)
+	if err != nil {
+		return err
+	}
+	run := &migrationRun{
+		ctx:            ctx,
+		upstreamPath:   upstreamRepoRootPath,
+		downstreamPath: downstreamRepoPath,
+		dir:            filepath.Join(downstreamRepoPath, migrationInstructions.WorkingDir),
+		env:            env,
+		simulate:       opts.simulateCommands(),
+		logger:         logger,
+	}
+	if opts.sandboxed() {
+		if err := confineToDownstream(run.dir, downstreamRepoPath); err != nil {
+			return err
+		}
+		sandboxDir, err := os.MkdirTemp("", "gitspork-migration-sandbox-*")
+		if err != nil {
+			return fmt.Errorf("error creating migration sandbox: %v", err)
+		}
+		defer os.RemoveAll(sandboxDir)
+		run.env = sandboxEnviron(env, migrationInstructions.Env, sandboxDir)
+	}
+	if migrationInstructions.Exec != "" {
+		cmd, cmdErr := run.execCommand(migrationInstructions.Exec)
+		if cmdErr == nil {
+			cmdErr = run.runCommand(cmd, nil)
+		}
+		err = cmdErr
+	} else {
+		err = run.steps(migrationInstructions.Steps)
+	}
+	if err != nil && ctx.Err() != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("timed out after %s: %w", timeout, err)
+		}
+		return fmt.Errorf("cancelled: %w", err)
+	}
+	return err
+}
+
+// migrationApplies evaluates a migration's applies_if against the upstream
+// commit the downstream last integrated (empty for a fresh integration), using
+// the history in the upstream clone at upstreamPath. When it doesn't apply,
+// reason says why.
+func migrationApplies(appliesIf *config.GitSporkConfigMigrationAppliesIf, upstreamPath string, prevCommit string, logger sdktypes.Logger) (applies bool, reason string, err error) {
+	fresh := prevCommit == ""
+	switch {
+	case appliesIf.Integration == config.MigrationIntegrationFresh && !fresh:
+		return false, "it only applies to fresh integrations", nil
+	case appliesIf.Integration == config.MigrationIntegrationUpgrade && fresh:
+		return false, "it only applies to upgrades", nil
+	}
+	if appliesIf.PreviousAncestorOf == "" && appliesIf.PreviousBefore == "" {
+		return true, "", nil
+	}
+	if fresh {
+		return false, "it only applies to upgrades from earlier upstream versions", nil
+	}
+	repo, err := gogit.PlainOpenWithOptions(upstreamPath, &gogit.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return false, "", fmt.Errorf("error opening upstream repo at %s: %v", upstreamPath, err)
+	}
+	prev, err := repo.CommitObject(plumbing.NewHash(prevCommit))
+	if errors.Is(err, plumbing.ErrObjectNotFound) {
+		logger.Log("⚠️  previous upstream commit %s isn't in the upstream history, so it can't be compared", prevCommit)
+		return false, fmt.Sprintf("previous upstream commit %s isn't in the upstream history", prevCommit), nil
+	}
+	if err != nil {
+		return false, "", fmt.Errorf("error resolving previous upstream commit %s: %v", prevCommit, err)
+	}
+	for _, cond := range []struct {
+		ref    string
+		strict bool
+	}{{appliesIf.PreviousAncestorOf, false}, {appliesIf.PreviousBefore, true}} {
+		if cond.ref == "" {
+			continue
+		}
+		refCommit, err := resolveUpstreamRevision(repo, cond.ref)
+		if err != nil {
+			return false, "", err
+		}
+		isAncestor, err := prev.IsAncestor(refCommit)
+		if err != nil {
+			return false, "", fmt.Errorf("error comparing upstream commits %s and %s: %v", prevCommit, cond.ref, err)
+		}
+		if cond.strict && prev.Hash == refCommit.Hash {
+			isAncestor = false
+		}
+		if !isAncestor && cond.strict {
+			return false, fmt.Sprintf("previous upstream commit %s isn't older than %s", prevCommit, cond.ref), nil
+		}
+		if !isAncestor {
+			return false, fmt.Sprintf("previous upstream commit %s isn't %s or an ancestor of it", prevCommit, cond.ref), nil
+		}
+	}
+	return true, "", nil
+}
+
+// resolveUpstreamRevision resolves a tag, branch or commit in the upstream
+// clone, where branches other than the checked out one are remote-tracking.
+func resolveUpstreamRevision(repo *gogit.Repository, ref string) (*object.Commit, error) {
+	hash, err := repo.ResolveRevision(plumbing.Revision(ref))
+	if err != nil {
+		hash, err = repo.ResolveRevision(plumbing.Revision("origin/" + ref))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't resolve %q in the upstream history", ref)
+	}
+	commit, err := repo.CommitObject(*hash)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving upstream commit for %q: %v", ref, err)
+	}
+	return commit, nil
+}
+
+// migrationPending reports whether any of a migration's pre_integrate or
+// post_integrate instructions has yet to be recorded as complete.
+func migrationPending(migrationConfig *config.GitSporkConfigMigration, downstreamPath string) (bool, error) {
+	for _, instructions := range []*config.GitSporkConfigMigrationInstructions{migrationConfig.PreIntegrate, migrationConfig.PostIntegrate} {
+		if instructions == nil {
+			continue
+		}
+		completed, err := migrationCompletedInDownstream(instructions.ID, downstreamPath, instructions.Aliases...)
+		if err != nil {
+			return false, fmt.Errorf("error determining if migration %s was already run in downstream: %v", instructions.ID, err)
+		}
+		if !completed {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// skipInapplicableMigration records a migration whose applies_if doesn't hold
+// as not applicable, so it's never reconsidered, except in a dry run or drift
+// check.
+func skipInapplicableMigration(migrationConfig *config.GitSporkConfigMigration, reason string, downstreamPath string, opts migrationOptions, logger sdktypes.Logger) error {
+	for _, instructions := range []*config.GitSporkConfigMigrationInstructions{migrationConfig.PreIntegrate, migrationConfig.PostIntegrate} {
+		if instructions == nil {
+			continue
+		}
+		completed, err := migrationCompletedInDownstream(instructions.ID, downstreamPath, instructions.Aliases...)
+		if err != nil {
+			return fmt.Errorf("error determining if migration %s was already run in downstream: %v", instructions.ID, err)
+		}
+		if completed {
+			continue
+		}
+		logger.Log("⏭️  skipping migration %s, which doesn't apply: %s", instructions.ID, reason)
+		if opts.dryRun || opts.forDriftCheck {
+			continue
+		}
+		record := sdktypes.MigrationRecord{
+			ID:             instructions.ID,
+			Outcome:        sdktypes.MigrationOutcomeNotApplicable,
+			At:             time.Now(),
+			UpstreamCommit: opts.newCommit,
+		}
+		if err := recordMigration(record, downstreamPath); err != nil {
+			return fmt.Errorf("error recording skipped migration: %v", err)
+		}
+	}
+	return nil
+}
+
+// runAndRecordMigration runs a pending migration and, outside of drift checks,
+// records how it went in the downstream state, whether it succeeded or not.
+func runAndRecordMigration(migrationInstructions *config.GitSporkConfigMigrationInstructions, upstreamRepoRootPath string, downstreamRepoPath string, opts migrationOptions, logger sdktypes.Logger) error {
+	if opts.forDriftCheck {
+		return runMigrationForDriftCheck(migrationInstructions, upstreamRepoRootPath, downstreamRepoPath, opts, logger)
+	}
+	start := time.Now()
+	runErr := runMigration(migrationInstructions, upstreamRepoRootPath, downstreamRepoPath, opts, logger)
+	record := sdktypes.MigrationRecord{
+		ID:             migrationInstructions.ID,
+		Outcome:        sdktypes.MigrationOutcomeRan,
+		At:             time.Now(),
+		UpstreamCommit: opts.newCommit,
+		DurationMillis: time.Since(start).Milliseconds(),
+	}
+	if runErr != nil {
+		record.Outcome = sdktypes.MigrationOutcomeFailed
+		record.ExitStatus = migrationExitStatus(runErr)
+		record.Error = runErr.Error()
+	}
+	if err := recordMigration(record, downstreamRepoPath); err != nil {
+		if runErr != nil {
+			return runErr
+		}
+		return fmt.Errorf("error recording successful migration result: %v", err)
+	}
+	return runErr
+}
+
+// runMigrationForDriftCheck runs a migration during drift-check
+// re-integration without recording it, noting each file it changes in
+// opts.producedFiles.
+func runMigrationForDriftCheck(migrationInstructions *config.GitSporkConfigMigrationInstructions, upstreamRepoRootPath string, downstreamRepoPath string, opts migrationOptions, logger sdktypes.Logger) error {
+	before, err := ListWorktreeFiles(downstreamRepoPath)
+	if err != nil {
+		return fmt.Errorf("error listing downstream files before migration %s: %v", migrationInstructions.ID, err)
+	}
+	runErr := runMigration(migrationInstructions, upstreamRepoRootPath, downstreamRepoPath, opts, logger)
+	after, err := ListWorktreeFiles(downstreamRepoPath)
+	if err != nil {
+		return fmt.Errorf("error listing downstream files after migration %s: %v", migrationInstructions.ID, err)
+	}
+	for f, hash := range after {
+		if before[f] != hash {
+			opts.producedFiles[filepath.ToSlash(f)] = migrationInstructions.ID
+		}
+	}
+	for f := range before {
+		if _, ok := after[f]; !ok {
+			opts.producedFiles[filepath.ToSlash(f)] = migrationInstructions.ID
+		}
+	}
+	return runErr
+}
+
+// migrationSandboxEnvKeep are the variables of gitspork's own environment a
+// sandboxed migration command keeps.
+var migrationSandboxEnvKeep = []string{"PATH", "LANG", "LC_ALL", "LC_CTYPE", "TERM", "TZ", "USER", "LOGNAME", "SHELL"}
+
+// migrationSandboxProxy is a closed local port that proxy-aware tools in a
+// sandboxed migration fail fast against.
+const migrationSandboxProxy = "http://127.0.0.1:9"
+
+// sandboxEnviron restricts env for a migration command in a drift-check
+// sandbox: only migrationSandboxEnvKeep, the GITSPORK_* variables and the
+// migration's own env are kept, HOME and TMPDIR point at sandboxDir, and
+// proxy variables point at a closed port as a hint that the network is off
+// limits. It is not a security boundary.
+func sandboxEnviron(env []string, migrationEnv map[string]string, sandboxDir string) []string {
+	var kept []string
+	for _, kv := range env {
+		key, _, _ := strings.Cut(kv, "=")
+		if _, own := migrationEnv[key]; own || slices.Contains(migrationSandboxEnvKeep, key) || strings.HasPrefix(key, "GITSPORK_") {
+			kept = append(kept, kv)
+		}
+	}
+	kept = append(kept,
+		"HOME="+sandboxDir,
+		"TMPDIR="+sandboxDir,
+		"GIT_TERMINAL_PROMPT=0",
+		"GITSPORK_SANDBOX=true",
+		"GITSPORK_NETWORK=none",
+	)
+	for _, proxy := range []string{"http_proxy", "https_proxy", "all_proxy", "HTTP_PROXY", "HTTPS_PROXY", "ALL_PROXY"} {
+		kept = append(kept, proxy+"="+migrationSandboxProxy)
+	}
+	return kept
+}
+
+// confineToDownstream checks that dir, with symlinks resolved, is the
+// downstream root or inside it.
+func confineToDownstream(dir string, downstreamRepoPath string) error {
+	realDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return fmt.Errorf("error resolving migration working directory %s: %v", dir, err)
+	}
+	realRoot, err := filepath.EvalSymlinks(downstreamRepoPath)
+	if err != nil {
+		return fmt.Errorf("error resolving downstream path %s: %v", downstreamRepoPath, err)
+	}
+	if rel, err := filepath.Rel(realRoot, realDir); err != nil || !filepath.IsLocal(rel) {
+		return fmt.Errorf("migration working directory %s resolves outside the downstream", dir)
+	}
+	return nil
+}
+
+// confineStepPath joins a declarative step's file to the downstream, checking
+// that with symlinks resolved it stays inside the downstream and out of
+// .gitspork, so a step can't write through a link to anywhere else.
+func confineStepPath(file string, downstreamRepoPath string) (string, error) {
+	path := filepath.Join(downstreamRepoPath, filepath.FromSlash(file))
+	realRoot, err := filepath.EvalSymlinks(downstreamRepoPath)
+	if err != nil {
+		return "", fmt.Errorf("error resolving downstream path %s: %v", downstreamRepoPath, err)
+	}
+	// The file, or directories leading to it, may not exist yet: resolve the
+	// deepest part that does and keep the rest as is.
+	existing, rest := path, ""
+	realPath, err := filepath.EvalSymlinks(existing)
+	for os.IsNotExist(err) {
+		if _, lstatErr := os.Lstat(existing); lstatErr == nil {
+			return "", fmt.Errorf("%s is a symlink to nothing, which a migration step won't write through", file)
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			break
+		}
+		existing, rest = parent, filepath.Join(filepath.Base(existing), rest)
+		realPath, err = filepath.EvalSymlinks(existing)
+	}
+	if err != nil {
+		return "", fmt.Errorf("error resolving %s: %v", file, err)
+	}
+	rel, err := filepath.Rel(realRoot, filepath.Join(realPath, rest))
+	if err != nil || !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("%s resolves outside the downstream", file)
+	}
+	if config.InGitSporkDir(filepath.ToSlash(rel)) {
+		return "", fmt.Errorf("%s resolves under %s, which only gitspork writes", file, config.GitSporkDirName)
+	}
+	return path, nil
+}
+
+// confineMoveSource is confineStepPath for a move's from. A symlink is renamed
+// rather than followed, so only the directory holding it has to stay within
+// the downstream, wherever the link points.
+func confineMoveSource(file string, downstreamRepoPath string) (string, error) {
+	path := filepath.Join(downstreamRepoPath, filepath.FromSlash(file))
+	info, err := os.Lstat(path)
+	if err != nil || info.Mode()&os.ModeSymlink == 0 {
+		return confineStepPath(file, downstreamRepoPath)
+	}
+	if config.InGitSporkDir(file) {
+		return "", fmt.Errorf("%s is under %s, which only gitspork writes", file, config.GitSporkDirName)
+	}
+	if _, err := confineStepPath(filepath.ToSlash(filepath.Dir(filepath.FromSlash(file))), downstreamRepoPath); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// migrationExitStatus is the exit code of the command that failed a
+// migration, or -1 if it failed some other way.
+func migrationExitStatus(err error) int {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
+
+// migrationEnviron is the environment for a migration's commands: gitspork's
+// own, the integration context as GITSPORK_* variables, then the migration's
+// env.
+func migrationEnviron(migrationInstructions *config.GitSporkConfigMigrationInstructions, upstreamRepoRootPath string, downstreamRepoPath string, opts migrationOptions) ([]string, error) {
+	cachedInputs, err := loadTemplatedInputs(downstreamRepoPath)
+	if err != nil {
+		return nil, fmt.Errorf("error loading templated inputs for the migration environment: %v", err)
+	}
+	inputsJSON, err := json.Marshal(cachedInputs)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding templated inputs for the migration environment: %v", err)
+	}
+	globals, err := loadGlobalInputs(downstreamRepoPath)
+	if err != nil {
+		return nil, fmt.Errorf("error loading global inputs for the migration environment: %v", err)
+	}
+	globalValues := map[string]any{}
+	for _, item := range globals.values {
+		globalValues[fmt.Sprint(item.Key)] = item.Value
+	}
+	globalsJSON, err := json.Marshal(globalValues)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding global inputs for the migration environment: %v", err)
+	}
+	env := append(os.Environ(),
+		"GITSPORK_MIGRATION_ID="+migrationInstructions.ID,
+		"GITSPORK_UPSTREAM_URL="+opts.upstreamURL,
+		"GITSPORK_UPSTREAM_PATH="+upstreamRepoRootPath,
+		"GITSPORK_PREV_COMMIT="+opts.prevCommit,
+		"GITSPORK_NEW_COMMIT="+opts.newCommit,
+		"GITSPORK_DOWNSTREAM_PATH="+downstreamRepoPath,
+		"GITSPORK_INPUTS="+string(inputsJSON),
+		"GITSPORK_GLOBAL_INPUTS="+string(globalsJSON),
+		"GITSPORK_DRIFT_CHECK="+strconv.FormatBool(opts.forDriftCheck),
+	)
+	return appendEnv(env, migrationInstructions.Env), nil
+}
+
+// appendEnv adds vars to env in key order; later entries win.
+func appendEnv(env []string, vars map[string]string) []string {
+	for _, k := range slices.Sorted(maps.Keys(vars)) {
+		env = append(env, k+"="+vars[k])
+	}
+	return env
+}
+
+func (r *migrationRun) steps(steps []config.GitSporkConfigMigrationStep) error {
+	prefix := ""
+	if r.dryRun {
+		prefix = "(dry run) "
+	}
+	for idx, step := range steps {
+		r.logger.Log("%sstep %d/%d: %s", prefix, idx+1, len(steps), describeMigrationStep(step))
+		if err := r.step(step); err != nil {
+			return fmt.Errorf("step %d (%s): %w", idx+1, describeMigrationStep(step), err)
+		}
+	}
+	return nil
+}
+
+// execCommand builds the command for an exec migration or step.
+func (r *migrationRun) execCommand(execLine string) (*exec.Cmd, error) {
+	// strings.Fields splits on any run of whitespace (spaces, tabs, newlines),
+	// so double-spaced or tab-separated commands tokenize correctly. Users
+	// needing arguments that contain literal spaces should invoke a shell
+	// explicitly: `sh -c 'my-script "arg with spaces"'`.
+	execParts := strings.Fields(execLine)
+	if len(execParts) == 0 {
+		return nil, fmt.Errorf("migration exec %q resolved to zero tokens", execLine)
+	}
+	if _, err := os.Stat(filepath.Join(r.upstreamPath, execParts[0])); err == nil {
+		// this is a case where the exec is calling a script that exists in the upstream, so call from that absolute path
+		execParts[0] = filepath.Join(r.upstreamPath, execParts[0])
+	}
+	return exec.CommandContext(r.ctx, execParts[0], execParts[1:]...), nil
+}
+
+// runCommand runs cmd with its output logged and stepEnv added to the
+// migration's environment. Cancelling the run kills cmd's whole process tree.
+func (r *migrationRun) runCommand(cmd *exec.Cmd, stepEnv map[string]string) error {
+	if r.simulate {
+		r.logger.Log("(simulated) not running %s", cmd)
+		return nil
+	}
+	cmd.Stdout = &logutil.LoggerWriter{L: r.logger}
+	cmd.Stderr = &logutil.LoggerWriter{L: r.logger}
+	cmd.Dir = r.dir
+	cmd.Env = appendEnv(slices.Clone(r.env), stepEnv)
+	startProcessGroup(cmd)
+	cmd.Cancel = func() error { return killProcessTree(cmd) }
+	cmd.WaitDelay = migrationCommandWaitDelay
+	return cmd.Run()
+}
+
+// describeMigrationStep is the step's name, or a summary of its operation.
+func describeMigrationStep(step config.GitSporkConfigMigrationStep) string {
+	if step.Name != "" {
+		return step.Name
+	}
+	switch {
+	case step.Move != nil:
+		return fmt.Sprintf("move %s to %s", step.Move.From, step.Move.To)
+	case len(step.Delete) > 0:
+		return fmt.Sprintf("delete %s", strings.Join(step.Delete, ", "))
+	case step.Replace != nil:
+		return fmt.Sprintf("replace %q in %s", step.Replace.Pattern, strings.Join(step.Replace.Files, ", "))
+	case step.Patch != nil:
+		return fmt.Sprintf("patch %s", step.Patch.File)
+	case step.EnsureLine != nil:
+		return fmt.Sprintf("ensure line %q in %s", step.EnsureLine.Line, step.EnsureLine.File)
+	case step.Append != nil:
+		return fmt.Sprintf("append to %s", step.Append.File)
+	case step.Exec != "":
+		return fmt.Sprintf("exec %s", step.Exec)
+	case step.Shell != "":
+		return fmt.Sprintf("shell %s", step.Shell)
+	}
+	return "no-op"
+}
+
+func (r *migrationRun) step(step config.GitSporkConfigMigrationStep) error {
+	switch {
+	case step.Move != nil:
+		return migrateMove(step.Move, r.downstreamPath, r.dryRun, r.logger)
+	case len(step.Delete) > 0:
+		return migrateDelete(step.Delete, r.downstreamPath, r.dryRun, r.logger)
+	case step.Replace != nil:
+		return migrateReplace(step.Replace, r.downstreamPath, r.dryRun, r.logger)
+	case step.Patch != nil:
+		return migratePatch(step.Patch, r.downstreamPath, r.dryRun, r.logger)
+	case step.EnsureLine != nil:
+		return migrateEnsureLine(step.EnsureLine, r.downstreamPath, r.dryRun, r.logger)
+	case step.Append != nil:
+		return migrateAppend(step.Append, r.downstreamPath, r.dryRun, r.logger)
+	case step.Exec != "":
+		cmd, err := r.execCommand(step.Exec)
+		if err != nil || r.dryRun {
+			return err
+		}
+		return r.runCommand(cmd, step.Env)
+	case step.Shell != "":
+		if r.dryRun {
+			return nil
+		}
+		return r.runCommand(exec.CommandContext(r.ctx, "sh", "-c", step.Shell), step.Env)
+	}
+	return nil
+}
+
+func migrateMove(move *config.GitSporkConfigMigrationMove, downstreamRepoPath string, dryRun bool, logger sdktypes.Logger) error {
+	from, err := confineMoveSource(move.From, downstreamRepoPath)
+	if err != nil {
+		return err
+	}
+	to, err := confineStepPath(move.To, downstreamRepoPath)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Lstat(from); os.IsNotExist(err) {
+		logger.Log("⚠️  %s absent in downstream, skipping move", move.From)
+		return nil
+	}
+	if _, err := os.Lstat(to); err == nil {
+		return fmt.Errorf("can't move %s to %s, which already exists", move.From, move.To)
+	}
+	logger.Log("📦 moving %s → %s", move.From, move.To)
+	if dryRun {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+		return fmt.Errorf("error creating directory for %s: %v", move.To, err)
+	}
+	return os.Rename(from, to)
+}
+
+func migrateDelete(patterns []string, downstreamRepoPath string, dryRun bool, logger sdktypes.Logger) error {
+	files, err := matchDownstreamFiles(downstreamRepoPath, patterns)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		logger.Log("🗑️  deleting %s", f)
+		if dryRun {
+			continue
+		}
+		if err := os.Remove(filepath.Join(downstreamRepoPath, f)); err != nil {
+			return fmt.Errorf("error deleting %s: %v", f, err)
+		}
+	}
+	return nil
+}
+
+func migrateReplace(replace *config.GitSporkConfigMigrationReplace, downstreamRepoPath string, dryRun bool, logger sdktypes.Logger) error {
+	re, err := regexp.Compile(replace.Pattern)
+	if err != nil {
+		return fmt.Errorf("invalid pattern %q: %v", replace.Pattern, err)
+	}
+	files, err := matchDownstreamFiles(downstreamRepoPath, replace.Files)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		path, err := confineStepPath(f, downstreamRepoPath)
+		if err != nil {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("error reading %s: %v", f, err)
+		}
+		replaced := re.ReplaceAll(content, []byte(replace.With))
+		if string(replaced) == string(content) {
+			continue
+		}
+		logger.Log("✏️  replacing in %s", f)
+		if dryRun {
+			continue
+		}
+		if err := writeMigratedFile(path, replaced); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func migratePatch(patch *config.GitSporkConfigMigrationPatch, downstreamRepoPath string, dryRun bool, logger sdktypes.Logger) error {
+	path, err := confineStepPath(patch.File, downstreamRepoPath)
+	if err != nil {
+		return err
+	}
+	structuredDataType := ""
+	switch ext := filepath.Ext(patch.File); {
+	case slices.Contains(structuredDataYAMLExtensions, ext):
+		structuredDataType = structuredDataTypeYAML
+	case slices.Contains(structuredDataJSONExtensions, ext):
+		structuredDataType = structuredDataTypeJSON
+	default:
+		return fmt.Errorf("%s is not a supported structured data file, supported: %v", patch.File, append(structuredDataYAMLExtensions, structuredDataJSONExtensions...))
+	}
+	data := newMappingNode()
+	content, err := os.ReadFile(path)
+	switch {
+	case os.IsNotExist(err) && len(patch.Set) == 0:
+		logger.Log("⚠️  %s absent in downstream, skipping patch", patch.File)
+		return nil
+	case os.IsNotExist(err):
+	case err != nil:
+		return fmt.Errorf("error reading %s: %v", patch.File, err)
+	default:
+		parse := parseYAML
+		if structuredDataType == structuredDataTypeJSON {
+			parse = parseJSON
+		}
+		if data, err = parse(content); err != nil {
+			return fmt.Errorf("error parsing %s: %v", patch.File, err)
+		}
+	}
+	changed := false
+	for _, keyPath := range slices.Sorted(maps.Keys(patch.Set)) {
+		segments, err := config.ParseKeyPath(keyPath)
+		if err != nil {
+			return err
+		}
+		if err := setKeyPath(data, segments, yamlValueToNode(patch.Set[keyPath])); err != nil {
+			return fmt.Errorf("error setting %s in %s: %v", keyPath, patch.File, err)
+		}
+		logger.Log("✏️  setting %s in %s", keyPath, patch.File)
+		changed = true
+	}
+	for _, keyPath := range patch.Delete {
+		segments, err := config.ParseKeyPath(keyPath)
+		if err != nil {
+			return err
+		}
+		if !deleteKeyPath(data, segments) {
+			continue
+		}
+		logger.Log("✏️  deleting %s from %s", keyPath, patch.File)
+		changed = true
+	}
+	if !changed || dryRun {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("error creating directory for %s: %v", patch.File, err)
+	}
+	if err := writeStructuredData(data, structuredDataType, path); err != nil {
+		return fmt.Errorf("error writing %s: %v", patch.File, err)
+	}
+	return nil
+}
+
+// setKeyPath sets the value at segments under root, creating missing mappings
+// along the way. A sequence index must exist, or be the sequence's length to
+// append as the last segment.
+func setKeyPath(root *node, segments []config.KeyPathSegment, value *node) error {
+	cur := root
+	for idx, seg := range segments {
+		last := idx == len(segments)-1
+		if seg.Index >= 0 {
+			if cur.kind != nodeSequence {
+				return fmt.Errorf("[%d] indexes something that isn't a sequence", seg.Index)
+			}
+			switch {
+			case seg.Index < len(cur.seq) && last:
+				cur.seq[seg.Index] = value
+			case seg.Index < len(cur.seq):
+				cur = cur.seq[seg.Index]
+			case seg.Index == len(cur.seq) && last:
+				cur.seq = append(cur.seq, value)
+			default:
+				return fmt.Errorf("index %d is out of range", seg.Index)
+			}
+			continue
+		}
+		if cur.kind != nodeMapping {
+			return fmt.Errorf("key %s is under something that isn't a mapping", seg.Key)
+		}
+		if last {
+			cur.mapping.Set(seg.Key, value)
+			continue
+		}
+		next, ok := cur.mapping.Get(seg.Key)
+		if !ok {
+			next = newMappingNode()
+			cur.mapping.Set(seg.Key, next)
+		}
+		cur = next
+	}
+	return nil
+}
+
+// deleteKeyPath removes the value at segments under root, reporting whether
+// there was one.
+func deleteKeyPath(root *node, segments []config.KeyPathSegment) bool {
+	cur := root
+	for idx, seg := range segments {
+		last := idx == len(segments)-1
+		if seg.Index >= 0 {
+			if cur.kind != nodeSequence || seg.Index >= len(cur.seq) {
+				return false
+			}
+			if last {
+				cur.seq = slices.Delete(cur.seq, seg.Index, seg.Index+1)
+				return true
+			}
+			cur = cur.seq[seg.Index]
+			continue
+		}
+		if cur.kind != nodeMapping {
+			return false
+		}
+		next, ok := cur.mapping.Get(seg.Key)
+		if !ok {
+			return false
+		}
+		if last {
+			cur.mapping.Delete(seg.Key)
+			return true
+		}
+		cur = next
+	}
+	return false
+}
+
+func migrateEnsureLine(ensureLine *config.GitSporkConfigMigrationEnsureLine, downstreamRepoPath string, dryRun bool, logger sdktypes.Logger) error {
+	path, err := confineStepPath(ensureLine.File, downstreamRepoPath)
+	if err != nil {
+		return err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error reading %s: %v", ensureLine.File, err)
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		if strings.TrimSuffix(line, "\r") == ensureLine.Line {
+			return nil
+		}
+	}
+	logger.Log("✏️  adding line %q to %s", ensureLine.Line, ensureLine.File)
+	if dryRun {
+		return nil
+	}
+	if len(content) > 0 && !strings.HasSuffix(string(content), "\n") {
+		content = append(content, '\n')
+	}
+	return writeMigratedFile(path, append(content, ensureLine.Line+"\n"...))
+}
+
+func migrateAppend(appendInstr *config.GitSporkConfigMigrationAppend, downstreamRepoPath string, dryRun bool, logger sdktypes.Logger) error {
+	path, err := confineStepPath(appendInstr.File, downstreamRepoPath)
+	if err != nil {
+		return err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error reading %s: %v", appendInstr.File, err)
+	}
+	logger.Log("✏️  appending to %s", appendInstr.File)
+	if dryRun {
+		return nil
+	}
+	return writeMigratedFile(path, append(content, appendInstr.Content...))
+}
+
+// writeMigratedFile writes content to path, keeping an existing file's mode.
+func writeMigratedFile(path string, content []byte) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("error creating directory for %s: %v", path, err)
+	}
+	if err := os.WriteFile(path, content, mode); err != nil {
+		return fmt.Errorf("error writing %s: %v", path, err)
+	}
+	return nil
+}
+
+// matchDownstreamFiles returns the slash-separated paths of the downstream's
+// files, outside .git and .gitspork, matching any of patterns.
+func matchDownstreamFiles(downstreamRepoPath string, patterns []string) ([]string, error) {
+	globs := make([]glob.Glob, 0, len(patterns))
+	for _, p := range patterns {
+		g, err := glob.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %v", p, err)
+		}
+		globs = append(globs, g)
+	}
+	var files []string
+	err := filepath.WalkDir(downstreamRepoPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" || (filepath.Dir(path) == filepath.Clean(downstreamRepoPath) && config.InGitSporkDir(d.Name())) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(downstreamRepoPath, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if slices.ContainsFunc(globs, func(g glob.Glob) bool { return g.Match(rel) }) {
+			files = append(files, rel)
+		}
+		return nil
+	})
+	return files, err
+}
@@ -0,0 +1,20 @@
+//go:build windows
+
+package integrate
+
+import (
+	"os/exec"
+	"strconv"
+)
+
+// startProcessGroup is a no-op on Windows, where taskkill /T finds the tree
+// from the process itself.
+func startProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessTree kills cmd and every process it spawned.
+func killProcessTree(cmd *exec.Cmd) error {
+	if err := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid)).Run(); err != nil {
+		return cmd.Process.Kill()
+	}
+	return nil
+}
@@ -0,0 +1,265 @@
+package integrate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/rockholla/gitspork/v2/internal/config"
+	"github.com/rockholla/gitspork/v2/internal/sdktypes"
+)
+
+// loadMigrationConfigs parses each migration file the upstream config lists,
+// keyed by its path, with the IDs of its instructions assigned.
+func loadMigrationConfigs(gitSporkConfig *config.GitSporkConfig, upstreamPath string) (map[string]*config.GitSporkConfigMigration, error) {
+	migrationConfigs := map[string]*config.GitSporkConfigMigration{}
+	pathsByID := map[string]string{}
+	for _, migrationConfigPath := range gitSporkConfig.Migrations {
+		migrationConfig, err := config.ParseMigrationConfig(filepath.Join(upstreamPath, migrationConfigPath))
+		if err != nil {
+			return nil, fmt.Errorf("error parsing migration config: %v", err)
+		}
+		migrationConfig.SetIDs(migrationConfigPath)
+		id := migrationConfigPath
+		if migrationConfig.ID != "" {
+			id = migrationConfig.ID
+		}
+		if otherPath, ok := pathsByID[id]; ok {
+			return nil, fmt.Errorf("migrations %s and %s have the same id %s", otherPath, migrationConfigPath, id)
+		}
+		pathsByID[id] = migrationConfigPath
+		migrationConfigs[migrationConfigPath] = migrationConfig
+	}
+	return migrationConfigs, nil
+}
+
+// findMigrationRecord returns the downstream's record of the migration under
+// any of migrationIDs, preferring one that marks it complete, or nil.
+func findMigrationRecord(state *sdktypes.DownstreamState, migrationIDs ...string) *sdktypes.MigrationRecord {
+	var failed *sdktypes.MigrationRecord
+	for _, id := range migrationIDs {
+		for i, record := range state.MigrationsComplete {
+			if record.ID != id {
+				continue
+			}
+			if record.Complete() {
+				return &state.MigrationsComplete[i]
+			}
+			if failed == nil {
+				failed = &state.MigrationsComplete[i]
+			}
+		}
+	}
+	return failed
+}
+
+func migrationCompletedInDownstream(migrationID string, downstreamRepoPath string, aliases ...string) (bool, error) {
+	state, err := LoadDownstreamState(downstreamRepoPath)
+	if err != nil {
+		return false, err
+	}
+	record := findMigrationRecord(state, append([]string{migrationID}, aliases...)...)
+	return record != nil && record.Complete(), nil
+}
+
+// recordMigration saves record in the downstream state, replacing any earlier
+// record of the same migration.
+func recordMigration(record sdktypes.MigrationRecord, downstreamRepoPath string) error {
+	state, err := LoadDownstreamState(downstreamRepoPath)
+	if err != nil {
+		return err
+	}
+	if idx := slices.IndexFunc(state.MigrationsComplete, func(r sdktypes.MigrationRecord) bool { return r.ID == record.ID }); idx >= 0 {
+		state.MigrationsComplete[idx] = record
+	} else {
+		state.MigrationsComplete = append(state.MigrationsComplete, record)
+	}
+	return SaveDownstreamState(downstreamRepoPath, state)
+}
+
+// adoptMigrationAlias moves the record of a migration completed under one of
+// its aliases to its current ID, so the downstream state, and commands like
+// `gitspork migrations rerun`, use the ID the upstream now gives it.
+func adoptMigrationAlias(instructions *config.GitSporkConfigMigrationInstructions, downstreamRepoPath string) error {
+	state, err := LoadDownstreamState(downstreamRepoPath)
+	if err != nil {
+		return err
+	}
+	record := findMigrationRecord(state, append([]string{instructions.ID}, instructions.Aliases...)...)
+	if record == nil || !record.Complete() || record.ID == instructions.ID {
+		return nil
+	}
+	aliasID, adopted := record.ID, *record
+	adopted.ID = instructions.ID
+	state.MigrationsComplete = slices.DeleteFunc(state.MigrationsComplete, func(r sdktypes.MigrationRecord) bool {
+		return r.ID == aliasID || r.ID == instructions.ID
+	})
+	state.MigrationsComplete = append(state.MigrationsComplete, adopted)
+	return SaveDownstreamState(downstreamRepoPath, state)
+}
+
+// RerunMigration removes the downstream's record of a migration so the next
+// integrate runs it again. migrationID is either a migration instruction ID,
+// like "0001-split-services:pre_integrate", or a migration's ID, which covers
+// both its pre_integrate and post_integrate instructions. It returns the IDs
+// of the records removed.
+func RerunMigration(downstreamRepoPath string, migrationID string) ([]string, error) {
+	state, err := LoadDownstreamState(downstreamRepoPath)
+	if err != nil {
+		return nil, fmt.Errorf("error loading downstream state: %v", err)
+	}
+	var removed []string
+	state.MigrationsComplete = slices.DeleteFunc(state.MigrationsComplete, func(record sdktypes.MigrationRecord) bool {
+		baseID, _ := config.BaseMigrationID(record.ID)
+		if record.ID != migrationID && baseID != migrationID {
+			return false
+		}
+		removed = append(removed, record.ID)
+		return true
+	})
+	if len(removed) == 0 {
+		return nil, fmt.Errorf("no migration %s is recorded in the downstream", migrationID)
+	}
+	if err := SaveDownstreamState(downstreamRepoPath, state); err != nil {
+		return nil, fmt.Errorf("error saving downstream state: %v", err)
+	}
+	return removed, nil
+}
+
+// SkipMigration records a migration instruction, like
+// "0001-split-services:pre_integrate", as skipped so integrate never runs it.
+func SkipMigration(downstreamRepoPath string, migrationID string) error {
+	if _, phase := config.BaseMigrationID(migrationID); phase == "" {
+		return fmt.Errorf("invalid migration %s: expects an ID ending in :%s or :%s", migrationID, config.MigrationPhasePreIntegrate, config.MigrationPhasePostIntegrate)
+	}
+	state, err := LoadDownstreamState(downstreamRepoPath)
+	if err != nil {
+		return fmt.Errorf("error loading downstream state: %v", err)
+	}
+	if record := findMigrationRecord(state, migrationID); record != nil && record.Complete() {
+		return fmt.Errorf("migration %s is already recorded as %s", migrationID, record.Outcome)
+	}
+	return recordMigration(sdktypes.MigrationRecord{
+		ID:      migrationID,
+		Outcome: sdktypes.MigrationOutcomeSkipped,
+		At:      time.Now(),
+	}, downstreamRepoPath)
+}
+
+// MigrationStatus reports every migration the downstream's upstreams define,
+// and what the downstream has recorded of each, without integrating.
+func MigrationStatus(opts *sdktypes.MigrationStatusOptions) (*sdktypes.MigrationStatusReport, error) {
+	report := &sdktypes.MigrationStatusReport{}
+
+	if opts.Logger == nil {
+		opts.Logger = sdktypes.NoopLogger()
+	}
+	downstreamRepoPath, err := filepath.Abs(opts.DownstreamRepoPath)
+	if err != nil {
+		return report, fmt.Errorf("unable to determine local downstream repo path: %v", err)
+	}
+	state, err := LoadDownstreamState(downstreamRepoPath)
+	if err != nil {
+		return report, fmt.Errorf("error loading downstream state: %v", err)
+	}
+
+	upstreams := opts.Upstreams
+	if len(upstreams) == 0 {
+		for _, u := range state.Upstreams {
+			upstreams = append(upstreams, sdktypes.UpstreamSpec{URL: u.URL, Version: u.Version, Subpath: u.Subpath})
+		}
+	}
+	if len(upstreams) == 0 {
+		return report, fmt.Errorf("no upstream specified and none recorded in the downstream state: set Upstreams on MigrationStatusOptions")
+	}
+
+	for _, upstream := range upstreams {
+		statuses, err := upstreamMigrationStatus(opts, downstreamRepoPath, state, upstream)
+		if err != nil {
+			return report, err
+		}
+		report.Migrations = append(report.Migrations, statuses...)
+	}
+	return report, nil
+}
+
+// upstreamMigrationStatus clones a single upstream, as integrate would, and
+// reports its migrations.
+func upstreamMigrationStatus(opts *sdktypes.MigrationStatusOptions, downstreamRepoPath string, state *sdktypes.DownstreamState, upstream sdktypes.UpstreamSpec) ([]sdktypes.MigrationStatusEntry, error) {
+	upstream.Subpath = config.NormalizeUpstreamPath(upstream.Subpath)
+	prevHash := ""
+	key := NormalizeUpstreamURL(upstream.URL, upstream.Subpath)
+	for _, u := range state.Upstreams {
+		if NormalizeUpstreamURL(u.URL, u.Subpath) == key {
+			prevHash = u.CommitHash
+			break
+		}
+	}
+
+	cloneDir, err := os.MkdirTemp("", config.GitSpork)
+	if err != nil {
+		return nil, fmt.Errorf("error creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(cloneDir)
+
+	req := &internalRequest{
+		Logger:                 opts.Logger,
+		DownstreamRepoPath:     downstreamRepoPath,
+		prevUpstreamCommitHash: prevHash,
+		cacheTTL:               opts.CacheTTL,
+		noCache:                opts.NoCache,
+		progress:               opts.Progress,
+	}
+	opts.Logger.Log("cloning gitspork upstream repo %s", upstream.URL)
+	if _, err := cloneUpstreamForIntegrate(cloneDir, req, upstream); err != nil {
+		return nil, err
+	}
+	upstreamRootPath := filepath.Join(cloneDir, upstream.Subpath)
+	gitSporkConfig, err := getGitSporkConfig(upstreamRootPath)
+	if err != nil {
+		return nil, err
+	}
+	migrationConfigs, err := loadMigrationConfigs(gitSporkConfig, upstreamRootPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []sdktypes.MigrationStatusEntry
+	for _, migrationConfigPath := range gitSporkConfig.Migrations {
+		migrationConfig := migrationConfigs[migrationConfigPath]
+		evaluated, applies, reason := false, true, ""
+		for _, instructions := range []*config.GitSporkConfigMigrationInstructions{migrationConfig.PreIntegrate, migrationConfig.PostIntegrate} {
+			if instructions == nil {
+				continue
+			}
+			status := sdktypes.MigrationStatusEntry{
+				UpstreamURL: upstream.URL,
+				ID:          instructions.ID,
+				Path:        migrationConfigPath,
+				Status:      sdktypes.MigrationStatusPending,
+			}
+			if record := findMigrationRecord(state, append([]string{instructions.ID}, instructions.Aliases...)...); record != nil {
+				recorded := *record
+				status.Record = &recorded
+				status.Status = recorded.Outcome
+			}
+			if status.Record == nil || !status.Record.Complete() {
+				if migrationConfig.AppliesIf != nil && !evaluated {
+					applies, reason, err = migrationApplies(migrationConfig.AppliesIf, upstreamRootPath, prevHash, opts.Logger)
+					if err != nil {
+						return nil, fmt.Errorf("error evaluating applies_if of migration %s: %v", migrationConfigPath, err)
+					}
+					evaluated = true
+				}
+				if !applies {
+					status.Status = sdktypes.MigrationOutcomeNotApplicable
+					status.Reason = reason
+				}
+			}
+			statuses = append(statuses, status)
+		}
+	}
+	return statuses, nil
+}
@@ -0,0 +1,256 @@
+package integrate
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/rockholla/gitspork/v2/internal/config"
+	"github.com/rockholla/gitspork/v2/internal/sdktypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// migrationRecordIDs lists the IDs recorded in the downstream state at dir.
+func migrationRecordIDs(t *testing.T, dir string) []string {
+	t.Helper()
+	state, err := LoadDownstreamState(dir)
+	require.NoError(t, err)
+	var ids []string
+	for _, record := range state.MigrationsComplete {
+		ids = append(ids, record.ID)
+	}
+	return ids
+}
+
+func Test_migrationCompletedInDownstream(t *testing.T) {
+	t.Run("returns true when ID is present in state", func(t *testing.T) {
+		dir := t.TempDir()
+		state := &sdktypes.DownstreamState{MigrationsComplete: []sdktypes.MigrationRecord{{ID: "m/one:pre_integrate"}, {ID: "m/two:post_integrate"}}}
+		require.NoError(t, SaveDownstreamState(dir, state))
+
+		got, err := migrationCompletedInDownstream("m/one:pre_integrate", dir)
+		require.NoError(t, err)
+		assert.True(t, got)
+	})
+
+	t.Run("returns false when ID is not in state", func(t *testing.T) {
+		dir := t.TempDir()
+		state := &sdktypes.DownstreamState{MigrationsComplete: []sdktypes.MigrationRecord{{ID: "m/one:pre_integrate"}}}
+		require.NoError(t, SaveDownstreamState(dir, state))
+
+		got, err := migrationCompletedInDownstream("m/absent:post_integrate", dir)
+		require.NoError(t, err)
+		assert.False(t, got)
+	})
+
+	t.Run("returns true when an alias is present in state", func(t *testing.T) {
+		dir := t.TempDir()
+		state := &sdktypes.DownstreamState{MigrationsComplete: []sdktypes.MigrationRecord{{ID: "old/path.yml:pre_integrate"}}}
+		require.NoError(t, SaveDownstreamState(dir, state))
+
+		got, err := migrationCompletedInDownstream("0001:pre_integrate", dir, "old/path.yml:pre_integrate")
+		require.NoError(t, err)
+		assert.True(t, got)
+	})
+
+	t.Run("returns false when the recorded run failed", func(t *testing.T) {
+		dir := t.TempDir()
+		state := &sdktypes.DownstreamState{MigrationsComplete: []sdktypes.MigrationRecord{{ID: "m/one:pre_integrate", Outcome: sdktypes.MigrationOutcomeFailed}}}
+		require.NoError(t, SaveDownstreamState(dir, state))
+
+		got, err := migrationCompletedInDownstream("m/one:pre_integrate", dir)
+		require.NoError(t, err)
+		assert.False(t, got)
+	})
+
+	t.Run("returns false against a fresh downstream with no state file", func(t *testing.T) {
+		got, err := migrationCompletedInDownstream("anything", t.TempDir())
+		require.NoError(t, err)
+		assert.False(t, got)
+	})
+
+	t.Run("reads legacy ID-only records", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, ".gitspork"), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, ".gitspork", "downstream-state.json"), []byte(`{"migrations_complete":["m/one:pre_integrate"]}`), 0644))
+
+		got, err := migrationCompletedInDownstream("m/one:pre_integrate", dir)
+		require.NoError(t, err)
+		assert.True(t, got)
+		state, err := LoadDownstreamState(dir)
+		require.NoError(t, err)
+		assert.Equal(t, []sdktypes.MigrationRecord{{ID: "m/one:pre_integrate", Outcome: sdktypes.MigrationOutcomeRan}}, state.MigrationsComplete)
+	})
+}
+
+func Test_recordMigration(t *testing.T) {
+	t.Run("creates fresh state and records the migration", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, recordMigration(sdktypes.MigrationRecord{ID: "m/one:pre_integrate", Outcome: sdktypes.MigrationOutcomeRan}, dir))
+		assert.Equal(t, []string{"m/one:pre_integrate"}, migrationRecordIDs(t, dir))
+	})
+
+	t.Run("recording the same ID twice replaces the entry", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, recordMigration(sdktypes.MigrationRecord{ID: "m/one:pre_integrate", Outcome: sdktypes.MigrationOutcomeFailed, ExitStatus: 3}, dir))
+		require.NoError(t, recordMigration(sdktypes.MigrationRecord{ID: "m/one:pre_integrate", Outcome: sdktypes.MigrationOutcomeRan}, dir))
+
+		state, err := LoadDownstreamState(dir)
+		require.NoError(t, err)
+		assert.Equal(t, []sdktypes.MigrationRecord{{ID: "m/one:pre_integrate", Outcome: sdktypes.MigrationOutcomeRan}}, state.MigrationsComplete,
+			"recordMigration must not duplicate entries — this guarantees migrations run at most once")
+	})
+
+	t.Run("recording distinct IDs preserves order and grows the list", func(t *testing.T) {
+		dir := t.TempDir()
+		for _, id := range []string{"m/one:pre_integrate", "m/two:post_integrate", "m/three:pre_integrate"} {
+			require.NoError(t, recordMigration(sdktypes.MigrationRecord{ID: id}, dir))
+		}
+		assert.Equal(t, []string{"m/one:pre_integrate", "m/two:post_integrate", "m/three:pre_integrate"}, migrationRecordIDs(t, dir))
+	})
+}
+
+func Test_runAndRecordMigration(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX sh script")
+	}
+	upstreamDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(upstreamDir, "fail.sh"), []byte("#!/bin/sh\nexit 3\n"), 0755))
+
+	t.Run("records a successful run", func(t *testing.T) {
+		dir := t.TempDir()
+		instructions := &config.GitSporkConfigMigrationInstructions{ID: "m:pre_integrate", Exec: "true"}
+		require.NoError(t, runAndRecordMigration(instructions, upstreamDir, dir, migrationOptions{newCommit: "abc123"}, sdktypes.NoopLogger()))
+
+		state, err := LoadDownstreamState(dir)
+		require.NoError(t, err)
+		require.Len(t, state.MigrationsComplete, 1)
+		record := state.MigrationsComplete[0]
+		assert.Equal(t, sdktypes.MigrationOutcomeRan, record.Outcome)
+		assert.Equal(t, "abc123", record.UpstreamCommit)
+		assert.Equal(t, 0, record.ExitStatus)
+		assert.False(t, record.At.IsZero())
+	})
+
+	t.Run("records a failed run with its exit status", func(t *testing.T) {
+		dir := t.TempDir()
+		instructions := &config.GitSporkConfigMigrationInstructions{ID: "m:pre_integrate", Exec: "./fail.sh"}
+		require.Error(t, runAndRecordMigration(instructions, upstreamDir, dir, migrationOptions{}, sdktypes.NoopLogger()))
+
+		state, err := LoadDownstreamState(dir)
+		require.NoError(t, err)
+		require.Len(t, state.MigrationsComplete, 1)
+		record := state.MigrationsComplete[0]
+		assert.Equal(t, sdktypes.MigrationOutcomeFailed, record.Outcome)
+		assert.Equal(t, 3, record.ExitStatus)
+		assert.Contains(t, record.Error, "exit status 3")
+		completed, err := migrationCompletedInDownstream("m:pre_integrate", dir)
+		require.NoError(t, err)
+		assert.False(t, completed, "a failed migration runs again")
+	})
+
+	t.Run("records nothing for a drift check", func(t *testing.T) {
+		dir := t.TempDir()
+		instructions := &config.GitSporkConfigMigrationInstructions{ID: "m:pre_integrate", Exec: "true"}
+		require.NoError(t, runAndRecordMigration(instructions, upstreamDir, dir, migrationOptions{forDriftCheck: true}, sdktypes.NoopLogger()))
+		assert.Empty(t, migrationRecordIDs(t, dir))
+	})
+}
+
+func Test_migrationExitStatus(t *testing.T) {
+	assert.Equal(t, -1, migrationExitStatus(errors.New("not a process")))
+}
+
+func Test_adoptMigrationAlias(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, recordMigration(sdktypes.MigrationRecord{ID: "old.yml:pre_integrate", Outcome: sdktypes.MigrationOutcomeRan, UpstreamCommit: "abc123"}, dir))
+	instructions := &config.GitSporkConfigMigrationInstructions{ID: "0001:pre_integrate", Aliases: []string{"old.yml:pre_integrate"}}
+
+	require.NoError(t, adoptMigrationAlias(instructions, dir))
+	require.NoError(t, adoptMigrationAlias(instructions, dir))
+	assert.Equal(t, []string{"0001:pre_integrate"}, migrationRecordIDs(t, dir))
+	state, err := LoadDownstreamState(dir)
+	require.NoError(t, err)
+	assert.Equal(t, "abc123", state.MigrationsComplete[0].UpstreamCommit)
+}
+
+func Test_loadMigrationConfigs(t *testing.T) {
+	write := func(t *testing.T, dir, path, content string) {
+		t.Helper()
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, path)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, path), []byte(content), 0644))
+	}
+
+	t.Run("assigns IDs and aliases", func(t *testing.T) {
+		dir := t.TempDir()
+		write(t, dir, "m/a.yml", "pre_integrate:\n  exec: a.sh\n")
+		write(t, dir, "m/b.yml", "id: split\naliases: [legacy-split]\npost_integrate:\n  exec: b.sh\n")
+		migrationConfigs, err := loadMigrationConfigs(&config.GitSporkConfig{Migrations: []string{"m/a.yml", "m/b.yml"}}, dir)
+		require.NoError(t, err)
+
+		assert.Equal(t, "m/a.yml:pre_integrate", migrationConfigs["m/a.yml"].PreIntegrate.ID)
+		assert.Empty(t, migrationConfigs["m/a.yml"].PreIntegrate.Aliases)
+		assert.Equal(t, "split:post_integrate", migrationConfigs["m/b.yml"].PostIntegrate.ID)
+		assert.Equal(t, []string{"m/b.yml:post_integrate", "legacy-split:post_integrate"}, migrationConfigs["m/b.yml"].PostIntegrate.Aliases)
+	})
+
+	t.Run("rejects duplicate IDs", func(t *testing.T) {
+		dir := t.TempDir()
+		write(t, dir, "m/a.yml", "id: same\npre_integrate:\n  exec: a.sh\n")
+		write(t, dir, "m/b.yml", "id: same\npre_integrate:\n  exec: b.sh\n")
+		_, err := loadMigrationConfigs(&config.GitSporkConfig{Migrations: []string{"m/a.yml", "m/b.yml"}}, dir)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "migrations m/a.yml and m/b.yml have the same id same")
+	})
+}
+
+func Test_RerunMigration(t *testing.T) {
+	seed := func(t *testing.T) string {
+		dir := t.TempDir()
+		for _, id := range []string{"a:pre_integrate", "a:post_integrate", "b:pre_integrate"} {
+			require.NoError(t, recordMigration(sdktypes.MigrationRecord{ID: id, Outcome: sdktypes.MigrationOutcomeRan}, dir))
+		}
+		return dir
+	}
+
+	t.Run("a single instruction", func(t *testing.T) {
+		dir := seed(t)
+		removed, err := RerunMigration(dir, "a:post_integrate")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a:post_integrate"}, removed)
+		assert.Equal(t, []string{"a:pre_integrate", "b:pre_integrate"}, migrationRecordIDs(t, dir))
+	})
+
+	t.Run("both phases of a migration", func(t *testing.T) {
+		dir := seed(t)
+		removed, err := RerunMigration(dir, "a")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a:pre_integrate", "a:post_integrate"}, removed)
+		assert.Equal(t, []string{"b:pre_integrate"}, migrationRecordIDs(t, dir))
+	})
+
+	t.Run("an unrecorded migration", func(t *testing.T) {
+		_, err := RerunMigration(seed(t), "c")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "no migration c is recorded")
+	})
+}
+
+func Test_SkipMigration(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, SkipMigration(dir, "a:pre_integrate"))
+	completed, err := migrationCompletedInDownstream("a:pre_integrate", dir)
+	require.NoError(t, err)
+	assert.True(t, completed)
+
+	err = SkipMigration(dir, "a:pre_integrate")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "already recorded as skipped")
+
+	err = SkipMigration(dir, "a")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "expects an ID ending in :pre_integrate or :post_integrate")
+}
@@ -1,12 +1,19 @@
 package integrate
 
 import (
+	"context"
+	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
 	"testing"
+	"time"
 
+	gogit "github.com/go-git/go-git/v6"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/object"
 	"github.com/rockholla/gitspork/v2/internal/config"
 	"github.com/rockholla/gitspork/v2/internal/sdktypes"
 	"github.com/stretchr/testify/assert"
@@ -15,12 +22,12 @@ import (
 
 func Test_runMigration(t *testing.T) {
 	t.Run("empty Exec is a no-op", func(t *testing.T) {
-		err := runMigration(&config.GitSporkConfigMigrationInstructions{Exec: ""}, t.TempDir(), t.TempDir(), sdktypes.NoopLogger())
+		err := runMigration(&config.GitSporkConfigMigrationInstructions{Exec: ""}, t.TempDir(), t.TempDir(), migrationOptions{}, sdktypes.NoopLogger())
 		assert.NoError(t, err)
 	})
 
 	t.Run("whitespace-only Exec returns zero-token error", func(t *testing.T) {
-		err := runMigration(&config.GitSporkConfigMigrationInstructions{Exec: "   \t  "}, t.TempDir(), t.TempDir(), sdktypes.NoopLogger())
+		err := runMigration(&config.GitSporkConfigMigrationInstructions{Exec: "   \t  "}, t.TempDir(), t.TempDir(), migrationOptions{}, sdktypes.NoopLogger())
 		require.Error(t, err)
 		assert.Contains(t, err.Error(), "resolved to zero tokens")
 	})
@@ -42,7 +49,7 @@ func Test_runMigration(t *testing.T) {
 		scriptContents := "#!/bin/sh\npwd -P > cwd.txt\necho migration ran > ran.txt\n"
 		require.NoError(t, os.WriteFile(filepath.Join(upstreamDir, scriptRel), []byte(scriptContents), 0755))
 
-		err := runMigration(&config.GitSporkConfigMigrationInstructions{Exec: "./" + scriptRel}, upstreamDir, downstreamDir, sdktypes.NoopLogger())
+		err := runMigration(&config.GitSporkConfigMigrationInstructions{Exec: "./" + scriptRel}, upstreamDir, downstreamDir, migrationOptions{}, sdktypes.NoopLogger())
 		require.NoError(t, err)
 
 		// Script wrote to cwd — asserts it ran with cmd.Dir == downstreamDir,
@@ -71,7 +78,7 @@ func Test_runMigration(t *testing.T) {
 		downstreamDir := t.TempDir()
 		// "true" is not a file at upstreamDir/true; runMigration should
 		// leave execParts[0] alone and exec.LookPath resolves it from $PATH.
-		err := runMigration(&config.GitSporkConfigMigrationInstructions{Exec: "true"}, upstreamDir, downstreamDir, sdktypes.NoopLogger())
+		err := runMigration(&config.GitSporkConfigMigrationInstructions{Exec: "true"}, upstreamDir, downstreamDir, migrationOptions{}, sdktypes.NoopLogger())
 		assert.NoError(t, err)
 	})
 
@@ -79,7 +86,7 @@ func Test_runMigration(t *testing.T) {
 		upstreamDir := t.TempDir()
 		downstreamDir := t.TempDir()
 		require.NoError(t, os.WriteFile(filepath.Join(upstreamDir, "fail.sh"), []byte("#!/bin/sh\nexit 3\n"), 0755))
-		err := runMigration(&config.GitSporkConfigMigrationInstructions{Exec: "./fail.sh"}, upstreamDir, downstreamDir, sdktypes.NoopLogger())
+		err := runMigration(&config.GitSporkConfigMigrationInstructions{Exec: "./fail.sh"}, upstreamDir, downstreamDir, migrationOptions{}, sdktypes.NoopLogger())
 		require.Error(t, err, "subprocess non-zero exit must propagate")
 		assert.Contains(t, err.Error(), "exit status 3")
 	})
@@ -93,7 +100,7 @@ func Test_runMigration(t *testing.T) {
 		require.NoError(t, os.WriteFile(filepath.Join(upstreamDir, "args.sh"), []byte(scriptContents), 0755))
 
 		// Two tabs, three spaces, mixed — should still yield three arguments.
-		err := runMigration(&config.GitSporkConfigMigrationInstructions{Exec: "./args.sh\talpha  beta \tgamma"}, upstreamDir, downstreamDir, sdktypes.NoopLogger())
+		err := runMigration(&config.GitSporkConfigMigrationInstructions{Exec: "./args.sh\talpha  beta \tgamma"}, upstreamDir, downstreamDir, migrationOptions{}, sdktypes.NoopLogger())
 		require.NoError(t, err)
 
 		got, err := os.ReadFile(filepath.Join(downstreamDir, "argv.txt"))
@@ -102,63 +109,321 @@ func Test_runMigration(t *testing.T) {
 	})
 }
 
-func Test_migrationCompletedInDownstream(t *testing.T) {
-	t.Run("returns true when ID is present in state", func(t *testing.T) {
-		dir := t.TempDir()
-		state := &sdktypes.DownstreamState{MigrationsComplete: []string{"m/one:pre_integrate", "m/two:post_integrate"}}
-		require.NoError(t, SaveDownstreamState(dir, state))
+func Test_runMigration_steps(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell steps use POSIX sh")
+	}
+	setup := func(t *testing.T) string {
+		t.Helper()
+		downstreamDir := t.TempDir()
+		for path, content := range map[string]string{
+			".ci/config.yml":          "ci: true\n",
+			"scripts/legacy-build.sh": "#!/bin/sh\n",
+			"scripts/keep.sh":         "#!/bin/sh\n",
+			"cmd/main.go":             "import \"example.com/old/billing\"\n",
+			"package.json":            "{\n  \"name\": \"billing\",\n  \"scripts\": {\n    \"legacy\": \"make\"\n  }\n}",
+			"config.yml":              "jobs:\n  build:\n    steps:\n    - uses: checkout@v3\n",
+			".gitignore":              "/bin",
+		} {
+			require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(downstreamDir, path)), 0755))
+			require.NoError(t, os.WriteFile(filepath.Join(downstreamDir, path), []byte(content), 0644))
+		}
+		return downstreamDir
+	}
+	instructions := &config.GitSporkConfigMigrationInstructions{Steps: []config.GitSporkConfigMigrationStep{
+		{Move: &config.GitSporkConfigMigrationMove{From: ".ci/config.yml", To: ".github/ci.yml"}},
+		{Move: &config.GitSporkConfigMigrationMove{From: "absent.txt", To: "moved.txt"}},
+		{Delete: []string{"scripts/legacy-*.sh"}},
+		{Replace: &config.GitSporkConfigMigrationReplace{Files: []string{"**.go"}, Pattern: "example.com/old/([a-z]+)", With: "example.com/new/${1}"}},
+		{Patch: &config.GitSporkConfigMigrationPatch{
+			File:   "package.json",
+			Set:    map[string]any{"$.scripts.lint": "eslint .", "engines.node": map[string]any{"min": 20}},
+			Delete: []string{"$.scripts.legacy", "$.absent"},
+		}},
+		{Patch: &config.GitSporkConfigMigrationPatch{File: "config.yml", Set: map[string]any{"jobs.build.steps[0].uses": "checkout@v4", "jobs.build.steps[1]": map[string]any{"run": "make"}}}},
+		{EnsureLine: &config.GitSporkConfigMigrationEnsureLine{File: ".gitignore", Line: "/dist"}},
+		{EnsureLine: &config.GitSporkConfigMigrationEnsureLine{File: ".gitignore", Line: "/bin"}},
+		{Append: &config.GitSporkConfigMigrationAppend{File: "CHANGELOG.md", Content: "- v2 layout\n"}},
+		{Shell: `printf '%s' "$GREETING" > shell.txt`, Env: map[string]string{"GREETING": "hi there"}},
+	}}
 
-		got, err := migrationCompletedInDownstream("m/one:pre_integrate", dir)
-		require.NoError(t, err)
-		assert.True(t, got)
+	t.Run("steps run in order", func(t *testing.T) {
+		downstreamDir := setup(t)
+		require.NoError(t, runMigration(instructions, t.TempDir(), downstreamDir, migrationOptions{}, sdktypes.NoopLogger()))
+
+		assert.NoFileExists(t, filepath.Join(downstreamDir, ".ci/config.yml"))
+		assert.Equal(t, "ci: true\n", readFileString(t, filepath.Join(downstreamDir, ".github/ci.yml")))
+		assert.NoFileExists(t, filepath.Join(downstreamDir, "moved.txt"))
+		assert.NoFileExists(t, filepath.Join(downstreamDir, "scripts/legacy-build.sh"))
+		assert.FileExists(t, filepath.Join(downstreamDir, "scripts/keep.sh"))
+		assert.Equal(t, "import \"example.com/new/billing\"\n", readFileString(t, filepath.Join(downstreamDir, "cmd/main.go")))
+		assert.JSONEq(t, `{"name": "billing", "scripts": {"lint": "eslint ."}, "engines": {"node": {"min": 20}}}`, readFileString(t, filepath.Join(downstreamDir, "package.json")))
+		assert.YAMLEq(t, "jobs:\n  build:\n    steps:\n    - uses: checkout@v4\n    - run: make\n", readFileString(t, filepath.Join(downstreamDir, "config.yml")))
+		assert.Equal(t, "/bin\n/dist\n", readFileString(t, filepath.Join(downstreamDir, ".gitignore")))
+		assert.Equal(t, "- v2 layout\n", readFileString(t, filepath.Join(downstreamDir, "CHANGELOG.md")))
+		assert.Equal(t, "hi there", readFileString(t, filepath.Join(downstreamDir, "shell.txt")))
 	})
 
-	t.Run("returns false when ID is not in state", func(t *testing.T) {
-		dir := t.TempDir()
-		state := &sdktypes.DownstreamState{MigrationsComplete: []string{"m/one:pre_integrate"}}
-		require.NoError(t, SaveDownstreamState(dir, state))
+	t.Run("dry run logs every step and changes nothing", func(t *testing.T) {
+		downstreamDir := setup(t)
+		logger := &recordingLogger{}
+		require.NoError(t, runMigration(instructions, t.TempDir(), downstreamDir, migrationOptions{dryRun: true}, logger))
+
+		assert.FileExists(t, filepath.Join(downstreamDir, ".ci/config.yml"))
+		assert.FileExists(t, filepath.Join(downstreamDir, "scripts/legacy-build.sh"))
+		assert.Equal(t, "import \"example.com/old/billing\"\n", readFileString(t, filepath.Join(downstreamDir, "cmd/main.go")))
+		assert.Contains(t, readFileString(t, filepath.Join(downstreamDir, "package.json")), "legacy")
+		assert.Equal(t, "/bin", readFileString(t, filepath.Join(downstreamDir, ".gitignore")))
+		assert.NoFileExists(t, filepath.Join(downstreamDir, "CHANGELOG.md"))
+		assert.NoFileExists(t, filepath.Join(downstreamDir, "shell.txt"))
+
+		logged := strings.Join(logger.lines, "\n")
+		assert.Contains(t, logged, "(dry run) step 1/10: move .ci/config.yml to .github/ci.yml")
+		assert.Contains(t, logged, "deleting scripts/legacy-build.sh")
+		assert.Contains(t, logged, "replacing in cmd/main.go")
+		assert.Contains(t, logged, "setting $.scripts.lint in package.json")
+		assert.Contains(t, logged, "(dry run) step 10/10: shell")
+	})
 
-		got, err := migrationCompletedInDownstream("m/absent:post_integrate", dir)
+	t.Run("steps stay out of .gitspork and don't write through symlinks", func(t *testing.T) {
+		downstreamDir := setup(t)
+		outsideDir := t.TempDir()
+		require.NoError(t, os.MkdirAll(filepath.Join(downstreamDir, ".gitspork"), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(downstreamDir, ".gitspork", "trust.yml"), []byte("migrations:\n  deny: ['*']\n"), 0644))
+		require.NoError(t, os.Symlink(outsideDir, filepath.Join(downstreamDir, "linked")))
+		require.NoError(t, os.Symlink(filepath.Join(".gitspork", "trust.yml"), filepath.Join(downstreamDir, "trust.yml")))
+		require.NoError(t, os.Symlink(filepath.Join(outsideDir, "new.txt"), filepath.Join(downstreamDir, "dangling.txt")))
+
+		for _, tc := range []struct {
+			step    config.GitSporkConfigMigrationStep
+			wantErr string
+		}{
+			{config.GitSporkConfigMigrationStep{Append: &config.GitSporkConfigMigrationAppend{File: "linked/x.txt", Content: "x"}}, "linked/x.txt resolves outside the downstream"},
+			{config.GitSporkConfigMigrationStep{EnsureLine: &config.GitSporkConfigMigrationEnsureLine{File: "trust.yml", Line: "allow: ['*']"}}, "trust.yml resolves under .gitspork"},
+			{config.GitSporkConfigMigrationStep{Append: &config.GitSporkConfigMigrationAppend{File: "dangling.txt", Content: "x"}}, "dangling.txt is a symlink to nothing"},
+			{config.GitSporkConfigMigrationStep{Move: &config.GitSporkConfigMigrationMove{From: "config.yml", To: "linked/config.yml"}}, "linked/config.yml resolves outside the downstream"},
+			{config.GitSporkConfigMigrationStep{Replace: &config.GitSporkConfigMigrationReplace{Files: []string{"trust.yml"}, Pattern: "deny", With: "allow"}}, "trust.yml resolves under .gitspork"},
+		} {
+			err := runMigration(&config.GitSporkConfigMigrationInstructions{Steps: []config.GitSporkConfigMigrationStep{tc.step}}, t.TempDir(), downstreamDir, migrationOptions{}, sdktypes.NoopLogger())
+			require.ErrorContains(t, err, tc.wantErr)
+		}
+		entries, err := os.ReadDir(outsideDir)
 		require.NoError(t, err)
-		assert.False(t, got)
+		assert.Empty(t, entries)
+		assert.FileExists(t, filepath.Join(downstreamDir, "config.yml"))
+		assert.Equal(t, "migrations:\n  deny: ['*']\n", readFileString(t, filepath.Join(downstreamDir, ".gitspork", "trust.yml")))
 	})
 
-	t.Run("returns false against a fresh downstream with no state file", func(t *testing.T) {
-		got, err := migrationCompletedInDownstream("anything", t.TempDir())
+	t.Run("move renames a symlink wherever it points", func(t *testing.T) {
+		downstreamDir := setup(t)
+		cacheDir := t.TempDir()
+		require.NoError(t, os.Symlink(cacheDir, filepath.Join(downstreamDir, "node_modules")))
+		move := &config.GitSporkConfigMigrationInstructions{Steps: []config.GitSporkConfigMigrationStep{
+			{Move: &config.GitSporkConfigMigrationMove{From: "node_modules", To: "web/node_modules"}},
+		}}
+		require.NoError(t, runMigration(move, t.TempDir(), downstreamDir, migrationOptions{}, sdktypes.NoopLogger()))
+		target, err := os.Readlink(filepath.Join(downstreamDir, "web", "node_modules"))
 		require.NoError(t, err)
-		assert.False(t, got)
+		assert.Equal(t, cacheDir, target)
+		assert.NoFileExists(t, filepath.Join(downstreamDir, "node_modules"))
+		assert.DirExists(t, cacheDir)
+	})
+
+	t.Run("step globs don't match .gitspork", func(t *testing.T) {
+		downstreamDir := setup(t)
+		require.NoError(t, os.MkdirAll(filepath.Join(downstreamDir, ".gitspork"), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(downstreamDir, ".gitspork", "trust.yml"), []byte("migrations:\n  deny: ['*']\n"), 0644))
+		globs := &config.GitSporkConfigMigrationInstructions{Steps: []config.GitSporkConfigMigrationStep{
+			{Replace: &config.GitSporkConfigMigrationReplace{Files: []string{"**.yml"}, Pattern: "deny", With: "allow"}},
+			{Delete: []string{"**.yml"}},
+		}}
+		require.NoError(t, runMigration(globs, t.TempDir(), downstreamDir, migrationOptions{}, sdktypes.NoopLogger()))
+		assert.NoFileExists(t, filepath.Join(downstreamDir, "config.yml"))
+		assert.Equal(t, "migrations:\n  deny: ['*']\n", readFileString(t, filepath.Join(downstreamDir, ".gitspork", "trust.yml")))
+	})
+
+	t.Run("a failing step stops the migration", func(t *testing.T) {
+		downstreamDir := setup(t)
+		failing := &config.GitSporkConfigMigrationInstructions{Steps: []config.GitSporkConfigMigrationStep{
+			{Name: "move onto existing", Move: &config.GitSporkConfigMigrationMove{From: "scripts/keep.sh", To: ".gitignore"}},
+			{Delete: []string{"scripts/*"}},
+		}}
+		err := runMigration(failing, t.TempDir(), downstreamDir, migrationOptions{}, sdktypes.NoopLogger())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "step 1 (move onto existing)")
+		assert.FileExists(t, filepath.Join(downstreamDir, "scripts/keep.sh"))
 	})
 }
 
-func Test_recordCompleteMigration(t *testing.T) {
-	t.Run("creates fresh state and records the ID", func(t *testing.T) {
-		dir := t.TempDir()
-		require.NoError(t, recordCompleteMigration("m/one:pre_integrate", dir))
+func Test_runMigration_environment(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses POSIX sh")
+	}
+	upstreamDir, downstreamDir := t.TempDir(), t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(downstreamDir, "services"), 0755))
+	require.NoError(t, saveTemplatedInputs(downstreamDir, map[string]map[string]any{"README.md": {"name": "billing"}}))
+	instructions := &config.GitSporkConfigMigrationInstructions{
+		ID:         "m/0001.yml:post_integrate",
+		Env:        map[string]string{"LEVEL": "migration", "ONLY_MIGRATION": "yes"},
+		WorkingDir: "services",
+		Steps: []config.GitSporkConfigMigrationStep{{
+			Shell: `env | grep -E '^(GITSPORK_|LEVEL=|ONLY_MIGRATION=)' | sort > env.txt; pwd -P > cwd.txt`,
+			Env:   map[string]string{"LEVEL": "step"},
+		}},
+	}
+	opts := migrationOptions{upstreamURL: "https://example.com/up.git", prevCommit: "abc123", newCommit: "def456"}
+	require.NoError(t, runMigration(instructions, upstreamDir, downstreamDir, opts, sdktypes.NoopLogger()))
 
-		state, err := LoadDownstreamState(dir)
+	resolvedDownstream, err := filepath.EvalSymlinks(downstreamDir)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(resolvedDownstream, "services")+"\n", readFileString(t, filepath.Join(downstreamDir, "services/cwd.txt")))
+	envLines := strings.Split(readFileString(t, filepath.Join(downstreamDir, "services/env.txt")), "\n")
+	for _, want := range []string{
+		"GITSPORK_DOWNSTREAM_PATH=" + downstreamDir,
+		"GITSPORK_DRIFT_CHECK=false",
+		`GITSPORK_GLOBAL_INPUTS={}`,
+		`GITSPORK_INPUTS={"README.md":{"name":"billing"}}`,
+		"GITSPORK_MIGRATION_ID=m/0001.yml:post_integrate",
+		"GITSPORK_NEW_COMMIT=def456",
+		"GITSPORK_PREV_COMMIT=abc123",
+		"GITSPORK_UPSTREAM_PATH=" + upstreamDir,
+		"GITSPORK_UPSTREAM_URL=https://example.com/up.git",
+		"LEVEL=step",
+		"ONLY_MIGRATION=yes",
+	} {
+		assert.Contains(t, envLines, want)
+	}
+	assert.NotContains(t, envLines, "LEVEL=migration", "step env overrides migration env")
+}
+
+func Test_runMigration_killsProcessTree(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses POSIX sh and ps")
+	}
+	// The shell backgrounds a grandchild and waits on it, so only killing the
+	// whole process group ends the command promptly.
+	sleeper := `sleep 30 & echo $! > child.pid; wait`
+	childAlive := func(t *testing.T, downstreamDir string) bool {
+		t.Helper()
+		pid := strings.TrimSpace(readFileString(t, filepath.Join(downstreamDir, "child.pid")))
+		out, _ := exec.Command("ps", "-o", "stat=", "-p", pid).Output()
+		stat := strings.TrimSpace(string(out))
+		return stat != "" && !strings.HasPrefix(stat, "Z")
+	}
+
+	t.Run("timeout", func(t *testing.T) {
+		downstreamDir := t.TempDir()
+		instructions := &config.GitSporkConfigMigrationInstructions{Timeout: "300ms", Steps: []config.GitSporkConfigMigrationStep{{Shell: sleeper}}}
+		start := time.Now()
+		err := runMigration(instructions, t.TempDir(), downstreamDir, migrationOptions{}, sdktypes.NoopLogger())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "timed out after 300ms")
+		assert.Less(t, time.Since(start), 10*time.Second)
+		assert.Eventually(t, func() bool { return !childAlive(t, downstreamDir) }, 5*time.Second, 50*time.Millisecond, "the backgrounded child is killed too")
+	})
+
+	t.Run("cancellation", func(t *testing.T) {
+		downstreamDir := t.TempDir()
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(300*time.Millisecond, cancel)
+		instructions := &config.GitSporkConfigMigrationInstructions{Steps: []config.GitSporkConfigMigrationStep{{Shell: sleeper}}}
+		err := runMigration(instructions, t.TempDir(), downstreamDir, migrationOptions{ctx: ctx}, sdktypes.NoopLogger())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "cancelled")
+		assert.Eventually(t, func() bool { return !childAlive(t, downstreamDir) }, 5*time.Second, 50*time.Millisecond, "the backgrounded child is killed too")
+	})
+}
+
+func Test_migrationApplies(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := gogit.PlainInit(dir, false, gogit.WithDefaultBranch(plumbing.NewBranchReferenceName("main")))
+	require.NoError(t, err)
+	wt, err := repo.Worktree()
+	require.NoError(t, err)
+	sig := &object.Signature{Name: config.GitSpork, Email: config.GitSpork + "@localhost", When: time.Now()}
+	var commits []string
+	for i := range 3 {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "version.txt"), []byte(fmt.Sprint(i)), 0644))
+		require.NoError(t, wt.AddWithOptions(&gogit.AddOptions{All: true}))
+		hash, err := wt.Commit(fmt.Sprintf("commit %d", i), &gogit.CommitOptions{Author: sig})
 		require.NoError(t, err)
-		assert.Equal(t, []string{"m/one:pre_integrate"}, state.MigrationsComplete)
+		commits = append(commits, hash.String())
+	}
+	_, err = repo.CreateTag("v2.0.0", plumbing.NewHash(commits[1]), nil)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		appliesIf config.GitSporkConfigMigrationAppliesIf
+		prev      string
+		want      bool
+		reason    string
+	}{
+		{name: "fresh only, fresh", appliesIf: config.GitSporkConfigMigrationAppliesIf{Integration: config.MigrationIntegrationFresh}, want: true},
+		{name: "fresh only, upgrade", appliesIf: config.GitSporkConfigMigrationAppliesIf{Integration: config.MigrationIntegrationFresh}, prev: commits[0], reason: "fresh integrations"},
+		{name: "upgrade only, fresh", appliesIf: config.GitSporkConfigMigrationAppliesIf{Integration: config.MigrationIntegrationUpgrade}, reason: "upgrades"},
+		{name: "upgrade only, upgrade", appliesIf: config.GitSporkConfigMigrationAppliesIf{Integration: config.MigrationIntegrationUpgrade}, prev: commits[2], want: true},
+		{name: "previous_before, fresh", appliesIf: config.GitSporkConfigMigrationAppliesIf{PreviousBefore: "v2.0.0"}, reason: "upgrades from earlier"},
+		{name: "previous_before, older", appliesIf: config.GitSporkConfigMigrationAppliesIf{PreviousBefore: "v2.0.0"}, prev: commits[0], want: true},
+		{name: "previous_before, same", appliesIf: config.GitSporkConfigMigrationAppliesIf{PreviousBefore: "v2.0.0"}, prev: commits[1], reason: "isn't older than v2.0.0"},
+		{name: "previous_before, newer", appliesIf: config.GitSporkConfigMigrationAppliesIf{PreviousBefore: "v2.0.0"}, prev: commits[2], reason: "isn't older than v2.0.0"},
+		{name: "previous_ancestor_of, same", appliesIf: config.GitSporkConfigMigrationAppliesIf{PreviousAncestorOf: "v2.0.0"}, prev: commits[1], want: true},
+		{name: "previous_ancestor_of, newer", appliesIf: config.GitSporkConfigMigrationAppliesIf{PreviousAncestorOf: "v2.0.0"}, prev: commits[2], reason: "an ancestor of it"},
+		{name: "previous_ancestor_of a branch", appliesIf: config.GitSporkConfigMigrationAppliesIf{PreviousAncestorOf: "main"}, prev: commits[2], want: true},
+		{name: "previous commit not in history", appliesIf: config.GitSporkConfigMigrationAppliesIf{PreviousBefore: "v2.0.0"}, prev: strings.Repeat("a", 40), reason: "isn't in the upstream history"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reason, err := migrationApplies(&tt.appliesIf, dir, tt.prev, sdktypes.NoopLogger())
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.Contains(t, reason, tt.reason)
+		})
+	}
+
+	t.Run("unresolvable ref", func(t *testing.T) {
+		_, _, err := migrationApplies(&config.GitSporkConfigMigrationAppliesIf{PreviousBefore: "v9.9.9"}, dir, commits[0], sdktypes.NoopLogger())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `can't resolve "v9.9.9"`)
 	})
+}
 
-	t.Run("recording the same ID twice does not duplicate the entry", func(t *testing.T) {
-		dir := t.TempDir()
-		require.NoError(t, recordCompleteMigration("m/one:pre_integrate", dir))
-		require.NoError(t, recordCompleteMigration("m/one:pre_integrate", dir))
+func Test_skipInapplicableMigration(t *testing.T) {
+	migrationConfig := &config.GitSporkConfigMigration{
+		PreIntegrate:  &config.GitSporkConfigMigrationInstructions{ID: "m:pre_integrate", Exec: "true"},
+		PostIntegrate: &config.GitSporkConfigMigrationInstructions{ID: "m:post_integrate", Exec: "true"},
+	}
 
+	t.Run("records both as not applicable", func(t *testing.T) {
+		dir := t.TempDir()
+		logger := &recordingLogger{}
+		require.NoError(t, skipInapplicableMigration(migrationConfig, "it only applies to upgrades", dir, migrationOptions{newCommit: "abc123"}, logger))
 		state, err := LoadDownstreamState(dir)
 		require.NoError(t, err)
-		assert.Equal(t, []string{"m/one:pre_integrate"}, state.MigrationsComplete,
-			"recordCompleteMigration must be idempotent — this guarantees migrations run at most once")
+		require.Len(t, state.MigrationsComplete, 2)
+		for _, record := range state.MigrationsComplete {
+			assert.Equal(t, sdktypes.MigrationOutcomeNotApplicable, record.Outcome)
+			assert.Equal(t, "abc123", record.UpstreamCommit)
+		}
+		assert.Len(t, logger.lines, 2)
+		assert.Contains(t, logger.lines[0], "it only applies to upgrades")
 	})
 
-	t.Run("recording distinct IDs preserves order and grows the list", func(t *testing.T) {
+	t.Run("records nothing in a dry run", func(t *testing.T) {
 		dir := t.TempDir()
-		require.NoError(t, recordCompleteMigration("m/one:pre_integrate", dir))
-		require.NoError(t, recordCompleteMigration("m/two:post_integrate", dir))
-		require.NoError(t, recordCompleteMigration("m/three:pre_integrate", dir))
-
-		state, err := LoadDownstreamState(dir)
+		require.NoError(t, skipInapplicableMigration(migrationConfig, "", dir, migrationOptions{dryRun: true}, sdktypes.NoopLogger()))
+		completed, err := migrationCompletedInDownstream("m:pre_integrate", dir)
 		require.NoError(t, err)
-		assert.Equal(t, []string{"m/one:pre_integrate", "m/two:post_integrate", "m/three:pre_integrate"}, state.MigrationsComplete)
+		assert.False(t, completed)
 	})
 }
+
+// recordingLogger keeps each formatted log line.
+type recordingLogger struct{ lines []string }
+
+func (l *recordingLogger) Log(msg string, args ...any) {
+	l.lines = append(l.lines, fmt.Sprintf(msg, args...))
+}
+func (l *recordingLogger) Error(msg string, args ...any) {
+	l.lines = append(l.lines, fmt.Sprintf(msg, args...))
+}
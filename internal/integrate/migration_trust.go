@@ -0,0 +1,293 @@
+package integrate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	gogit "github.com/go-git/go-git/v6"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/rockholla/gitspork/v2/internal/config"
+	"github.com/rockholla/gitspork/v2/internal/sdktypes"
+)
+
+// Answers to the confirmation asked before an untrusted upstream's migration
+// runs commands.
+const (
+	migrationTrustAnswerYes    = "yes"
+	migrationTrustAnswerAlways = "always"
+	migrationTrustAnswerNo     = "no"
+	migrationTrustAnswerNever  = "never"
+)
+
+// migrationTrustSourcePrompt is the Source of a sdktypes.MigrationTrustDecision
+// made by answering a confirmation.
+const migrationTrustSourcePrompt = "prompt"
+
+// maxMigrationScriptPreview bounds how much of an upstream script is shown
+// when confirming a migration that runs it.
+const maxMigrationScriptPreview = 16 * 1024
+
+var migrationTrustAnswers = []string{migrationTrustAnswerYes, migrationTrustAnswerAlways, migrationTrustAnswerNo, migrationTrustAnswerNever}
+
+// migrationRunsCommands reports whether a migration executes anything, which
+// needs the upstream to be trusted, as opposed to only declarative file steps.
+func migrationRunsCommands(instructions *config.GitSporkConfigMigrationInstructions) bool {
+	if instructions.Exec != "" {
+		return true
+	}
+	return slices.ContainsFunc(instructions.Steps, func(step config.GitSporkConfigMigrationStep) bool {
+		return step.Exec != "" || step.Shell != ""
+	})
+}
+
+// describeMigrationCommands lists the commands a migration runs, each
+// followed by the content of the upstream script it calls, if any.
+func describeMigrationCommands(instructions *config.GitSporkConfigMigrationInstructions, upstreamPath string) string {
+	var b strings.Builder
+	describeExec := func(execLine string) {
+		fmt.Fprintf(&b, "exec %s\n", execLine)
+		fields := strings.Fields(execLine)
+		if len(fields) == 0 {
+			return
+		}
+		script, err := os.ReadFile(filepath.Join(upstreamPath, fields[0]))
+		if err != nil {
+			return
+		}
+		truncated := len(script) > maxMigrationScriptPreview
+		if truncated {
+			script = script[:maxMigrationScriptPreview]
+		}
+		fmt.Fprintf(&b, "--- %s\n%s", fields[0], script)
+		if !strings.HasSuffix(string(script), "\n") {
+			b.WriteString("\n")
+		}
+		if truncated {
+			b.WriteString("... (truncated)\n")
+		}
+		b.WriteString("---\n")
+	}
+	if instructions.Exec != "" {
+		describeExec(instructions.Exec)
+	}
+	for _, step := range instructions.Steps {
+		switch {
+		case step.Exec != "":
+			describeExec(step.Exec)
+		case step.Shell != "":
+			fmt.Fprintf(&b, "shell %s\n", step.Shell)
+		}
+	}
+	return b.String()
+}
+
+// findMigrationTrustDecision returns the downstream's recorded decision about
+// the upstream at normalizedURL, or nil.
+func findMigrationTrustDecision(state *sdktypes.DownstreamState, normalizedURL string) *sdktypes.MigrationTrustDecision {
+	for i, decision := range state.MigrationTrust {
+		if decision.URL == normalizedURL {
+			return &state.MigrationTrust[i]
+		}
+	}
+	return nil
+}
+
+// recordMigrationTrust saves decision in the downstream state, replacing any
+// earlier decision about the same upstream.
+func recordMigrationTrust(decision sdktypes.MigrationTrustDecision, downstreamRepoPath string) error {
+	state, err := LoadDownstreamState(downstreamRepoPath)
+	if err != nil {
+		return err
+	}
+	if existing := findMigrationTrustDecision(state, decision.URL); existing != nil {
+		*existing = decision
+	} else {
+		state.MigrationTrust = append(state.MigrationTrust, decision)
+	}
+	return SaveDownstreamState(downstreamRepoPath, state)
+}
+
+// verifyUpstreamCommitSignature checks that the upstream commit being
+// integrated is signed by one of the armored PGP keys at trustedKeys,
+// relative to the downstream root.
+func verifyUpstreamCommitSignature(upstreamPath string, commitHash string, trustedKeys []string, downstreamRepoPath string) error {
+	if commitHash == "" {
+		return fmt.Errorf("the upstream isn't a git commit, so its signature can't be verified")
+	}
+	repo, err := gogit.PlainOpenWithOptions(upstreamPath, &gogit.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return fmt.Errorf("error opening upstream repo at %s: %v", upstreamPath, err)
+	}
+	commit, err := repo.CommitObject(plumbing.NewHash(commitHash))
+	if err != nil {
+		return fmt.Errorf("error reading upstream commit %s: %v", commitHash, err)
+	}
+	if commit.Signature == "" {
+		return fmt.Errorf("upstream commit %s isn't signed", commitHash)
+	}
+	for _, key := range trustedKeys {
+		armored, err := os.ReadFile(filepath.Join(downstreamRepoPath, key))
+		if err != nil {
+			return fmt.Errorf("error reading trusted key %s: %v", key, err)
+		}
+		if _, err := commit.Verify(string(armored)); err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("upstream commit %s isn't signed by any of the trusted_keys", commitHash)
+}
+
+// untrustedMigrationError explains how to let an untrusted upstream's
+// migration run, or integrate without it.
+func untrustedMigrationError(instructions *config.GitSporkConfigMigrationInstructions, upstreamURL string, why string) error {
+	return fmt.Errorf("migration %s from %s runs commands, but %s: add the upstream to migrations.allow in %s/%s or pass --allow-migrations to run it, or pass --no-migrations to integrate without running migrations",
+		instructions.ID, upstreamURL, why, gitSporkMetaDirName, config.TrustPolicyFileName)
+}
+
+// trustUpstreamCommands decides, without asking, whether the upstream may run
+// commands in the downstream, as what, like "migration": --allow-migrations
+// trusts it for this run only, and the downstream's .gitspork/trust.yml or a
+// decision recorded in its state from then on. The trust policy's
+// require_signed_commits applies however it is trusted. confirm is true when
+// the upstream has to be confirmed through the prompter; when it can't be,
+// the error is untrusted's, given why.
+func trustUpstreamCommands(what string, upstreamPath string, downstreamPath string, opts migrationOptions, untrusted func(why string) error, logger sdktypes.Logger) (key string, confirm bool, err error) {
+	policy, err := config.ParseTrustPolicy(filepath.Join(downstreamPath, gitSporkMetaDirName, config.TrustPolicyFileName))
+	if err != nil {
+		return "", false, err
+	}
+	if policy.Migrations.RequireSignedCommits {
+		if err := verifyUpstreamCommitSignature(upstreamPath, opts.newCommit, policy.Migrations.TrustedKeys, downstreamPath); err != nil {
+			return "", false, fmt.Errorf("refusing to run %s commands from %s, since %s requires signed commits: %v", what, opts.upstreamURL, config.TrustPolicyFileName, err)
+		}
+	}
+
+	key = NormalizeUpstreamURL(opts.upstreamURL, "")
+	state, err := LoadDownstreamState(downstreamPath)
+	if err != nil {
+		return "", false, fmt.Errorf("error loading downstream state: %v", err)
+	}
+	decision := findMigrationTrustDecision(state, key)
+	switch {
+	case opts.allowMigrations:
+		logger.Log("🔐 %s commands from %s may run this time, allowed by --allow-migrations", what, opts.upstreamURL)
+		return key, false, nil
+	case policy.Migrations.AllowsUpstream(key):
+		logger.Log("🔐 %s commands from %s may run, allowed by %s/%s", what, opts.upstreamURL, gitSporkMetaDirName, config.TrustPolicyFileName)
+		return key, false, nil
+	case decision != nil && decision.Decision == sdktypes.MigrationTrustAllow:
+		logger.Log("🔐 %s commands from %s may run, as recorded in the downstream state", what, opts.upstreamURL)
+		return key, false, nil
+	case decision != nil:
+		return key, false, untrusted("the downstream state records the upstream as denied")
+	case opts.nonInteractive || opts.forDriftCheck:
+		return key, false, untrusted("the upstream isn't trusted and confirming it needs an interactive run")
+	case opts.noMigrations:
+		return key, false, untrusted("the upstream isn't trusted and --no-migrations confirms nothing")
+	}
+	return key, true, nil
+}
+
+// recordTrustAnswer records the decision an always or never answer to a
+// confirmation makes about the upstream at key; other answers record nothing.
+func recordTrustAnswer(answer string, key string, downstreamPath string) error {
+	decision := sdktypes.MigrationTrustAllow
+	switch answer {
+	case migrationTrustAnswerAlways:
+	case migrationTrustAnswerNever:
+		decision = sdktypes.MigrationTrustDeny
+	default:
+		return nil
+	}
+	return recordMigrationTrust(sdktypes.MigrationTrustDecision{
+		URL:      key,
+		Decision: decision,
+		At:       time.Now(),
+		Source:   migrationTrustSourcePrompt,
+	}, downstreamPath)
+}
+
+// authorizeMigrationCommands checks that the upstream may run the commands of
+// those queued migrations that have any, as trustUpstreamCommands decides;
+// otherwise each such migration is confirmed through the prompter, showing
+// what it runs, unless the run is non-interactive.
+func authorizeMigrationCommands(queued []*config.GitSporkConfigMigrationInstructions, upstreamPath string, downstreamPath string, opts migrationOptions, logger sdktypes.Logger) error {
+	var commands []*config.GitSporkConfigMigrationInstructions
+	for _, instructions := range queued {
+		if migrationRunsCommands(instructions) {
+			commands = append(commands, instructions)
+		}
+	}
+	if len(commands) == 0 {
+		return nil
+	}
+
+	untrusted := func(why string) error {
+		return untrustedMigrationError(commands[0], opts.upstreamURL, why)
+	}
+	key, confirm, err := trustUpstreamCommands("migration", upstreamPath, downstreamPath, opts, untrusted, logger)
+	if err != nil || !confirm {
+		return err
+	}
+
+	prompter := opts.prompter
+	if prompter == nil {
+		prompter = TerminalPrompter()
+	}
+	for _, instructions := range commands {
+		answer, err := promptCommandTrust(prompter, sdktypes.PromptRequest{
+			Migration: instructions.ID,
+			Name:      instructions.ID,
+			Prompt:    fmt.Sprintf("Migration %s from the untrusted upstream %s runs the commands above. Run it (%s)?", instructions.ID, opts.upstreamURL, strings.Join(migrationTrustAnswers, "/")),
+			Help:      describeMigrationCommands(instructions, upstreamPath),
+			Type:      config.TemplatedInputTypeString,
+			Options:   migrationTrustAnswers,
+			Default:   migrationTrustAnswerNo,
+		}, "migration "+instructions.ID, logger)
+		if err != nil {
+			return err
+		}
+		if err := recordTrustAnswer(answer, key, downstreamPath); err != nil {
+			return err
+		}
+		switch answer {
+		case migrationTrustAnswerYes:
+			continue
+		case migrationTrustAnswerAlways:
+			return nil
+		}
+		return untrustedMigrationError(instructions, opts.upstreamURL, "it wasn't allowed to run")
+	}
+	return nil
+}
+
+// promptCommandTrust asks prompter to confirm what, like a migration, runs
+// its commands, re-asking until the answer is one of req.Options or
+// maxTemplatedInputPromptAttempts is reached. An empty answer is req.Default.
+func promptCommandTrust(prompter sdktypes.Prompter, req sdktypes.PromptRequest, what string, logger sdktypes.Logger) (string, error) {
+	for attempt := 1; ; attempt++ {
+		req.Attempt = attempt
+		raw, err := prompter.Prompt(req)
+		if err != nil {
+			return "", fmt.Errorf("error confirming %s: %v", what, err)
+		}
+		answer, _ := raw.(string)
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		if answer == "" {
+			answer = req.Default
+		}
+		if slices.Contains(req.Options, answer) {
+			return answer, nil
+		}
+		err = fmt.Errorf("answer %q is not one of %s", answer, strings.Join(req.Options, ", "))
+		if attempt >= maxTemplatedInputPromptAttempts {
+			return "", fmt.Errorf("no valid answer given to confirm %s after %d attempts: %v", what, attempt, err)
+		}
+		logger.Error("❌ %v, please try again", err)
+		req.ValidationError = err.Error()
+	}
+}
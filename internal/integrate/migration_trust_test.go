@@ -0,0 +1,238 @@
+package integrate
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	gogit "github.com/go-git/go-git/v6"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/rockholla/gitspork/v2/internal/config"
+	"github.com/rockholla/gitspork/v2/internal/sdktypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pgpSigner signs go-git commits with an openpgp entity.
+type pgpSigner struct {
+	entity *openpgp.Entity
+}
+
+func (s pgpSigner) Sign(_ context.Context, message io.Reader) ([]byte, error) {
+	var b bytes.Buffer
+	err := openpgp.ArmoredDetachSign(&b, s.entity, message, nil)
+	return b.Bytes(), err
+}
+
+// newPGPKey generates a signing key and writes its armored public key to
+// dir/rel.
+func newPGPKey(t *testing.T, dir string, rel string) *openpgp.Entity {
+	t.Helper()
+	entity, err := openpgp.NewEntity(config.GitSpork, "", config.GitSpork+"@localhost", &packet.Config{Algorithm: packet.PubKeyAlgoEdDSA})
+	require.NoError(t, err)
+	var b bytes.Buffer
+	w, err := armor.Encode(&b, openpgp.PublicKeyType, nil)
+	require.NoError(t, err)
+	require.NoError(t, entity.Serialize(w))
+	require.NoError(t, w.Close())
+	require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, rel)), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, rel), b.Bytes(), 0644))
+	return entity
+}
+
+// commitUpstream makes a single commit in a new repo at dir, signed by signer
+// unless it is nil, and returns its hash.
+func commitUpstream(t *testing.T, dir string, signer gogit.Signer) string {
+	t.Helper()
+	repo, err := gogit.PlainInit(dir, false)
+	require.NoError(t, err)
+	wt, err := repo.Worktree()
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "migrate.sh"), []byte("#!/bin/sh\necho migrating\n"), 0755))
+	require.NoError(t, wt.AddWithOptions(&gogit.AddOptions{All: true}))
+	sig := &object.Signature{Name: config.GitSpork, Email: config.GitSpork + "@localhost", When: time.Now()}
+	hash, err := wt.Commit("upstream", &gogit.CommitOptions{Author: sig, Signer: signer})
+	require.NoError(t, err)
+	return hash.String()
+}
+
+// writeTrustPolicy writes .gitspork/trust.yml in the downstream at dir.
+func writeTrustPolicy(t *testing.T, dir string, content string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, gitSporkMetaDirName), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, gitSporkMetaDirName, config.TrustPolicyFileName), []byte(content), 0644))
+}
+
+// failingPrompter fails the test if it is ever asked anything.
+func failingPrompter(t *testing.T) sdktypes.Prompter {
+	return sdktypes.PromptFunc(func(req sdktypes.PromptRequest) (any, error) {
+		t.Errorf("unexpected prompt for %s", req.Migration)
+		return nil, fmt.Errorf("unexpected prompt")
+	})
+}
+
+func Test_migrationRunsCommands(t *testing.T) {
+	assert.True(t, migrationRunsCommands(&config.GitSporkConfigMigrationInstructions{Exec: "./migrate.sh"}))
+	assert.True(t, migrationRunsCommands(&config.GitSporkConfigMigrationInstructions{Steps: []config.GitSporkConfigMigrationStep{
+		{EnsureLine: &config.GitSporkConfigMigrationEnsureLine{File: "a", Line: "b"}},
+		{Shell: "true"},
+	}}))
+	assert.False(t, migrationRunsCommands(&config.GitSporkConfigMigrationInstructions{Steps: []config.GitSporkConfigMigrationStep{
+		{Move: &config.GitSporkConfigMigrationMove{From: "a", To: "b"}},
+		{Delete: []string{"c"}},
+	}}))
+}
+
+func Test_describeMigrationCommands(t *testing.T) {
+	upstreamDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(upstreamDir, "migrate.sh"), []byte("#!/bin/sh\nrm -rf legacy\n"), 0755))
+
+	got := describeMigrationCommands(&config.GitSporkConfigMigrationInstructions{Steps: []config.GitSporkConfigMigrationStep{
+		{Move: &config.GitSporkConfigMigrationMove{From: "a", To: "b"}},
+		{Exec: "./migrate.sh --force"},
+		{Shell: "make generate"},
+	}}, upstreamDir)
+	assert.Equal(t, "exec ./migrate.sh --force\n--- ./migrate.sh\n#!/bin/sh\nrm -rf legacy\n---\nshell make generate\n", got)
+}
+
+func Test_authorizeMigrationCommands(t *testing.T) {
+	execMigration := &config.GitSporkConfigMigrationInstructions{ID: "m:pre_integrate", Exec: "./migrate.sh"}
+	const upstreamURL = "https://GitHub.com/acme/platform.git"
+
+	t.Run("declarative migrations need no trust", func(t *testing.T) {
+		downstreamDir := t.TempDir()
+		stepsMigration := &config.GitSporkConfigMigrationInstructions{ID: "s:pre_integrate", Steps: []config.GitSporkConfigMigrationStep{{Delete: []string{"old.txt"}}}}
+		opts := migrationOptions{upstreamURL: upstreamURL, nonInteractive: true}
+		require.NoError(t, authorizeMigrationCommands([]*config.GitSporkConfigMigrationInstructions{stepsMigration}, t.TempDir(), downstreamDir, opts, sdktypes.NoopLogger()))
+	})
+
+	t.Run("untrusted upstream fails non-interactively", func(t *testing.T) {
+		downstreamDir := t.TempDir()
+		opts := migrationOptions{upstreamURL: upstreamURL, nonInteractive: true, prompter: failingPrompter(t)}
+		err := authorizeMigrationCommands([]*config.GitSporkConfigMigrationInstructions{execMigration}, t.TempDir(), downstreamDir, opts, sdktypes.NoopLogger())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "migration m:pre_integrate from "+upstreamURL+" runs commands")
+		assert.Contains(t, err.Error(), "--allow-migrations")
+		assert.Contains(t, err.Error(), "--no-migrations")
+	})
+
+	t.Run("trust policy allow list trusts the upstream", func(t *testing.T) {
+		downstreamDir := t.TempDir()
+		writeTrustPolicy(t, downstreamDir, "migrations:\n  allow:\n    - github.com/acme/*\n")
+		opts := migrationOptions{upstreamURL: upstreamURL, nonInteractive: true, prompter: failingPrompter(t)}
+		require.NoError(t, authorizeMigrationCommands([]*config.GitSporkConfigMigrationInstructions{execMigration}, t.TempDir(), downstreamDir, opts, sdktypes.NoopLogger()))
+	})
+
+	t.Run("allow migrations trusts this run only", func(t *testing.T) {
+		downstreamDir := t.TempDir()
+		opts := migrationOptions{upstreamURL: upstreamURL, allowMigrations: true, nonInteractive: true}
+		require.NoError(t, authorizeMigrationCommands([]*config.GitSporkConfigMigrationInstructions{execMigration}, t.TempDir(), downstreamDir, opts, sdktypes.NoopLogger()))
+
+		state, err := LoadDownstreamState(downstreamDir)
+		require.NoError(t, err)
+		assert.Empty(t, state.MigrationTrust)
+
+		opts = migrationOptions{upstreamURL: upstreamURL, nonInteractive: true, prompter: failingPrompter(t)}
+		err = authorizeMigrationCommands([]*config.GitSporkConfigMigrationInstructions{execMigration}, t.TempDir(), downstreamDir, opts, sdktypes.NoopLogger())
+		require.ErrorContains(t, err, "confirming it needs an interactive run")
+	})
+
+	t.Run("prompt shows the script and yes runs it once", func(t *testing.T) {
+		upstreamDir, downstreamDir := t.TempDir(), t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(upstreamDir, "migrate.sh"), []byte("#!/bin/sh\necho migrating\n"), 0755))
+		prompter := newSequencePrompter("yes")
+		opts := migrationOptions{upstreamURL: upstreamURL, prompter: prompter}
+		require.NoError(t, authorizeMigrationCommands([]*config.GitSporkConfigMigrationInstructions{execMigration}, upstreamDir, downstreamDir, opts, sdktypes.NoopLogger()))
+
+		require.Len(t, prompter.requests, 1)
+		assert.Equal(t, "m:pre_integrate", prompter.requests[0].Migration)
+		assert.Contains(t, prompter.requests[0].Help, "echo migrating")
+		assert.Equal(t, migrationTrustAnswers, prompter.requests[0].Options)
+		state, err := LoadDownstreamState(downstreamDir)
+		require.NoError(t, err)
+		assert.Empty(t, state.MigrationTrust)
+	})
+
+	t.Run("always records the upstream as trusted", func(t *testing.T) {
+		downstreamDir := t.TempDir()
+		prompter := newSequencePrompter("always")
+		opts := migrationOptions{upstreamURL: upstreamURL, prompter: prompter}
+		second := &config.GitSporkConfigMigrationInstructions{ID: "m:post_integrate", Exec: "./migrate.sh"}
+		require.NoError(t, authorizeMigrationCommands([]*config.GitSporkConfigMigrationInstructions{execMigration, second}, t.TempDir(), downstreamDir, opts, sdktypes.NoopLogger()))
+		assert.Len(t, prompter.requests, 1)
+
+		state, err := LoadDownstreamState(downstreamDir)
+		require.NoError(t, err)
+		require.Len(t, state.MigrationTrust, 1)
+		assert.Equal(t, sdktypes.MigrationTrustAllow, state.MigrationTrust[0].Decision)
+		assert.Equal(t, migrationTrustSourcePrompt, state.MigrationTrust[0].Source)
+	})
+
+	t.Run("never records the upstream as denied", func(t *testing.T) {
+		downstreamDir := t.TempDir()
+		opts := migrationOptions{upstreamURL: upstreamURL, prompter: newSequencePrompter("never")}
+		err := authorizeMigrationCommands([]*config.GitSporkConfigMigrationInstructions{execMigration}, t.TempDir(), downstreamDir, opts, sdktypes.NoopLogger())
+		require.ErrorContains(t, err, "it wasn't allowed to run")
+
+		opts.prompter = failingPrompter(t)
+		err = authorizeMigrationCommands([]*config.GitSporkConfigMigrationInstructions{execMigration}, t.TempDir(), downstreamDir, opts, sdktypes.NoopLogger())
+		require.ErrorContains(t, err, "records the upstream as denied")
+
+		// --allow-migrations runs it this once, and the deny still stands.
+		opts.allowMigrations = true
+		require.NoError(t, authorizeMigrationCommands([]*config.GitSporkConfigMigrationInstructions{execMigration}, t.TempDir(), downstreamDir, opts, sdktypes.NoopLogger()))
+		state, err := LoadDownstreamState(downstreamDir)
+		require.NoError(t, err)
+		require.Len(t, state.MigrationTrust, 1)
+		assert.Equal(t, sdktypes.MigrationTrustDeny, state.MigrationTrust[0].Decision)
+
+		opts.allowMigrations = false
+		err = authorizeMigrationCommands([]*config.GitSporkConfigMigrationInstructions{execMigration}, t.TempDir(), downstreamDir, opts, sdktypes.NoopLogger())
+		require.ErrorContains(t, err, "records the upstream as denied")
+	})
+
+	t.Run("invalid answers are asked again", func(t *testing.T) {
+		prompter := newSequencePrompter("maybe", "no")
+		opts := migrationOptions{upstreamURL: upstreamURL, prompter: prompter}
+		err := authorizeMigrationCommands([]*config.GitSporkConfigMigrationInstructions{execMigration}, t.TempDir(), t.TempDir(), opts, sdktypes.NoopLogger())
+		require.ErrorContains(t, err, "it wasn't allowed to run")
+		require.Len(t, prompter.requests, 2)
+		assert.Contains(t, prompter.requests[1].ValidationError, `"maybe"`)
+	})
+
+	t.Run("require signed commits", func(t *testing.T) {
+		downstreamDir := t.TempDir()
+		trusted := newPGPKey(t, downstreamDir, ".gitspork/keys/upstream.asc")
+		other := newPGPKey(t, t.TempDir(), "other.asc")
+		writeTrustPolicy(t, downstreamDir, "migrations:\n  allow: ['github.com/acme/*']\n  require_signed_commits: true\n  trusted_keys: [.gitspork/keys/upstream.asc]\n")
+
+		for _, tt := range []struct {
+			name    string
+			signer  gogit.Signer
+			wantErr string
+		}{
+			{name: "unsigned", wantErr: "isn't signed"},
+			{name: "signed by another key", signer: pgpSigner{other}, wantErr: "isn't signed by any of the trusted_keys"},
+			{name: "signed by a trusted key", signer: pgpSigner{trusted}},
+		} {
+			t.Run(tt.name, func(t *testing.T) {
+				upstreamDir := t.TempDir()
+				opts := migrationOptions{upstreamURL: upstreamURL, newCommit: commitUpstream(t, upstreamDir, tt.signer), allowMigrations: true}
+				err := authorizeMigrationCommands([]*config.GitSporkConfigMigrationInstructions{execMigration}, upstreamDir, downstreamDir, opts, sdktypes.NoopLogger())
+				if tt.wantErr == "" {
+					require.NoError(t, err)
+					return
+				}
+				require.ErrorContains(t, err, tt.wantErr)
+			})
+		}
+	})
+}
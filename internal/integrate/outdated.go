@@ -0,0 +1,319 @@
+package integrate
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	git "github.com/go-git/go-git/v6"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/rockholla/gitspork/v2/internal/config"
+	"github.com/rockholla/gitspork/v2/internal/sdktypes"
+)
+
+// LatestUpstreamRequest is the internal request shape used to compare an
+// upstream recorded in the downstream state with its latest version. It's a
+// package-integrate contract intended only for internal/drift.
+type LatestUpstreamRequest struct {
+	Logger sdktypes.Logger
+	// Upstream carries the URL, token and subpath, and the Version requested
+	// at integration.
+	Upstream sdktypes.UpstreamSpec
+	// CommitHash is the commit recorded in the downstream state.
+	CommitHash string
+	CacheTTL   time.Duration
+	NoCache    bool
+	Progress   io.Writer
+}
+
+// ResolveLatestUpstream finds the latest version of req.Upstream in its mirror
+// cache, fetching it when stale, and counts the commits and tags the recorded
+// commit is behind it. ChangedFiles is left for the caller.
+func ResolveLatestUpstream(req *LatestUpstreamRequest) (sdktypes.OutdatedUpstream, error) {
+	result := sdktypes.OutdatedUpstream{
+		URL:        req.Upstream.URL,
+		Subpath:    config.NormalizeUpstreamPath(req.Upstream.Subpath),
+		Version:    req.Upstream.Version,
+		CommitHash: req.CommitHash,
+		Track:      sdktypes.OutdatedTrackPinned,
+	}
+	if req.Logger == nil {
+		req.Logger = sdktypes.NoopLogger()
+	}
+	if commitHashRe.MatchString(req.Upstream.Version) {
+		return result, nil
+	}
+
+	upstreamURL := resolveUpstreamURL(req.Upstream.URL, req.Upstream.Token)
+	auth, err := upstreamAuth(upstreamURL, req.Upstream.Token)
+	if err != nil {
+		return result, err
+	}
+	cacheCfg, err := resolveCacheConfig(req.CacheTTL, req.NoCache)
+	if err != nil {
+		return result, err
+	}
+	mirrorDir, err := ensureUpstreamCache(cacheCfg, upstreamURL, auth, req.Logger, req.Progress)
+	if err != nil {
+		return result, err
+	}
+	if mirrorDir == "" {
+		// No cache to read from: mirror the upstream just for this call.
+		tmpDir, err := os.MkdirTemp("", "gitspork-outdated-*")
+		if err != nil {
+			return result, fmt.Errorf("error creating temporary directory: %v", err)
+		}
+		defer os.RemoveAll(tmpDir)
+		mirrorDir = filepath.Join(tmpDir, "mirror")
+		req.Logger.Log("cloning a mirror of upstream %s", req.Upstream.URL)
+		if err := populateCache(mirrorDir, upstreamURL, auth, req.Progress); err != nil {
+			return result, err
+		}
+	}
+	repo, err := git.PlainOpen(mirrorDir)
+	if err != nil {
+		return result, fmt.Errorf("error opening upstream mirror at %s: %v", mirrorDir, err)
+	}
+
+	tags, err := upstreamTagCommits(repo)
+	if err != nil {
+		return result, err
+	}
+	track, latestVersion, latestHash, err := resolveLatestVersion(repo, tags, req.Upstream.Version)
+	if err != nil {
+		return result, fmt.Errorf("error resolving the latest version of upstream %s: %v", req.Upstream.URL, err)
+	}
+	result.Track = track
+	if track == sdktypes.OutdatedTrackPinned {
+		return result, nil
+	}
+	result.LatestVersion, result.LatestCommitHash = latestVersion, latestHash.String()
+	result.Outdated = result.LatestCommitHash != req.CommitHash
+	if !result.Outdated {
+		return result, nil
+	}
+
+	behind, err := commitsBehind(repo, plumbing.NewHash(req.CommitHash), latestHash)
+	if errors.Is(err, plumbing.ErrObjectNotFound) {
+		req.Logger.Log("recorded commit %s is no longer in the history of upstream %s, so commits behind can't be counted", req.CommitHash, req.Upstream.URL)
+		return result, nil
+	}
+	if err != nil {
+		return result, fmt.Errorf("error counting commits behind upstream %s: %v", req.Upstream.URL, err)
+	}
+	result.CommitsBehind = len(behind)
+	for name, hash := range tags {
+		if behind[hash] {
+			result.TagsBehind = append(result.TagsBehind, name)
+		}
+	}
+	slices.SortFunc(result.TagsBehind, compareTagNames)
+	return result, nil
+}
+
+// upstreamTagCommits maps the name of each tag in repo to the commit it
+// points at, peeling annotated tags.
+func upstreamTagCommits(repo *git.Repository) (map[string]plumbing.Hash, error) {
+	refs, err := repo.Tags()
+	if err != nil {
+		return nil, fmt.Errorf("error listing upstream tags: %v", err)
+	}
+	tags := map[string]plumbing.Hash{}
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		hash := ref.Hash()
+		if tag, err := repo.TagObject(hash); err == nil {
+			commit, err := tag.Commit()
+			if err != nil {
+				// A tag of a tree or blob has no commit to compare.
+				return nil
+			}
+			hash = commit.Hash
+		}
+		tags[ref.Name().Short()] = hash
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing upstream tags: %v", err)
+	}
+	return tags, nil
+}
+
+// resolveLatestVersion works out how version, as requested at integration,
+// moves: an empty version follows the default branch, a semver tag the newest
+// semver tag, and a branch its latest commit. Like integrate, a tag wins over
+// a branch of the same name.
+func resolveLatestVersion(repo *git.Repository, tags map[string]plumbing.Hash, version string) (string, string, plumbing.Hash, error) {
+	if version == "" {
+		head, err := repo.Reference(plumbing.HEAD, false)
+		if err != nil {
+			return "", "", plumbing.ZeroHash, fmt.Errorf("error reading the default branch: %v", err)
+		}
+		branch, err := repo.Reference(head.Target(), true)
+		if err != nil {
+			return "", "", plumbing.ZeroHash, fmt.Errorf("error resolving the default branch %s: %v", head.Target().Short(), err)
+		}
+		return sdktypes.OutdatedTrackBranch, head.Target().Short(), branch.Hash(), nil
+	}
+
+	tagName, explicitTag := strings.CutPrefix(version, "tags/")
+	if _, isTag := tags[tagName]; isTag || explicitTag {
+		if !isTag {
+			return "", "", plumbing.ZeroHash, fmt.Errorf("tag %s not found", tagName)
+		}
+		current, ok := parseSemver(tagName)
+		if !ok {
+			return sdktypes.OutdatedTrackPinned, "", plumbing.ZeroHash, nil
+		}
+		latest, latestVersion := current, tagName
+		for _, name := range slices.Sorted(maps.Keys(tags)) {
+			v, ok := parseSemver(name)
+			if !ok || (len(v.prerelease) > 0 && len(current.prerelease) == 0) {
+				continue
+			}
+			if v.compare(latest) > 0 {
+				latest, latestVersion = v, name
+			}
+		}
+		return sdktypes.OutdatedTrackTag, latestVersion, tags[latestVersion], nil
+	}
+
+	branch, err := repo.Reference(plumbing.NewBranchReferenceName(version), true)
+	if err != nil {
+		return "", "", plumbing.ZeroHash, fmt.Errorf("version %s is neither a tag nor a branch", version)
+	}
+	return sdktypes.OutdatedTrackBranch, version, branch.Hash(), nil
+}
+
+// commitsBehind returns the commits reachable from latest but not from
+// pinned. The error satisfies errors.Is(err, plumbing.ErrObjectNotFound) when
+// pinned isn't in repo.
+func commitsBehind(repo *git.Repository, pinned, latest plumbing.Hash) (map[plumbing.Hash]bool, error) {
+	pinnedCommit, err := repo.CommitObject(pinned)
+	if err != nil {
+		return nil, err
+	}
+	latestCommit, err := repo.CommitObject(latest)
+	if err != nil {
+		return nil, err
+	}
+	have := map[plumbing.Hash]bool{}
+	if err := object.NewCommitPreorderIter(pinnedCommit, nil, nil).ForEach(func(c *object.Commit) error {
+		have[c.Hash] = true
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	behind := map[plumbing.Hash]bool{}
+	err = object.NewCommitPreorderIter(latestCommit, have, nil).ForEach(func(c *object.Commit) error {
+		behind[c.Hash] = true
+		return nil
+	})
+	return behind, err
+}
+
+// semver is a parsed semantic version tag, like v1.2.3 or 1.2.3-rc.1+build.5.
+type semver struct {
+	major, minor, patch int
+	prerelease          []string
+}
+
+// parseSemver parses tag, with or without a leading "v", as a semantic version.
+func parseSemver(tag string) (semver, bool) {
+	v := strings.TrimPrefix(tag, "v")
+	v, _, _ = strings.Cut(v, "+")
+	core, prerelease, hasPrerelease := strings.Cut(v, "-")
+	parts := strings.Split(core, ".")
+	if len(parts) != 3 {
+		return semver{}, false
+	}
+	var numbers [3]int
+	for i, part := range parts {
+		if !isDigits(part) {
+			return semver{}, false
+		}
+		numbers[i], _ = strconv.Atoi(part)
+	}
+	parsed := semver{major: numbers[0], minor: numbers[1], patch: numbers[2]}
+	if hasPrerelease {
+		parsed.prerelease = strings.Split(prerelease, ".")
+		if slices.Contains(parsed.prerelease, "") {
+			return semver{}, false
+		}
+	}
+	return parsed, true
+}
+
+// compare orders versions by semver precedence, returning -1, 0 or +1.
+func (v semver) compare(other semver) int {
+	if c := cmp.Or(cmp.Compare(v.major, other.major), cmp.Compare(v.minor, other.minor), cmp.Compare(v.patch, other.patch)); c != 0 {
+		return c
+	}
+	// A release outranks its pre-releases.
+	switch {
+	case len(v.prerelease) == 0 && len(other.prerelease) == 0:
+		return 0
+	case len(v.prerelease) == 0:
+		return 1
+	case len(other.prerelease) == 0:
+		return -1
+	}
+	for i := 0; i < len(v.prerelease) && i < len(other.prerelease); i++ {
+		a, b := v.prerelease[i], other.prerelease[i]
+		aNumeric, bNumeric := isDigits(a), isDigits(b)
+		var c int
+		switch {
+		case aNumeric && bNumeric:
+			an, _ := strconv.Atoi(a)
+			bn, _ := strconv.Atoi(b)
+			c = cmp.Compare(an, bn)
+		case aNumeric:
+			c = -1
+		case bNumeric:
+			c = 1
+		default:
+			c = strings.Compare(a, b)
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return cmp.Compare(len(v.prerelease), len(other.prerelease))
+}
+
+// compareTagNames orders semver tags by version, ahead of any other tags,
+// which are ordered by name.
+func compareTagNames(a, b string) int {
+	av, aOK := parseSemver(a)
+	bv, bOK := parseSemver(b)
+	switch {
+	case aOK && bOK:
+		return cmp.Or(av.compare(bv), strings.Compare(a, b))
+	case aOK:
+		return -1
+	case bOK:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// isDigits reports whether s is a non-empty run of ASCII digits.
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
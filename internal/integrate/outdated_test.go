@@ -0,0 +1,114 @@
+package integrate
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	gogit "github.com/go-git/go-git/v6"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/rockholla/gitspork/v2/internal/logutil"
+	"github.com/rockholla/gitspork/v2/internal/sdktypes"
+	"github.com/rockholla/gitspork/v2/test/testharness"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_semver_compare(t *testing.T) {
+	ordered := []string{"v1.0.0-alpha", "v1.0.0-alpha.1", "v1.0.0-alpha.beta", "v1.0.0-beta.2", "v1.0.0-beta.11", "v1.0.0-rc.1", "1.0.0+build.7", "v1.0.1", "v1.10.0", "v2.0.0"}
+	for i := 1; i < len(ordered); i++ {
+		a, ok := parseSemver(ordered[i-1])
+		require.True(t, ok, ordered[i-1])
+		b, ok := parseSemver(ordered[i])
+		require.True(t, ok, ordered[i])
+		assert.Equal(t, -1, a.compare(b), "%s < %s", ordered[i-1], ordered[i])
+		assert.Equal(t, 1, b.compare(a), "%s > %s", ordered[i], ordered[i-1])
+	}
+	for _, tag := range []string{"stable", "v1.2", "v1.2.3.4", "v1.x.0", "v1.2.3-", "v1.2.3-rc..1", "1.-2.3"} {
+		_, ok := parseSemver(tag)
+		assert.False(t, ok, tag)
+	}
+
+	tags := []string{"latest", "v1.10.0", "v1.0.0", "v1.2.0"}
+	slices.SortFunc(tags, compareTagNames)
+	assert.Equal(t, []string{"v1.0.0", "v1.2.0", "v1.10.0", "latest"}, tags)
+}
+
+func Test_ResolveLatestUpstream(t *testing.T) {
+	upstreamDir, first := testharness.MinimalUpstreamWithTag(t, "v1.0.0")
+	repo, err := gogit.PlainOpen(upstreamDir)
+	require.NoError(t, err)
+	_, err = repo.CreateTag("stable", first, nil)
+	require.NoError(t, err)
+	commit := func(content string) plumbing.Hash {
+		require.NoError(t, os.WriteFile(filepath.Join(upstreamDir, "upstream-owned", "file.txt"), []byte(content), 0644))
+		return testharness.CommitAllWithMessage(t, repo, content)
+	}
+	second := commit("second\n")
+	_, err = repo.CreateTag("v1.1.0", second, &gogit.CreateTagOptions{
+		Tagger:  &object.Signature{Name: "gitspork-test", Email: "gitspork-test@localhost", When: time.Now()},
+		Message: "v1.1.0",
+	})
+	require.NoError(t, err)
+	third := commit("third\n")
+	_, err = repo.CreateTag("v2.0.0-rc.1", third, nil)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		version    string
+		commitHash plumbing.Hash
+		noCache    bool
+		want       sdktypes.OutdatedUpstream
+	}{
+		{
+			name: "default branch", commitHash: first,
+			want: sdktypes.OutdatedUpstream{Track: sdktypes.OutdatedTrackBranch, LatestVersion: "main", LatestCommitHash: third.String(), Outdated: true, CommitsBehind: 2, TagsBehind: []string{"v1.1.0", "v2.0.0-rc.1"}},
+		},
+		{
+			name: "branch without the cache", version: "main", commitHash: second, noCache: true,
+			want: sdktypes.OutdatedUpstream{Track: sdktypes.OutdatedTrackBranch, LatestVersion: "main", LatestCommitHash: third.String(), Outdated: true, CommitsBehind: 1, TagsBehind: []string{"v2.0.0-rc.1"}},
+		},
+		{
+			name: "semver tag skips pre-releases", version: "v1.0.0", commitHash: first,
+			want: sdktypes.OutdatedUpstream{Track: sdktypes.OutdatedTrackTag, LatestVersion: "v1.1.0", LatestCommitHash: second.String(), Outdated: true, CommitsBehind: 1, TagsBehind: []string{"v1.1.0"}},
+		},
+		{
+			name: "explicit tag up to date", version: "tags/v1.1.0", commitHash: second,
+			want: sdktypes.OutdatedUpstream{Track: sdktypes.OutdatedTrackTag, LatestVersion: "v1.1.0", LatestCommitHash: second.String()},
+		},
+		{
+			name: "pre-release tag follows pre-releases", version: "v2.0.0-rc.1", commitHash: third,
+			want: sdktypes.OutdatedUpstream{Track: sdktypes.OutdatedTrackTag, LatestVersion: "v2.0.0-rc.1", LatestCommitHash: third.String()},
+		},
+		{name: "non-semver tag is pinned", version: "stable", commitHash: first, want: sdktypes.OutdatedUpstream{Track: sdktypes.OutdatedTrackPinned}},
+		{name: "commit hash is pinned", version: first.String()[:10], commitHash: first, want: sdktypes.OutdatedUpstream{Track: sdktypes.OutdatedTrackPinned}},
+		{
+			name: "recorded commit gone from history", version: "main", commitHash: plumbing.NewHash("0123456789abcdef0123456789abcdef01234567"),
+			want: sdktypes.OutdatedUpstream{Track: sdktypes.OutdatedTrackBranch, LatestVersion: "main", LatestCommitHash: third.String(), Outdated: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolveLatestUpstream(&LatestUpstreamRequest{
+				Logger:     logutil.New(),
+				Upstream:   sdktypes.UpstreamSpec{URL: "file://" + upstreamDir, Version: tt.version},
+				CommitHash: tt.commitHash.String(),
+				CacheTTL:   time.Nanosecond,
+				NoCache:    tt.noCache,
+			})
+			require.NoError(t, err)
+			tt.want.URL, tt.want.Version, tt.want.CommitHash = "file://"+upstreamDir, tt.version, tt.commitHash.String()
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err = ResolveLatestUpstream(&LatestUpstreamRequest{
+		Upstream:   sdktypes.UpstreamSpec{URL: "file://" + upstreamDir, Version: "no-such-version"},
+		CommitHash: first.String(),
+	})
+	require.ErrorContains(t, err, "version no-such-version is neither a tag nor a branch")
+}
@@ -0,0 +1,49 @@
+package integrate
+
+import (
+	"github.com/rockholla/gitspork/v2/internal/config"
+	inputpkg "github.com/rockholla/gitspork/v2/internal/input"
+	"github.com/rockholla/gitspork/v2/internal/sdktypes"
+)
+
+// terminalPrompter is the default Prompter, asking on the controlling
+// terminal via the input package.
+type terminalPrompter struct{}
+
+var _ sdktypes.Prompter = terminalPrompter{}
+
+// TerminalPrompter returns the Prompter used when IntegrateOptions.Prompter
+// (or IntegrateLocalOptions.Prompter) is nil: it reads answers from stdin.
+func TerminalPrompter() sdktypes.Prompter {
+	return terminalPrompter{}
+}
+
+// Prompt maps the request's input type onto the matching terminal prompt.
+func (terminalPrompter) Prompt(req sdktypes.PromptRequest) (any, error) {
+	opts := &inputpkg.RequestInputOptions{
+		Type:    inputpkg.SingleValue,
+		Prompt:  req.Prompt,
+		Help:    req.Help,
+		Default: req.Default,
+	}
+	switch {
+	case req.Secret:
+		opts.Type = inputpkg.Masked
+	case req.Type == config.TemplatedInputTypeBool:
+		opts.Type = inputpkg.YesNo
+	case req.Type == config.TemplatedInputTypeSelect:
+		opts.Type = inputpkg.Selection
+		opts.SelectOptions = req.Options
+	case req.Type == config.TemplatedInputTypeMultiSelect:
+		opts.Type = inputpkg.MultiSelection
+		opts.SelectOptions = req.Options
+	}
+	result, err := inputpkg.RequestInput(opts)
+	if err != nil {
+		return nil, err
+	}
+	if result.StringValues != nil {
+		return result.StringValues, nil
+	}
+	return result.StringValue, nil
+}
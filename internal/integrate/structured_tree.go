@@ -1,5 +1,7 @@
 package integrate
 
+import "slices"
+
 type nodeKind int
 
 const (
@@ -48,6 +50,14 @@ func (m *orderedMap) Get(k string) (*node, bool) {
 	return v, ok
 }
 
+func (m *orderedMap) Delete(k string) {
+	if _, ok := m.values[k]; !ok {
+		return
+	}
+	delete(m.values, k)
+	m.keys = slices.DeleteFunc(m.keys, func(key string) bool { return key == k })
+}
+
 func (m *orderedMap) Keys() []string {
 	return m.keys
 }
@@ -2,6 +2,8 @@ package integrate
 
 import (
 	"fmt"
+	"maps"
+	"slices"
 
 	"github.com/goccy/go-yaml"
 )
@@ -33,6 +35,14 @@ func yamlValueToNode(v any) *node {
 			m.mapping.Set(key, yamlValueToNode(item.Value))
 		}
 		return m
+	case map[string]any:
+		// Unordered maps, e.g. from a plain yaml.Unmarshal, are keyed in sorted
+		// order so the output is stable.
+		m := newMappingNode()
+		for _, k := range slices.Sorted(maps.Keys(val)) {
+			m.mapping.Set(k, yamlValueToNode(val[k]))
+		}
+		return m
 	case []any:
 		items := make([]*node, len(val))
 		for i, item := range val {
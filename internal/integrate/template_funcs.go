@@ -0,0 +1,420 @@
+package integrate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/goccy/go-yaml"
+)
+
+// TemplateFuncDoc describes a single function available to upstream templates.
+// It is the documentation half of templateFuncs, surfaced by `gitspork schema`.
+type TemplateFuncDoc struct {
+	Name        string
+	Signature   string
+	Description string
+}
+
+// templateFunc pairs a template function with its documentation so the
+// FuncMap handed to text/template and the list printed by `gitspork schema`
+// come from one source and cannot drift apart.
+type templateFunc struct {
+	TemplateFuncDoc
+	fn any
+}
+
+// templateFuncs is the built-in function library for templated instructions.
+// Names and semantics intentionally follow the widely-known sprig/Helm
+// conventions (including the pipeline-friendly argument order, e.g.
+// `{{ .Inputs.name | default "x" }}`) so upstream authors can reuse what they
+// already know.
+var templateFuncs = []templateFunc{
+	// strings
+	{TemplateFuncDoc{"lower", "lower STRING", "convert a string to lower case"}, strings.ToLower},
+	{TemplateFuncDoc{"upper", "upper STRING", "convert a string to upper case"}, strings.ToUpper},
+	{TemplateFuncDoc{"title", "title STRING", "upper-case the first letter of each word"}, tplTitle},
+	{TemplateFuncDoc{"camelcase", "camelcase STRING", "convert snake/kebab/space separated words to CamelCase"}, tplCamelCase},
+	{TemplateFuncDoc{"snakecase", "snakecase STRING", "convert a string to snake_case"}, func(s string) string { return tplJoinWords(s, "_") }},
+	{TemplateFuncDoc{"kebabcase", "kebabcase STRING", "convert a string to kebab-case"}, func(s string) string { return tplJoinWords(s, "-") }},
+	{TemplateFuncDoc{"trim", "trim STRING", "remove leading and trailing whitespace"}, strings.TrimSpace},
+	{TemplateFuncDoc{"trimPrefix", "trimPrefix PREFIX STRING", "remove PREFIX from the start of STRING, if present"}, func(prefix, s string) string { return strings.TrimPrefix(s, prefix) }},
+	{TemplateFuncDoc{"trimSuffix", "trimSuffix SUFFIX STRING", "remove SUFFIX from the end of STRING, if present"}, func(suffix, s string) string { return strings.TrimSuffix(s, suffix) }},
+	{TemplateFuncDoc{"replace", "replace OLD NEW STRING", "replace every occurrence of OLD with NEW in STRING"}, func(old, new, s string) string { return strings.ReplaceAll(s, old, new) }},
+	{TemplateFuncDoc{"contains", "contains SUBSTRING STRING", "report whether STRING contains SUBSTRING"}, func(sub, s string) bool { return strings.Contains(s, sub) }},
+	{TemplateFuncDoc{"hasPrefix", "hasPrefix PREFIX STRING", "report whether STRING starts with PREFIX"}, func(prefix, s string) bool { return strings.HasPrefix(s, prefix) }},
+	{TemplateFuncDoc{"hasSuffix", "hasSuffix SUFFIX STRING", "report whether STRING ends with SUFFIX"}, func(suffix, s string) bool { return strings.HasSuffix(s, suffix) }},
+	{TemplateFuncDoc{"split", "split SEPARATOR STRING", "split STRING on SEPARATOR into a list"}, func(sep, s string) []string { return strings.Split(s, sep) }},
+	{TemplateFuncDoc{"join", "join SEPARATOR LIST", "join the elements of LIST with SEPARATOR"}, tplJoin},
+	{TemplateFuncDoc{"repeat", "repeat COUNT STRING", "repeat STRING COUNT times"}, func(n int, s string) string { return strings.Repeat(s, n) }},
+	{TemplateFuncDoc{"quote", "quote VALUE", "wrap VALUE in double quotes, escaping as needed (safe for YAML and JSON scalars)"}, func(v any) string { return strconv.Quote(tplToString(v)) }},
+	{TemplateFuncDoc{"squote", "squote VALUE", "wrap VALUE in YAML single quotes, doubling any embedded single quote"}, func(v any) string {
+		return "'" + strings.ReplaceAll(tplToString(v), "'", "''") + "'"
+	}},
+	{TemplateFuncDoc{"indent", "indent SPACES STRING", "indent every line of STRING by SPACES spaces"}, tplIndent},
+	{TemplateFuncDoc{"nindent", "nindent SPACES STRING", "same as indent, but prepends a newline (for use after a YAML key)"}, func(n int, s string) string { return "\n" + tplIndent(n, s) }},
+
+	// defaults and validation
+	{TemplateFuncDoc{"default", "default DEFAULT VALUE", "return VALUE, or DEFAULT when VALUE is empty"}, tplDefault},
+	{TemplateFuncDoc{"required", "required MESSAGE VALUE", "return VALUE, or fail rendering with MESSAGE when VALUE is empty"}, tplRequired},
+	{TemplateFuncDoc{"empty", "empty VALUE", "report whether VALUE is empty (zero value, empty string/list/map)"}, tplEmpty},
+	{TemplateFuncDoc{"coalesce", "coalesce VALUE...", "return the first non-empty VALUE"}, tplCoalesce},
+	{TemplateFuncDoc{"ternary", "ternary TRUE_VALUE FALSE_VALUE CONDITION", "return TRUE_VALUE when CONDITION is true, otherwise FALSE_VALUE"}, func(t, f any, cond bool) any {
+		if cond {
+			return t
+		}
+		return f
+	}},
+
+	// encoding
+	{TemplateFuncDoc{"toJson", "toJson VALUE", "encode VALUE as compact JSON"}, tplToJSON},
+	{TemplateFuncDoc{"toPrettyJson", "toPrettyJson VALUE", "encode VALUE as indented JSON"}, tplToPrettyJSON},
+	{TemplateFuncDoc{"fromJson", "fromJson STRING", "decode a JSON STRING into a value"}, tplFromJSON},
+	{TemplateFuncDoc{"toYaml", "toYaml VALUE", "encode VALUE as YAML (no trailing newline; pair with nindent)"}, tplToYAML},
+	{TemplateFuncDoc{"fromYaml", "fromYaml STRING", "decode a YAML STRING into a value"}, tplFromYAML},
+	{TemplateFuncDoc{"sha256sum", "sha256sum STRING", "hex-encoded SHA-256 digest of STRING"}, func(s string) string {
+		sum := sha256.Sum256([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}},
+
+	// regular expressions
+	{TemplateFuncDoc{"regexMatch", "regexMatch REGEX STRING", "report whether STRING matches REGEX"}, func(re, s string) (bool, error) { return regexp.MatchString(re, s) }},
+	{TemplateFuncDoc{"regexFind", "regexFind REGEX STRING", "return the first match of REGEX in STRING"}, func(re, s string) (string, error) {
+		r, err := regexp.Compile(re)
+		if err != nil {
+			return "", err
+		}
+		return r.FindString(s), nil
+	}},
+	{TemplateFuncDoc{"regexReplaceAll", "regexReplaceAll REGEX STRING REPLACEMENT", "replace every match of REGEX in STRING with REPLACEMENT ($1 etc. expand submatches)"}, func(re, s, repl string) (string, error) {
+		r, err := regexp.Compile(re)
+		if err != nil {
+			return "", err
+		}
+		return r.ReplaceAllString(s, repl), nil
+	}},
+
+	// lists and dicts
+	{TemplateFuncDoc{"list", "list VALUE...", "build a list from the given values"}, func(v ...any) []any { return v }},
+	{TemplateFuncDoc{"first", "first LIST", "return the first element of LIST, or nothing when empty"}, tplFirst},
+	{TemplateFuncDoc{"last", "last LIST", "return the last element of LIST, or nothing when empty"}, tplLast},
+	{TemplateFuncDoc{"has", "has VALUE LIST", "report whether LIST contains VALUE"}, tplHas},
+	{TemplateFuncDoc{"uniq", "uniq LIST", "return LIST with duplicate elements removed, preserving order"}, tplUniq},
+	{TemplateFuncDoc{"sortAlpha", "sortAlpha LIST", "return LIST converted to strings and sorted alphabetically"}, tplSortAlpha},
+	{TemplateFuncDoc{"dict", "dict KEY VALUE [KEY VALUE...]", "build a map from alternating keys and values"}, tplDict},
+	{TemplateFuncDoc{"keys", "keys DICT", "return the sorted keys of DICT"}, tplKeys},
+	{TemplateFuncDoc{"get", "get DICT KEY", "return DICT[KEY], or an empty string when absent"}, func(d map[string]any, k string) any {
+		if v, ok := d[k]; ok {
+			return v
+		}
+		return ""
+	}},
+	{TemplateFuncDoc{"set", "set DICT KEY VALUE", "set DICT[KEY] to VALUE and return DICT"}, func(d map[string]any, k string, v any) map[string]any {
+		d[k] = v
+		return d
+	}},
+
+	// upstream files; bound per render by templatedPartials.parse
+	{TemplateFuncDoc{"include", "include PATH", "render the upstream file at PATH (relative to the upstream root) with the current data and partials, and insert the result"}, includePlaceholder},
+}
+
+// templateFuncMap returns the text/template FuncMap for templated instructions.
+func templateFuncMap() template.FuncMap {
+	m := template.FuncMap{}
+	for _, f := range templateFuncs {
+		m[f.Name] = f.fn
+	}
+	return m
+}
+
+// TemplateFuncDocs returns documentation for every built-in template function
+// in a stable, grouped order, for display by `gitspork schema`.
+func TemplateFuncDocs() []TemplateFuncDoc {
+	docs := make([]TemplateFuncDoc, len(templateFuncs))
+	for i, f := range templateFuncs {
+		docs[i] = f.TemplateFuncDoc
+	}
+	return docs
+}
+
+func tplToString(v any) string {
+	switch s := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return s
+	case []byte:
+		return string(s)
+	case fmt.Stringer:
+		return s.String()
+	}
+	return fmt.Sprint(v)
+}
+
+func tplTitle(s string) string {
+	var b strings.Builder
+	prev := ' '
+	for _, r := range s {
+		if unicode.IsSpace(prev) {
+			r = unicode.ToUpper(r)
+		}
+		b.WriteRune(r)
+		prev = r
+	}
+	return b.String()
+}
+
+// tplWords splits s into lower-cased words on separators (space, '-', '_',
+// '.') and on lower→upper case transitions, so "myService-name" yields
+// ["my", "service", "name"].
+func tplWords(s string) []string {
+	var words []string
+	var cur []rune
+	flush := func() {
+		if len(cur) > 0 {
+			words = append(words, strings.ToLower(string(cur)))
+			cur = nil
+		}
+	}
+	runes := []rune(s)
+	for i, r := range runes {
+		switch {
+		case unicode.IsSpace(r) || r == '-' || r == '_' || r == '.':
+			flush()
+		case unicode.IsUpper(r) && i > 0 && unicode.IsLower(runes[i-1]):
+			flush()
+			cur = append(cur, r)
+		default:
+			cur = append(cur, r)
+		}
+	}
+	flush()
+	return words
+}
+
+func tplJoinWords(s, sep string) string {
+	return strings.Join(tplWords(s), sep)
+}
+
+func tplCamelCase(s string) string {
+	var b strings.Builder
+	for _, w := range tplWords(s) {
+		r := []rune(w)
+		r[0] = unicode.ToUpper(r[0])
+		b.WriteString(string(r))
+	}
+	return b.String()
+}
+
+func tplIndent(n int, s string) string {
+	pad := strings.Repeat(" ", n)
+	return pad + strings.ReplaceAll(s, "\n", "\n"+pad)
+}
+
+// tplList converts any slice or array into []any so list helpers accept both
+// []string (e.g. from split) and []any (e.g. from list or fromJson).
+func tplList(v any) ([]any, error) {
+	if v == nil {
+		return nil, nil
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, fmt.Errorf("expected a list, got %T", v)
+	}
+	out := make([]any, rv.Len())
+	for i := range out {
+		out[i] = rv.Index(i).Interface()
+	}
+	return out, nil
+}
+
+func tplJoin(sep string, v any) (string, error) {
+	items, err := tplList(v)
+	if err != nil {
+		return "", fmt.Errorf("join: %v", err)
+	}
+	parts := make([]string, len(items))
+	for i, item := range items {
+		parts[i] = tplToString(item)
+	}
+	return strings.Join(parts, sep), nil
+}
+
+func tplEmpty(v any) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		return rv.Len() == 0
+	case reflect.Pointer, reflect.Interface:
+		return rv.IsNil()
+	}
+	return rv.IsZero()
+}
+
+func tplDefault(def any, v ...any) any {
+	if len(v) == 0 || tplEmpty(v[0]) {
+		return def
+	}
+	return v[0]
+}
+
+func tplRequired(msg string, v any) (any, error) {
+	if tplEmpty(v) {
+		return nil, errors.New(msg)
+	}
+	return v, nil
+}
+
+func tplCoalesce(v ...any) any {
+	for _, item := range v {
+		if !tplEmpty(item) {
+			return item
+		}
+	}
+	return nil
+}
+
+func tplToJSON(v any) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("toJson: %v", err)
+	}
+	return string(b), nil
+}
+
+func tplToPrettyJSON(v any) (string, error) {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("toPrettyJson: %v", err)
+	}
+	return string(b), nil
+}
+
+func tplFromJSON(s string) (any, error) {
+	var v any
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		return nil, fmt.Errorf("fromJson: %v", err)
+	}
+	return v, nil
+}
+
+func tplToYAML(v any) (string, error) {
+	b, err := yaml.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("toYaml: %v", err)
+	}
+	return strings.TrimSuffix(string(b), "\n"), nil
+}
+
+func tplFromYAML(s string) (any, error) {
+	var v any
+	if err := yaml.Unmarshal([]byte(s), &v); err != nil {
+		return nil, fmt.Errorf("fromYaml: %v", err)
+	}
+	return v, nil
+}
+
+func tplFirst(v any) (any, error) {
+	items, err := tplList(v)
+	if err != nil {
+		return nil, fmt.Errorf("first: %v", err)
+	}
+	if len(items) == 0 {
+		return nil, nil
+	}
+	return items[0], nil
+}
+
+func tplLast(v any) (any, error) {
+	items, err := tplList(v)
+	if err != nil {
+		return nil, fmt.Errorf("last: %v", err)
+	}
+	if len(items) == 0 {
+		return nil, nil
+	}
+	return items[len(items)-1], nil
+}
+
+func tplHas(needle any, v any) (bool, error) {
+	items, err := tplList(v)
+	if err != nil {
+		return false, fmt.Errorf("has: %v", err)
+	}
+	for _, item := range items {
+		if reflect.DeepEqual(item, needle) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func tplUniq(v any) ([]any, error) {
+	items, err := tplList(v)
+	if err != nil {
+		return nil, fmt.Errorf("uniq: %v", err)
+	}
+	out := []any{}
+	for _, item := range items {
+		seen := false
+		for _, o := range out {
+			if reflect.DeepEqual(o, item) {
+				seen = true
+				break
+			}
+		}
+		if !seen {
+			out = append(out, item)
+		}
+	}
+	return out, nil
+}
+
+func tplSortAlpha(v any) ([]string, error) {
+	items, err := tplList(v)
+	if err != nil {
+		return nil, fmt.Errorf("sortAlpha: %v", err)
+	}
+	out := make([]string, len(items))
+	for i, item := range items {
+		out[i] = tplToString(item)
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+func tplDict(kv ...any) (map[string]any, error) {
+	if len(kv)%2 != 0 {
+		return nil, fmt.Errorf("dict: expected an even number of arguments, got %d", len(kv))
+	}
+	d := make(map[string]any, len(kv)/2)
+	for i := 0; i < len(kv); i += 2 {
+		d[tplToString(kv[i])] = kv[i+1]
+	}
+	return d, nil
+}
+
+func tplKeys(v any) ([]string, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Map {
+		return nil, fmt.Errorf("keys: expected a dict, got %T", v)
+	}
+	out := make([]string, 0, rv.Len())
+	for _, k := range rv.MapKeys() {
+		out = append(out, tplToString(k.Interface()))
+	}
+	sort.Strings(out)
+	return out, nil
+}
@@ -0,0 +1,123 @@
+package integrate
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"text/template"
+
+	"github.com/rockholla/gitspork/v2/internal/config"
+	"github.com/rockholla/gitspork/v2/internal/sdktypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func renderWithFuncs(t *testing.T, tmpl string, data any) (string, error) {
+	t.Helper()
+	parsed, err := template.New("").Funcs(templateFuncMap()).Parse(tmpl)
+	require.NoError(t, err)
+	var out bytes.Buffer
+	err = parsed.Execute(&out, data)
+	return out.String(), err
+}
+
+func TestTemplateFuncs_render(t *testing.T) {
+	data := IntegratorTemplatedData{Inputs: map[string]any{
+		"name":  "My Service",
+		"empty": "",
+		"csv":   "b,a,b",
+		"json":  `{"k":"v","n":[1,2]}`,
+	}}
+	cases := []struct {
+		name string
+		tmpl string
+		want string
+	}{
+		{"lower", `{{ .Inputs.name | lower }}`, "my service"},
+		{"upper", `{{ .Inputs.name | upper }}`, "MY SERVICE"},
+		{"title", `{{ "hello big world" | title }}`, "Hello Big World"},
+		{"snakecase", `{{ .Inputs.name | snakecase }}`, "my_service"},
+		{"kebabcase", `{{ "myServiceName" | kebabcase }}`, "my-service-name"},
+		{"camelcase", `{{ "my-service_name" | camelcase }}`, "MyServiceName"},
+		{"trim", `{{ "  x  " | trim }}`, "x"},
+		{"trimPrefix", `{{ "v1.2.3" | trimPrefix "v" }}`, "1.2.3"},
+		{"trimSuffix", `{{ "repo.git" | trimSuffix ".git" }}`, "repo"},
+		{"replace", `{{ .Inputs.name | replace " " "-" }}`, "My-Service"},
+		{"contains", `{{ .Inputs.name | contains "Serv" }}`, "true"},
+		{"split and join", `{{ .Inputs.csv | split "," | join "|" }}`, "b|a|b"},
+		{"quote", `{{ .Inputs.name | quote }}`, `"My Service"`},
+		{"quote escapes", `{{ "say \"hi\"" | quote }}`, `"say \"hi\""`},
+		{"squote", `{{ "it's" | squote }}`, `'it''s'`},
+		{"indent", `{{ "a\nb" | indent 2 }}`, "  a\n  b"},
+		{"nindent", `key:{{ "a: 1\nb: 2" | nindent 2 }}`, "key:\n  a: 1\n  b: 2"},
+		{"default on empty", `{{ .Inputs.empty | default "fallback" }}`, "fallback"},
+		{"default on missing", `{{ .Inputs.missing | default "fallback" }}`, "fallback"},
+		{"default keeps value", `{{ .Inputs.name | default "fallback" }}`, "My Service"},
+		{"coalesce", `{{ coalesce .Inputs.empty "" "third" }}`, "third"},
+		{"ternary", `{{ ternary "yes" "no" (eq .Inputs.name "My Service") }}`, "yes"},
+		{"toJson", `{{ dict "a" 1 "b" (list "x" "y") | toJson }}`, `{"a":1,"b":["x","y"]}`},
+		{"fromJson", `{{ (fromJson .Inputs.json).k }}`, "v"},
+		{"toYaml", `{{ dict "a" "b" | toYaml }}`, "a: b"},
+		{"fromYaml", `{{ (fromYaml "x: 1\ny: two").y }}`, "two"},
+		{"regexMatch", `{{ regexMatch "^[a-z]+$" "abc" }}`, "true"},
+		{"regexFind", `{{ regexFind "[0-9]+" "abc123def" }}`, "123"},
+		{"regexReplaceAll", `{{ regexReplaceAll "([a-z]+)-([0-9]+)" "svc-42" "$2-$1" }}`, "42-svc"},
+		{"sha256sum", `{{ "abc" | sha256sum }}`, "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{"first/last", `{{ first (list 1 2 3) }}{{ last (list 1 2 3) }}`, "13"},
+		{"has", `{{ has "a" (split "," .Inputs.csv) }}`, "true"},
+		{"uniq", `{{ .Inputs.csv | split "," | uniq | join "," }}`, "b,a"},
+		{"sortAlpha", `{{ .Inputs.csv | split "," | sortAlpha | join "," }}`, "a,b,b"},
+		{"keys", `{{ keys (dict "b" 1 "a" 2) | join "," }}`, "a,b"},
+		{"get/set", `{{ $d := dict "a" 1 }}{{ $_ := set $d "b" 2 }}{{ get $d "b" }}{{ get $d "zz" }}`, "2"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := renderWithFuncs(t, tc.tmpl, data)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestTemplateFuncs_requiredFailsRenderWithMessage(t *testing.T) {
+	_, err := renderWithFuncs(t, `{{ required "service_name must be set" .Inputs.service_name }}`,
+		IntegratorTemplatedData{Inputs: map[string]any{}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "service_name must be set")
+}
+
+func TestTemplateFuncs_invalidRegexSurfacesError(t *testing.T) {
+	_, err := renderWithFuncs(t, `{{ regexFind "(" "x" }}`, nil)
+	require.Error(t, err)
+}
+
+func TestTemplateFuncDocs_coverEveryFunction(t *testing.T) {
+	docs := TemplateFuncDocs()
+	funcMap := templateFuncMap()
+	require.Len(t, docs, len(funcMap), "every function in the FuncMap must be documented exactly once")
+	for _, d := range docs {
+		assert.Contains(t, funcMap, d.Name)
+		assert.NotEmpty(t, d.Signature, "%s: signature must be documented", d.Name)
+		assert.NotEmpty(t, d.Description, "%s: description must be documented", d.Name)
+	}
+}
+
+// TestIntegratorTemplated_usesFunctionLibrary confirms the integrator wires
+// the FuncMap into template parsing; a template referencing a library
+// function would otherwise fail at Parse with "function not defined".
+func TestIntegratorTemplated_usesFunctionLibrary(t *testing.T) {
+	upstreamDir, downstreamDir := setupTemplatedFixture(t,
+		`name: {{ index .Inputs "name" | lower | quote }}`,
+		`{"name":"World"}`,
+	)
+	instructions := []config.GitSporkConfigTemplated{{
+		Template:    "template.txt",
+		Destination: "rendered.yaml",
+		Inputs:      []config.GitSporkConfigTemplatedInput{{Name: "name", JSONDataPath: "inputs.json"}},
+	}}
+	require.NoError(t, (&IntegratorTemplated{}).Integrate(instructions, upstreamDir, downstreamDir, false, sdktypes.NoopLogger()))
+	got, err := os.ReadFile(filepath.Join(downstreamDir, "rendered.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, `name: "world"`, string(got))
+}
@@ -0,0 +1,152 @@
+package integrate
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	gogit "github.com/go-git/go-git/v6"
+	"github.com/go-git/go-git/v6/plumbing"
+)
+
+// TemplatedMetadata is the built-in context rendered templates receive
+// alongside their inputs, as .Gitspork, .Downstream and .Upstream.
+type TemplatedMetadata struct {
+	Gitspork   TemplatedGitsporkData
+	Downstream TemplatedDownstreamData
+	Upstream   TemplatedUpstreamData
+}
+
+// TemplatedGitsporkData describes the integration itself.
+type TemplatedGitsporkData struct {
+	// Time is when the upstream commit was integrated, in UTC. It is kept
+	// from the downstream state while the commit is unchanged, so re-running
+	// integrate (or check-drift) renders the same value.
+	Time time.Time `json:"time"`
+	// Date is Time formatted as YYYY-MM-DD.
+	Date string `json:"date"`
+}
+
+// TemplatedDownstreamData describes the downstream repo being integrated into.
+type TemplatedDownstreamData struct {
+	// Name is the repo name from the origin remote URL, or the downstream
+	// directory name when there is no origin.
+	Name string `json:"name"`
+	// RemoteURL is the origin remote URL with any credentials removed.
+	RemoteURL string `json:"remote_url"`
+	// DefaultBranch is origin's default branch (refs/remotes/origin/HEAD),
+	// falling back to the checked-out branch.
+	DefaultBranch string `json:"default_branch"`
+}
+
+// TemplatedUpstreamData describes the upstream being integrated.
+type TemplatedUpstreamData struct {
+	URL     string `json:"url"`
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Subpath string `json:"subpath"`
+	// Commit is the full upstream commit hash, empty for a local upstream
+	// that isn't a git repo.
+	Commit      string `json:"commit"`
+	ShortCommit string `json:"short_commit"`
+}
+
+// newTemplatedGitsporkData returns the .Gitspork context for an integration at t.
+func newTemplatedGitsporkData(t time.Time) TemplatedGitsporkData {
+	t = t.UTC().Truncate(time.Second)
+	return TemplatedGitsporkData{Time: t, Date: t.Format(time.DateOnly)}
+}
+
+// newTemplatedUpstreamData returns the .Upstream context for an upstream at commit.
+func newTemplatedUpstreamData(upstreamURL, version, subpath, commit string) TemplatedUpstreamData {
+	short := commit
+	if len(short) > 7 {
+		short = short[:7]
+	}
+	return TemplatedUpstreamData{
+		URL:         redactRemoteURL(upstreamURL),
+		Name:        repoNameFromURL(upstreamURL),
+		Version:     version,
+		Subpath:     subpath,
+		Commit:      commit,
+		ShortCommit: short,
+	}
+}
+
+// downstreamTemplatedData reads the .Downstream context from the git repo at
+// repoPath. It is best-effort: a path that isn't a git repo, or has no origin,
+// yields just the directory name.
+func downstreamTemplatedData(repoPath string) TemplatedDownstreamData {
+	if abs, err := filepath.Abs(repoPath); err == nil {
+		repoPath = abs
+	}
+	data := TemplatedDownstreamData{Name: filepath.Base(repoPath)}
+	repo, err := gogit.PlainOpen(repoPath)
+	if err != nil {
+		return data
+	}
+	if origin, err := repo.Remote("origin"); err == nil && len(origin.Config().URLs) > 0 {
+		data.RemoteURL = redactRemoteURL(origin.Config().URLs[0])
+		if name := repoNameFromURL(data.RemoteURL); name != "" {
+			data.Name = name
+		}
+	}
+	if ref, err := repo.Reference(plumbing.NewRemoteHEADReferenceName("origin"), false); err == nil && ref.Type() == plumbing.SymbolicReference {
+		data.DefaultBranch = strings.TrimPrefix(ref.Target().String(), "refs/remotes/origin/")
+	} else if head, err := repo.Reference(plumbing.HEAD, false); err == nil && head.Type() == plumbing.SymbolicReference {
+		// Read HEAD unresolved so a downstream with no commits yet still names its branch.
+		data.DefaultBranch = head.Target().Short()
+	}
+	return data
+}
+
+// localUpstreamCommit returns the HEAD commit of the git repo containing the
+// local upstream path, or "" when it isn't inside one.
+func localUpstreamCommit(upstreamPath string) string {
+	repo, err := gogit.PlainOpenWithOptions(upstreamPath, &gogit.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return ""
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return ""
+	}
+	return head.Hash().String()
+}
+
+// upstreamCommitTime returns the committer time of commit in the upstream
+// clone at cloneDir.
+func upstreamCommitTime(cloneDir string, commit string) (time.Time, error) {
+	repo, err := gogit.PlainOpen(cloneDir)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error opening upstream clone %s: %v", cloneDir, err)
+	}
+	c, err := repo.CommitObject(plumbing.NewHash(commit))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error reading upstream commit %s: %v", commit, err)
+	}
+	return c.Committer.When, nil
+}
+
+// repoNameFromURL returns the last path segment of a git remote URL or local
+// path, without a trailing .git: "git@github.com:org/repo.git" → "repo".
+func repoNameFromURL(remoteURL string) string {
+	name := strings.TrimSuffix(strings.TrimRight(remoteURL, "/"), ".git")
+	if i := strings.LastIndexAny(name, "/:\\"); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+// redactRemoteURL drops the userinfo from http(s) URLs so tokens embedded by
+// CI checkouts never end up in rendered files.
+func redactRemoteURL(remoteURL string) string {
+	u, err := url.Parse(remoteURL)
+	if err != nil || u.User == nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return remoteURL
+	}
+	u.User = nil
+	return u.String()
+}
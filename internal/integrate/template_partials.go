@@ -0,0 +1,194 @@
+package integrate
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+)
+
+// templatedPartials is the template set shared by every templated instruction
+// of an upstream: the built-in functions plus each template_partials file,
+// parsed under its upstream-relative path so its {{ define }} blocks (and the
+// file itself) can be used from any template via {{ template }}.
+type templatedPartials struct {
+	upstreamPath string
+	set          *template.Template
+	// files holds the slash-form upstream-relative paths of the partials.
+	files map[string]bool
+	// definedIn maps each template name in set to the partial it came from,
+	// to reject a name defined twice.
+	definedIn map[string]string
+}
+
+// loadTemplatedPartials parses every upstream file matching patterns. A name
+// defined by two different partials is an error rather than letting the
+// later file silently win.
+func loadTemplatedPartials(upstreamPath string, patterns []string) (*templatedPartials, error) {
+	p := &templatedPartials{
+		upstreamPath: upstreamPath,
+		set:          template.New("").Funcs(templateFuncMap()),
+		files:        map[string]bool{},
+		definedIn:    map[string]string{},
+	}
+	if len(patterns) == 0 {
+		return p, nil
+	}
+	globs, err := compileTemplatedDirGlobs(patterns)
+	if err != nil {
+		return nil, err
+	}
+	err = filepath.WalkDir(upstreamPath, func(full string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(upstreamPath, full)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if matchesAnyGlob(globs, rel) {
+			p.files[rel] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error finding template_partials: %v", err)
+	}
+
+	files := make([]string, 0, len(p.files))
+	for f := range p.files {
+		files = append(files, f)
+	}
+	slices.Sort(files)
+	for _, f := range files {
+		contents, err := os.ReadFile(filepath.Join(upstreamPath, filepath.FromSlash(f)))
+		if err != nil {
+			return nil, fmt.Errorf("error reading partial %s: %v", f, err)
+		}
+		parsed, err := template.New(f).Funcs(templateFuncMap()).Parse(string(contents))
+		if err != nil {
+			return nil, fmt.Errorf("error parsing partial %s: %v", f, err)
+		}
+		for _, t := range parsed.Templates() {
+			if other, ok := p.definedIn[t.Name()]; ok {
+				return nil, fmt.Errorf("template %q is defined in both partials %s and %s", t.Name(), other, f)
+			}
+			if _, err := p.set.AddParseTree(t.Name(), t.Tree); err != nil {
+				return nil, fmt.Errorf("error loading partial %s: %v", f, err)
+			}
+			p.definedIn[t.Name()] = f
+		}
+	}
+	return p, nil
+}
+
+// includePlaceholder is include's entry in the built-in function library, so
+// templates calling it parse; templatedPartials.parse binds the real one.
+func includePlaceholder(string) (string, error) {
+	return "", errors.New("include is not available here")
+}
+
+// isPartial reports whether the slash-form upstream-relative path is a
+// partial, which template_dir rendering skips.
+func (p *templatedPartials) isPartial(rel string) bool {
+	return p.files[rel]
+}
+
+// parse parses text as the template name within a copy of the partials set,
+// binding include to render other upstream files with data.
+func (p *templatedPartials) parse(name string, text string, data IntegratorTemplatedData) (*template.Template, error) {
+	return p.parseIncluding(name, text, data, nil)
+}
+
+func (p *templatedPartials) parseIncluding(name string, text string, data IntegratorTemplatedData, includeStack []string) (*template.Template, error) {
+	set, err := p.set.Clone()
+	if err != nil {
+		return nil, err
+	}
+	includeStack = append(slices.Clip(includeStack), name)
+	return set.New(name).Funcs(template.FuncMap{
+		"include": func(includePath string) (string, error) {
+			return p.include(includePath, data, includeStack)
+		},
+	}).Parse(text)
+}
+
+// execute runs t with data. Partials keep their file as the parse name, so an
+// error inside a {{ define }} block already reads "template: <partial>:<line>".
+func (p *templatedPartials) execute(t *template.Template, data IntegratorTemplatedData) ([]byte, error) {
+	var out bytes.Buffer
+	if err := t.Execute(&out, data); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// render parses and executes text as the template name.
+func (p *templatedPartials) render(name string, text string, data IntegratorTemplatedData) (string, error) {
+	t, err := p.parse(name, text, data)
+	if err != nil {
+		return "", err
+	}
+	out, err := p.execute(t, data)
+	return string(out), err
+}
+
+// include renders the upstream file at includePath (relative to the upstream
+// root) with the including template's data and partials.
+func (p *templatedPartials) include(includePath string, data IntegratorTemplatedData, includeStack []string) (string, error) {
+	includePath = path.Clean(filepath.ToSlash(includePath))
+	if !filepath.IsLocal(filepath.FromSlash(includePath)) {
+		return "", fmt.Errorf("include path %s is outside the upstream", includePath)
+	}
+	if slices.Contains(includeStack, includePath) {
+		return "", fmt.Errorf("include cycle: %s -> %s", strings.Join(includeStack, " -> "), includePath)
+	}
+	full, err := confineUpstreamPath(includePath, p.upstreamPath)
+	if err != nil {
+		return "", err
+	}
+	contents, err := os.ReadFile(full)
+	if err != nil {
+		return "", fmt.Errorf("error reading included file %s: %v", includePath, err)
+	}
+	t, err := p.parseIncluding(includePath, string(contents), data, includeStack)
+	if err != nil {
+		return "", fmt.Errorf("error parsing included file %s: %v", includePath, err)
+	}
+	out, err := p.execute(t, data)
+	if err != nil {
+		return "", fmt.Errorf("error rendering included file %s: %v", includePath, err)
+	}
+	return string(out), nil
+}
+
+// confineUpstreamPath resolves the slash-form upstream-relative path rel,
+// following symlinks, and checks that it stays within the upstream clone, so
+// an upstream symlink can't pull in a file from elsewhere on the machine.
+func confineUpstreamPath(rel string, upstreamPath string) (string, error) {
+	realRoot, err := filepath.EvalSymlinks(upstreamPath)
+	if err != nil {
+		return "", fmt.Errorf("error resolving upstream path %s: %v", upstreamPath, err)
+	}
+	realPath, err := filepath.EvalSymlinks(filepath.Join(upstreamPath, filepath.FromSlash(rel)))
+	if err != nil {
+		return "", fmt.Errorf("error reading included file %s: %v", rel, err)
+	}
+	if within, err := filepath.Rel(realRoot, realPath); err != nil || !filepath.IsLocal(within) {
+		return "", fmt.Errorf("include path %s resolves outside the upstream", rel)
+	}
+	return realPath, nil
+}
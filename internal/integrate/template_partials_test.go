@@ -0,0 +1,132 @@
+package integrate
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/rockholla/gitspork/v2/internal/config"
+	"github.com/rockholla/gitspork/v2/internal/sdktypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIntegratorTemplated_partials(t *testing.T) {
+	upstreamDir, downstreamDir := setupTemplatedDirFixture(t, map[string]string{
+		"partials/header.tmpl":    `{{ define "header" }}# managed by {{ .Upstream.Name }} for {{ .Inputs.name }}{{ end }}`,
+		"partials/footer.tmpl":    `{{ define "footer" }}-- {{ include "snippets/sig.txt" }}{{ end }}`,
+		"snippets/sig.txt":        `{{ .Inputs.name | upper }}`,
+		"tmpl/README.md":          "{{ template \"header\" . }}\n{{ include \"snippets/body.txt\" }}\n{{ template \"footer\" . }}",
+		"snippets/body.txt":       `body of {{ .Inputs.name }}`,
+		"dir/partials/local.tmpl": `{{ define "local" }}local{{ end }}`,
+		"dir/file.txt":            `{{ template "local" }} {{ template "header" . }}`,
+	})
+	integrator := &IntegratorTemplated{
+		Inputs:   map[string]any{"name": "billing"},
+		Metadata: TemplatedMetadata{Upstream: TemplatedUpstreamData{Name: "platform"}},
+		Partials: []string{"partials/*.tmpl", "dir/partials/*"},
+	}
+	instructions := []config.GitSporkConfigTemplated{
+		{Template: "tmpl/README.md", Destination: "README.md", Inputs: []config.GitSporkConfigTemplatedInput{{Name: "name", Prompt: "name?"}}},
+		{TemplateDir: "dir", DestinationDir: "out", Inputs: []config.GitSporkConfigTemplatedInput{{Name: "name", Prompt: "name?"}}},
+	}
+	require.NoError(t, integrator.Integrate(instructions, upstreamDir, downstreamDir, false, sdktypes.NoopLogger()))
+
+	t.Run("defines and includes render with the template's data", func(t *testing.T) {
+		b, err := os.ReadFile(filepath.Join(downstreamDir, "README.md"))
+		require.NoError(t, err)
+		assert.Equal(t, "# managed by platform for billing\nbody of billing\n-- BILLING", string(b))
+	})
+	t.Run("template_dir files can use partials, which aren't rendered themselves", func(t *testing.T) {
+		b, err := os.ReadFile(filepath.Join(downstreamDir, "out/file.txt"))
+		require.NoError(t, err)
+		assert.Equal(t, "local # managed by platform for billing", string(b))
+		assert.NoFileExists(t, filepath.Join(downstreamDir, "out/partials/local.tmpl"))
+	})
+}
+
+func TestIntegratorTemplated_partialErrors(t *testing.T) {
+	cases := []struct {
+		name     string
+		files    map[string]string
+		partials []string
+		wantErr  []string
+	}{
+		{
+			name:     "parse error names the partial",
+			files:    map[string]string{"partials/bad.tmpl": `{{ define "x" }}{{ .Oops `, "template.txt": `x`},
+			partials: []string{"partials/*"},
+			wantErr:  []string{"error parsing partial partials/bad.tmpl"},
+		},
+		{
+			name: "duplicate define names both partials",
+			files: map[string]string{
+				"partials/a.tmpl": `{{ define "header" }}a{{ end }}`,
+				"partials/b.tmpl": `{{ define "header" }}b{{ end }}`,
+				"template.txt":    `x`,
+			},
+			partials: []string{"partials/*"},
+			wantErr:  []string{`template "header" is defined in both partials partials/a.tmpl and partials/b.tmpl`},
+		},
+		{
+			name: "execution error inside a define names the partial",
+			files: map[string]string{
+				"partials/a.tmpl": `{{ define "header" }}{{ required "name is required" .Inputs.missing }}{{ end }}`,
+				"template.txt":    `{{ template "header" . }}`,
+			},
+			partials: []string{"partials/*"},
+			wantErr:  []string{"template: partials/a.tmpl:1:", "name is required"},
+		},
+		{
+			name:    "include cycle",
+			files:   map[string]string{"a.txt": `{{ include "b.txt" }}`, "b.txt": `{{ include "a.txt" }}`, "template.txt": `{{ include "a.txt" }}`},
+			wantErr: []string{"include cycle: template.txt -> a.txt -> b.txt -> a.txt"},
+		},
+		{
+			name:    "include outside the upstream",
+			files:   map[string]string{"template.txt": `{{ include "../secret" }}`},
+			wantErr: []string{"include path ../secret is outside the upstream"},
+		},
+		{
+			name:    "missing include",
+			files:   map[string]string{"template.txt": `{{ include "nope.txt" }}`},
+			wantErr: []string{"error reading included file nope.txt"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			upstreamDir, downstreamDir := setupTemplatedDirFixture(t, tc.files)
+			instructions := []config.GitSporkConfigTemplated{{Template: "template.txt", Destination: "out.txt"}}
+			err := (&IntegratorTemplated{Partials: tc.partials}).Integrate(instructions, upstreamDir, downstreamDir, false, sdktypes.NoopLogger())
+			require.Error(t, err)
+			for _, want := range tc.wantErr {
+				assert.Contains(t, err.Error(), want)
+			}
+		})
+	}
+}
+
+func TestIntegratorTemplated_includeSymlinks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("creating symlinks needs privileges on Windows")
+	}
+	secret := filepath.Join(t.TempDir(), "id_rsa")
+	require.NoError(t, os.WriteFile(secret, []byte("PRIVATE KEY"), 0600))
+	upstreamDir, downstreamDir := setupTemplatedDirFixture(t, map[string]string{
+		"snippets/body.txt": `body`,
+		"template.txt":      `{{ include "snippets/link.txt" }}`,
+		"leak.txt":          `{{ include "snippets/key.txt" }}`,
+	})
+	require.NoError(t, os.Symlink("body.txt", filepath.Join(upstreamDir, "snippets", "link.txt")))
+	require.NoError(t, os.Symlink(secret, filepath.Join(upstreamDir, "snippets", "key.txt")))
+
+	instructions := []config.GitSporkConfigTemplated{{Template: "template.txt", Destination: "out.txt"}}
+	require.NoError(t, (&IntegratorTemplated{}).Integrate(instructions, upstreamDir, downstreamDir, false, sdktypes.NoopLogger()))
+	assert.Equal(t, "body", readFileString(t, filepath.Join(downstreamDir, "out.txt")))
+
+	instructions = []config.GitSporkConfigTemplated{{Template: "leak.txt", Destination: "leak.txt"}}
+	err := (&IntegratorTemplated{}).Integrate(instructions, upstreamDir, downstreamDir, false, sdktypes.NoopLogger())
+	require.ErrorContains(t, err, "include path snippets/key.txt resolves outside the upstream")
+	assert.NoFileExists(t, filepath.Join(downstreamDir, "leak.txt"))
+}
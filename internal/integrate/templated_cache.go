@@ -12,17 +12,18 @@ const templatedInputsCacheFileName = "templated-inputs.json"
 // loadTemplatedInputs reads the consolidated templated-inputs cache at
 // <downstream>/.gitspork/templated-inputs.json. Returns an empty map (not nil) and
 // nil error if the file doesn't exist so callers can look up destinations without
-// nil-checking.
-func loadTemplatedInputs(downstreamPath string) (map[string]map[string]string, error) {
+// nil-checking. Values are decoded as generic JSON (string, bool, float64, []any);
+// resolveTemplatedInputValue converts them back to each input's declared type.
+func loadTemplatedInputs(downstreamPath string) (map[string]map[string]any, error) {
 	path := filepath.Join(downstreamPath, gitSporkMetaDirName, templatedInputsCacheFileName)
 	b, err := os.ReadFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return map[string]map[string]string{}, nil
+			return map[string]map[string]any{}, nil
 		}
 		return nil, fmt.Errorf("reading %s: %w", path, err)
 	}
-	out := map[string]map[string]string{}
+	out := map[string]map[string]any{}
 	if err := json.Unmarshal(b, &out); err != nil {
 		return nil, fmt.Errorf("parsing %s: %w", path, err)
 	}
@@ -33,7 +34,7 @@ func loadTemplatedInputs(downstreamPath string) (map[string]map[string]string, e
 // are responsible for including only currently-configured destinations — entries not
 // in the passed map are pruned by construction. Go's json.Marshal sorts map keys
 // alphabetically, so file bytes are deterministic across runs.
-func saveTemplatedInputs(downstreamPath string, inputs map[string]map[string]string) error {
+func saveTemplatedInputs(downstreamPath string, inputs map[string]map[string]any) error {
 	dir := filepath.Join(downstreamPath, gitSporkMetaDirName)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return fmt.Errorf("ensuring %s: %w", dir, err)
@@ -45,3 +46,48 @@ func saveTemplatedInputs(downstreamPath string, inputs map[string]map[string]str
 	path := filepath.Join(dir, templatedInputsCacheFileName)
 	return os.WriteFile(path, b, 0644)
 }
+
+const templatedRenderedCacheFileName = "templated-rendered.json"
+
+// loadTemplatedRendered reads <downstream>/.gitspork/templated-rendered.json,
+// which records, per templated destination, the files the last render
+// produced so they can be removed once no longer rendered: for a template_dir,
+// paths relative to destination_dir; for a conditional (`when`) single-file
+// destination, ".". Returns an empty map and nil error if the file doesn't
+// exist.
+func loadTemplatedRendered(downstreamPath string) (map[string][]string, error) {
+	path := filepath.Join(downstreamPath, gitSporkMetaDirName, templatedRenderedCacheFileName)
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string][]string{}, nil
+		}
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	out := map[string][]string{}
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return out, nil
+}
+
+// saveTemplatedRendered writes the rendered-files record, removing the file
+// altogether once no destinations remain so downstreams without directory or
+// conditional templates never carry it.
+func saveTemplatedRendered(downstreamPath string, rendered map[string][]string) error {
+	path := filepath.Join(downstreamPath, gitSporkMetaDirName, templatedRenderedCacheFileName)
+	if len(rendered) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("removing %s: %w", path, err)
+		}
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("ensuring %s: %w", filepath.Dir(path), err)
+	}
+	b, err := json.Marshal(rendered)
+	if err != nil {
+		return fmt.Errorf("marshaling templated rendered files: %w", err)
+	}
+	return os.WriteFile(path, b, 0644)
+}
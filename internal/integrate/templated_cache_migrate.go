@@ -53,10 +53,11 @@ func migrateLegacyTemplatedCache(downstreamPath string) error {
 		if err := json.Unmarshal(b, &legacy); err != nil {
 			return fmt.Errorf("parsing legacy cache %s: %w", legacyPath, err)
 		}
-		if legacy.Inputs == nil {
-			legacy.Inputs = map[string]string{}
+		inputs := map[string]any{}
+		for k, v := range legacy.Inputs {
+			inputs[k] = v
 		}
-		consolidated[destination] = legacy.Inputs
+		consolidated[destination] = inputs
 	}
 
 	if err := saveTemplatedInputs(downstreamPath, consolidated); err != nil {
@@ -86,7 +87,7 @@ func findLegacyTemplatedCacheFiles(cacheDir string) ([]string, error) {
 		if err != nil {
 			return err
 		}
-		if rel == downstreamStateFileName || rel == templatedInputsCacheFileName {
+		if rel == downstreamStateFileName || rel == templatedInputsCacheFileName || rel == templatedRenderedCacheFileName {
 			return nil
 		}
 		out = append(out, path)
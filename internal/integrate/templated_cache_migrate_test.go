@@ -22,16 +22,20 @@ func TestMigrateLegacyTemplatedCache_noopWhenOnlyProtectedFiles(t *testing.T) {
 	require.NoError(t, os.MkdirAll(cacheDir, 0755))
 	require.NoError(t, os.WriteFile(filepath.Join(cacheDir, "downstream-state.json"), []byte(`{"upstreams":[]}`), 0644))
 	require.NoError(t, os.WriteFile(filepath.Join(cacheDir, templatedInputsCacheFileName), []byte(`{"docs/api.md":{"k":"v"}}`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(cacheDir, templatedRenderedCacheFileName), []byte(`{"services":["a.txt"]}`), 0644))
 
 	require.NoError(t, migrateLegacyTemplatedCache(dir))
 
-	// both protected files must survive untouched
+	// all protected files must survive untouched
 	stateBytes, err := os.ReadFile(filepath.Join(cacheDir, "downstream-state.json"))
 	require.NoError(t, err)
 	assert.Equal(t, `{"upstreams":[]}`, string(stateBytes))
 	consBytes, err := os.ReadFile(filepath.Join(cacheDir, templatedInputsCacheFileName))
 	require.NoError(t, err)
 	assert.Equal(t, `{"docs/api.md":{"k":"v"}}`, string(consBytes))
+	renderedBytes, err := os.ReadFile(filepath.Join(cacheDir, templatedRenderedCacheFileName))
+	require.NoError(t, err)
+	assert.Equal(t, `{"services":["a.txt"]}`, string(renderedBytes))
 }
 
 func TestMigrateLegacyTemplatedCache_migratesTopLevelFile(t *testing.T) {
@@ -34,7 +34,7 @@ func TestLoadTemplatedInputs_parsesExistingCache(t *testing.T) {
 
 func TestSaveTemplatedInputs_writesFileAndCreatesDir(t *testing.T) {
 	dir := t.TempDir()
-	inputs := map[string]map[string]string{
+	inputs := map[string]map[string]any{
 		"docs/api.md": {"name": "alice"},
 	}
 	require.NoError(t, saveTemplatedInputs(dir, inputs))
@@ -47,7 +47,7 @@ func TestSaveTemplatedInputs_writesFileAndCreatesDir(t *testing.T) {
 
 func TestSaveTemplatedInputs_deterministicKeyOrder(t *testing.T) {
 	dir := t.TempDir()
-	inputs := map[string]map[string]string{
+	inputs := map[string]map[string]any{
 		"z-last":   {"z-key": "z-val", "a-key": "a-val"},
 		"a-first":  {"z-key": "z-val", "a-key": "a-val"},
 		"m-middle": {"z-key": "z-val", "a-key": "a-val"},
@@ -69,7 +69,7 @@ func TestSaveTemplatedInputs_deterministicKeyOrder(t *testing.T) {
 
 func TestTemplatedInputs_roundTrip(t *testing.T) {
 	dir := t.TempDir()
-	original := map[string]map[string]string{
+	original := map[string]map[string]any{
 		"docs/api.md": {"name": "alice", "role": "admin"},
 		"Makefile":    {"key": "value"},
 	}
@@ -82,7 +82,7 @@ func TestTemplatedInputs_roundTrip(t *testing.T) {
 
 func TestSaveTemplatedInputs_emptyMapWritesEmptyObject(t *testing.T) {
 	dir := t.TempDir()
-	require.NoError(t, saveTemplatedInputs(dir, map[string]map[string]string{}))
+	require.NoError(t, saveTemplatedInputs(dir, map[string]map[string]any{}))
 
 	got, err := os.ReadFile(filepath.Join(dir, ".gitspork", templatedInputsCacheFileName))
 	require.NoError(t, err)
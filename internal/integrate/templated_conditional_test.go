@@ -0,0 +1,113 @@
+package integrate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rockholla/gitspork/v2/internal/config"
+	"github.com/rockholla/gitspork/v2/internal/sdktypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readFileString(t *testing.T, path string) string {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return string(b)
+}
+
+func TestIntegratorTemplated_renderOnce(t *testing.T) {
+	upstreamDir, downstreamDir := setupTemplatedDirFixture(t, map[string]string{
+		"CHANGELOG.md.tmpl": "# {{ .Inputs.name }} changelog\n",
+		"seed/a.txt":        "seeded {{ .Inputs.name }}",
+	})
+	instructions := []config.GitSporkConfigTemplated{
+		{
+			Template: "CHANGELOG.md.tmpl", Destination: "CHANGELOG.md", Render: config.TemplatedRenderOnce,
+			Inputs: []config.GitSporkConfigTemplatedInput{{Name: "name", Prompt: "name?"}},
+		},
+		{
+			TemplateDir: "seed", DestinationDir: "seeded", Render: config.TemplatedRenderOnce,
+			Inputs: []config.GitSporkConfigTemplatedInput{{Name: "name", Prompt: "name?"}},
+		},
+	}
+
+	stub := &stubPrompter{returnValue: "billing"}
+	require.NoError(t, (&IntegratorTemplated{Prompter: stub}).Integrate(instructions, upstreamDir, downstreamDir, false, sdktypes.NoopLogger()))
+	assert.Equal(t, "# billing changelog\n", readFileString(t, filepath.Join(downstreamDir, "CHANGELOG.md")))
+	assert.Equal(t, "seeded billing", readFileString(t, filepath.Join(downstreamDir, "seeded/a.txt")))
+	assert.NoFileExists(t, filepath.Join(downstreamDir, gitSporkMetaDirName, templatedRenderedCacheFileName), "seeded files aren't recorded for removal")
+
+	t.Run("existing destinations are left alone without prompting", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(filepath.Join(downstreamDir, "CHANGELOG.md"), []byte("edited downstream"), 0644))
+		stub := &stubPrompter{returnValue: "other"}
+		require.NoError(t, (&IntegratorTemplated{Prompter: stub}).Integrate(instructions, upstreamDir, downstreamDir, true, sdktypes.NoopLogger()))
+		assert.Equal(t, 0, stub.calls)
+		assert.Equal(t, "edited downstream", readFileString(t, filepath.Join(downstreamDir, "CHANGELOG.md")))
+		assert.Equal(t, "seeded billing", readFileString(t, filepath.Join(downstreamDir, "seeded/a.txt")))
+
+		cache, err := loadTemplatedInputs(downstreamDir)
+		require.NoError(t, err)
+		assert.Equal(t, "billing", cache["CHANGELOG.md"]["name"], "cached answers are kept for previous_input")
+	})
+
+	t.Run("non-interactive runs don't require inputs of existing destinations", func(t *testing.T) {
+		require.NoError(t, os.Remove(filepath.Join(downstreamDir, gitSporkMetaDirName, templatedInputsCacheFileName)))
+		integrator := &IntegratorTemplated{NonInteractive: true}
+		require.NoError(t, integrator.Integrate(instructions, upstreamDir, downstreamDir, false, sdktypes.NoopLogger()))
+	})
+}
+
+func TestIntegratorTemplated_when(t *testing.T) {
+	upstreamDir, downstreamDir := setupTemplatedDirFixture(t, map[string]string{
+		"Dockerfile.tmpl": "FROM {{ .Inputs.base }}\n",
+		"docker/a.txt":    "a",
+	})
+	instructions := func() []config.GitSporkConfigTemplated {
+		inputs := []config.GitSporkConfigTemplatedInput{
+			{Name: "uses_docker", Prompt: "docker?", Type: config.TemplatedInputTypeBool},
+			{Name: "base", Prompt: "base?"},
+		}
+		return []config.GitSporkConfigTemplated{
+			{Template: "Dockerfile.tmpl", Destination: "Dockerfile", When: ".Inputs.uses_docker", Inputs: inputs},
+			{TemplateDir: "docker", DestinationDir: "docker", When: `and .Inputs.uses_docker (ne .Inputs.base "scratch")`, Inputs: inputs},
+		}
+	}
+	integrate := func(usesDocker bool) {
+		t.Helper()
+		integrator := &IntegratorTemplated{Inputs: map[string]any{"uses_docker": usesDocker, "base": "alpine"}}
+		require.NoError(t, integrator.Integrate(instructions(), upstreamDir, downstreamDir, true, sdktypes.NoopLogger()))
+	}
+
+	t.Run("false doesn't render, and leaves a downstream file it never rendered", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(filepath.Join(downstreamDir, "Dockerfile"), []byte("hand written"), 0644))
+		integrate(false)
+		assert.Equal(t, "hand written", readFileString(t, filepath.Join(downstreamDir, "Dockerfile")))
+		assert.NoDirExists(t, filepath.Join(downstreamDir, "docker"))
+		cache, err := loadTemplatedInputs(downstreamDir)
+		require.NoError(t, err)
+		assert.Equal(t, false, cache["Dockerfile"]["uses_docker"], "inputs are cached even when not rendered")
+	})
+
+	t.Run("true renders", func(t *testing.T) {
+		integrate(true)
+		assert.Equal(t, "FROM alpine\n", readFileString(t, filepath.Join(downstreamDir, "Dockerfile")))
+		assert.FileExists(t, filepath.Join(downstreamDir, "docker/a.txt"))
+	})
+
+	t.Run("turning false removes what was rendered", func(t *testing.T) {
+		integrate(false)
+		assert.NoFileExists(t, filepath.Join(downstreamDir, "Dockerfile"))
+		assert.NoDirExists(t, filepath.Join(downstreamDir, "docker"))
+		assert.NoFileExists(t, filepath.Join(downstreamDir, gitSporkMetaDirName, templatedRenderedCacheFileName))
+	})
+
+	t.Run("invalid pipeline", func(t *testing.T) {
+		bad := []config.GitSporkConfigTemplated{{Template: "Dockerfile.tmpl", Destination: "Dockerfile", When: ".Inputs.uses_docker }}"}}
+		err := (&IntegratorTemplated{}).Integrate(bad, upstreamDir, downstreamDir, false, sdktypes.NoopLogger())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "error evaluating when")
+	})
+}
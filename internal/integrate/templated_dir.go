@@ -0,0 +1,182 @@
+package integrate
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/gobwas/glob"
+	"github.com/rockholla/gitspork/v2/internal/config"
+	"github.com/rockholla/gitspork/v2/internal/sdktypes"
+)
+
+// compileTemplatedDirGlobs compiles one of a template_dir instruction's
+// include/exclude/raw pattern lists.
+func compileTemplatedDirGlobs(patterns []string) ([]glob.Glob, error) {
+	globs := make([]glob.Glob, 0, len(patterns))
+	for _, p := range patterns {
+		g, err := glob.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid glob pattern %q: %v", p, err)
+		}
+		globs = append(globs, g)
+	}
+	return globs, nil
+}
+
+func matchesAnyGlob(globs []glob.Glob, p string) bool {
+	for _, g := range globs {
+		if g.Match(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// renderTemplatedDir renders every included file under the instruction's
+// template_dir into its destination_dir. Both the contents (unless matched by
+// a raw pattern) and the relative path of each file are rendered with data;
+// a file whose rendered path has an empty segment is skipped, which lets a
+// path like `{{ if .Inputs.with_docs }}docs{{ end }}/README.md` be optional.
+//
+// Returns the rendered paths, relative to destination_dir in slash form and
+// sorted, so the caller can record them and clean up on the next run.
+func renderTemplatedDir(templatedInstruction config.GitSporkConfigTemplated, upstreamPath string, downstreamPath string, data IntegratorTemplatedData, partials *templatedPartials) ([]string, error) {
+	include, err := compileTemplatedDirGlobs(templatedInstruction.Include)
+	if err != nil {
+		return nil, err
+	}
+	exclude, err := compileTemplatedDirGlobs(templatedInstruction.Exclude)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := compileTemplatedDirGlobs(templatedInstruction.Raw)
+	if err != nil {
+		return nil, err
+	}
+
+	sourceRoot := filepath.Join(upstreamPath, templatedInstruction.TemplateDir)
+	destinationRoot := filepath.Join(downstreamPath, templatedInstruction.DestinationDir)
+	renderedFrom := map[string]string{}
+	err = filepath.WalkDir(sourceRoot, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(sourceRoot, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		// Templates are named by upstream-relative path, as single-file
+		// templates and include are, so errors and include cycles line up.
+		upstreamRel := path.Join(filepath.ToSlash(templatedInstruction.TemplateDir), rel)
+		if partials.isPartial(upstreamRel) {
+			return nil
+		}
+		if len(include) > 0 && !matchesAnyGlob(include, rel) {
+			return nil
+		}
+		if matchesAnyGlob(exclude, rel) {
+			return nil
+		}
+
+		renderedRel, err := partials.render(upstreamRel, rel, data)
+		if err != nil {
+			return fmt.Errorf("error rendering path %s: %v", rel, err)
+		}
+		if slices.Contains(strings.Split(renderedRel, "/"), "") {
+			return nil
+		}
+		renderedRel = path.Clean(renderedRel)
+		if !filepath.IsLocal(filepath.FromSlash(renderedRel)) {
+			return fmt.Errorf("path %s renders to %s, which is outside destination_dir", rel, renderedRel)
+		}
+		if dest := path.Join(filepath.ToSlash(templatedInstruction.DestinationDir), renderedRel); config.InGitSporkDir(dest) {
+			return fmt.Errorf("path %s renders to %s, which is under %s and only gitspork writes", rel, dest, config.GitSporkDirName)
+		}
+		if other, ok := renderedFrom[renderedRel]; ok {
+			return fmt.Errorf("paths %s and %s both render to %s", other, rel, renderedRel)
+		}
+		renderedFrom[renderedRel] = rel
+
+		contents, err := os.ReadFile(p)
+		if err != nil {
+			return fmt.Errorf("error reading %s: %v", rel, err)
+		}
+		if !matchesAnyGlob(raw, rel) {
+			rendered, err := partials.render(upstreamRel, string(contents), data)
+			if err != nil {
+				return fmt.Errorf("error rendering %s: %v", rel, err)
+			}
+			contents = []byte(rendered)
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(destinationRoot, filepath.FromSlash(renderedRel))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return fmt.Errorf("error ensuring %s exists: %v", filepath.Dir(target), err)
+		}
+		// Keep the upstream file's permission bits so executable scripts stay executable.
+		if err := os.WriteFile(target, contents, info.Mode().Perm()); err != nil {
+			return fmt.Errorf("error writing %s: %v", target, err)
+		}
+		return os.Chmod(target, info.Mode().Perm())
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error rendering template_dir %s: %v", templatedInstruction.TemplateDir, err)
+	}
+
+	rendered := make([]string, 0, len(renderedFrom))
+	for p := range renderedFrom {
+		rendered = append(rendered, p)
+	}
+	slices.Sort(rendered)
+	return rendered, nil
+}
+
+// removeStaleRenderedFiles removes files under destination that were
+// rendered previously but are not in current — because inputs changed a
+// rendered path, the file left the upstream template_dir, or a `when`
+// condition turned false — along with any directories that removal leaves
+// empty. A single-file destination is recorded as ".". Files already absent
+// are skipped.
+func removeStaleRenderedFiles(downstreamPath string, destination string, previous []string, current []string, logger sdktypes.Logger) error {
+	destinationRoot := filepath.Join(downstreamPath, destination)
+	for _, p := range previous {
+		if slices.Contains(current, p) {
+			continue
+		}
+		if !filepath.IsLocal(filepath.FromSlash(p)) {
+			continue
+		}
+		target := filepath.Join(destinationRoot, filepath.FromSlash(p))
+		if _, err := os.Lstat(target); os.IsNotExist(err) {
+			continue
+		}
+		if p == "." {
+			logger.Log("🗑️  removing %s, no longer rendered", destination)
+		} else {
+			logger.Log("🗑️  removing %s, no longer rendered into %s", path.Join(destination, p), destination)
+		}
+		if err := os.Remove(target); err != nil {
+			return fmt.Errorf("error removing %s: %v", target, err)
+		}
+		// Prune now-empty parents up to (and including) destination_dir; a
+		// non-empty directory fails os.Remove, which ends the walk.
+		for dir := filepath.Dir(target); dir != downstreamPath && strings.HasPrefix(dir, destinationRoot); dir = filepath.Dir(dir) {
+			if os.Remove(dir) != nil {
+				break
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package integrate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rockholla/gitspork/v2/internal/config"
+	"github.com/rockholla/gitspork/v2/internal/sdktypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupTemplatedDirFixture writes files (upstream-relative path → contents)
+// under an upstream temp dir and returns the upstream and downstream dirs.
+func setupTemplatedDirFixture(t *testing.T, files map[string]string) (string, string) {
+	t.Helper()
+	upstreamDir := t.TempDir()
+	for p, contents := range files {
+		full := filepath.Join(upstreamDir, filepath.FromSlash(p))
+		require.NoError(t, os.MkdirAll(filepath.Dir(full), 0755))
+		require.NoError(t, os.WriteFile(full, []byte(contents), 0644))
+	}
+	return upstreamDir, t.TempDir()
+}
+
+func serviceDirInstruction() config.GitSporkConfigTemplated {
+	return config.GitSporkConfigTemplated{
+		TemplateDir:    "templates/service",
+		DestinationDir: "services",
+		Exclude:        []string{"*.md"},
+		Raw:            []string{"*.png"},
+		Inputs: []config.GitSporkConfigTemplatedInput{
+			{Name: "service_name", Prompt: "service name?"},
+		},
+	}
+}
+
+func TestIntegratorTemplated_templateDir(t *testing.T) {
+	upstreamDir, downstreamDir := setupTemplatedDirFixture(t, map[string]string{
+		"templates/service/{{ .Inputs.service_name }}/main.go":   `package {{ .Inputs.service_name }}`,
+		"templates/service/{{ .Inputs.service_name }}/logo.png":  `{{ not a template }}`,
+		"templates/service/NOTES.md":                             `excluded`,
+		"templates/service/{{ if false }}optional{{ end }}/x.go": `skipped`,
+	})
+	require.NoError(t, os.Chmod(filepath.Join(upstreamDir, "templates/service/{{ .Inputs.service_name }}/main.go"), 0755))
+	instructions := []config.GitSporkConfigTemplated{serviceDirInstruction()}
+
+	integrator := &IntegratorTemplated{Inputs: map[string]any{"service_name": "billing"}}
+	require.NoError(t, integrator.Integrate(instructions, upstreamDir, downstreamDir, false, sdktypes.NoopLogger()))
+
+	t.Run("paths and contents are rendered", func(t *testing.T) {
+		b, err := os.ReadFile(filepath.Join(downstreamDir, "services/billing/main.go"))
+		require.NoError(t, err)
+		assert.Equal(t, "package billing", string(b))
+	})
+	t.Run("file mode is preserved", func(t *testing.T) {
+		info, err := os.Stat(filepath.Join(downstreamDir, "services/billing/main.go"))
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+	})
+	t.Run("raw files are copied verbatim", func(t *testing.T) {
+		b, err := os.ReadFile(filepath.Join(downstreamDir, "services/billing/logo.png"))
+		require.NoError(t, err)
+		assert.Equal(t, "{{ not a template }}", string(b))
+	})
+	t.Run("excluded and empty-segment paths are skipped", func(t *testing.T) {
+		assert.NoFileExists(t, filepath.Join(downstreamDir, "services/NOTES.md"))
+		assert.NoFileExists(t, filepath.Join(downstreamDir, "services/x.go"))
+	})
+	t.Run("rendered files are recorded and inputs cached by destination_dir", func(t *testing.T) {
+		rendered, err := loadTemplatedRendered(downstreamDir)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"billing/logo.png", "billing/main.go"}, rendered["services"])
+		cache, err := loadTemplatedInputs(downstreamDir)
+		require.NoError(t, err)
+		assert.Equal(t, "billing", cache["services"]["service_name"])
+	})
+
+	t.Run("changed input moves rendered files and removes the stale ones", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(filepath.Join(downstreamDir, "services/local.txt"), []byte("mine"), 0644))
+		integrator := &IntegratorTemplated{Inputs: map[string]any{"service_name": "payments"}}
+		require.NoError(t, integrator.Integrate(instructions, upstreamDir, downstreamDir, false, sdktypes.NoopLogger()))
+
+		assert.FileExists(t, filepath.Join(downstreamDir, "services/payments/main.go"))
+		_, err := os.Stat(filepath.Join(downstreamDir, "services/billing"))
+		assert.True(t, os.IsNotExist(err), "stale rendered directory must be removed")
+		assert.FileExists(t, filepath.Join(downstreamDir, "services/local.txt"), "unrecorded files must be left alone")
+		rendered, err := loadTemplatedRendered(downstreamDir)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"payments/logo.png", "payments/main.go"}, rendered["services"])
+	})
+}
+
+func TestIntegratorTemplated_templateDir_include(t *testing.T) {
+	upstreamDir, downstreamDir := setupTemplatedDirFixture(t, map[string]string{
+		"tmpl/a.txt":     "a",
+		"tmpl/sub/b.txt": "b",
+		"tmpl/c.yml":     "c",
+	})
+	instructions := []config.GitSporkConfigTemplated{{
+		TemplateDir:    "tmpl",
+		DestinationDir: "out",
+		Include:        []string{"**.txt"},
+	}}
+	require.NoError(t, (&IntegratorTemplated{}).Integrate(instructions, upstreamDir, downstreamDir, false, sdktypes.NoopLogger()))
+	assert.FileExists(t, filepath.Join(downstreamDir, "out/a.txt"))
+	assert.FileExists(t, filepath.Join(downstreamDir, "out/sub/b.txt"))
+	assert.NoFileExists(t, filepath.Join(downstreamDir, "out/c.yml"))
+}
+
+func TestIntegratorTemplated_templateDir_pathEscapesDestination(t *testing.T) {
+	upstreamDir, downstreamDir := setupTemplatedDirFixture(t, map[string]string{
+		"tmpl/{{ .Inputs.name }}.txt": "x",
+	})
+	instructions := []config.GitSporkConfigTemplated{{
+		TemplateDir:    "tmpl",
+		DestinationDir: "out",
+		Inputs:         []config.GitSporkConfigTemplatedInput{{Name: "name", Prompt: "name?"}},
+	}}
+	integrator := &IntegratorTemplated{Inputs: map[string]any{"name": "../../escaped"}}
+	err := integrator.Integrate(instructions, upstreamDir, downstreamDir, false, sdktypes.NoopLogger())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "outside destination_dir")
+	assert.NoFileExists(t, filepath.Join(filepath.Dir(downstreamDir), "escaped.txt"))
+}
+
+func TestIntegratorTemplated_templateDir_pathUnderGitSporkDir(t *testing.T) {
+	upstreamDir, downstreamDir := setupTemplatedDirFixture(t, map[string]string{
+		"tmpl/{{ .Inputs.dir }}/trust.yml": "migrations:\n  allow: ['*']\n",
+	})
+	instructions := []config.GitSporkConfigTemplated{{
+		TemplateDir:    "tmpl",
+		DestinationDir: ".",
+		Inputs:         []config.GitSporkConfigTemplatedInput{{Name: "dir", Prompt: "dir?"}},
+	}}
+	integrator := &IntegratorTemplated{Inputs: map[string]any{"dir": ".gitspork"}}
+	err := integrator.Integrate(instructions, upstreamDir, downstreamDir, false, sdktypes.NoopLogger())
+	require.ErrorContains(t, err, "which is under .gitspork")
+	assert.NoFileExists(t, filepath.Join(downstreamDir, ".gitspork", "trust.yml"))
+}
+
+func TestIntegratorTemplated_templateDir_collision(t *testing.T) {
+	upstreamDir, downstreamDir := setupTemplatedDirFixture(t, map[string]string{
+		"tmpl/{{ \"a\" }}.txt": "one",
+		"tmpl/a.txt":           "two",
+	})
+	instructions := []config.GitSporkConfigTemplated{{TemplateDir: "tmpl", DestinationDir: "out"}}
+	err := (&IntegratorTemplated{}).Integrate(instructions, upstreamDir, downstreamDir, false, sdktypes.NoopLogger())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "both render to a.txt")
+}
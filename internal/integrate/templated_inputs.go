@@ -0,0 +1,622 @@
+package integrate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-yaml"
+	"github.com/rockholla/gitspork/v2/internal/config"
+	inputpkg "github.com/rockholla/gitspork/v2/internal/input"
+	"github.com/rockholla/gitspork/v2/internal/logutil"
+	"github.com/rockholla/gitspork/v2/internal/sdktypes"
+)
+
+// templatedInputEnvPrefix prefixes the env vars that supply prompt input
+// values non-interactively, e.g. GITSPORK_INPUT_SERVICE_NAME.
+const templatedInputEnvPrefix = "GITSPORK_INPUT_"
+
+var reTemplatedInputEnvUnsafe = regexp.MustCompile(`[^A-Z0-9_]`)
+
+// templatedInputEnvVar returns the env var name that supplies the value for
+// the input called name: upper-cased, with anything outside [A-Z0-9_]
+// replaced by an underscore.
+func templatedInputEnvVar(name string) string {
+	return templatedInputEnvPrefix + reTemplatedInputEnvUnsafe.ReplaceAllString(strings.ToUpper(name), "_")
+}
+
+// maxTemplatedInputPromptAttempts bounds the re-prompt loop for an input whose
+// answers keep failing validation, so a non-interactive stdin (or a stuck
+// caller-supplied prompter) fails loudly instead of spinning forever.
+const maxTemplatedInputPromptAttempts = 5
+
+// resolveTemplatedInputValue converts a raw input value — a prompt answer, a
+// json_data_path value, a previous_input value or a cached value — into the
+// typed value handed to templates, applying the input's default when raw is
+// empty and then enforcing required/options/validate.
+//
+// Typed values are: string for string/select, bool for bool, int for int and
+// []string for multi-select. Raw values may already be typed (JSON numbers
+// and arrays from the cache or a data file) or strings from a prompt.
+func resolveTemplatedInputValue(input config.GitSporkConfigTemplatedInput, raw any) (any, error) {
+	if templatedInputIsEmpty(raw) && input.Default != "" {
+		raw = input.Default
+	}
+	value, err := coerceTemplatedInputValue(input, raw)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateTemplatedInputValue(input, value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// templatedInputIsEmpty reports whether v carries no answer at all. false and
+// 0 are real answers for bool/int inputs and are not considered empty.
+func templatedInputIsEmpty(v any) bool {
+	switch val := v.(type) {
+	case nil:
+		return true
+	case string:
+		return val == ""
+	case []string:
+		return len(val) == 0
+	case []any:
+		return len(val) == 0
+	}
+	return false
+}
+
+func coerceTemplatedInputValue(input config.GitSporkConfigTemplatedInput, raw any) (any, error) {
+	switch input.InputType() {
+	case config.TemplatedInputTypeBool:
+		switch val := raw.(type) {
+		case nil:
+			return false, nil
+		case bool:
+			return val, nil
+		case string:
+			switch strings.ToLower(strings.TrimSpace(val)) {
+			case "":
+				return false, nil
+			case "y", "yes", "true", "1", "on":
+				return true, nil
+			case "n", "no", "false", "0", "off":
+				return false, nil
+			}
+		}
+		return nil, fmt.Errorf("value %s for input %s is not a valid bool (expects yes/no or true/false)", shownInputValue(input, fmt.Sprint(raw)), input.Name)
+	case config.TemplatedInputTypeInt:
+		switch val := raw.(type) {
+		case nil:
+			return 0, nil
+		case int:
+			return val, nil
+		case int64:
+			return int(val), nil
+		case uint64:
+			return int(val), nil
+		case float64:
+			if val == math.Trunc(val) {
+				return int(val), nil
+			}
+		case string:
+			if strings.TrimSpace(val) == "" {
+				return 0, nil
+			}
+			if n, err := strconv.Atoi(strings.TrimSpace(val)); err == nil {
+				return n, nil
+			}
+		}
+		return nil, fmt.Errorf("value %s for input %s is not a valid integer", shownInputValue(input, fmt.Sprint(raw)), input.Name)
+	case config.TemplatedInputTypeMultiSelect:
+		values := []string{}
+		switch val := raw.(type) {
+		case nil:
+		case []string:
+			values = append(values, val...)
+		case []any:
+			for _, item := range val {
+				values = append(values, fmt.Sprint(item))
+			}
+		case string:
+			values = inputpkg.ParseMultiSelection(val, input.Options)
+		default:
+			return nil, fmt.Errorf("value %s for input %s is not a valid list of selections", shownInputValue(input, fmt.Sprint(raw)), input.Name)
+		}
+		return values, nil
+	default:
+		switch val := raw.(type) {
+		case nil:
+			return "", nil
+		case string:
+			return val, nil
+		case []any, map[string]any:
+			return nil, fmt.Errorf("value for input %s must be a single value, got %T", input.Name, raw)
+		default:
+			return fmt.Sprint(val), nil
+		}
+	}
+}
+
+func validateTemplatedInputValue(input config.GitSporkConfigTemplatedInput, value any) error {
+	if input.Required && templatedInputIsEmpty(value) {
+		return fmt.Errorf("input %s is required", input.Name)
+	}
+	var checks []string
+	switch val := value.(type) {
+	case string:
+		if val != "" {
+			checks = []string{val}
+		}
+	case int:
+		checks = []string{strconv.Itoa(val)}
+	case []string:
+		checks = val
+	}
+	isSelect := input.InputType() == config.TemplatedInputTypeSelect || input.InputType() == config.TemplatedInputTypeMultiSelect
+	for _, check := range checks {
+		shown := shownInputValue(input, strconv.Quote(check))
+		if isSelect && !slices.Contains(input.Options, check) {
+			return fmt.Errorf("value %s for input %s is not one of the allowed options: %s", shown, input.Name, strings.Join(input.Options, ", "))
+		}
+		if input.ValidateRegex == "" {
+			continue
+		}
+		re, err := regexp.Compile(input.ValidateRegex)
+		if err != nil {
+			return fmt.Errorf("invalid validate expression %q for input %s: %v", input.ValidateRegex, input.Name, err)
+		}
+		if !re.MatchString(check) {
+			if input.ValidateMessage != "" {
+				return fmt.Errorf("value %s for input %s is invalid: %s", shown, input.Name, input.ValidateMessage)
+			}
+			return fmt.Errorf("value %s for input %s does not match %s", shown, input.Name, input.ValidateRegex)
+		}
+	}
+	return nil
+}
+
+// shownInputValue is how an error message shows value, the formatted value of
+// input, redacted for a secret input.
+func shownInputValue(input config.GitSporkConfigTemplatedInput, value string) string {
+	if input.Secret {
+		return "(secret)"
+	}
+	return value
+}
+
+// promptTemplatedInput asks the configured Prompter for a prompt input's
+// value, re-asking with the validation error until a valid answer is given or
+// maxTemplatedInputPromptAttempts is reached.
+func (i *IntegratorTemplated) promptTemplatedInput(templatedInstruction config.GitSporkConfigTemplated, input config.GitSporkConfigTemplatedInput, logger sdktypes.Logger) (any, error) {
+	prompter := i.Prompter
+	if prompter == nil {
+		prompter = TerminalPrompter()
+	}
+	req := sdktypes.PromptRequest{
+		Template:    templatedInstruction.Source(),
+		Destination: templatedInstruction.Target(),
+		Name:        input.Name,
+		Prompt:      input.Prompt,
+		Help:        input.Help,
+		Type:        input.InputType(),
+		Options:     input.Options,
+		Default:     input.Default,
+		Secret:      input.Secret,
+	}
+	for attempt := 1; ; attempt++ {
+		req.Attempt = attempt
+		raw, err := prompter.Prompt(req)
+		if err != nil {
+			return nil, fmt.Errorf("error prompting for input %s under template %s: %v", input.Name, templatedInstruction.Source(), err)
+		}
+		value, err := resolveTemplatedInputValue(input, raw)
+		if err == nil {
+			return value, nil
+		}
+		if attempt >= maxTemplatedInputPromptAttempts {
+			return nil, fmt.Errorf("no valid value given for input %s after %d attempts: %v", input.Name, attempt, err)
+		}
+		logger.Error("❌ %v, please try again", err)
+		req.ValidationError = err.Error()
+	}
+}
+
+// suppliedInputValue returns a value given for a prompt input without asking:
+// IntegratorTemplated.Inputs first, then the GITSPORK_INPUT_<NAME> env var.
+// source describes where the value came from for error messages.
+func (i *IntegratorTemplated) suppliedInputValue(name string) (raw any, source string, ok bool) {
+	if raw, ok := i.Inputs[name]; ok {
+		return raw, "supplied inputs", true
+	}
+	envVar := templatedInputEnvVar(name)
+	if raw, ok := os.LookupEnv(envVar); ok {
+		return raw, envVar, true
+	}
+	return nil, "", false
+}
+
+// resolvePromptInput determines a prompt input's value without prompting. A
+// supplied value always wins (and must be valid); otherwise a cached value is
+// used unless forceRePrompt is set or it no longer validates, and in a
+// non-interactive run the default after that. ok is false when the user has to
+// be asked.
+func (i *IntegratorTemplated) resolvePromptInput(input config.GitSporkConfigTemplatedInput, template string, cached any, isCached bool, forceRePrompt bool, logger sdktypes.Logger) (value any, ok bool, err error) {
+	if raw, source, supplied := i.suppliedInputValue(input.Name); supplied {
+		value, err := resolveTemplatedInputValue(input, raw)
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid value from %s under template %s: %v", source, template, err)
+		}
+		return value, true, nil
+	}
+	if forceRePrompt || !isCached || templatedInputIsEmpty(cached) {
+		return i.nonInteractiveDefault(input, template)
+	}
+	// Cached answers are re-validated on every integrate: the upstream
+	// may have tightened the input (new options, a validate expression,
+	// a type change) since the value was captured.
+	value, err = resolveTemplatedInputValue(input, cached)
+	if err != nil {
+		logger.Log("⚠️  cached value for input %s under template %s is no longer valid (%v), re-prompting", input.Name, template, err)
+		return i.nonInteractiveDefault(input, template)
+	}
+	return value, true, nil
+}
+
+// nonInteractiveDefault answers an input that would otherwise be prompted for
+// with its default, when nobody can be asked. ok is false when the run is
+// interactive or the input has no default.
+func (i *IntegratorTemplated) nonInteractiveDefault(input config.GitSporkConfigTemplatedInput, template string) (value any, ok bool, err error) {
+	if !i.NonInteractive || input.Default == "" {
+		return nil, false, nil
+	}
+	value, err = resolveTemplatedInputValue(input, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid default for input %s under template %s: %v", input.Name, template, err)
+	}
+	return value, true, nil
+}
+
+// resolveSecretInput determines a secret input's value, never from the
+// cache: a supplied value or GITSPORK_INPUT_<NAME> first, then secret_env,
+// then secret_command outside a drift check, and otherwise a masked prompt.
+func (i *IntegratorTemplated) resolveSecretInput(templatedInstruction config.GitSporkConfigTemplated, input config.GitSporkConfigTemplatedInput, downstreamPath string, logger sdktypes.Logger) (any, error) {
+	raw, source, ok := i.secretInputValue(input)
+	if !ok && input.SecretCommand != "" && !i.commandTrust.forDriftCheck {
+		out, err := i.runInputCommand(input.SecretCommand, downstreamPath, logger)
+		if err != nil {
+			return nil, fmt.Errorf("error running secret_command for input %s under template %s: %v", input.Name, templatedInstruction.Source(), err)
+		}
+		raw, source, ok = out, "secret_command", true
+	}
+	if ok {
+		value, err := resolveTemplatedInputValue(input, raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value from %s under template %s: %v", source, templatedInstruction.Source(), err)
+		}
+		return value, nil
+	}
+	if input.Prompt == "" {
+		return nil, fmt.Errorf("no value for secret input %s under template %s, set %s", input.Name, templatedInstruction.Source(), templatedInputEnvVar(input.Name))
+	}
+	return i.promptTemplatedInput(templatedInstruction, input, logger)
+}
+
+// secretInputAvailable reports whether a secret input has a source other
+// than a prompt. A secret_command counts without being run, except in a drift
+// check, which doesn't run it; its failure surfaces when the input is resolved.
+func (i *IntegratorTemplated) secretInputAvailable(input config.GitSporkConfigTemplatedInput) bool {
+	if _, _, ok := i.secretInputValue(input); ok {
+		return true
+	}
+	return input.SecretCommand != "" && !i.commandTrust.forDriftCheck
+}
+
+// secretInputValue returns a secret input's raw value from a supplied value,
+// GITSPORK_INPUT_<NAME> or secret_env, and where it came from.
+func (i *IntegratorTemplated) secretInputValue(input config.GitSporkConfigTemplatedInput) (raw any, source string, ok bool) {
+	if raw, source, ok := i.suppliedInputValue(input.Name); ok {
+		return raw, source, true
+	}
+	if input.SecretEnv != "" {
+		if raw, ok := os.LookupEnv(input.SecretEnv); ok {
+			return raw, input.SecretEnv, true
+		}
+	}
+	return nil, "", false
+}
+
+// inputCommand is a command an input would run in the downstream for its
+// value: field is the input's exec or secret_command.
+type inputCommand struct {
+	template string
+	input    string
+	field    string
+	command  string
+}
+
+// inputRunsExec reports whether an input takes its value from its exec
+// command, which comes after every other source but a prompt.
+func inputRunsExec(input config.GitSporkConfigTemplatedInput) bool {
+	return input.Exec != "" && !input.Secret && !input.Global && input.JSONDataPath == "" && input.YAMLDataPath == "" && input.Env == ""
+}
+
+// recordedExecInputValue returns the value an exec input is given in a drift
+// check, which doesn't run the command: a supplied value, else the one cached
+// when it was integrated. ok is false when there's neither.
+func (i *IntegratorTemplated) recordedExecInputValue(input config.GitSporkConfigTemplatedInput, template string, cached any, isCached bool) (value any, ok bool, err error) {
+	raw, source, supplied := i.suppliedInputValue(input.Name)
+	if !supplied {
+		if !isCached {
+			return nil, false, nil
+		}
+		raw, source = cached, "the inputs cache"
+	}
+	value, err = resolveTemplatedInputValue(input, raw)
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid value from %s under template %s: %v", source, template, err)
+	}
+	return value, true, nil
+}
+
+// pendingInputCommands lists the commands resolving instructions' inputs
+// would run: each exec, and each secret_command whose secret has no other
+// value. None run in a drift check.
+func (i *IntegratorTemplated) pendingInputCommands(templatedInstructions []config.GitSporkConfigTemplated, downstreamPath string) []inputCommand {
+	if i.commandTrust.forDriftCheck {
+		return nil
+	}
+	var commands []inputCommand
+	for _, templatedInstruction := range templatedInstructions {
+		if renderedOnce(templatedInstruction, downstreamPath) {
+			continue
+		}
+		for _, input := range templatedInstruction.Inputs {
+			switch {
+			case inputRunsExec(input):
+				commands = append(commands, inputCommand{templatedInstruction.Source(), input.Name, "exec", input.Exec})
+			case input.Secret && input.SecretCommand != "":
+				if _, _, ok := i.secretInputValue(input); !ok {
+					commands = append(commands, inputCommand{templatedInstruction.Source(), input.Name, "secret_command", input.SecretCommand})
+				}
+			}
+		}
+	}
+	return commands
+}
+
+// untrustedInputCommandsError explains how to let an untrusted upstream's
+// inputs run their commands.
+func untrustedInputCommandsError(commands []inputCommand, upstreamURL string, why string) error {
+	var inputs []string
+	for _, c := range commands {
+		inputs = append(inputs, fmt.Sprintf("%s under template %s", c.input, c.template))
+	}
+	return fmt.Errorf("templated inputs from %s run commands (%s), but %s: add the upstream to migrations.allow in %s/%s or pass --allow-migrations to run them",
+		upstreamURL, strings.Join(inputs, ", "), why, gitSporkMetaDirName, config.TrustPolicyFileName)
+}
+
+// authorizeInputCommands checks that the upstream may run the commands
+// resolving instructions' inputs would, as trustUpstreamCommands decides for
+// migrations; otherwise they're confirmed together through the prompter,
+// showing what each runs, unless the run is non-interactive. Commands it
+// authorized before aren't checked again.
+func (i *IntegratorTemplated) authorizeInputCommands(templatedInstructions []config.GitSporkConfigTemplated, upstreamPath string, downstreamPath string, logger sdktypes.Logger) error {
+	var commands []inputCommand
+	for _, c := range i.pendingInputCommands(templatedInstructions, downstreamPath) {
+		if !i.authorizedCommands[c] {
+			commands = append(commands, c)
+		}
+	}
+	if len(commands) == 0 {
+		return nil
+	}
+	opts := i.commandTrust
+	untrusted := func(why string) error {
+		return untrustedInputCommandsError(commands, opts.upstreamURL, why)
+	}
+	key, confirm, err := trustUpstreamCommands("templated input", upstreamPath, downstreamPath, opts, untrusted, logger)
+	if err != nil {
+		return err
+	}
+	if !confirm {
+		i.authorize(commands)
+		return nil
+	}
+
+	var help strings.Builder
+	for _, c := range commands {
+		fmt.Fprintf(&help, "input %s under template %s: %s %s\n", c.input, c.template, c.field, c.command)
+	}
+	prompter := opts.prompter
+	if prompter == nil {
+		prompter = TerminalPrompter()
+	}
+	answer, err := promptCommandTrust(prompter, sdktypes.PromptRequest{
+		Name:    "input commands",
+		Prompt:  fmt.Sprintf("Templated inputs from the untrusted upstream %s run the commands above. Run them (%s)?", opts.upstreamURL, strings.Join(migrationTrustAnswers, "/")),
+		Help:    help.String(),
+		Type:    config.TemplatedInputTypeString,
+		Options: migrationTrustAnswers,
+		Default: migrationTrustAnswerNo,
+	}, "templated input commands", logger)
+	if err != nil {
+		return err
+	}
+	if err := recordTrustAnswer(answer, key, downstreamPath); err != nil {
+		return err
+	}
+	if answer == migrationTrustAnswerYes || answer == migrationTrustAnswerAlways {
+		i.authorize(commands)
+		return nil
+	}
+	return untrustedInputCommandsError(commands, opts.upstreamURL, "they weren't allowed to run")
+}
+
+// authorize records commands as authorized to run.
+func (i *IntegratorTemplated) authorize(commands []inputCommand) {
+	if i.authorizedCommands == nil {
+		i.authorizedCommands = map[inputCommand]bool{}
+	}
+	for _, c := range commands {
+		i.authorizedCommands[c] = true
+	}
+}
+
+// inputCommandTimeout bounds an input's exec or secret_command, so one that
+// hangs fails the integrate instead of blocking it.
+var inputCommandTimeout = 2 * time.Minute
+
+// runInputCommand runs an input's exec or secret_command in the downstream
+// repo and returns its trimmed stdout. Like migration exec, the command is
+// split on whitespace, with no quoting, and its whole process tree is killed
+// when it times out or the integration's context is cancelled.
+func (i *IntegratorTemplated) runInputCommand(command string, downstreamPath string, logger sdktypes.Logger) (string, error) {
+	parts := strings.Fields(command)
+	if len(parts) == 0 {
+		return "", fmt.Errorf("command %q resolved to zero tokens", command)
+	}
+	ctx := i.commandTrust.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(ctx, inputCommandTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, parts[0], parts[1:]...)
+	cmd.Dir = downstreamPath
+	cmd.Stderr = &logutil.LoggerWriter{L: logger}
+	startProcessGroup(cmd)
+	cmd.Cancel = func() error { return killProcessTree(cmd) }
+	cmd.WaitDelay = migrationCommandWaitDelay
+	out, err := cmd.Output()
+	if err != nil && ctx.Err() != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return "", fmt.Errorf("timed out after %s: %w", inputCommandTimeout, err)
+		}
+		return "", fmt.Errorf("cancelled: %w", err)
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// missingTemplatedInputs lists every prompt or secret input across
+// instructions that would have to be asked for, and in a drift check every
+// exec input without a recorded value, so a non-interactive run can fail up
+// front with the complete list rather than at the first gap.
+func (i *IntegratorTemplated) missingTemplatedInputs(templatedInstructions []config.GitSporkConfigTemplated, downstreamPath string, existingCache map[string]map[string]any, globals *globalInputs, forceRePrompt bool) ([]sdktypes.MissingInput, error) {
+	missing := []sdktypes.MissingInput{}
+	// A global input is checked once, under the first template declaring it:
+	// whatever value that template ends up with is shared by the rest.
+	seenGlobals := map[string]bool{}
+	for _, templatedInstruction := range templatedInstructions {
+		if renderedOnce(templatedInstruction, downstreamPath) {
+			continue
+		}
+		for _, input := range templatedInstruction.Inputs {
+			ok := false
+			cached, isCached := existingCache[templatedInstruction.Target()][input.Name]
+			if input.Secret {
+				ok = i.secretInputAvailable(input)
+			} else if input.Global {
+				if seenGlobals[input.Name] {
+					continue
+				}
+				seenGlobals[input.Name] = true
+				var err error
+				_, ok, err = i.knownGlobalInputValue(input, templatedInstruction.Source(), globals, cached, isCached, forceRePrompt)
+				if err != nil {
+					return nil, err
+				}
+			} else if input.JSONDataPath != "" || input.YAMLDataPath != "" || input.Env != "" {
+				// Integrate resolves these from the data file or env, never
+				// asking, whatever prompt they also set.
+				continue
+			} else if input.Exec != "" {
+				if !i.commandTrust.forDriftCheck {
+					continue
+				}
+				var err error
+				_, ok, err = i.recordedExecInputValue(input, templatedInstruction.Source(), cached, isCached)
+				if err != nil {
+					return nil, err
+				}
+			} else if input.Prompt == "" {
+				continue
+			} else {
+				var err error
+				_, ok, err = i.resolvePromptInput(input, templatedInstruction.Source(), cached, isCached, forceRePrompt, sdktypes.NoopLogger())
+				if err != nil {
+					return nil, err
+				}
+			}
+			if !ok {
+				missing = append(missing, sdktypes.MissingInput{
+					Template:    templatedInstruction.Source(),
+					Destination: templatedInstruction.Target(),
+					Name:        input.Name,
+					Prompt:      input.Prompt,
+					EnvVar:      templatedInputEnvVar(input.Name),
+				})
+			}
+		}
+	}
+	return missing, nil
+}
+
+// templatedDataInputFile returns which data file field an input uses and the
+// file's path in the downstream.
+func templatedDataInputFile(input config.GitSporkConfigTemplatedInput, downstreamPath string) (field string, dataPath string) {
+	if input.YAMLDataPath != "" {
+		return "yaml_data_path", filepath.Join(downstreamPath, input.YAMLDataPath)
+	}
+	return "json_data_path", filepath.Join(downstreamPath, input.JSONDataPath)
+}
+
+// readTemplatedDataInput returns the raw value of an input from its data file.
+// Without a data_key every root property is merged into inputs, as
+// json_data_path always has, and the one named like the input is returned;
+// with one, only the selected value is. A key path that selects nothing is a
+// nil value, to which the input's default applies.
+func readTemplatedDataInput(input config.GitSporkConfigTemplatedInput, field string, dataPath string, inputs map[string]any) (any, error) {
+	data, err := os.ReadFile(dataPath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s at %s: %v", field, dataPath, err)
+	}
+	if input.DataKey != "" {
+		keyPath, err := yaml.PathString(input.DataKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid data_key %q for input %s: %v", input.DataKey, input.Name, err)
+		}
+		var raw any
+		if err := keyPath.Read(bytes.NewReader(data), &raw); err != nil && !yaml.IsNotFoundNodeError(err) {
+			return nil, fmt.Errorf("error reading data_key %s from %s file %s: %v", input.DataKey, field, dataPath, err)
+		}
+		return raw, nil
+	}
+	if input.YAMLDataPath != "" {
+		root := map[string]any{}
+		if err := yaml.Unmarshal(data, &root); err != nil {
+			return nil, fmt.Errorf("error parsing %s file %s into inputs: %v", field, dataPath, err)
+		}
+		maps.Copy(inputs, root)
+	} else if err := json.Unmarshal(data, &inputs); err != nil {
+		return nil, fmt.Errorf("error parsing %s file %s into inputs: %v", field, dataPath, err)
+	}
+	return inputs[input.Name], nil
+}
@@ -0,0 +1,678 @@
+package integrate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rockholla/gitspork/v2/internal/config"
+	"github.com/rockholla/gitspork/v2/internal/sdktypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sequencePrompter answers each Prompt call with the next entry of answers,
+// recording every request. Once answers run out the last one is repeated.
+type sequencePrompter struct {
+	answers  []string
+	requests []sdktypes.PromptRequest
+}
+
+func newSequencePrompter(answers ...string) *sequencePrompter {
+	return &sequencePrompter{answers: answers}
+}
+
+func (sp *sequencePrompter) Prompt(req sdktypes.PromptRequest) (any, error) {
+	answer := sp.answers[min(len(sp.requests), len(sp.answers)-1)]
+	sp.requests = append(sp.requests, req)
+	return answer, nil
+}
+
+func TestResolveTemplatedInputValue(t *testing.T) {
+	cases := []struct {
+		name    string
+		input   config.GitSporkConfigTemplatedInput
+		raw     any
+		want    any
+		wantErr string
+	}{
+		{"string passthrough", config.GitSporkConfigTemplatedInput{Name: "s"}, "x", "x", ""},
+		{"string default", config.GitSporkConfigTemplatedInput{Name: "s", Default: "d"}, "", "d", ""},
+		{"string required", config.GitSporkConfigTemplatedInput{Name: "s", Required: true}, "", nil, "input s is required"},
+		{"string validate", config.GitSporkConfigTemplatedInput{Name: "s", ValidateRegex: "^[a-z]+$"}, "Abc", nil, "does not match"},
+		{"string validate message", config.GitSporkConfigTemplatedInput{Name: "s", ValidateRegex: "^[a-z]+$", ValidateMessage: "lower-case only"}, "Abc", nil, "lower-case only"},
+		{"bool from yes", config.GitSporkConfigTemplatedInput{Name: "b", Type: "bool"}, "yes", true, ""},
+		{"bool from cached bool", config.GitSporkConfigTemplatedInput{Name: "b", Type: "bool"}, false, false, ""},
+		{"bool default", config.GitSporkConfigTemplatedInput{Name: "b", Type: "bool", Default: "true"}, "", true, ""},
+		{"bool invalid", config.GitSporkConfigTemplatedInput{Name: "b", Type: "bool"}, "maybe", nil, "not a valid bool"},
+		{"int from string", config.GitSporkConfigTemplatedInput{Name: "i", Type: "int"}, "42", 42, ""},
+		{"int from json number", config.GitSporkConfigTemplatedInput{Name: "i", Type: "int"}, float64(7), 7, ""},
+		{"int fractional", config.GitSporkConfigTemplatedInput{Name: "i", Type: "int"}, float64(7.5), nil, "not a valid integer"},
+		{"int validate", config.GitSporkConfigTemplatedInput{Name: "i", Type: "int", ValidateRegex: "^[0-9]{2}$"}, "123", nil, "does not match"},
+		{"select allowed", config.GitSporkConfigTemplatedInput{Name: "e", Type: "select", Options: []string{"dev", "prod"}}, "prod", "prod", ""},
+		{"select not allowed", config.GitSporkConfigTemplatedInput{Name: "e", Type: "select", Options: []string{"dev", "prod"}}, "qa", nil, "not one of the allowed options: dev, prod"},
+		{"multi-select from cached list", config.GitSporkConfigTemplatedInput{Name: "m", Type: "multi-select", Options: []string{"a", "b", "c"}}, []any{"a", "c"}, []string{"a", "c"}, ""},
+		{"multi-select default", config.GitSporkConfigTemplatedInput{Name: "m", Type: "multi-select", Options: []string{"a", "b", "c"}, Default: "b,c"}, []string{}, []string{"b", "c"}, ""},
+		{"multi-select not allowed", config.GitSporkConfigTemplatedInput{Name: "m", Type: "multi-select", Options: []string{"a", "b"}}, []any{"a", "z"}, nil, `"z"`},
+		{"multi-select required", config.GitSporkConfigTemplatedInput{Name: "m", Type: "multi-select", Options: []string{"a"}, Required: true}, nil, nil, "required"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := resolveTemplatedInputValue(tc.input, tc.raw)
+			if tc.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+// TestIntegratorTemplated_typedInputsRender confirms templates receive typed
+// values rather than strings: a bool drives {{ if }}, an int supports
+// arithmetic comparisons and a multi-select ranges as a list.
+func TestIntegratorTemplated_typedInputsRender(t *testing.T) {
+	upstreamDir, downstreamDir := setupTemplatedFixture(t,
+		`{{ if .Inputs.enabled }}on{{ else }}off{{ end }} {{ if gt .Inputs.replicas 2 }}ha{{ end }} {{ join "+" .Inputs.regions }} {{ .Inputs.env }}`,
+		"",
+	)
+	instructions := []config.GitSporkConfigTemplated{{
+		Template:    "template.txt",
+		Destination: "rendered.txt",
+		Inputs: []config.GitSporkConfigTemplatedInput{
+			{Name: "enabled", Prompt: "enabled?", Type: config.TemplatedInputTypeBool},
+			{Name: "replicas", Prompt: "replicas?", Type: config.TemplatedInputTypeInt},
+			{Name: "regions", Prompt: "regions?", Type: config.TemplatedInputTypeMultiSelect, Options: []string{"us", "eu", "ap"}},
+			{Name: "env", Prompt: "env?", Type: config.TemplatedInputTypeSelect, Options: []string{"dev", "prod"}},
+		},
+	}}
+	prompter := newSequencePrompter("y", "3", "1,eu", "prod")
+	require.NoError(t, (&IntegratorTemplated{Prompter: prompter}).Integrate(instructions, upstreamDir, downstreamDir, false, sdktypes.NoopLogger()))
+	require.Len(t, prompter.requests, 4)
+	assert.Equal(t, config.TemplatedInputTypeBool, prompter.requests[0].Type)
+	assert.Equal(t, config.TemplatedInputTypeInt, prompter.requests[1].Type)
+	assert.Equal(t, config.TemplatedInputTypeMultiSelect, prompter.requests[2].Type)
+	assert.Equal(t, config.TemplatedInputTypeSelect, prompter.requests[3].Type)
+	assert.Equal(t, []string{"dev", "prod"}, prompter.requests[3].Options)
+	assert.Equal(t, "regions", prompter.requests[2].Name)
+	assert.Equal(t, "rendered.txt", prompter.requests[2].Destination)
+
+	got, err := os.ReadFile(filepath.Join(downstreamDir, "rendered.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "on ha us+eu prod", string(got))
+
+	// A second run must read the typed values back out of the JSON cache
+	// without prompting and render identically.
+	prompter = newSequencePrompter("SHOULD-NEVER-BE-USED")
+	require.NoError(t, (&IntegratorTemplated{Prompter: prompter}).Integrate(instructions, upstreamDir, downstreamDir, false, sdktypes.NoopLogger()))
+	assert.Empty(t, prompter.requests)
+	got, err = os.ReadFile(filepath.Join(downstreamDir, "rendered.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "on ha us+eu prod", string(got))
+}
+
+func TestIntegratorTemplated_invalidAnswerRePrompts(t *testing.T) {
+	upstreamDir, downstreamDir := setupTemplatedFixture(t, `{{ .Inputs.name }}`, "")
+	instructions := []config.GitSporkConfigTemplated{{
+		Template:    "template.txt",
+		Destination: "rendered.txt",
+		Inputs: []config.GitSporkConfigTemplatedInput{{
+			Name: "name", Prompt: "name?", Required: true, ValidateRegex: "^[a-z-]+$", Help: "lower-case service name",
+		}},
+	}}
+	prompter := newSequencePrompter("", "Bad Name", "good-name")
+	require.NoError(t, (&IntegratorTemplated{Prompter: prompter}).Integrate(instructions, upstreamDir, downstreamDir, false, sdktypes.NoopLogger()))
+	require.Len(t, prompter.requests, 3, "an empty required answer and a non-matching answer must each trigger a re-prompt")
+	assert.Equal(t, "lower-case service name", prompter.requests[0].Help)
+	assert.Equal(t, 1, prompter.requests[0].Attempt)
+	assert.Empty(t, prompter.requests[0].ValidationError)
+	assert.Equal(t, 3, prompter.requests[2].Attempt)
+	assert.Contains(t, prompter.requests[2].ValidationError, "does not match", "a re-ask must tell the Prompter why the last answer was rejected")
+	got, err := os.ReadFile(filepath.Join(downstreamDir, "rendered.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "good-name", string(got))
+}
+
+func TestIntegratorTemplated_invalidAnswerGivesUpAfterMaxAttempts(t *testing.T) {
+	upstreamDir, downstreamDir := setupTemplatedFixture(t, `{{ .Inputs.n }}`, "")
+	instructions := []config.GitSporkConfigTemplated{{
+		Template:    "template.txt",
+		Destination: "rendered.txt",
+		Inputs:      []config.GitSporkConfigTemplatedInput{{Name: "n", Prompt: "n?", Type: config.TemplatedInputTypeInt}},
+	}}
+	prompter := newSequencePrompter("not-a-number")
+	err := (&IntegratorTemplated{Prompter: prompter}).Integrate(instructions, upstreamDir, downstreamDir, false, sdktypes.NoopLogger())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not a valid integer")
+	assert.Len(t, prompter.requests, maxTemplatedInputPromptAttempts)
+}
+
+func TestIntegratorTemplated_emptyAnswerUsesDefault(t *testing.T) {
+	upstreamDir, downstreamDir := setupTemplatedFixture(t, `{{ .Inputs.env }}`, "")
+	instructions := []config.GitSporkConfigTemplated{{
+		Template:    "template.txt",
+		Destination: "rendered.txt",
+		Inputs:      []config.GitSporkConfigTemplatedInput{{Name: "env", Prompt: "env?", Default: "staging"}},
+	}}
+	prompter := newSequencePrompter("")
+	require.NoError(t, (&IntegratorTemplated{Prompter: prompter}).Integrate(instructions, upstreamDir, downstreamDir, false, sdktypes.NoopLogger()))
+	require.Len(t, prompter.requests, 1)
+	assert.Equal(t, "staging", prompter.requests[0].Default)
+	got, err := os.ReadFile(filepath.Join(downstreamDir, "rendered.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "staging", string(got))
+}
+
+// TestIntegratorTemplated_cachedValueNoLongerValidRePrompts covers an upstream
+// tightening an input after downstreams have answered it: the cached value is
+// rejected and the user is asked again instead of rendering a stale value.
+func TestIntegratorTemplated_cachedValueNoLongerValidRePrompts(t *testing.T) {
+	upstreamDir, downstreamDir := setupTemplatedFixture(t, `{{ .Inputs.env }}`, "")
+	require.NoError(t, saveTemplatedInputs(downstreamDir, map[string]map[string]any{
+		"rendered.txt": {"env": "qa"},
+	}))
+	instructions := []config.GitSporkConfigTemplated{{
+		Template:    "template.txt",
+		Destination: "rendered.txt",
+		Inputs: []config.GitSporkConfigTemplatedInput{{
+			Name: "env", Prompt: "env?", Type: config.TemplatedInputTypeSelect, Options: []string{"dev", "prod"},
+		}},
+	}}
+	prompter := newSequencePrompter("prod")
+	require.NoError(t, (&IntegratorTemplated{Prompter: prompter}).Integrate(instructions, upstreamDir, downstreamDir, false, sdktypes.NoopLogger()))
+	assert.Len(t, prompter.requests, 1)
+	got, err := os.ReadFile(filepath.Join(downstreamDir, "rendered.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "prod", string(got))
+}
+
+func TestIntegratorTemplated_jsonDataPathValueValidated(t *testing.T) {
+	upstreamDir, downstreamDir := setupTemplatedFixture(t, `{{ .Inputs.replicas }}`, `{"replicas":"lots"}`)
+	instructions := []config.GitSporkConfigTemplated{{
+		Template:    "template.txt",
+		Destination: "rendered.txt",
+		Inputs:      []config.GitSporkConfigTemplatedInput{{Name: "replicas", JSONDataPath: "inputs.json", Type: config.TemplatedInputTypeInt}},
+	}}
+	err := (&IntegratorTemplated{}).Integrate(instructions, upstreamDir, downstreamDir, false, sdktypes.NoopLogger())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "json_data_path")
+	assert.Contains(t, err.Error(), "not a valid integer")
+}
+
+func TestTemplatedInputEnvVar(t *testing.T) {
+	assert.Equal(t, "GITSPORK_INPUT_SERVICE_NAME", templatedInputEnvVar("service_name"))
+	assert.Equal(t, "GITSPORK_INPUT_TEAM_OWNER_2", templatedInputEnvVar("team-owner.2"))
+}
+
+// TestIntegratorTemplated_suppliedInputs covers the non-interactive sources
+// for prompt inputs and their precedence: supplied Inputs over env vars over
+// the cache, with none of them prompting.
+func TestIntegratorTemplated_suppliedInputs(t *testing.T) {
+	instructions := []config.GitSporkConfigTemplated{{
+		Template:    "template.txt",
+		Destination: "rendered.txt",
+		Inputs: []config.GitSporkConfigTemplatedInput{
+			{Name: "name", Prompt: "name?"},
+			{Name: "replicas", Prompt: "replicas?", Type: config.TemplatedInputTypeInt},
+		},
+	}}
+	render := func(t *testing.T, integrator *IntegratorTemplated, seedCache map[string]any) string {
+		t.Helper()
+		upstreamDir, downstreamDir := setupTemplatedFixture(t, `{{ .Inputs.name }}/{{ .Inputs.replicas }}`, "")
+		if seedCache != nil {
+			require.NoError(t, saveTemplatedInputs(downstreamDir, map[string]map[string]any{"rendered.txt": seedCache}))
+		}
+		prompter := newSequencePrompter("SHOULD-NEVER-BE-USED")
+		integrator.Prompter = prompter
+		require.NoError(t, integrator.Integrate(instructions, upstreamDir, downstreamDir, false, sdktypes.NoopLogger()))
+		assert.Empty(t, prompter.requests, "supplied values must not prompt")
+		got, err := os.ReadFile(filepath.Join(downstreamDir, "rendered.txt"))
+		require.NoError(t, err)
+		return string(got)
+	}
+
+	t.Run("supplied inputs", func(t *testing.T) {
+		got := render(t, &IntegratorTemplated{Inputs: map[string]any{"name": "svc", "replicas": 3}}, nil)
+		assert.Equal(t, "svc/3", got)
+	})
+	t.Run("env vars", func(t *testing.T) {
+		t.Setenv("GITSPORK_INPUT_NAME", "from-env")
+		t.Setenv("GITSPORK_INPUT_REPLICAS", "2")
+		assert.Equal(t, "from-env/2", render(t, &IntegratorTemplated{}, nil))
+	})
+	t.Run("supplied beats env beats cache", func(t *testing.T) {
+		t.Setenv("GITSPORK_INPUT_NAME", "from-env")
+		t.Setenv("GITSPORK_INPUT_REPLICAS", "2")
+		got := render(t, &IntegratorTemplated{Inputs: map[string]any{"name": "supplied"}},
+			map[string]any{"name": "cached", "replicas": 1})
+		assert.Equal(t, "supplied/2", got)
+	})
+	t.Run("invalid supplied value fails", func(t *testing.T) {
+		upstreamDir, downstreamDir := setupTemplatedFixture(t, `{{ .Inputs.replicas }}`, "")
+		integrator := &IntegratorTemplated{Inputs: map[string]any{"name": "svc", "replicas": "many"}}
+		err := integrator.Integrate(instructions, upstreamDir, downstreamDir, false, sdktypes.NoopLogger())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "supplied inputs")
+		assert.Contains(t, err.Error(), "not a valid integer")
+	})
+}
+
+func TestIntegratorTemplated_nonInteractiveListsAllMissingInputs(t *testing.T) {
+	upstreamDir, downstreamDir := setupTemplatedFixture(t, `{{ .Inputs.a }}`, "")
+	require.NoError(t, os.WriteFile(filepath.Join(upstreamDir, "other.txt"), []byte(`{{ .Inputs.c }}`), 0644))
+	require.NoError(t, saveTemplatedInputs(downstreamDir, map[string]map[string]any{
+		"rendered.txt": {"b": "cached"},
+	}))
+	instructions := []config.GitSporkConfigTemplated{
+		{
+			Template:    "template.txt",
+			Destination: "rendered.txt",
+			Inputs: []config.GitSporkConfigTemplatedInput{
+				{Name: "a", Prompt: "value of a?"},
+				{Name: "b", Prompt: "value of b?"},
+			},
+		},
+		{
+			Template:    "other.txt",
+			Destination: "other-rendered.txt",
+			Inputs:      []config.GitSporkConfigTemplatedInput{{Name: "c", Prompt: "value of c?"}},
+		},
+	}
+	prompter := newSequencePrompter("SHOULD-NEVER-BE-USED")
+	err := (&IntegratorTemplated{NonInteractive: true, Prompter: prompter}).Integrate(instructions, upstreamDir, downstreamDir, false, sdktypes.NoopLogger())
+	require.Error(t, err)
+	assert.Empty(t, prompter.requests)
+	assert.ErrorIs(t, err, sdktypes.ErrMissingInputs)
+	var missingErr *sdktypes.MissingInputsError
+	require.ErrorAs(t, err, &missingErr)
+	assert.Equal(t, []sdktypes.MissingInput{
+		{Template: "template.txt", Destination: "rendered.txt", Name: "a", Prompt: "value of a?", EnvVar: "GITSPORK_INPUT_A"},
+		{Template: "other.txt", Destination: "other-rendered.txt", Name: "c", Prompt: "value of c?", EnvVar: "GITSPORK_INPUT_C"},
+	}, missingErr.Inputs, "the cached input b is not missing; a and c are")
+	assert.Contains(t, err.Error(), `"value of c?"`)
+	_, statErr := os.Stat(filepath.Join(downstreamDir, "rendered.txt"))
+	assert.True(t, os.IsNotExist(statErr), "nothing may be rendered when inputs are missing")
+
+	t.Run("forceRePrompt makes cached inputs missing too", func(t *testing.T) {
+		err := (&IntegratorTemplated{NonInteractive: true}).Integrate(instructions, upstreamDir, downstreamDir, true, sdktypes.NoopLogger())
+		require.ErrorAs(t, err, &missingErr)
+		assert.Len(t, missingErr.Inputs, 3)
+	})
+
+	t.Run("succeeds once every input is supplied", func(t *testing.T) {
+		integrator := &IntegratorTemplated{NonInteractive: true, Inputs: map[string]any{"a": "A", "c": "C"}}
+		require.NoError(t, integrator.Integrate(instructions, upstreamDir, downstreamDir, false, sdktypes.NoopLogger()))
+		got, err := os.ReadFile(filepath.Join(downstreamDir, "other-rendered.txt"))
+		require.NoError(t, err)
+		assert.Equal(t, "C", string(got))
+	})
+}
+
+func TestIntegratorTemplated_nonInteractiveDataAndEnvInputsWithPrompt(t *testing.T) {
+	for name, tc := range map[string]struct {
+		input config.GitSporkConfigTemplatedInput
+		want  string
+	}{
+		"yaml_data_path": {config.GitSporkConfigTemplatedInput{Name: "owner", Prompt: "owner?", YAMLDataPath: "service.yml", DataKey: "$.owner"}, "payments"},
+		"env":            {config.GitSporkConfigTemplatedInput{Name: "owner", Prompt: "owner?", Env: "TEST_GITSPORK_OWNER"}, "billing"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			upstreamDir, downstreamDir := setupTemplatedFixture(t, `{{ .Inputs.owner }}`, "")
+			require.NoError(t, os.WriteFile(filepath.Join(downstreamDir, "service.yml"), []byte("owner: payments\n"), 0644))
+			t.Setenv("TEST_GITSPORK_OWNER", "billing")
+			instructions := []config.GitSporkConfigTemplated{{Template: "template.txt", Destination: "rendered.txt", Inputs: []config.GitSporkConfigTemplatedInput{tc.input}}}
+			prompter := newSequencePrompter("SHOULD-NEVER-BE-USED")
+			require.NoError(t, (&IntegratorTemplated{NonInteractive: true, Prompter: prompter}).Integrate(instructions, upstreamDir, downstreamDir, false, sdktypes.NoopLogger()))
+			assert.Empty(t, prompter.requests)
+			assert.Equal(t, tc.want, readFileString(t, filepath.Join(downstreamDir, "rendered.txt")))
+		})
+	}
+}
+
+func TestIntegratorTemplated_nonInteractiveUsesDefault(t *testing.T) {
+	upstreamDir, downstreamDir := setupTemplatedFixture(t, `{{ .Inputs.env }}/{{ .Inputs.region }}`, "")
+	instructions := []config.GitSporkConfigTemplated{{
+		Template:    "template.txt",
+		Destination: "rendered.txt",
+		Inputs: []config.GitSporkConfigTemplatedInput{
+			{Name: "env", Prompt: "env?", Default: "staging"},
+			{Name: "region", Prompt: "region?", Default: "us-east-1", Global: true},
+		},
+	}}
+	prompter := newSequencePrompter("SHOULD-NEVER-BE-USED")
+	require.NoError(t, (&IntegratorTemplated{NonInteractive: true, Prompter: prompter}).Integrate(instructions, upstreamDir, downstreamDir, false, sdktypes.NoopLogger()))
+	assert.Empty(t, prompter.requests)
+	got, err := os.ReadFile(filepath.Join(downstreamDir, "rendered.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "staging/us-east-1", string(got))
+
+	t.Run("an invalid default fails", func(t *testing.T) {
+		invalid := []config.GitSporkConfigTemplated{{
+			Template:    "template.txt",
+			Destination: "rendered.txt",
+			Inputs:      []config.GitSporkConfigTemplatedInput{{Name: "env", Prompt: "env?", Default: "nope", Type: config.TemplatedInputTypeInt}},
+		}}
+		err := (&IntegratorTemplated{NonInteractive: true}).Integrate(invalid, upstreamDir, downstreamDir, true, sdktypes.NoopLogger())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid default for input env")
+	})
+}
+
+func TestIntegratorTemplated_dataEnvAndExecSources(t *testing.T) {
+	upstreamDir, downstreamDir := setupTemplatedFixture(t,
+		`{{ .Inputs.name }}|{{ .Inputs.owner }}|{{ .Inputs.first }}|{{ .Inputs.replicas }}|{{ .Inputs.region }}|{{ .Inputs.module }}|{{ .Inputs.missing }}`, "")
+	require.NoError(t, os.WriteFile(filepath.Join(downstreamDir, "package.json"), []byte(`{"name":"billing","contributors":[{"name":"ada"}]}`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(downstreamDir, "service.yml"), []byte("service:\n  owner: payments\n  replicas: 3\n"), 0644))
+	t.Setenv("TEST_GITSPORK_REGION", "eu-west-1")
+	instructions := []config.GitSporkConfigTemplated{{
+		Template:    "template.txt",
+		Destination: "rendered.txt",
+		Inputs: []config.GitSporkConfigTemplatedInput{
+			{Name: "name", JSONDataPath: "package.json", DataKey: "$.name"},
+			{Name: "first", JSONDataPath: "package.json", DataKey: "$.contributors[0].name"},
+			{Name: "owner", YAMLDataPath: "service.yml", DataKey: "$.service.owner"},
+			{Name: "replicas", YAMLDataPath: "service.yml", DataKey: "$.service.replicas", Type: config.TemplatedInputTypeInt},
+			{Name: "region", Env: "TEST_GITSPORK_REGION"},
+			{Name: "module", Exec: "echo  example.com/billing  "},
+			{Name: "missing", YAMLDataPath: "service.yml", DataKey: "$.service.tier", Default: "standard"},
+		},
+	}}
+	trusted := migrationOptions{allowMigrations: true}
+	require.NoError(t, (&IntegratorTemplated{commandTrust: trusted}).Integrate(instructions, upstreamDir, downstreamDir, false, sdktypes.NoopLogger()))
+	got, err := os.ReadFile(filepath.Join(downstreamDir, "rendered.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "billing|payments|ada|3|eu-west-1|example.com/billing|standard", string(got))
+
+	t.Run("yaml_data_path without data_key reads root properties", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(filepath.Join(downstreamDir, "root.yml"), []byte("name: from-yaml\n"), 0644))
+		upstreamDir, _ := setupTemplatedFixture(t, `{{ .Inputs.name }}`, "")
+		instructions := []config.GitSporkConfigTemplated{{
+			Template:    "template.txt",
+			Destination: "root.txt",
+			Inputs:      []config.GitSporkConfigTemplatedInput{{Name: "name", YAMLDataPath: "root.yml"}},
+		}}
+		require.NoError(t, (&IntegratorTemplated{}).Integrate(instructions, upstreamDir, downstreamDir, false, sdktypes.NoopLogger()))
+		got, err := os.ReadFile(filepath.Join(downstreamDir, "root.txt"))
+		require.NoError(t, err)
+		assert.Equal(t, "from-yaml", string(got))
+	})
+	t.Run("invalid values and failing commands", func(t *testing.T) {
+		for _, tc := range []struct {
+			input   config.GitSporkConfigTemplatedInput
+			wantErr string
+		}{
+			{config.GitSporkConfigTemplatedInput{Name: "n", YAMLDataPath: "service.yml", DataKey: "$.service", Type: config.TemplatedInputTypeInt}, "invalid value in yaml_data_path file"},
+			{config.GitSporkConfigTemplatedInput{Name: "n", Env: "TEST_GITSPORK_REGION", Type: config.TemplatedInputTypeInt}, "invalid value from env var TEST_GITSPORK_REGION"},
+			{config.GitSporkConfigTemplatedInput{Name: "n", Exec: "false"}, "error running exec for input n"},
+		} {
+			instructions := []config.GitSporkConfigTemplated{{Template: "template.txt", Destination: "err.txt", Inputs: []config.GitSporkConfigTemplatedInput{tc.input}}}
+			err := (&IntegratorTemplated{commandTrust: trusted}).Integrate(instructions, upstreamDir, downstreamDir, false, sdktypes.NoopLogger())
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.wantErr)
+		}
+	})
+}
+
+func TestIntegratorTemplated_execInputs(t *testing.T) {
+	const upstreamURL = "https://github.com/acme/platform.git"
+	instructions := []config.GitSporkConfigTemplated{{
+		Template:    "template.txt",
+		Destination: "rendered.txt",
+		Inputs:      []config.GitSporkConfigTemplatedInput{{Name: "module", Exec: "echo example.com/billing"}},
+	}}
+
+	t.Run("needs a trusted upstream", func(t *testing.T) {
+		upstreamDir, downstreamDir := setupTemplatedFixture(t, `{{ .Inputs.module }}`, "")
+		untrusted := migrationOptions{upstreamURL: upstreamURL, nonInteractive: true, prompter: failingPrompter(t)}
+		err := (&IntegratorTemplated{commandTrust: untrusted}).Integrate(instructions, upstreamDir, downstreamDir, false, sdktypes.NoopLogger())
+		require.ErrorContains(t, err, "templated inputs from "+upstreamURL+" run commands (module under template template.txt)")
+		_, statErr := os.Stat(filepath.Join(downstreamDir, "rendered.txt"))
+		assert.True(t, os.IsNotExist(statErr), "nothing may be rendered by an untrusted upstream's commands")
+
+		prompter := newSequencePrompter("no")
+		err = (&IntegratorTemplated{commandTrust: migrationOptions{upstreamURL: upstreamURL, prompter: prompter}}).Integrate(instructions, upstreamDir, downstreamDir, false, sdktypes.NoopLogger())
+		require.ErrorContains(t, err, "they weren't allowed to run")
+		require.Len(t, prompter.requests, 1)
+		assert.Contains(t, prompter.requests[0].Help, "input module under template template.txt: exec echo example.com/billing")
+
+		writeTrustPolicy(t, downstreamDir, "migrations:\n  allow:\n    - github.com/acme/*\n")
+		require.NoError(t, (&IntegratorTemplated{commandTrust: untrusted}).Integrate(instructions, upstreamDir, downstreamDir, false, sdktypes.NoopLogger()))
+		got, err := os.ReadFile(filepath.Join(downstreamDir, "rendered.txt"))
+		require.NoError(t, err)
+		assert.Equal(t, "example.com/billing", string(got))
+	})
+
+	t.Run("is refused before anything is integrated", func(t *testing.T) {
+		upstreamDir, downstreamDir := setupTemplatedFixture(t, `{{ .Inputs.module }}`, "")
+		require.NoError(t, os.WriteFile(filepath.Join(upstreamDir, "owned.txt"), []byte("owned\n"), 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(upstreamDir, config.GitSporkConfigFileName), []byte(`upstream_owned:
+- owned.txt
+templated:
+- template: template.txt
+  destination: rendered.txt
+  inputs:
+  - name: module
+    exec: echo example.com/billing
+`), 0644))
+		_, err := IntegrateLocal(&sdktypes.IntegrateLocalOptions{
+			Logger:         sdktypes.NoopLogger(),
+			UpstreamPaths:  []string{upstreamDir},
+			DownstreamPath: downstreamDir,
+			NonInteractive: true,
+		})
+		require.ErrorContains(t, err, "run commands (module under template template.txt)")
+		assert.NoFileExists(t, filepath.Join(downstreamDir, "owned.txt"))
+	})
+
+	t.Run("times out", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("uses POSIX sleep")
+		}
+		defer func(timeout time.Duration) { inputCommandTimeout = timeout }(inputCommandTimeout)
+		inputCommandTimeout = 100 * time.Millisecond
+		upstreamDir, downstreamDir := setupTemplatedFixture(t, `{{ .Inputs.module }}`, "")
+		writeTrustPolicy(t, downstreamDir, "migrations:\n  allow:\n    - github.com/acme/*\n")
+		sleeping := []config.GitSporkConfigTemplated{{
+			Template:    "template.txt",
+			Destination: "rendered.txt",
+			Inputs:      []config.GitSporkConfigTemplatedInput{{Name: "module", Exec: "sleep 30"}},
+		}}
+		start := time.Now()
+		err := (&IntegratorTemplated{commandTrust: migrationOptions{upstreamURL: upstreamURL, nonInteractive: true}}).Integrate(sleeping, upstreamDir, downstreamDir, false, sdktypes.NoopLogger())
+		require.ErrorContains(t, err, "timed out after 100ms")
+		assert.Less(t, time.Since(start), 10*time.Second)
+	})
+
+	t.Run("a drift check reuses the recorded value", func(t *testing.T) {
+		upstreamDir, downstreamDir := setupTemplatedFixture(t, `{{ .Inputs.module }}`, "")
+		drift := migrationOptions{upstreamURL: upstreamURL, forDriftCheck: true, prompter: failingPrompter(t)}
+		failing := []config.GitSporkConfigTemplated{{
+			Template:    "template.txt",
+			Destination: "rendered.txt",
+			Inputs:      []config.GitSporkConfigTemplatedInput{{Name: "module", Exec: "false"}},
+		}}
+
+		err := (&IntegratorTemplated{NonInteractive: true, commandTrust: drift}).Integrate(failing, upstreamDir, downstreamDir, false, sdktypes.NoopLogger())
+		var missingErr *sdktypes.MissingInputsError
+		require.ErrorAs(t, err, &missingErr)
+		require.Len(t, missingErr.Inputs, 1)
+		assert.Equal(t, "module", missingErr.Inputs[0].Name)
+
+		require.NoError(t, saveTemplatedInputs(downstreamDir, map[string]map[string]any{"rendered.txt": {"module": "example.com/cached"}}))
+		require.NoError(t, (&IntegratorTemplated{NonInteractive: true, commandTrust: drift}).Integrate(failing, upstreamDir, downstreamDir, false, sdktypes.NoopLogger()))
+		got, err := os.ReadFile(filepath.Join(downstreamDir, "rendered.txt"))
+		require.NoError(t, err)
+		assert.Equal(t, "example.com/cached", string(got))
+
+		integrator := &IntegratorTemplated{NonInteractive: true, Inputs: map[string]any{"module": "example.com/supplied"}, commandTrust: drift}
+		require.NoError(t, integrator.Integrate(failing, upstreamDir, downstreamDir, false, sdktypes.NoopLogger()))
+		got, err = os.ReadFile(filepath.Join(downstreamDir, "rendered.txt"))
+		require.NoError(t, err)
+		assert.Equal(t, "example.com/supplied", string(got))
+	})
+}
+
+func TestIntegratorTemplated_secretInputs(t *testing.T) {
+	instructions := func(secret config.GitSporkConfigTemplatedInput) []config.GitSporkConfigTemplated {
+		return []config.GitSporkConfigTemplated{
+			{
+				Template:    "template.txt",
+				Destination: "rendered.txt",
+				Inputs:      []config.GitSporkConfigTemplatedInput{{Name: "name", Prompt: "name?"}, secret},
+			},
+			{
+				Template:    "other.txt",
+				Destination: "copy.txt",
+				Inputs: []config.GitSporkConfigTemplatedInput{
+					{Name: "name", PreviousInput: &config.GitSporkConfigTemplatedInputPrevious{Template: "template.txt", Name: "name"}},
+					{Name: "token", PreviousInput: &config.GitSporkConfigTemplatedInputPrevious{Template: "template.txt", Name: "token"}},
+				},
+			},
+		}
+	}
+	render := func(t *testing.T, integrator *IntegratorTemplated, secret config.GitSporkConfigTemplatedInput) string {
+		t.Helper()
+		upstreamDir, downstreamDir := setupTemplatedFixture(t, `{{ .Inputs.token }}`, "")
+		require.NoError(t, os.WriteFile(filepath.Join(upstreamDir, "other.txt"), []byte(`{{ .Inputs.name }}:{{ .Inputs.token }}`), 0644))
+		require.NoError(t, integrator.Integrate(instructions(secret), upstreamDir, downstreamDir, false, sdktypes.NoopLogger()))
+		cache, err := os.ReadFile(filepath.Join(downstreamDir, gitSporkMetaDirName, templatedInputsCacheFileName))
+		require.NoError(t, err)
+		assert.Contains(t, string(cache), `"name"`)
+		assert.NotContains(t, string(cache), "token", "secrets, and inputs taken from them, are never cached")
+		got, err := os.ReadFile(filepath.Join(downstreamDir, "copy.txt"))
+		require.NoError(t, err)
+		return string(got)
+	}
+
+	t.Run("prompted with masked entry on every integrate", func(t *testing.T) {
+		prompter := newSequencePrompter("svc", "s3cret")
+		secret := config.GitSporkConfigTemplatedInput{Name: "token", Prompt: "token?", Secret: true}
+		assert.Equal(t, "svc:s3cret", render(t, &IntegratorTemplated{Prompter: prompter}, secret))
+		require.Len(t, prompter.requests, 2)
+		assert.False(t, prompter.requests[0].Secret)
+		assert.True(t, prompter.requests[1].Secret)
+	})
+	trusted := migrationOptions{allowMigrations: true}
+	t.Run("supplied, then env, then secret_env, then secret_command", func(t *testing.T) {
+		secret := config.GitSporkConfigTemplatedInput{Name: "token", Prompt: "token?", Secret: true, SecretEnv: "TEST_GITSPORK_TOKEN", SecretCommand: "echo from-command"}
+		assert.Equal(t, "svc:from-command", render(t, &IntegratorTemplated{Inputs: map[string]any{"name": "svc"}, commandTrust: trusted}, secret))
+		t.Setenv("TEST_GITSPORK_TOKEN", "from-secret-env")
+		assert.Equal(t, "svc:from-secret-env", render(t, &IntegratorTemplated{Inputs: map[string]any{"name": "svc"}}, secret))
+		t.Setenv("GITSPORK_INPUT_TOKEN", "from-env")
+		assert.Equal(t, "svc:from-env", render(t, &IntegratorTemplated{Inputs: map[string]any{"name": "svc"}}, secret))
+		assert.Equal(t, "svc:supplied", render(t, &IntegratorTemplated{Inputs: map[string]any{"name": "svc", "token": "supplied"}}, secret))
+	})
+	t.Run("non-interactive fails without a source", func(t *testing.T) {
+		upstreamDir, downstreamDir := setupTemplatedFixture(t, `{{ .Inputs.token }}`, "")
+		secret := config.GitSporkConfigTemplatedInput{Name: "token", Prompt: "token?", Secret: true, SecretEnv: "TEST_GITSPORK_UNSET_TOKEN"}
+		err := (&IntegratorTemplated{NonInteractive: true, Inputs: map[string]any{"name": "svc"}}).Integrate(instructions(secret), upstreamDir, downstreamDir, false, sdktypes.NoopLogger())
+		var missingErr *sdktypes.MissingInputsError
+		require.ErrorAs(t, err, &missingErr)
+		assert.Equal(t, []sdktypes.MissingInput{
+			{Template: "template.txt", Destination: "rendered.txt", Name: "token", Prompt: "token?", EnvVar: "GITSPORK_INPUT_TOKEN"},
+		}, missingErr.Inputs)
+	})
+	t.Run("invalid values aren't echoed", func(t *testing.T) {
+		upstreamDir, downstreamDir := setupTemplatedFixture(t, `{{ .Inputs.token }}`, "")
+		secret := config.GitSporkConfigTemplatedInput{Name: "token", Secret: true, ValidateRegex: "^tok_"}
+		err := (&IntegratorTemplated{Inputs: map[string]any{"name": "svc", "token": "s3cret"}}).Integrate(instructions(secret), upstreamDir, downstreamDir, false, sdktypes.NoopLogger())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "value (secret) for input token does not match")
+		assert.NotContains(t, err.Error(), "s3cret")
+
+		selectSecret := config.GitSporkConfigTemplatedInput{Name: "token", Secret: true, Type: config.TemplatedInputTypeSelect, Options: []string{"a", "b"}}
+		err = (&IntegratorTemplated{Inputs: map[string]any{"name": "svc", "token": "s3cret"}}).Integrate(instructions(selectSecret), upstreamDir, downstreamDir, false, sdktypes.NoopLogger())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "value (secret) for input token is not one of the allowed options")
+		assert.NotContains(t, err.Error(), "s3cret")
+
+		intSecret := config.GitSporkConfigTemplatedInput{Name: "token", Secret: true, Type: config.TemplatedInputTypeInt}
+		err = (&IntegratorTemplated{Inputs: map[string]any{"name": "svc", "token": "s3cret"}}).Integrate(instructions(intSecret), upstreamDir, downstreamDir, false, sdktypes.NoopLogger())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "value (secret) for input token is not a valid integer")
+		assert.NotContains(t, err.Error(), "s3cret")
+	})
+	t.Run("failing secret_command", func(t *testing.T) {
+		upstreamDir, downstreamDir := setupTemplatedFixture(t, `{{ .Inputs.token }}`, "")
+		secret := config.GitSporkConfigTemplatedInput{Name: "token", Secret: true, SecretCommand: "false"}
+		err := (&IntegratorTemplated{Inputs: map[string]any{"name": "svc"}, commandTrust: trusted}).Integrate(instructions(secret), upstreamDir, downstreamDir, false, sdktypes.NoopLogger())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "error running secret_command for input token")
+	})
+	t.Run("secret_command needs a trusted upstream", func(t *testing.T) {
+		secret := config.GitSporkConfigTemplatedInput{Name: "token", Secret: true, SecretCommand: "echo from-command"}
+		const upstreamURL = "https://github.com/acme/platform.git"
+
+		upstreamDir, downstreamDir := setupTemplatedFixture(t, `{{ .Inputs.token }}`, "")
+		untrusted := migrationOptions{upstreamURL: upstreamURL, nonInteractive: true, prompter: failingPrompter(t)}
+		err := (&IntegratorTemplated{Inputs: map[string]any{"name": "svc"}, commandTrust: untrusted}).Integrate(instructions(secret), upstreamDir, downstreamDir, false, sdktypes.NoopLogger())
+		require.ErrorContains(t, err, "templated inputs from "+upstreamURL+" run commands (token under template template.txt)")
+		assert.ErrorContains(t, err, "--allow-migrations")
+		_, statErr := os.Stat(filepath.Join(downstreamDir, "rendered.txt"))
+		assert.True(t, os.IsNotExist(statErr), "nothing may be rendered by an untrusted upstream's commands")
+
+		noMigrations := migrationOptions{upstreamURL: upstreamURL, noMigrations: true, prompter: failingPrompter(t)}
+		err = (&IntegratorTemplated{Inputs: map[string]any{"name": "svc"}, commandTrust: noMigrations}).Integrate(instructions(secret), upstreamDir, downstreamDir, false, sdktypes.NoopLogger())
+		require.ErrorContains(t, err, "--no-migrations confirms nothing")
+
+		prompter := newSequencePrompter("no")
+		err = (&IntegratorTemplated{Inputs: map[string]any{"name": "svc"}, commandTrust: migrationOptions{upstreamURL: upstreamURL, prompter: prompter}}).Integrate(instructions(secret), upstreamDir, downstreamDir, false, sdktypes.NoopLogger())
+		require.ErrorContains(t, err, "they weren't allowed to run")
+		require.Len(t, prompter.requests, 1)
+		assert.Contains(t, prompter.requests[0].Help, "input token under template template.txt: secret_command echo from-command")
+
+		assert.Equal(t, "svc:from-command", render(t, &IntegratorTemplated{Inputs: map[string]any{"name": "svc"}, commandTrust: migrationOptions{upstreamURL: upstreamURL, prompter: newSequencePrompter("yes")}}, secret))
+
+		t.Run("trust policy allow list", func(t *testing.T) {
+			upstreamDir, downstreamDir := setupTemplatedFixture(t, `{{ .Inputs.token }}`, "")
+			writeTrustPolicy(t, downstreamDir, "migrations:\n  allow:\n    - github.com/acme/*\n")
+			require.NoError(t, os.WriteFile(filepath.Join(upstreamDir, "other.txt"), []byte(`{{ .Inputs.name }}:{{ .Inputs.token }}`), 0644))
+			require.NoError(t, (&IntegratorTemplated{Inputs: map[string]any{"name": "svc"}, commandTrust: untrusted}).Integrate(instructions(secret), upstreamDir, downstreamDir, false, sdktypes.NoopLogger()))
+		})
+		t.Run("a value from elsewhere needs no trust", func(t *testing.T) {
+			upstreamDir, downstreamDir := setupTemplatedFixture(t, `{{ .Inputs.token }}`, "")
+			require.NoError(t, os.WriteFile(filepath.Join(upstreamDir, "other.txt"), []byte(`{{ .Inputs.name }}:{{ .Inputs.token }}`), 0644))
+			require.NoError(t, (&IntegratorTemplated{Inputs: map[string]any{"name": "svc", "token": "supplied"}, commandTrust: untrusted}).Integrate(instructions(secret), upstreamDir, downstreamDir, false, sdktypes.NoopLogger()))
+		})
+	})
+}
+
+// TestIntegratorTemplated_concurrentPrompters guards the reason Prompter is
+// per-integrator state rather than a package-level seam: integrations running
+// side by side in one process must each get answers from their own Prompter.
+func TestIntegratorTemplated_concurrentPrompters(t *testing.T) {
+	instructions := []config.GitSporkConfigTemplated{{
+		Template:    "template.txt",
+		Destination: "rendered.txt",
+		Inputs:      []config.GitSporkConfigTemplatedInput{{Name: "name", Prompt: "name?"}},
+	}}
+	const workers = 8
+	downstreams := make([]string, workers)
+	errs := make([]error, workers)
+	var wg sync.WaitGroup
+	for w := range workers {
+		upstreamDir, downstreamDir := setupTemplatedFixture(t, `{{ .Inputs.name }}`, "")
+		downstreams[w] = downstreamDir
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			prompter := sdktypes.PromptFunc(func(req sdktypes.PromptRequest) (any, error) {
+				return fmt.Sprintf("worker-%d", w), nil
+			})
+			errs[w] = (&IntegratorTemplated{Prompter: prompter}).Integrate(instructions, upstreamDir, downstreamDir, false, sdktypes.NoopLogger())
+		}()
+	}
+	wg.Wait()
+	for w := range workers {
+		require.NoError(t, errs[w])
+		got, err := os.ReadFile(filepath.Join(downstreams[w], "rendered.txt"))
+		require.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("worker-%d", w), string(got))
+	}
+}
@@ -25,6 +25,10 @@ type upstreamRename struct {
 type upstreamDelta struct {
 	Deletions []string
 	Renames   []upstreamRename
+	// RenderedRemovals are destinations of template_dir or conditional
+	// (`when`) entries that were removed or retargeted; their recorded
+	// rendered files are deleted.
+	RenderedRemovals []string
 }
 
 func computeUpstreamDelta(repo *gogit.Repository, prevHash, newHash string, cfg *config.GitSporkConfig, upstreamSubpath string) (*upstreamDelta, error) {
@@ -242,19 +246,46 @@ func applyTemplatedConfigDelta(prevCommit, newCommit *object.Commit, upstreamSub
 	if err != nil {
 		// No config file in new commit — treat all prev templated entries as deleted
 		for _, prev := range prevConfig.Templated {
-			delta.Deletions = append(delta.Deletions, prev.Destination)
+			switch {
+			case prev.RenderOnce():
+			case prev.IsDir() || prev.When != "":
+				delta.RenderedRemovals = append(delta.RenderedRemovals, prev.Target())
+			default:
+				delta.Deletions = append(delta.Deletions, prev.Destination)
+			}
 		}
 		return nil
 	}
 
 	newByTemplate := map[string]config.GitSporkConfigTemplated{}
 	for _, t := range newConfig.Templated {
-		newByTemplate[t.Template] = t
+		newByTemplate[t.Source()] = t
 	}
 
 	for _, prev := range prevConfig.Templated {
-		next, exists := newByTemplate[prev.Template]
-		if !exists {
+		next, exists := newByTemplate[prev.Source()]
+		if prev.RenderOnce() {
+			// Seeded destinations belong to the downstream: never removed or moved.
+			continue
+		}
+		if prev.When != "" && !prev.IsDir() {
+			// A conditional destination may never have been rendered, so only
+			// what the rendered record holds is removed; the next render
+			// writes any new destination.
+			if !exists || next.IsDir() || next.Destination != prev.Destination {
+				delta.RenderedRemovals = append(delta.RenderedRemovals, prev.Destination)
+			}
+			continue
+		}
+		if prev.IsDir() {
+			// Rendered files aren't moved to a new destination_dir: the next
+			// render writes them there, so the old ones are just removed.
+			if !exists || !next.IsDir() || next.DestinationDir != prev.DestinationDir {
+				delta.RenderedRemovals = append(delta.RenderedRemovals, prev.DestinationDir)
+			}
+			continue
+		}
+		if !exists || next.IsDir() {
 			delta.Deletions = append(delta.Deletions, prev.Destination)
 			continue
 		}
@@ -316,6 +347,23 @@ func applyUpstreamDelta(delta *upstreamDelta, downstreamPath string, logger sdkt
 		}
 	}
 
+	if len(delta.RenderedRemovals) > 0 {
+		rendered, err := loadTemplatedRendered(downstreamPath)
+		if err != nil {
+			return fmt.Errorf("error loading templated rendered files record: %v", err)
+		}
+		for _, destination := range delta.RenderedRemovals {
+			logger.Log("🗑️  delta: removing files rendered to %s from downstream", destination)
+			if err := removeStaleRenderedFiles(downstreamPath, destination, rendered[destination], nil, logger); err != nil {
+				return fmt.Errorf("error removing files rendered to %s from downstream: %v", destination, err)
+			}
+			delete(rendered, destination)
+		}
+		if err := saveTemplatedRendered(downstreamPath, rendered); err != nil {
+			return fmt.Errorf("error writing templated rendered files record: %v", err)
+		}
+	}
+
 	for _, ren := range delta.Renames {
 		oldTarget := filepath.Join(downstreamPath, ren.OldPath)
 		newTarget := filepath.Join(downstreamPath, ren.NewPath)
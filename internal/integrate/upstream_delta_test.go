@@ -207,6 +207,61 @@ func Test_computeUpstreamDelta(t *testing.T) {
 		assert.Equal(t, "out/new.txt", delta.Renames[0].NewPath)
 	})
 
+	t.Run("template_dir retargeted appears in RenderedRemovals", func(t *testing.T) {
+		dir, err := os.MkdirTemp("", "gitspork-delta-test")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		prevCfg := &config.GitSporkConfig{
+			Templated: []config.GitSporkConfigTemplated{
+				{TemplateDir: "tmpl/service", DestinationDir: "services"},
+				{TemplateDir: "tmpl/docs", DestinationDir: "docs"},
+				{TemplateDir: "tmpl/ci", DestinationDir: "ci"},
+			},
+		}
+		newCfg := &config.GitSporkConfig{
+			Templated: []config.GitSporkConfigTemplated{
+				{TemplateDir: "tmpl/service", DestinationDir: "svc"},
+				{TemplateDir: "tmpl/ci", DestinationDir: "ci"},
+			},
+		}
+		repo, prevHash, newHash := makeUpstreamWithTemplatedConfigChange(t, dir, prevCfg, newCfg)
+
+		delta, err := computeUpstreamDelta(repo, prevHash, newHash, newCfg, "")
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"services", "docs"}, delta.RenderedRemovals)
+		assert.Empty(t, delta.Renames, "rendered directories are re-rendered, not moved")
+		assert.Empty(t, delta.Deletions)
+	})
+
+	t.Run("conditional entries use RenderedRemovals and render once entries are left alone", func(t *testing.T) {
+		dir, err := os.MkdirTemp("", "gitspork-delta-test")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		prevCfg := &config.GitSporkConfig{
+			Templated: []config.GitSporkConfigTemplated{
+				{Template: "tmpl/Dockerfile", Destination: "Dockerfile", When: ".Inputs.docker"},
+				{Template: "tmpl/compose.yml", Destination: "compose.yml", When: ".Inputs.docker"},
+				{Template: "tmpl/CHANGELOG.md", Destination: "CHANGELOG.md", Render: config.TemplatedRenderOnce},
+				{Template: "tmpl/LICENSE", Destination: "LICENSE", Render: config.TemplatedRenderOnce},
+			},
+		}
+		newCfg := &config.GitSporkConfig{
+			Templated: []config.GitSporkConfigTemplated{
+				{Template: "tmpl/compose.yml", Destination: "deploy/compose.yml", When: ".Inputs.docker"},
+				{Template: "tmpl/LICENSE", Destination: "LICENSE.md", Render: config.TemplatedRenderOnce},
+			},
+		}
+		repo, prevHash, newHash := makeUpstreamWithTemplatedConfigChange(t, dir, prevCfg, newCfg)
+
+		delta, err := computeUpstreamDelta(repo, prevHash, newHash, newCfg, "")
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"Dockerfile", "compose.yml"}, delta.RenderedRemovals)
+		assert.Empty(t, delta.Renames)
+		assert.Empty(t, delta.Deletions)
+	})
+
 	// UpstreamSpec.Subpath may be supplied by users with trailing or leading
 	// slashes (e.g. "upstream/"). Pre-normalization such inputs made
 	// stripSubpath build a "upstream//" prefix that matched nothing, silently
@@ -551,6 +606,32 @@ func Test_applyUpstreamDelta(t *testing.T) {
 		assert.NotZero(t, info.Mode()&os.ModeSymlink)
 	})
 
+	t.Run("templated dir removal deletes recorded files only", func(t *testing.T) {
+		dir, err := os.MkdirTemp("", "gitspork-apply-test")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		for _, p := range []string{"services/api/main.go", "services/api/README.md", "services/local.txt", "keep/other.txt"} {
+			require.NoError(t, os.MkdirAll(filepath.Join(dir, filepath.Dir(p)), 0755))
+			require.NoError(t, os.WriteFile(filepath.Join(dir, p), []byte("x"), 0644))
+		}
+		require.NoError(t, saveTemplatedRendered(dir, map[string][]string{
+			"services": {"api/README.md", "api/main.go"},
+			"keep":     {"other.txt"},
+		}))
+
+		delta := &upstreamDelta{RenderedRemovals: []string{"services"}}
+		require.NoError(t, applyUpstreamDelta(delta, dir, logutil.New()))
+
+		_, err = os.Stat(filepath.Join(dir, "services/api"))
+		assert.True(t, os.IsNotExist(err), "emptied rendered directory must be pruned")
+		assert.FileExists(t, filepath.Join(dir, "services/local.txt"), "files not rendered by gitspork must be left alone")
+		assert.FileExists(t, filepath.Join(dir, "keep/other.txt"))
+		rendered, err := loadTemplatedRendered(dir)
+		require.NoError(t, err)
+		assert.Equal(t, map[string][]string{"keep": {"other.txt"}}, rendered)
+	})
+
 	t.Run("rename target that is a broken symlink is respected and the move is skipped", func(t *testing.T) {
 		dir, err := os.MkdirTemp("", "gitspork-apply-test")
 		require.NoError(t, err)
@@ -32,7 +32,17 @@ func New() *Logger {
 	}
 }
 
-// Log writes an informational message to stdout.
+// NewStderr returns a Logger that writes informational messages to stderr
+// too, for commands whose stdout is machine-readable output.
+func NewStderr() *Logger {
+	return &Logger{
+		defaultLogger: log.New(os.Stderr, color.CyanString("INFO: "), 0),
+		errorLogger:   log.New(os.Stderr, color.RedString("ERROR: "), 0),
+	}
+}
+
+// Log writes an informational message to stdout, or stderr for a Logger from
+// NewStderr.
 func (l *Logger) Log(msg string, v ...any) {
 	l.defaultLogger.Printf(msg, v...)
 }
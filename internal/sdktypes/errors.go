@@ -1,6 +1,10 @@
 package sdktypes
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
 
 // ErrDriftDetected is returned by CheckDrift when drift is found in the downstream.
 var ErrDriftDetected = errors.New("drift detected")
@@ -14,3 +18,44 @@ var ErrGitBinaryMissing = errors.New("git binary not found on PATH")
 // detects that the upstream identifies the same repo as the downstream. SDK
 // consumers can check via errors.Is(err, gitspork.ErrSelfIntegration).
 var ErrSelfIntegration = errors.New("upstream and downstream identify the same repo")
+
+// ErrMissingInputs is matched (via errors.Is) by the *MissingInputsError
+// returned when a non-interactive integrate lacks values for templated inputs.
+var ErrMissingInputs = errors.New("missing templated input values")
+
+// MissingInput identifies a templated prompt input that had no value in a
+// non-interactive integrate.
+type MissingInput struct {
+	Template    string
+	Destination string
+	Name        string
+	Prompt      string
+	// EnvVar is the GITSPORK_INPUT_<NAME> variable that would supply the value.
+	EnvVar string
+}
+
+// MissingInputsError is returned by Integrate and IntegrateLocal in
+// non-interactive mode, and by the re-integrations of CheckDrift, Outdated and
+// Scan, which never prompt, listing every templated input that would otherwise
+// have been prompted for. Those re-integrations don't run exec either, so they
+// also list exec inputs with no recorded value.
+type MissingInputsError struct {
+	Inputs []MissingInput
+}
+
+func (e *MissingInputsError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%v in non-interactive mode:", ErrMissingInputs)
+	for _, in := range e.Inputs {
+		fmt.Fprintf(&b, "\n  - template %s, input %s (%s)", in.Template, in.Name, in.EnvVar)
+		if in.Prompt != "" {
+			fmt.Fprintf(&b, ": %q", in.Prompt)
+		}
+	}
+	return b.String()
+}
+
+// Is reports ErrMissingInputs as matching so callers can use errors.Is.
+func (e *MissingInputsError) Is(target error) bool {
+	return target == ErrMissingInputs
+}
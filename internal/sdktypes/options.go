@@ -1,6 +1,7 @@
 package sdktypes
 
 import (
+	"context"
 	"io"
 	"time"
 )
@@ -13,6 +14,49 @@ type IntegrateOptions struct {
 	ForceRePrompt      bool
 	Logger             Logger
 
+	// Inputs supplies values for templated prompt inputs up front, keyed by
+	// input name, so they are never asked for interactively. A supplied value
+	// applies to every templated instruction declaring an input of that name
+	// and takes precedence over GITSPORK_INPUT_<NAME> env vars and the
+	// downstream's cached answers. Values may be strings (parsed per the
+	// input's type) or already typed (bool, int, []string).
+	Inputs map[string]any
+
+	// NonInteractive, when true, never prompts: if any templated prompt input
+	// has no supplied, env or valid cached value, integration fails with a
+	// *MissingInputsError listing every such input instead of waiting on stdin.
+	NonInteractive bool
+
+	// Prompter collects answers for templated prompt inputs that have no
+	// supplied, env or cached value. Nil means TerminalPrompter (stdin).
+	// Supply your own to gather answers through another UI; it is ignored
+	// when NonInteractive is set.
+	Prompter Prompter
+
+	// MigrationsDryRun, when true, logs each step of every pending migration
+	// (what it would move, delete, edit or run) without running it, and
+	// returns without integrating, applying upstream renames/removals, or
+	// recording anything. Post-integrate steps are described against the
+	// downstream as it is before integrating.
+	MigrationsDryRun bool
+
+	// AllowMigrations, when true, trusts the upstreams to run migration
+	// commands (exec, or exec and shell steps) and templated input commands
+	// without asking, for this integration only: nothing is recorded, and a
+	// decision already recorded stays as it is. Without it, an upstream that
+	// the downstream's .gitspork/trust.yml and recorded decisions don't
+	// trust is confirmed through the Prompter, or fails the integration when
+	// NonInteractive is set.
+	AllowMigrations bool
+
+	// NoMigrations, when true, runs no migrations at all; they stay pending
+	// for a later integrate. It can't be combined with AllowMigrations.
+	NoMigrations bool
+
+	// Context, if non-nil, cancels a running migration: its commands are
+	// killed along with every process they started, and integration fails.
+	Context context.Context
+
 	// CacheTTL controls the machine-scoped upstream mirror cache freshness
 	// threshold. A cache entry younger than CacheTTL is used as-is; older
 	// triggers a `git fetch` refresh. Zero-value means "use GITSPORK_CACHE_TTL
@@ -42,6 +86,49 @@ type IntegrateLocalOptions struct {
 	ForceRePrompt  bool
 	Logger         Logger
 
+	// Inputs supplies values for templated prompt inputs up front, keyed by
+	// input name, so they are never asked for interactively. A supplied value
+	// applies to every templated instruction declaring an input of that name
+	// and takes precedence over GITSPORK_INPUT_<NAME> env vars and the
+	// downstream's cached answers. Values may be strings (parsed per the
+	// input's type) or already typed (bool, int, []string).
+	Inputs map[string]any
+
+	// NonInteractive, when true, never prompts: if any templated prompt input
+	// has no supplied, env or valid cached value, integration fails with a
+	// *MissingInputsError listing every such input instead of waiting on stdin.
+	NonInteractive bool
+
+	// Prompter collects answers for templated prompt inputs that have no
+	// supplied, env or cached value. Nil means TerminalPrompter (stdin).
+	// Supply your own to gather answers through another UI; it is ignored
+	// when NonInteractive is set.
+	Prompter Prompter
+
+	// MigrationsDryRun, when true, logs each step of every pending migration
+	// (what it would move, delete, edit or run) without running it, and
+	// returns without integrating, applying upstream renames/removals, or
+	// recording anything. Post-integrate steps are described against the
+	// downstream as it is before integrating.
+	MigrationsDryRun bool
+
+	// AllowMigrations, when true, trusts the upstreams to run migration
+	// commands (exec, or exec and shell steps) and templated input commands
+	// without asking, for this integration only: nothing is recorded, and a
+	// decision already recorded stays as it is. Without it, an upstream that
+	// the downstream's .gitspork/trust.yml and recorded decisions don't
+	// trust is confirmed through the Prompter, or fails the integration when
+	// NonInteractive is set.
+	AllowMigrations bool
+
+	// NoMigrations, when true, runs no migrations at all; they stay pending
+	// for a later integrate. It can't be combined with AllowMigrations.
+	NoMigrations bool
+
+	// Context, if non-nil, cancels a running migration: its commands are
+	// killed along with every process they started, and integration fails.
+	Context context.Context
+
 	// CacheTTL controls the machine-scoped upstream mirror cache freshness
 	// threshold. A cache entry younger than CacheTTL is used as-is; older
 	// triggers a `git fetch` refresh. Zero-value means "use GITSPORK_CACHE_TTL
@@ -56,6 +143,18 @@ type IntegrateLocalOptions struct {
 	NoCache bool
 }
 
+// Values of CheckDriftOptions.Migrations.
+const (
+	// DriftMigrationsSkip runs none of the pending migrations.
+	DriftMigrationsSkip = "skip"
+	// DriftMigrationsSimulate applies the declarative steps of pending
+	// migrations and only logs the commands they would run.
+	DriftMigrationsSimulate = "simulate"
+	// DriftMigrationsSandbox runs pending migrations, commands included, with
+	// a restricted environment confined to the scratch clone.
+	DriftMigrationsSandbox = "sandbox"
+)
+
 // CheckDriftOptions configures a call to CheckDrift. Leave Upstreams empty
 // to use the recorded state; supply entries to override with different
 // URLs/tokens for the same recorded commit hashes.
@@ -64,6 +163,13 @@ type CheckDriftOptions struct {
 	DownstreamRepoPath string
 	Logger             Logger
 
+	// Inputs supplies templated input values to the re-integration, as on
+	// IntegrateOptions. The re-integration never prompts: a secret input, or
+	// a prompt input with no cached value or default, that isn't supplied
+	// here, by GITSPORK_INPUT_<NAME> or by its secret_env fails the check with
+	// a *MissingInputsError.
+	Inputs map[string]any
+
 	// CacheTTL controls the machine-scoped upstream mirror cache freshness
 	// threshold. A cache entry younger than CacheTTL is used as-is; older
 	// triggers a `git fetch` refresh. Zero-value means "use GITSPORK_CACHE_TTL
@@ -83,6 +189,78 @@ type CheckDriftOptions struct {
 	// terminal-style progress; leave nil to suppress. Ignored when NoCache is
 	// true (direct clone path).
 	Progress io.Writer
+
+	// Migrations decides what the re-integration does with migrations the
+	// downstream state doesn't record as complete: DriftMigrationsSkip (the
+	// default when empty), DriftMigrationsSimulate or DriftMigrationsSandbox.
+	// Files those migrations change are marked with DriftedFile.Migration.
+	Migrations string
+
+	// Context, if non-nil, cancels a migration the re-integration runs, as on
+	// IntegrateOptions.
+	Context context.Context
+
+	// WorkingTree, when true, checks the downstream's working tree as it is on
+	// disk, including staged, unstaged and untracked-but-not-ignored changes,
+	// instead of refusing to run unless the working tree is clean. Drift is
+	// then relative to the working tree rather than HEAD, and
+	// DriftReport.WorkingTree is set.
+	WorkingTree bool
+
+	// Fix, when true, applies the corrective patch for the drifted files to
+	// the downstream working tree, restoring them to what the recorded
+	// upstream commits produce, and leaves the changes uncommitted. The
+	// recorded commits are reused, so no upstream is bumped. Ignored drift is
+	// left alone. The report still describes the drift found before the fix.
+	Fix bool
+
+	// PatchOut, if non-nil, receives the corrective patch for the drifted
+	// files: a binary-safe patch that `git apply` applies at the downstream
+	// root. Nothing is written when there's no drift.
+	PatchOut io.Writer
+
+	// FixPaths limits Fix and PatchOut to the drifted files matching any of
+	// these patterns (https://github.com/gobwas/glob), relative to the
+	// downstream root. Empty means every drifted file.
+	FixPaths []string
+}
+
+// MigrationStatusOptions configures a call to MigrationStatus. Leave
+// Upstreams empty to use the upstreams recorded in the downstream state, at
+// their recorded versions.
+type MigrationStatusOptions struct {
+	Upstreams          []UpstreamSpec
+	DownstreamRepoPath string
+	Logger             Logger
+
+	// CacheTTL, NoCache and Progress behave as on IntegrateOptions.
+	CacheTTL time.Duration
+	NoCache  bool
+	Progress io.Writer
+}
+
+// OutdatedOptions configures a call to Outdated. Leave Upstreams empty to
+// check every upstream recorded in the downstream state; each one given
+// overrides the URL, token and, when set, version of the state entry it
+// matches by normalized URL and subpath.
+type OutdatedOptions struct {
+	Upstreams          []UpstreamSpec
+	DownstreamRepoPath string
+	Logger             Logger
+
+	// Files, when true, also re-integrates each outdated upstream at its
+	// latest commit, against an isolated copy of the downstream, to list the
+	// managed files updating would change. Migrations don't run.
+	Files bool
+
+	// Inputs behaves as on CheckDriftOptions, for the re-integrations Files
+	// runs. An input the latest commit adds needs a value or default here.
+	Inputs map[string]any
+
+	// CacheTTL, NoCache and Progress behave as on IntegrateOptions.
+	CacheTTL time.Duration
+	NoCache  bool
+	Progress io.Writer
 }
 
 // UpstreamSpec identifies a single upstream to integrate from.
@@ -105,3 +283,37 @@ type UpstreamSpec struct {
 	Subpath string
 	Token   string
 }
+
+// ScanOptions configures a call to Scan, which checks drift in each of many
+// downstream checkouts, as CheckDrift does with its recorded upstreams.
+type ScanOptions struct {
+	// DownstreamRepoPaths are downstream checkout paths, or path patterns, as
+	// in filepath.Match, like /srv/checkouts/*, matching several.
+	DownstreamRepoPaths []string
+
+	// Manifest, if set, is the path to a YAML scan manifest listing more
+	// downstream paths or patterns, relative to the manifest, under
+	// `downstreams`.
+	Manifest string
+
+	// Logger receives each downstream's outcome as its check finishes, from
+	// concurrent goroutines, so it must be safe for concurrent use.
+	Logger Logger
+
+	// Concurrency bounds how many downstreams are checked at once. Zero means
+	// runtime.NumCPU().
+	Concurrency int
+
+	// CacheTTL, NoCache and Progress behave as on CheckDriftOptions. Every
+	// check shares the upstream mirror cache, so an upstream common to many
+	// downstreams is fetched at most once per scan.
+	CacheTTL time.Duration
+	NoCache  bool
+	Progress io.Writer
+
+	// Migrations, Inputs and Context behave as on CheckDriftOptions, for
+	// every check.
+	Migrations string
+	Inputs     map[string]any
+	Context    context.Context
+}
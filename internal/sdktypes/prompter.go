@@ -0,0 +1,51 @@
+package sdktypes
+
+// PromptRequest describes a single templated input value gitspork needs from
+// the user. Type is one of the templated input types ("string", "bool",
+// "int", "select", "multi-select"); Options is set for select/multi-select.
+//
+// Migration is set instead of Template and Destination when the request
+// confirms running an untrusted upstream's migration commands: it is the
+// migration ID, Help lists the commands and the scripts they run, and the
+// answer is a string, one of Options ("yes", "always", "no" or "never").
+type PromptRequest struct {
+	Template    string
+	Destination string
+	Migration   string
+	Name        string
+	Prompt      string
+	Help        string
+	Type        string
+	Options     []string
+	// Default is the value applied when the answer is empty; comma-separated
+	// for multi-select. Prompters may pre-fill it but need not apply it.
+	Default string
+	// Attempt is 1 on the first request for an input and increments each time
+	// a previous answer was rejected; ValidationError then carries the reason.
+	Attempt         int
+	ValidationError string
+	// Secret asks for the answer to be entered without echoing it; gitspork
+	// never caches it.
+	Secret bool
+}
+
+// Prompter collects templated input values on behalf of Integrate and
+// IntegrateLocal. The answer may be a string, parsed according to the
+// input's type (yes/no for bool, comma-separated for multi-select), or an
+// already typed value (bool, int, []string). Answers are validated by
+// gitspork; an invalid answer leads to another Prompt call for the same
+// input with Attempt incremented. Returning an error aborts the integration.
+//
+// A Prompter is called sequentially within one integration, but distinct
+// concurrent integrations each call their own Prompter.
+type Prompter interface {
+	Prompt(req PromptRequest) (any, error)
+}
+
+// PromptFunc adapts an ordinary function to the Prompter interface.
+type PromptFunc func(req PromptRequest) (any, error)
+
+// Prompt calls f(req).
+func (f PromptFunc) Prompt(req PromptRequest) (any, error) {
+	return f(req)
+}
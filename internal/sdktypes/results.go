@@ -30,17 +30,173 @@ type IntegratedUpstream struct {
 // DriftedFile records the last-writing upstream — matching the last-writer-wins
 // semantics of multi-upstream integrate.
 //
+// Drifted files matched by an unexpired rule of the downstream's
+// .gitspork/drift-ignore.yml are listed in Ignored instead of Files, and
+// don't count toward HasDrift.
+//
+// Fixed lists the drifted files CheckDriftOptions.Fix restored. They still
+// count toward HasDrift, which describes the downstream as it was checked:
+// its HEAD or, when WorkingTree is true, its working tree on disk.
+//
 // The returned *DriftReport is always non-nil — callers do not need to
 // nil-check before inspecting HasDrift or Files.
 type DriftReport struct {
-	HasDrift bool
-	Files    []DriftedFile
+	HasDrift    bool                 `json:"has_drift"`
+	WorkingTree bool                 `json:"working_tree,omitempty"` // true when the working tree on disk was checked, per CheckDriftOptions.WorkingTree, rather than HEAD
+	Files       []DriftedFile        `json:"files"`
+	Ignored     []IgnoredDriftedFile `json:"ignored,omitempty"`
+	Fixed       []string             `json:"fixed,omitempty"` // paths of the drifted files CheckDriftOptions.Fix restored in the downstream working tree
 }
 
+// Values of DriftedFile.Change, describing the downstream file relative to
+// what the re-integration produces.
+const (
+	// DriftChangeModified is a file whose content differs.
+	DriftChangeModified = "modified"
+	// DriftChangeDeleted is a file the re-integration produces that the
+	// downstream doesn't have.
+	DriftChangeDeleted = "deleted"
+	// DriftChangeAdded is a file the downstream has that the re-integration
+	// doesn't produce.
+	DriftChangeAdded = "added"
+	// DriftChangeMode is a file whose mode differs, such as a lost executable
+	// bit or a symlink replaced by a regular file, whether or not its content
+	// does too.
+	DriftChangeMode = "mode"
+)
+
+// Values of DriftedFile.Ownership, named after the .gitspork.yml lists that
+// manage a file.
+const (
+	OwnershipUpstreamOwned              = "upstream_owned"
+	OwnershipDownstreamOwned            = "downstream_owned"
+	OwnershipMerged                     = "shared_ownership.merged"
+	OwnershipStructuredPreferUpstream   = "shared_ownership.structured.prefer_upstream"
+	OwnershipStructuredPreferDownstream = "shared_ownership.structured.prefer_downstream"
+	OwnershipTemplated                  = "templated"
+)
+
 // DriftedFile is a single entry in a DriftReport.
 type DriftedFile struct {
-	Path          string
-	AttributedURL string // upstream URL responsible for this file; empty means unattributed
-	Diff          string // unified-diff text for this file; a `Binary files ... differ` marker line when the file is binary
-	ColorizedDiff string // same content as Diff with ANSI color codes applied by line prefix (headers bold, hunks cyan, additions green, removals red); always populated regardless of the process's TTY state so SDK consumers can render into any sink
+	Path          string `json:"path"`
+	AttributedURL string `json:"attributed_url,omitempty"` // upstream URL responsible for this file; empty means unattributed
+	Change        string `json:"change"`                   // one of the DriftChange* values
+	Ownership     string `json:"ownership,omitempty"`      // one of the Ownership* values, the .gitspork.yml list managing this file; empty when no entry does
+	ConfigEntry   string `json:"config_entry,omitempty"`   // the entry within Ownership that manages this file: its pattern, a {from, to} rename, or a templated {template, destination}
+	Diff          string `json:"diff"`                     // unified-diff text for this file; a `Binary files ... differ` marker line when the file is binary
+	ColorizedDiff string `json:"-"`                        // same content as Diff with ANSI color codes applied by line prefix (headers bold, hunks cyan, additions green, removals red); always populated regardless of the process's TTY state so SDK consumers can render into any sink
+	Migration     string `json:"migration,omitempty"`      // ID of the migration that last changed this file during the re-integration (simulate or sandbox migrations); empty when no migration did
+	ExpiredIgnore string `json:"expired_ignore,omitempty"` // expiry date of a drift ignore rule that matched this file but has expired; empty when none did
+}
+
+// IgnoredDriftedFile is a drifted file accepted by a rule of the downstream's
+// drift ignore file.
+type IgnoredDriftedFile struct {
+	DriftedFile
+	Pattern       string `json:"pattern"`                 // the rule's path pattern that matched
+	Justification string `json:"justification,omitempty"` // the rule's justification, if any
+	Expires       string `json:"expires,omitempty"`       // the YYYY-MM-DD date the rule applies through; empty means it never expires
+}
+
+// Values of OutdatedUpstream.Track, saying how Outdated finds an upstream's
+// latest version from the version requested at integration.
+const (
+	// OutdatedTrackBranch follows the latest commit on the requested branch,
+	// or on the default branch when no version was requested.
+	OutdatedTrackBranch = "branch"
+	// OutdatedTrackTag follows the newest semver tag, for a requested semver
+	// tag. Pre-release tags only count when the requested tag is one.
+	OutdatedTrackTag = "tag"
+	// OutdatedTrackPinned is a requested commit hash or non-semver tag, which
+	// never moves, so the upstream is never outdated.
+	OutdatedTrackPinned = "pinned"
+)
+
+// OutdatedReport is the structural return value of Outdated. HasOutdated is
+// true when any upstream is behind its latest version.
+//
+// The returned *OutdatedReport is always non-nil.
+type OutdatedReport struct {
+	HasOutdated bool
+	Upstreams   []OutdatedUpstream
+}
+
+// OutdatedUpstream compares a single upstream recorded in the downstream state
+// with its latest version.
+type OutdatedUpstream struct {
+	URL              string
+	Subpath          string
+	Version          string // version requested at integration; empty means the default branch
+	CommitHash       string // commit recorded in the downstream state
+	Track            string // one of the OutdatedTrack* values
+	LatestVersion    string // branch followed, or newest semver tag; empty when pinned
+	LatestCommitHash string // commit LatestVersion resolves to; empty when pinned
+	Outdated         bool   // true when LatestCommitHash differs from CommitHash
+	// CommitsBehind counts the commits reachable from LatestCommitHash but not
+	// from CommitHash. It's zero when CommitHash is no longer in the upstream's
+	// history, e.g. after a force-push.
+	CommitsBehind int
+	// TagsBehind are the tags on those commits, oldest version first.
+	TagsBehind []string
+	// ChangedFiles are the downstream files, relative to its root with forward
+	// slashes, that updating to LatestCommitHash would change. Only set when
+	// OutdatedOptions.Files is.
+	ChangedFiles []string
+}
+
+// Values of ScanRepoResult.Status.
+const (
+	// ScanStatusClean is a downstream without drift.
+	ScanStatusClean = "clean"
+	// ScanStatusDrift is a downstream with drift.
+	ScanStatusDrift = "drift"
+	// ScanStatusError is a downstream whose drift check failed.
+	ScanStatusError = "error"
+)
+
+// ScanReport is the structural return value of Scan. HasDrift is true when
+// any downstream has drifted, and HasErrors when any couldn't be checked.
+//
+// The returned *ScanReport is always non-nil.
+type ScanReport struct {
+	HasDrift  bool             `json:"has_drift"`
+	HasErrors bool             `json:"has_errors"`
+	Repos     []ScanRepoResult `json:"repos"`
+}
+
+// ScanRepoResult is the drift check of a single downstream in a ScanReport.
+type ScanRepoResult struct {
+	Path         string          `json:"path"`
+	Status       string          `json:"status"`              // one of the ScanStatus* values
+	DriftedFiles int             `json:"drifted_files"`       // count of drifted files, as in DriftReport.Files
+	IgnoredFiles int             `json:"ignored_files"`       // count of drifted files the downstream's drift ignore file accepts, as in DriftReport.Ignored
+	Files        []string        `json:"files,omitempty"`     // paths of the drifted files
+	Error        string          `json:"error,omitempty"`     // why the drift check failed, when Status is ScanStatusError
+	Upstreams    []UpstreamState `json:"upstreams,omitempty"` // upstreams recorded in the downstream state, with their versions and commits
+}
+
+// MigrationStatusPending is the Status of a migration the next integrate will
+// run. Any other Status is the Outcome of the migration's MigrationRecord, or
+// MigrationOutcomeNotApplicable for one the next integrate will skip.
+const MigrationStatusPending = "pending"
+
+// MigrationStatusReport is the structural return value of MigrationStatus:
+// every migration instruction the upstreams define, in the order integrate
+// considers them.
+//
+// The returned *MigrationStatusReport is always non-nil.
+type MigrationStatusReport struct {
+	Migrations []MigrationStatusEntry
+}
+
+// MigrationStatusEntry is a single migration instruction in a MigrationStatusReport.
+type MigrationStatusEntry struct {
+	UpstreamURL string
+	ID          string // e.g. "0001-split-services:pre_integrate"
+	Path        string // migration file in the upstream
+	Status      string // MigrationStatusPending or a MigrationOutcome* value
+	Reason      string // why a not-yet-recorded migration doesn't apply
+	// Record is the downstream's record of the migration, under its ID or one
+	// of its aliases, if any. A failed record leaves the migration pending.
+	Record *MigrationRecord
 }
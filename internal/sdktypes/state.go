@@ -1,12 +1,20 @@
 package sdktypes
 
+import (
+	"encoding/json"
+	"time"
+)
+
 // DownstreamState is the on-disk state stored at
 // .gitspork/downstream-state.json in the downstream repo. It records each
 // integrated upstream so subsequent runs (integrate, check-drift) can locate
 // the previous commit hash and detect drift.
 type DownstreamState struct {
-	MigrationsComplete []string        `json:"migrations_complete"`
-	Upstreams          []UpstreamState `json:"upstreams,omitempty"`
+	MigrationsComplete []MigrationRecord `json:"migrations_complete"`
+	Upstreams          []UpstreamState   `json:"upstreams,omitempty"`
+	// MigrationTrust records the decisions made about which upstreams may run
+	// migration commands in the downstream.
+	MigrationTrust []MigrationTrustDecision `json:"migration_trust,omitempty"`
 	// Deprecated: migrated to Upstreams on first load.
 	LastUpstreamRepoURL string `json:"last_upstream_repo_url,omitempty"`
 	// Deprecated: migrated to Upstreams on first load.
@@ -20,4 +28,80 @@ type UpstreamState struct {
 	URL        string `json:"url"`
 	Subpath    string `json:"subpath,omitempty"`
 	CommitHash string `json:"commit_hash"`
+	// Version is the upstream version requested at integration, if any.
+	Version string `json:"version,omitempty"`
+	// IntegratedAt is when CommitHash was first integrated; it is left
+	// unchanged by re-integrating the same commit.
+	IntegratedAt time.Time `json:"integrated_at,omitzero"`
+}
+
+// Outcomes of a MigrationRecord.
+const (
+	// MigrationOutcomeRan is a migration that ran to completion.
+	MigrationOutcomeRan = "ran"
+	// MigrationOutcomeNotApplicable is a migration whose applies_if didn't
+	// hold, so it was recorded without running.
+	MigrationOutcomeNotApplicable = "not_applicable"
+	// MigrationOutcomeSkipped is a migration skipped with `gitspork migrations skip`.
+	MigrationOutcomeSkipped = "skipped"
+	// MigrationOutcomeFailed is a migration whose last run failed. Unlike the
+	// other outcomes it doesn't mark the migration complete, so it runs again
+	// on the next integrate.
+	MigrationOutcomeFailed = "failed"
+)
+
+// MigrationRecord records a migration's last run in the downstream, keyed by
+// the migration instruction ID, e.g. "0001-split-services:pre_integrate".
+type MigrationRecord struct {
+	ID      string `json:"id"`
+	Outcome string `json:"outcome,omitempty"`
+	// At is when the migration finished, or was recorded without running.
+	At time.Time `json:"at,omitzero"`
+	// UpstreamCommit is the upstream commit being integrated at the time.
+	UpstreamCommit string `json:"upstream_commit,omitempty"`
+	// ExitStatus is the exit code of the migration's failing command, -1 if
+	// it failed some other way, and 0 otherwise.
+	ExitStatus int `json:"exit_status"`
+	// DurationMillis is how long the migration ran.
+	DurationMillis int64 `json:"duration_ms,omitempty"`
+	// Error is why a failed migration failed.
+	Error string `json:"error,omitempty"`
+}
+
+// Complete reports whether the record keeps the migration from running again.
+func (r MigrationRecord) Complete() bool {
+	return r.Outcome != MigrationOutcomeFailed
+}
+
+// UnmarshalJSON also accepts the bare migration ID strings that state files
+// recorded before migration records carried any detail.
+func (r *MigrationRecord) UnmarshalJSON(b []byte) error {
+	var id string
+	if err := json.Unmarshal(b, &id); err == nil {
+		*r = MigrationRecord{ID: id, Outcome: MigrationOutcomeRan}
+		return nil
+	}
+	type plain MigrationRecord
+	return json.Unmarshal(b, (*plain)(r))
+}
+
+// Decisions of a MigrationTrustDecision.
+const (
+	// MigrationTrustAllow lets the upstream's migrations run commands.
+	MigrationTrustAllow = "allow"
+	// MigrationTrustDeny refuses to run the upstream's migration commands
+	// without asking again.
+	MigrationTrustDeny = "deny"
+)
+
+// MigrationTrustDecision records whether an upstream may run migration
+// commands in the downstream, keyed by the upstream's normalized URL.
+type MigrationTrustDecision struct {
+	URL      string `json:"url"`
+	Decision string `json:"decision"`
+	// At is when the decision was made.
+	At time.Time `json:"at,omitzero"`
+	// Source is how the decision was made: "prompt". Releases that recorded
+	// --allow-migrations wrote "flag".
+	Source string `json:"source,omitempty"`
 }
@@ -4,6 +4,7 @@ package functional
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
 
 	gogit "github.com/go-git/go-git/v6"
@@ -117,6 +118,130 @@ func TestCheckDrift_drift_detected(t *testing.T) {
 		"expected verbose output to name the drifted file:\n%s", out)
 }
 
+func TestCheckDrift_formats(t *testing.T) {
+	upstreamDir := buildSimpleUpstream(t)
+	downstreamDir := NewDownstreamRepo(t)
+	prepDownstreamWithInputData(t, downstreamDir)
+	runner := resolveRunner(t, upstreamDir, downstreamDir)
+
+	integrateForDrift(t, runner, upstreamDir, downstreamDir)
+	WriteFiles(t, downstreamDir, map[string]string{
+		"upstream-owned/file.txt": "drifted content\n",
+	})
+	CommitAll(t, OpenRepo(t, downstreamDir), downstreamDir, "introduce drift")
+	prepDownstreamWithInputData(t, downstreamDir)
+
+	for format, want := range map[string][]string{
+		"json":     {`"has_drift": true`, `"path": "upstream-owned/file.txt"`, `"change": "modified"`, `+drifted content`},
+		"sarif":    {`"version": "2.1.0"`, `"ruleId": "drift/modified"`, `"uri": "upstream-owned/file.txt"`},
+		"junit":    {`<testsuites name="gitspork check-drift" tests="1" failures="1">`, `name="upstream-owned/file.txt"`, `type="modified"`},
+		"markdown": {"## gitspork drift", "| `upstream-owned/file.txt` | modified |", "```diff"},
+	} {
+		t.Run(format, func(t *testing.T) {
+			out, code := runner.Run(t, []string{
+				"check-drift",
+				"--downstream-repo-path", downstreamDir,
+				"--format", format,
+			}, downstreamDir)
+			require.Equal(t, 2, code, "expected drift detected (exit 2):\n%s", out)
+			for _, w := range want {
+				assert.Contains(t, out, w)
+			}
+		})
+	}
+
+	out, code := runner.Run(t, []string{"check-drift", "--downstream-repo-path", downstreamDir, "--format", "yaml"}, downstreamDir)
+	require.Equal(t, 1, code, "expected an unknown format to fail:\n%s", out)
+	assert.Contains(t, out, `invalid --format "yaml"`)
+}
+
+func TestCheckDrift_drift_ignore(t *testing.T) {
+	upstreamDir := buildSimpleUpstream(t)
+	downstreamDir := NewDownstreamRepo(t)
+	prepDownstreamWithInputData(t, downstreamDir)
+	runner := resolveRunner(t, upstreamDir, downstreamDir)
+
+	integrateForDrift(t, runner, upstreamDir, downstreamDir)
+	WriteFiles(t, downstreamDir, map[string]string{
+		"upstream-owned/file.txt": "drifted content\n",
+		".gitspork/drift-ignore.yml": `ignore:
+- paths: [upstream-owned/*.txt]
+  justification: pinned until the upstream fix lands
+  expires: 2999-12-31
+`,
+	})
+	CommitAll(t, OpenRepo(t, downstreamDir), downstreamDir, "accept drift")
+	prepDownstreamWithInputData(t, downstreamDir)
+
+	out, code := runner.Run(t, []string{"check-drift", "--downstream-repo-path", downstreamDir}, downstreamDir)
+	require.Equal(t, 0, code, "expected ignored drift to pass (exit 0):\n%s", out)
+	assert.Contains(t, out, "ignored drift: 1 file(s) accepted by .gitspork/drift-ignore.yml")
+	assert.Contains(t, out, "upstream-owned/file.txt (pinned until the upstream fix lands, until 2999-12-31)")
+	assert.Contains(t, out, "no drift detected")
+
+	out, code = runner.Run(t, []string{"check-drift", "--downstream-repo-path", downstreamDir, "--format", "junit"}, downstreamDir)
+	require.Equal(t, 0, code, "expected ignored drift to pass (exit 0):\n%s", out)
+	assert.Contains(t, out, `<testsuites name="gitspork check-drift" tests="1" failures="0" skipped="1">`)
+
+	WriteFiles(t, downstreamDir, map[string]string{
+		".gitspork/drift-ignore.yml": "ignore:\n- paths: [upstream-owned/*.txt]\n  expires: 2000-01-31\n",
+	})
+	CommitAll(t, OpenRepo(t, downstreamDir), downstreamDir, "expire accepted drift")
+	prepDownstreamWithInputData(t, downstreamDir)
+
+	out, code = runner.Run(t, []string{"check-drift", "--downstream-repo-path", downstreamDir}, downstreamDir)
+	require.Equal(t, 2, code, "expected expired ignore to report drift (exit 2):\n%s", out)
+	assert.Contains(t, out, "ignore expired on 2000-01-31")
+}
+
+func TestCheckDrift_fix_and_patch_out(t *testing.T) {
+	upstreamDir := buildSimpleUpstream(t)
+	downstreamDir := NewDownstreamRepo(t)
+	prepDownstreamWithInputData(t, downstreamDir)
+	runner := resolveRunner(t, upstreamDir, downstreamDir)
+
+	integrateForDrift(t, runner, upstreamDir, downstreamDir)
+	want := ReadFile(t, downstreamDir, "upstream-owned/file.txt")
+	WriteFiles(t, downstreamDir, map[string]string{"upstream-owned/file.txt": "drifted content\n"})
+	CommitAll(t, OpenRepo(t, downstreamDir), downstreamDir, "drift")
+	prepDownstreamWithInputData(t, downstreamDir)
+
+	// Inside .git, the patch doesn't dirty the working tree for the next run.
+	patchPath := filepath.Join(downstreamDir, ".git", "drift.patch")
+	out, code := runner.Run(t, []string{"check-drift", "--downstream-repo-path", downstreamDir,
+		"--patch-out", patchPath, "--path", "upstream-owned/*"}, downstreamDir)
+	require.Equal(t, 2, code, "expected drift (exit 2):\n%s", out)
+	patch := ReadFile(t, downstreamDir, ".git/drift.patch")
+	assert.Contains(t, patch, "+++ b/upstream-owned/file.txt")
+	assert.Equal(t, "drifted content\n", ReadFile(t, downstreamDir, "upstream-owned/file.txt"))
+
+	out, code = runner.Run(t, []string{"check-drift", "--downstream-repo-path", downstreamDir, "--fix"}, downstreamDir)
+	require.Equal(t, 0, code, "expected fixed drift to pass (exit 0):\n%s", out)
+	assert.Contains(t, out, "fixed drift: 1 file(s) restored")
+	assert.Equal(t, want, ReadFile(t, downstreamDir, "upstream-owned/file.txt"))
+}
+
+func TestCheckDrift_working_tree(t *testing.T) {
+	upstreamDir := buildSimpleUpstream(t)
+	downstreamDir := NewDownstreamRepo(t)
+	prepDownstreamWithInputData(t, downstreamDir)
+	runner := resolveRunner(t, upstreamDir, downstreamDir)
+
+	integrateForDrift(t, runner, upstreamDir, downstreamDir)
+	prepDownstreamWithInputData(t, downstreamDir)
+	WriteFiles(t, downstreamDir, map[string]string{"upstream-owned/file.txt": "uncommitted content\n"})
+
+	out, code := runner.Run(t, []string{"check-drift", "--downstream-repo-path", downstreamDir}, downstreamDir)
+	require.Equal(t, 1, code, "expected a dirty working tree to fail (exit 1):\n%s", out)
+	assert.Contains(t, out, "--working-tree")
+
+	out, code = runner.Run(t, []string{"check-drift", "--downstream-repo-path", downstreamDir, "--working-tree", "--format", "json"}, downstreamDir)
+	require.Equal(t, 2, code, "expected uncommitted drift (exit 2):\n%s", out)
+	assert.Contains(t, out, `"working_tree": true`)
+	assert.Contains(t, out, `+uncommitted content`)
+	assert.Equal(t, "uncommitted content\n", ReadFile(t, downstreamDir, "upstream-owned/file.txt"))
+}
+
 func TestCheckDrift_multi_upstream_no_drift(t *testing.T) {
 	if isDockerBuild {
 		t.Skip("multi-upstream path rewriting not supported in DockerRunner")
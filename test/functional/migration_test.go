@@ -65,7 +65,7 @@ func TestIntegrate_migration_hooks_run_end_to_end(t *testing.T) {
 
 	downstreamDir := NewDownstreamRepo(t)
 	runner := resolveRunner(t, upstreamDir, downstreamDir)
-	args := integrateArgs(upstreamDir, downstreamDir)
+	args := append(integrateArgs(upstreamDir, downstreamDir), "--allow-migrations")
 
 	// First integrate: hooks should run.
 	out, code := runner.Run(t, args, downstreamDir)
@@ -99,3 +99,209 @@ func TestIntegrate_migration_hooks_run_end_to_end(t *testing.T) {
 	assert.Equal(t, 1, strings.Count(logAfterSecond, "post-ran"),
 		"post_integrate must run exactly once across two integrates")
 }
+
+// TestIntegrate_migration_steps_dry_run_then_run verifies declarative steps:
+// --migrations-dry-run narrates them without touching the downstream or state,
+// and a real integrate then applies them once.
+func TestIntegrate_migration_steps_dry_run_then_run(t *testing.T) {
+	upstreamDir := NewUpstreamRepo(t, map[string]string{
+		"upstream-owned/file.txt": "upstream content\n",
+		".gitspork/migrations/0001/migration.yml": `pre_integrate:
+  steps:
+  - move: {from: old-name.txt, to: new-name.txt}
+  - ensure_line: {file: .gitignore, line: /dist}
+`,
+	}, migrationGitsporkYML)
+	downstreamDir := NewDownstreamRepo(t)
+	require.NoError(t, os.WriteFile(filepath.Join(downstreamDir, "old-name.txt"), []byte("kept\n"), 0644))
+	CommitAll(t, OpenRepo(t, downstreamDir), downstreamDir, "downstream file to migrate")
+	runner := resolveRunner(t, upstreamDir, downstreamDir)
+	args := integrateArgs(upstreamDir, downstreamDir)
+
+	out, code := runner.Run(t, append(args, "--migrations-dry-run"), downstreamDir)
+	require.Equal(t, 0, code, "dry run exited non-zero:\n%s", out)
+	assert.Contains(t, out, "(dry run) step 1/2: move old-name.txt to new-name.txt")
+	assert.Contains(t, out, "(dry run) step 2/2: ensure line \"/dist\" in .gitignore")
+	assert.FileExists(t, filepath.Join(downstreamDir, "old-name.txt"))
+	assert.NoFileExists(t, filepath.Join(downstreamDir, "upstream-owned/file.txt"), "a dry run integrates nothing")
+	assert.NoFileExists(t, filepath.Join(downstreamDir, ".gitspork/downstream-state.json"))
+
+	out, code = runner.Run(t, args, downstreamDir)
+	require.Equal(t, 0, code, "integrate exited non-zero:\n%s", out)
+	assert.Equal(t, "kept\n", ReadFile(t, downstreamDir, "new-name.txt"))
+	assert.NoFileExists(t, filepath.Join(downstreamDir, "old-name.txt"))
+	assert.Contains(t, ReadFile(t, downstreamDir, ".gitignore"), "/dist\n")
+	assert.Contains(t, ReadFile(t, downstreamDir, ".gitspork/downstream-state.json"), "0001/migration.yml:pre_integrate")
+}
+
+func TestIntegrate_migration_applies_if_skips_on_first_integrate(t *testing.T) {
+	upstreamDir := NewUpstreamRepo(t, map[string]string{
+		"upstream-owned/file.txt": "upstream content\n",
+		".gitspork/migrations/0001/migration.yml": `applies_if:
+  integration: upgrade
+pre_integrate:
+  steps:
+  - ensure_line: {file: .gitignore, line: /dist}
+`,
+	}, migrationGitsporkYML)
+	downstreamDir := NewDownstreamRepo(t)
+	runner := resolveRunner(t, upstreamDir, downstreamDir)
+
+	out, code := runner.Run(t, integrateArgs(upstreamDir, downstreamDir), downstreamDir)
+	require.Equal(t, 0, code, "integrate exited non-zero:\n%s", out)
+	assert.Contains(t, out, "skipping migration .gitspork/migrations/0001/migration.yml:pre_integrate, which doesn't apply: it only applies to upgrades")
+	assert.NoFileExists(t, filepath.Join(downstreamDir, ".gitignore"))
+	assert.Contains(t, ReadFile(t, downstreamDir, ".gitspork/downstream-state.json"), "0001/migration.yml:pre_integrate", "a skipped migration is recorded as complete")
+}
+
+// TestMigrations_id_and_commands covers giving an existing migration an id
+// without re-running it, and the migrations list, status, rerun and skip
+// commands.
+func TestMigrations_id_and_commands(t *testing.T) {
+	if isDockerBuild {
+		t.Skip("migrations status re-clones the upstream URL recorded in state, a host path DockerRunner does not rewrite")
+	}
+	upstreamDir := NewUpstreamRepo(t, map[string]string{
+		"upstream-owned/file.txt": "upstream content\n",
+		".gitspork/migrations/0001/migration.yml": `pre_integrate:
+  steps:
+  - append: {file: migration-log.txt, content: "ran\n"}
+`,
+	}, migrationGitsporkYML)
+	downstreamDir := NewDownstreamRepo(t)
+	runner := resolveRunner(t, upstreamDir, downstreamDir)
+	args := integrateArgs(upstreamDir, downstreamDir)
+
+	out, code := runner.Run(t, args, downstreamDir)
+	require.Equal(t, 0, code, "integrate exited non-zero:\n%s", out)
+	require.Equal(t, "ran\n", ReadFile(t, downstreamDir, "migration-log.txt"))
+
+	// Giving the migration an id keeps its path as an alias, so it doesn't run
+	// again, and its record moves to the new ID.
+	WriteFiles(t, upstreamDir, map[string]string{
+		".gitspork/migrations/0001/migration.yml": `id: log-runs
+pre_integrate:
+  steps:
+  - append: {file: migration-log.txt, content: "ran\n"}
+`,
+	})
+	CommitAll(t, OpenRepo(t, upstreamDir), upstreamDir, "give the migration an id")
+	out, code = runner.Run(t, args, downstreamDir)
+	require.Equal(t, 0, code, "integrate exited non-zero:\n%s", out)
+	assert.Equal(t, "ran\n", ReadFile(t, downstreamDir, "migration-log.txt"))
+
+	out, code = runner.Run(t, []string{"migrations", "list", "-d", downstreamDir}, downstreamDir)
+	require.Equal(t, 0, code, "migrations list exited non-zero:\n%s", out)
+	assert.Contains(t, out, "log-runs:pre_integrate  ran")
+	assert.Contains(t, out, "exit 0")
+	assert.NotContains(t, out, "0001/migration.yml:pre_integrate")
+
+	out, code = runner.Run(t, []string{"migrations", "status", "-d", downstreamDir}, downstreamDir)
+	require.Equal(t, 0, code, "migrations status exited non-zero:\n%s", out)
+	assert.Regexp(t, `ran +log-runs:pre_integrate \(\.gitspork/migrations/0001/migration\.yml`, out)
+
+	out, code = runner.Run(t, []string{"migrations", "rerun", "log-runs", "-d", downstreamDir}, downstreamDir)
+	require.Equal(t, 0, code, "migrations rerun exited non-zero:\n%s", out)
+	out, code = runner.Run(t, []string{"migrations", "status", "-d", downstreamDir}, downstreamDir)
+	require.Equal(t, 0, code, "migrations status exited non-zero:\n%s", out)
+	assert.Regexp(t, `pending +log-runs:pre_integrate`, out)
+	out, code = runner.Run(t, args, downstreamDir)
+	require.Equal(t, 0, code, "integrate exited non-zero:\n%s", out)
+	assert.Equal(t, "ran\nran\n", ReadFile(t, downstreamDir, "migration-log.txt"))
+
+	// Skipping a pending migration keeps integrate from running it.
+	out, code = runner.Run(t, []string{"migrations", "rerun", "log-runs:pre_integrate", "-d", downstreamDir}, downstreamDir)
+	require.Equal(t, 0, code, "migrations rerun exited non-zero:\n%s", out)
+	out, code = runner.Run(t, []string{"migrations", "skip", "log-runs:pre_integrate", "-d", downstreamDir}, downstreamDir)
+	require.Equal(t, 0, code, "migrations skip exited non-zero:\n%s", out)
+	out, code = runner.Run(t, args, downstreamDir)
+	require.Equal(t, 0, code, "integrate exited non-zero:\n%s", out)
+	assert.Equal(t, "ran\nran\n", ReadFile(t, downstreamDir, "migration-log.txt"))
+	assert.Contains(t, ReadFile(t, downstreamDir, ".gitspork/downstream-state.json"), `"outcome":"skipped"`)
+}
+
+// TestIntegrate_migration_trust verifies that an upstream's exec migration
+// only runs once the downstream trusts the upstream: a non-interactive
+// integrate fails, --no-migrations integrates leaving it pending, and
+// --allow-migrations runs it without recording a decision for later runs.
+func TestIntegrate_migration_trust(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the migration command is POSIX shell in this test")
+	}
+	upstreamDir := NewUpstreamRepo(t, map[string]string{
+		"upstream-owned/file.txt": "upstream content\n",
+		".gitspork/migrations/0001/migration.yml": `pre_integrate:
+  steps:
+  - shell: echo ran >> migration-log.txt
+`,
+	}, migrationGitsporkYML)
+	downstreamDir := NewDownstreamRepo(t)
+	runner := resolveRunner(t, upstreamDir, downstreamDir)
+	args := integrateArgs(upstreamDir, downstreamDir)
+
+	out, code := runner.Run(t, append(args, "--non-interactive"), downstreamDir)
+	require.NotEqual(t, 0, code, "integrate of an untrusted upstream should fail:\n%s", out)
+	assert.Contains(t, out, "runs commands")
+	assert.Contains(t, out, "--allow-migrations")
+	assert.NoFileExists(t, filepath.Join(downstreamDir, "migration-log.txt"))
+
+	out, code = runner.Run(t, append(args, "--no-migrations"), downstreamDir)
+	require.Equal(t, 0, code, "integrate --no-migrations exited non-zero:\n%s", out)
+	assert.Contains(t, out, "not running 1 pending migration(s)")
+	assert.FileExists(t, filepath.Join(downstreamDir, "upstream-owned/file.txt"))
+	assert.NoFileExists(t, filepath.Join(downstreamDir, "migration-log.txt"))
+	CommitAll(t, OpenRepo(t, downstreamDir), downstreamDir, "integrate without migrations")
+
+	out, code = runner.Run(t, append(args, "--allow-migrations", "--no-migrations"), downstreamDir)
+	require.NotEqual(t, 0, code, "--allow-migrations and --no-migrations together should fail:\n%s", out)
+
+	out, code = runner.Run(t, append(args, "--allow-migrations", "--non-interactive"), downstreamDir)
+	require.Equal(t, 0, code, "integrate --allow-migrations exited non-zero:\n%s", out)
+	assert.Equal(t, "ran\n", ReadFile(t, downstreamDir, "migration-log.txt"))
+	assert.NotContains(t, ReadFile(t, downstreamDir, ".gitspork/downstream-state.json"), `"decision":"allow"`)
+}
+
+func TestCheckDrift_pending_migrations(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the migration command is POSIX shell in this test")
+	}
+	upstreamDir := NewUpstreamRepo(t, map[string]string{
+		"upstream-owned/file.txt": "upstream content\n",
+		".gitspork/migrations/0001/migration.yml": `post_integrate:
+  steps:
+  - append:
+      file: CHANGELOG.md
+      content: "- migrated\n"
+  - shell: printf '%s' "$GITSPORK_SANDBOX" > sandbox.txt
+`,
+	}, migrationGitsporkYML)
+	downstreamDir := NewDownstreamRepo(t)
+	runner := resolveRunner(t, upstreamDir, downstreamDir)
+
+	out, code := runner.Run(t, append(integrateArgs(upstreamDir, downstreamDir), "--no-migrations"), downstreamDir)
+	require.Equal(t, 0, code, "integrate --no-migrations exited non-zero:\n%s", out)
+	WriteFiles(t, downstreamDir, map[string]string{".gitspork/trust.yml": "migrations:\n  allow: ['**']\n"})
+	CommitAll(t, OpenRepo(t, downstreamDir), downstreamDir, "integrate without migrations")
+	checkDrift := func(mode string) (string, int) {
+		return runner.Run(t, []string{"check-drift", "--downstream-repo-path", downstreamDir, "--migrations", mode}, downstreamDir)
+	}
+
+	out, code = checkDrift("skip")
+	require.Equal(t, 0, code, "pending migrations should be skipped by default:\n%s", out)
+
+	out, code = checkDrift("simulate")
+	require.Equal(t, 2, code, "simulated migration steps should show as drift:\n%s", out)
+	assert.Contains(t, out, "CHANGELOG.md (upstream: ")
+	assert.Contains(t, out, "produced by migration .gitspork/migrations/0001/migration.yml:post_integrate")
+	assert.Contains(t, out, "(simulated) not running")
+	assert.NotContains(t, out, "sandbox.txt (upstream: ")
+
+	out, code = checkDrift("sandbox")
+	require.Equal(t, 2, code, "sandboxed migration should show as drift:\n%s", out)
+	assert.Contains(t, out, "sandbox.txt (upstream: ")
+	assert.NoFileExists(t, filepath.Join(downstreamDir, "sandbox.txt"), "the migration must only run in the scratch clone")
+
+	out, code = checkDrift("everything")
+	require.Equal(t, 1, code, "an unknown mode should fail:\n%s", out)
+	assert.Contains(t, out, "invalid migrations mode")
+}
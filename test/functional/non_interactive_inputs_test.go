@@ -0,0 +1,54 @@
+//go:build functional || functional_docker
+
+package functional
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const promptedGitsporkYML = `templated:
+- template: svc.txt.go.tmpl
+  destination: svc.txt
+  inputs:
+  - name: service_name
+    prompt: What is the service name?
+  - name: environment
+    prompt: Which environment?
+    type: select
+    options: [dev, prod]
+`
+
+// TestIntegrateLocal_nonInteractive verifies --non-interactive fails fast
+// listing every missing templated input, and that --input / --inputs-file
+// supply them so the same run succeeds without a terminal.
+func TestIntegrateLocal_nonInteractive(t *testing.T) {
+	upstreamDir := NewUpstreamRepo(t, map[string]string{
+		"svc.txt.go.tmpl": `{{ .Inputs.service_name }} in {{ .Inputs.environment }}`,
+	}, promptedGitsporkYML)
+	downstreamDir := NewDownstreamRepo(t)
+	runner := resolveRunner(t, upstreamDir, downstreamDir)
+	baseArgs := []string{
+		"integrate-local",
+		"--upstream-path", upstreamDir,
+		"--downstream-path", downstreamDir,
+		"--non-interactive",
+	}
+
+	out, code := runner.Run(t, baseArgs, downstreamDir)
+	require.Equal(t, 1, code, "missing inputs must fail:\n%s", out)
+	assert.Contains(t, out, "input service_name (GITSPORK_INPUT_SERVICE_NAME)")
+	assert.Contains(t, out, `"Which environment?"`)
+	AssertFileAbsent(t, downstreamDir, "svc.txt")
+
+	WriteFiles(t, downstreamDir, map[string]string{"ci-inputs.yml": "service_name: from-file\nenvironment: dev\n"})
+	args := append(append([]string{}, baseArgs...),
+		"--inputs-file", "ci-inputs.yml",
+		"--input", "environment=prod",
+	)
+	out, code = runner.Run(t, args, downstreamDir)
+	require.Equal(t, 0, code, "integrate-local with supplied inputs failed:\n%s", out)
+	AssertFileContains(t, downstreamDir, "svc.txt", "from-file in prod")
+}
@@ -0,0 +1,36 @@
+//go:build functional || functional_docker
+
+package functional
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutdated(t *testing.T) {
+	upstreamDir := buildSimpleUpstream(t)
+	downstreamDir := NewDownstreamRepo(t)
+	prepDownstreamWithInputData(t, downstreamDir)
+	runner := resolveRunner(t, upstreamDir, downstreamDir)
+
+	integrateForDrift(t, runner, upstreamDir, downstreamDir)
+	prepDownstreamWithInputData(t, downstreamDir)
+
+	args := []string{"outdated", "--downstream-repo-path", downstreamDir, "--no-cache"}
+	out, code := runner.Run(t, args, downstreamDir)
+	require.Equal(t, 0, code, "expected an up to date upstream (exit 0):\n%s", out)
+	assert.Contains(t, out, "current   file://"+upstreamDir+" at main")
+
+	WriteFiles(t, upstreamDir, map[string]string{"upstream-owned/file.txt": "updated upstream content\n"})
+	CommitAll(t, OpenRepo(t, upstreamDir), upstreamDir, "update upstream")
+
+	out, code = runner.Run(t, append(args, "--files"), downstreamDir)
+	require.Equal(t, 2, code, "expected an outdated upstream (exit 2):\n%s", out)
+	assert.Contains(t, out, "outdated  file://"+upstreamDir+" at ")
+	assert.Contains(t, out, "latest main ")
+	assert.Contains(t, out, "(1 commit(s) behind)")
+	assert.Contains(t, out, "  would change upstream-owned/file.txt\n")
+	assert.NotContains(t, out, "would change meta.txt")
+}
@@ -0,0 +1,66 @@
+//go:build functional || functional_docker
+
+package functional
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScan(t *testing.T) {
+	if isDockerBuild {
+		t.Skip("scanning several downstreams not supported in DockerRunner")
+	}
+	upstreamDir := buildSimpleUpstream(t)
+	clean := NewDownstreamRepo(t)
+	drifted := NewDownstreamRepo(t)
+	runner := resolveRunner(t, upstreamDir, clean)
+	for _, downstreamDir := range []string{clean, drifted} {
+		prepDownstreamWithInputData(t, downstreamDir)
+		integrateForDrift(t, runner, upstreamDir, downstreamDir)
+		prepDownstreamWithInputData(t, downstreamDir)
+	}
+
+	out, code := runner.Run(t, []string{"scan", clean, drifted}, clean)
+	require.Equal(t, 0, code, "expected no drift (exit 0):\n%s", out)
+	assert.Contains(t, out, "2 repo(s): 2 clean, 0 drifted, 0 failed")
+
+	WriteFiles(t, drifted, map[string]string{"upstream-owned/file.txt": "drifted content\n"})
+	CommitAll(t, OpenRepo(t, drifted), drifted, "drift")
+	prepDownstreamWithInputData(t, drifted)
+
+	out, code = runner.Run(t, []string{"scan", clean, drifted, "--concurrency", "2", "--format", "json"}, clean)
+	require.Equal(t, 2, code, "expected drift (exit 2):\n%s", out)
+	var report struct {
+		HasDrift bool `json:"has_drift"`
+		Repos    []struct {
+			Path         string   `json:"path"`
+			Status       string   `json:"status"`
+			DriftedFiles int      `json:"drifted_files"`
+			Files        []string `json:"files"`
+			Upstreams    []struct {
+				URL string `json:"url"`
+			} `json:"upstreams"`
+		} `json:"repos"`
+	}
+	// Progress on stderr precedes the report in the combined output.
+	start := strings.Index(out, "{\n")
+	require.GreaterOrEqual(t, start, 0, out)
+	require.NoError(t, json.Unmarshal([]byte(out[start:]), &report), out)
+	assert.True(t, report.HasDrift)
+	require.Len(t, report.Repos, 2)
+	assert.Equal(t, "clean", report.Repos[0].Status)
+	assert.Equal(t, "drift", report.Repos[1].Status)
+	assert.Equal(t, 1, report.Repos[1].DriftedFiles)
+	assert.Equal(t, []string{"upstream-owned/file.txt"}, report.Repos[1].Files)
+	require.Len(t, report.Repos[1].Upstreams, 1)
+	assert.Equal(t, "file://"+upstreamDir, report.Repos[1].Upstreams[0].URL)
+
+	out, code = runner.Run(t, []string{"scan", clean, t.TempDir()}, clean)
+	require.Equal(t, 1, code, "expected a failed check (exit 1):\n%s", out)
+	assert.Contains(t, out, "errors:")
+}
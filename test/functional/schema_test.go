@@ -78,6 +78,14 @@ func TestSchema_printsAnnotatedScaffolds(t *testing.T) {
 		}
 	})
 
+	t.Run("template function library is documented", func(t *testing.T) {
+		assert.Contains(t, out, "Template functions:",
+			"schema should introduce the template function library section")
+		for _, fn := range []string{"lower STRING", "default DEFAULT VALUE", "toYaml VALUE", "nindent SPACES STRING"} {
+			assert.Contains(t, out, fn, "template function %q missing from schema output", fn)
+		}
+	})
+
 	t.Run("migration schema exposes both hook slots", func(t *testing.T) {
 		assert.Contains(t, out, "pre_integrate:",
 			"migration schema should show the pre_integrate hook")
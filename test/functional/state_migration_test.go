@@ -31,8 +31,11 @@ type oldFormatState struct {
 // public gitspork.DownstreamState) so a genuine schema regression that
 // dropped omitempty would be visible as a non-empty value here.
 type stateAfterIntegrate struct {
-	MigrationsComplete []string `json:"migrations_complete"`
-	Upstreams          []struct {
+	MigrationsComplete []struct {
+		ID      string `json:"id"`
+		Outcome string `json:"outcome"`
+	} `json:"migrations_complete"`
+	Upstreams []struct {
 		URL        string `json:"url"`
 		Subpath    string `json:"subpath,omitempty"`
 		CommitHash string `json:"commit_hash"`
@@ -100,8 +103,12 @@ func TestStateMigration_seedMatchesFreshIntegrate(t *testing.T) {
 	assert.NotEmpty(t, state.Upstreams[0].CommitHash)
 
 	// Legacy migrations_complete must be preserved so downstream hooks don't
-	// re-run after the upgrade.
-	assert.Equal(t, []string{"legacy/0001:pre_integrate", "legacy/0002:post_integrate"}, state.MigrationsComplete)
+	// re-run after the upgrade, now as records of migrations that ran.
+	require.Len(t, state.MigrationsComplete, 2)
+	for i, id := range []string{"legacy/0001:pre_integrate", "legacy/0002:post_integrate"} {
+		assert.Equal(t, id, state.MigrationsComplete[i].ID)
+		assert.Equal(t, "ran", state.MigrationsComplete[i].Outcome)
+	}
 
 	// Deprecated fields cleared. With omitempty they should not appear in the
 	// serialised JSON at all — assert on the decoded struct for defence in
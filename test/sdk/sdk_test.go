@@ -753,3 +753,182 @@ func hasLog(c *captureLogger, substr string) bool {
 	}
 	return false
 }
+
+// TestIntegrateLocal_nonInteractive_missingInputs pins the headless contract:
+// NonInteractive never prompts, the error matches ErrMissingInputs and lists
+// every missing input, and supplying them via Inputs lets the run succeed.
+func TestIntegrateLocal_nonInteractive_missingInputs(t *testing.T) {
+	upstreamDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(upstreamDir, ".gitspork.yml"), []byte(`templated:
+- template: svc.tmpl
+  destination: svc.txt
+  inputs:
+  - name: service_name
+    prompt: What is the service name?
+  - name: replicas
+    prompt: How many replicas?
+    type: int
+`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(upstreamDir, "svc.tmpl"),
+		[]byte(`{{ .Inputs.service_name }}={{ .Inputs.replicas }}`), 0644))
+	downstreamDir := emptyDownstream(t)
+
+	_, err := gitspork.IntegrateLocal(&gitspork.IntegrateLocalOptions{
+		UpstreamPaths:  []string{upstreamDir},
+		DownstreamPath: downstreamDir,
+		NonInteractive: true,
+	})
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, gitspork.ErrMissingInputs))
+	var missingErr *gitspork.MissingInputsError
+	require.True(t, errors.As(err, &missingErr))
+	require.Len(t, missingErr.Inputs, 2)
+	assert.Equal(t, "service_name", missingErr.Inputs[0].Name)
+	assert.Equal(t, "What is the service name?", missingErr.Inputs[0].Prompt)
+	assert.Equal(t, "GITSPORK_INPUT_REPLICAS", missingErr.Inputs[1].EnvVar)
+
+	_, err = gitspork.IntegrateLocal(&gitspork.IntegrateLocalOptions{
+		UpstreamPaths:  []string{upstreamDir},
+		DownstreamPath: downstreamDir,
+		NonInteractive: true,
+		Inputs:         map[string]any{"service_name": "billing", "replicas": 3},
+	})
+	require.NoError(t, err)
+	got, err := os.ReadFile(filepath.Join(downstreamDir, "svc.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "billing=3", string(got))
+}
+
+// TestIntegrateLocal_customPrompter verifies a caller-supplied Prompter
+// receives the full prompt request and its answers are rendered, without
+// touching stdin.
+func TestIntegrateLocal_customPrompter(t *testing.T) {
+	upstreamDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(upstreamDir, ".gitspork.yml"), []byte(`templated:
+- template: env.tmpl
+  destination: env.txt
+  inputs:
+  - name: environment
+    prompt: Which environment?
+    type: select
+    options: [dev, prod]
+    default: dev
+`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(upstreamDir, "env.tmpl"), []byte(`env={{ .Inputs.environment }}`), 0644))
+	downstreamDir := emptyDownstream(t)
+
+	var requests []gitspork.PromptRequest
+	_, err := gitspork.IntegrateLocal(&gitspork.IntegrateLocalOptions{
+		UpstreamPaths:  []string{upstreamDir},
+		DownstreamPath: downstreamDir,
+		Prompter: gitspork.PromptFunc(func(req gitspork.PromptRequest) (any, error) {
+			requests = append(requests, req)
+			return "prod", nil
+		}),
+	})
+	require.NoError(t, err)
+	require.Len(t, requests, 1)
+	assert.Equal(t, gitspork.PromptRequest{
+		Template:    "env.tmpl",
+		Destination: "env.txt",
+		Name:        "environment",
+		Prompt:      "Which environment?",
+		Type:        "select",
+		Options:     []string{"dev", "prod"},
+		Default:     "dev",
+		Attempt:     1,
+	}, requests[0])
+	got, err := os.ReadFile(filepath.Join(downstreamDir, "env.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "env=prod", string(got))
+}
+
+// migrations: MigrationStatus reports a pending migration, then the record
+// integrate leaves of it
+func TestMigrationStatus_pendingThenRan(t *testing.T) {
+	upstreamDir, _ := minimalUpstream(t)
+	writeAndCommit(t, upstreamDir, "m.yml", "id: add-marker\npost_integrate:\n  steps:\n  - append: {file: marker.txt, content: x}\n")
+	writeAndCommit(t, upstreamDir, ".gitspork.yml", "migrations:\n- m.yml\n")
+	downstreamDir := emptyDownstream(t)
+	upstreams := []gitspork.UpstreamSpec{{URL: "file://" + upstreamDir, Version: "main"}}
+
+	report, err := gitspork.MigrationStatus(&gitspork.MigrationStatusOptions{Upstreams: upstreams, DownstreamRepoPath: downstreamDir})
+	require.NoError(t, err)
+	require.Len(t, report.Migrations, 1)
+	assert.Equal(t, "add-marker:post_integrate", report.Migrations[0].ID)
+	assert.Equal(t, gitspork.MigrationStatusPending, report.Migrations[0].Status)
+	assert.Nil(t, report.Migrations[0].Record)
+
+	result, err := gitspork.Integrate(&gitspork.IntegrateOptions{Upstreams: upstreams, DownstreamRepoPath: downstreamDir})
+	require.NoError(t, err)
+
+	// Without Upstreams, the upstreams recorded in state are used.
+	report, err = gitspork.MigrationStatus(&gitspork.MigrationStatusOptions{DownstreamRepoPath: downstreamDir})
+	require.NoError(t, err)
+	require.Len(t, report.Migrations, 1)
+	assert.Equal(t, gitspork.MigrationOutcomeRan, report.Migrations[0].Status)
+	require.NotNil(t, report.Migrations[0].Record)
+	assert.Equal(t, result.Upstreams[0].CommitHash, report.Migrations[0].Record.UpstreamCommit)
+}
+
+// outdated: an upstream with a newer commit on its branch is reported behind
+func TestOutdated_behindBranch(t *testing.T) {
+	upstreamDir, _ := minimalUpstream(t)
+	downstreamDir := emptyDownstream(t)
+	result, err := gitspork.Integrate(&gitspork.IntegrateOptions{
+		Upstreams:          []gitspork.UpstreamSpec{{URL: "file://" + upstreamDir, Version: "main"}},
+		DownstreamRepoPath: downstreamDir,
+	})
+	require.NoError(t, err)
+	writeAndCommit(t, downstreamDir, ".gitspork/marker", "baseline")
+	writeAndCommit(t, upstreamDir, "upstream-owned/file.txt", "updated\n")
+
+	report, err := gitspork.Outdated(&gitspork.OutdatedOptions{
+		DownstreamRepoPath: downstreamDir,
+		Files:              true,
+		NoCache:            true,
+	})
+	require.NoError(t, err)
+	assert.True(t, report.HasOutdated)
+	require.Len(t, report.Upstreams, 1)
+	upstream := report.Upstreams[0]
+	assert.Equal(t, gitspork.OutdatedTrackBranch, upstream.Track)
+	assert.Equal(t, result.Upstreams[0].CommitHash, upstream.CommitHash)
+	assert.Equal(t, 1, upstream.CommitsBehind)
+	assert.Equal(t, []string{"upstream-owned/file.txt"}, upstream.ChangedFiles)
+}
+
+func TestScan_fleet(t *testing.T) {
+	upstreamDir, _ := minimalUpstream(t)
+	var downstreams []string
+	for range 3 {
+		downstreamDir := emptyDownstream(t)
+		_, err := gitspork.Integrate(&gitspork.IntegrateOptions{
+			Upstreams:          []gitspork.UpstreamSpec{{URL: "file://" + upstreamDir, Version: "main"}},
+			DownstreamRepoPath: downstreamDir,
+		})
+		require.NoError(t, err)
+		writeAndCommit(t, downstreamDir, ".gitspork/marker", "baseline")
+		downstreams = append(downstreams, downstreamDir)
+	}
+	writeAndCommit(t, downstreams[1], "upstream-owned/file.txt", "drifted\n")
+
+	report, err := gitspork.Scan(&gitspork.ScanOptions{DownstreamRepoPaths: downstreams, Concurrency: 2})
+	require.NoError(t, err)
+	assert.True(t, report.HasDrift)
+	assert.False(t, report.HasErrors)
+	require.Len(t, report.Repos, 3)
+	for i, repo := range report.Repos {
+		assert.Equal(t, downstreams[i], repo.Path)
+		require.Len(t, repo.Upstreams, 1)
+		assert.Equal(t, "main", repo.Upstreams[0].Version)
+	}
+	assert.Equal(t, gitspork.ScanStatusClean, report.Repos[0].Status)
+	assert.Equal(t, gitspork.ScanStatusDrift, report.Repos[1].Status)
+	assert.Equal(t, 1, report.Repos[1].DriftedFiles)
+	assert.Equal(t, gitspork.ScanStatusClean, report.Repos[2].Status)
+
+	var table strings.Builder
+	require.NoError(t, gitspork.WriteScanReport(&table, report, gitspork.ScanReportFormatTable))
+	assert.Contains(t, table.String(), "3 repo(s): 2 clean, 1 drifted, 0 failed")
+}